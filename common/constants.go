@@ -100,6 +100,9 @@ var TurnstileSecretKey = ""
 var TelegramBotToken = ""
 var TelegramBotName = ""
 
+// MetricsToken 访问 /metrics 所需的 Bearer Token，为空时不开放该端点
+var MetricsToken = ""
+
 var QuotaForNewUser = 0
 var QuotaForInviter = 0
 var QuotaForInvitee = 0
@@ -147,6 +147,10 @@ func initConstantEnv() {
 	constant.TaskQueryLimit = GetEnvOrDefault("TASK_QUERY_LIMIT", 1000)
 	// 异步任务超时时间（分钟），超过此时间未完成的任务将被标记为失败并退款。0 表示禁用。
 	constant.TaskTimeoutMinutes = GetEnvOrDefault("TASK_TIMEOUT_MINUTES", 1440)
+	// 批处理输入文件大小上限（MB）
+	constant.BatchMaxFileMB = GetEnvOrDefault("BATCH_MAX_FILE_MB", 100)
+	// 单个批处理任务并发执行的请求行数
+	constant.BatchConcurrency = GetEnvOrDefault("BATCH_CONCURRENCY", 4)
 
 	soraPatchStr := GetEnvOrDefaultString("TASK_PRICE_PATCH", "")
 	if soraPatchStr != "" {
@@ -0,0 +1,147 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed lua/concurrency.lua
+var concurrencyScriptSource string
+
+var concurrencyScript = redis.NewScript(concurrencyScriptSource)
+
+// concurrencyKeyTTLSeconds 计数器过期时间，每次获取都会续期，避免进程崩溃后计数永久泄漏
+const concurrencyKeyTTLSeconds = 15 * 60
+
+// AcquireConcurrency 尝试占用一个并发名额，返回是否成功以及当前并发数。
+// 成功时调用方必须在请求结束后调用 ReleaseConcurrency。
+func AcquireConcurrency(ctx context.Context, key string, limit int64) (bool, int64, error) {
+	if common.RedisEnabled && common.RDB != nil {
+		values, err := concurrencyScript.Run(ctx, common.RDB, []string{key}, limit, concurrencyKeyTTLSeconds).Int64Slice()
+		if err != nil {
+			return false, 0, fmt.Errorf("concurrency limit failed: %w", err)
+		}
+		if len(values) != 2 {
+			return false, 0, fmt.Errorf("concurrency limit returned %d values", len(values))
+		}
+		return values[0] == 1, values[1], nil
+	}
+	allowed, current := memoryConcurrency.acquire(key, limit, time.Now())
+	return allowed, current, nil
+}
+
+func ReleaseConcurrency(ctx context.Context, key string) {
+	if common.RedisEnabled && common.RDB != nil {
+		current, err := common.RDB.Decr(ctx, key).Result()
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to release concurrency slot %s: %v", key, err))
+			return
+		}
+		if current <= 0 {
+			common.RDB.Del(ctx, key)
+		}
+		return
+	}
+	memoryConcurrency.release(key, time.Now())
+}
+
+type memoryConcurrencyCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+type memoryConcurrencyStore struct {
+	mu        sync.Mutex
+	counters  map[string]*memoryConcurrencyCounter
+	lastSweep time.Time
+}
+
+var memoryConcurrency = &memoryConcurrencyStore{counters: make(map[string]*memoryConcurrencyCounter)}
+
+// get 返回未过期的计数器，过期的计数器与 Redis 的 key 过期一致视为 0，调用方需持有锁
+func (s *memoryConcurrencyStore) get(key string, now time.Time) *memoryConcurrencyCounter {
+	s.sweep(now)
+	counter, ok := s.counters[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(counter.expiresAt) {
+		delete(s.counters, key)
+		return nil
+	}
+	return counter
+}
+
+// sweep 定期清理过期的计数器，避免未释放（如进程内 panic）的 key 常驻内存，调用方需持有锁
+func (s *memoryConcurrencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < concurrencyKeyTTLSeconds*time.Second {
+		return
+	}
+	s.lastSweep = now
+	for key, counter := range s.counters {
+		if !now.Before(counter.expiresAt) {
+			delete(s.counters, key)
+		}
+	}
+}
+
+func (s *memoryConcurrencyStore) acquire(key string, limit int64, now time.Time) (bool, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counter := s.get(key, now)
+	if counter == nil {
+		counter = &memoryConcurrencyCounter{}
+	}
+	if counter.count >= limit {
+		return false, counter.count
+	}
+	counter.count++
+	counter.expiresAt = now.Add(concurrencyKeyTTLSeconds * time.Second)
+	s.counters[key] = counter
+	return true, counter.count
+}
+
+func (s *memoryConcurrencyStore) release(key string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counter := s.get(key, now)
+	if counter == nil {
+		return
+	}
+	if counter.count <= 1 {
+		delete(s.counters, key)
+		return
+	}
+	counter.count--
+}
+
+func (s *memoryConcurrencyStore) current(key string, now time.Time) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if counter := s.get(key, now); counter != nil {
+		return counter.count
+	}
+	return 0
+}
+
+// CurrentConcurrency 返回当前并发数，不占用名额
+func CurrentConcurrency(ctx context.Context, key string) (int64, error) {
+	if common.RedisEnabled && common.RDB != nil {
+		current, err := common.RDB.Get(ctx, key).Int64()
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("concurrency query failed: %w", err)
+		}
+		return current, nil
+	}
+	return memoryConcurrency.current(key, time.Now()), nil
+}
@@ -0,0 +1,17 @@
+-- 并发计数器
+-- KEYS[1]: 计数器唯一标识
+-- ARGV[1]: 最大并发数
+-- ARGV[2]: 计数器过期时间（秒），防止节点异常退出后计数泄漏
+-- 返回: {是否允许, 当前并发数}
+
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[2])
+
+local current = redis.call('INCR', key)
+redis.call('EXPIRE', key, ttl)
+if current > limit then
+    current = redis.call('DECR', key)
+    return {0, current}
+end
+return {1, current}
@@ -0,0 +1,64 @@
+-- 分桶滑动窗口计数器（用于 TPM 等按窗口累计的限制）
+-- KEYS[1]: 计数器唯一标识（hash，field 为桶序号）
+-- ARGV[1]: 本次累加的数量（负数表示结算修正）
+-- ARGV[2]: 窗口内上限（<=0 表示只记录不校验）
+-- ARGV[3]: 窗口长度（秒）
+-- ARGV[4]: 桶长度（秒）
+-- ARGV[5]: 修正的目标桶序号（<=0 表示当前桶）；目标桶已滑出窗口时，负数修正直接丢弃，正数计入当前桶
+-- 返回: {是否允许, 窗口内已用量, 距离最早的桶滑出窗口的秒数, 本次计入的桶序号}
+
+local key = KEYS[1]
+local amount = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local bucketSize = tonumber(ARGV[4])
+local target = tonumber(ARGV[5] or '0')
+
+local now = tonumber(redis.call('TIME')[1])
+local bucketCount = math.floor(window / bucketSize)
+local current = math.floor(now / bucketSize)
+local first = current - bucketCount + 1
+
+local used = 0
+local oldest = -1
+local data = redis.call('HGETALL', key)
+for i = 1, #data, 2 do
+    local bucket = tonumber(data[i])
+    if bucket < first then
+        redis.call('HDEL', key, data[i])
+    else
+        local value = tonumber(data[i + 1])
+        used = used + value
+        if value > 0 and (oldest == -1 or bucket < oldest) then
+            oldest = bucket
+        end
+    end
+end
+
+local reset = window
+if oldest ~= -1 then
+    reset = (oldest + bucketCount) * bucketSize - now
+end
+
+if target <= 0 or target > current then
+    target = current
+elseif target < first then
+    if amount < 0 then
+        return {1, used, reset, target}
+    end
+    target = current
+end
+
+if limit > 0 and amount > 0 and used + amount > limit then
+    return {0, used, reset, target}
+end
+
+if amount ~= 0 then
+    redis.call('HINCRBY', key, tostring(target), amount)
+    redis.call('EXPIRE', key, window + bucketSize)
+    if oldest == -1 and amount > 0 then
+        reset = (target + bucketCount) * bucketSize - now
+    end
+end
+
+return {1, used + amount, reset, target}
@@ -0,0 +1,192 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed lua/sliding_window.lua
+var slidingWindowScriptSource string
+
+var slidingWindowScript = redis.NewScript(slidingWindowScriptSource)
+
+const (
+	slidingWindowSeconds       = 60
+	slidingWindowBucketSeconds = 10
+
+	// memoryWindowSweepInterval 内存计数器清理空闲 key 的间隔
+	memoryWindowSweepInterval = slidingWindowSeconds * time.Second
+)
+
+// WindowResult 滑动窗口计数结果
+type WindowResult struct {
+	Allowed bool
+	Limit   int64
+	// Used 窗口内累计用量（已包含本次放行的数量）
+	Used int64
+	// ResetAfter 最早的一个桶滑出窗口所需的时间，可用作 Retry-After
+	ResetAfter time.Duration
+	// Bucket 本次计入的桶序号，结算时传给 AdjustWindow 以修正同一个桶
+	Bucket int64
+}
+
+func (r WindowResult) Remaining() int64 {
+	return max(r.Limit-r.Used, 0)
+}
+
+// ReserveWindow 在一分钟滑动窗口内申请 amount 的额度，超过 limit 时拒绝且不记录。
+// Redis 可用时在 Redis 中计数（多节点共享），否则在内存中计数。
+func ReserveWindow(ctx context.Context, key string, limit int64, amount int64) (WindowResult, error) {
+	if common.RedisEnabled && common.RDB != nil {
+		return redisReserveWindow(ctx, key, limit, amount)
+	}
+	return memoryWindows.reserve(key, limit, amount, 0, time.Now()), nil
+}
+
+// AdjustWindow 对 bucket（ReserveWindow 返回的 Bucket）中的计数做修正（例如按实际用量结算），不做上限校验。
+// 该桶已滑出窗口时，退还的额度直接丢弃（预占已随桶过期），补扣的额度计入当前桶。
+func AdjustWindow(ctx context.Context, key string, bucket int64, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	if common.RedisEnabled && common.RDB != nil {
+		_, err := redisAdjustWindow(ctx, key, 0, delta, bucket)
+		return err
+	}
+	memoryWindows.reserve(key, 0, delta, bucket, time.Now())
+	return nil
+}
+
+func redisReserveWindow(ctx context.Context, key string, limit int64, amount int64) (WindowResult, error) {
+	return redisAdjustWindow(ctx, key, limit, amount, 0)
+}
+
+func redisAdjustWindow(ctx context.Context, key string, limit int64, amount int64, bucket int64) (WindowResult, error) {
+	values, err := slidingWindowScript.Run(ctx, common.RDB, []string{key},
+		amount, limit, slidingWindowSeconds, slidingWindowBucketSeconds, bucket).Int64Slice()
+	if err != nil {
+		return WindowResult{}, fmt.Errorf("sliding window failed: %w", err)
+	}
+	if len(values) != 4 {
+		return WindowResult{}, fmt.Errorf("sliding window returned %d values", len(values))
+	}
+	return WindowResult{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Used:       max(values[1], 0),
+		ResetAfter: time.Duration(max(values[2], 1)) * time.Second,
+		Bucket:     values[3],
+	}, nil
+}
+
+type memoryWindow struct {
+	buckets map[int64]int64
+}
+
+type memoryWindowStore struct {
+	mu        sync.Mutex
+	windows   map[string]*memoryWindow
+	lastSweep time.Time
+}
+
+var memoryWindows = &memoryWindowStore{windows: make(map[string]*memoryWindow)}
+
+// reserve 在内存中计数，target 为修正的目标桶（<=0 表示当前桶），语义与 lua/sliding_window.lua 一致
+func (s *memoryWindowStore) reserve(key string, limit int64, amount int64, target int64, now time.Time) WindowResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nowSec := now.Unix()
+	bucketCount := int64(slidingWindowSeconds / slidingWindowBucketSeconds)
+	current := nowSec / slidingWindowBucketSeconds
+	first := current - bucketCount + 1
+	s.sweep(now, first)
+
+	w, ok := s.windows[key]
+	if !ok {
+		w = &memoryWindow{buckets: make(map[int64]int64)}
+		s.windows[key] = w
+	}
+	var used int64
+	oldest := int64(-1)
+	for bucket, value := range w.buckets {
+		if bucket < first {
+			delete(w.buckets, bucket)
+			continue
+		}
+		used += value
+		if value > 0 && (oldest == -1 || bucket < oldest) {
+			oldest = bucket
+		}
+	}
+
+	resetAt := func(bucket int64) time.Duration {
+		return time.Duration(max((bucket+bucketCount)*slidingWindowBucketSeconds-nowSec, 1)) * time.Second
+	}
+	result := WindowResult{Limit: limit, ResetAfter: time.Duration(slidingWindowSeconds) * time.Second}
+	if oldest != -1 {
+		result.ResetAfter = resetAt(oldest)
+	}
+
+	if target <= 0 || target > current {
+		target = current
+	} else if target < first {
+		result.Bucket = target
+		if amount < 0 {
+			if len(w.buckets) == 0 {
+				delete(s.windows, key)
+			}
+			result.Allowed = true
+			result.Used = max(used, 0)
+			return result
+		}
+		target = current
+	}
+	result.Bucket = target
+
+	if limit > 0 && amount > 0 && used+amount > limit {
+		result.Used = max(used, 0)
+		return result
+	}
+	if amount != 0 {
+		w.buckets[target] += amount
+		if oldest == -1 && amount > 0 {
+			result.ResetAfter = resetAt(target)
+		}
+	}
+	if len(w.buckets) == 0 {
+		delete(s.windows, key)
+	}
+	result.Allowed = true
+	result.Used = max(used+amount, 0)
+	return result
+}
+
+// sweep 定期清理已滑出窗口的桶以及没有剩余桶的 key，避免不再访问的 key 常驻内存，调用方需持有锁
+func (s *memoryWindowStore) sweep(now time.Time, first int64) {
+	if now.Sub(s.lastSweep) < memoryWindowSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, w := range s.windows {
+		for bucket := range w.buckets {
+			if bucket < first {
+				delete(w.buckets, bucket)
+			}
+		}
+		if len(w.buckets) == 0 {
+			delete(s.windows, key)
+		}
+	}
+}
+
+// PeekWindow 查询窗口内已用量，不做累加
+func PeekWindow(ctx context.Context, key string, limit int64) (WindowResult, error) {
+	return ReserveWindow(ctx, key, limit, 0)
+}
@@ -0,0 +1,90 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryWindowReserveAndSettle(t *testing.T) {
+	store := &memoryWindowStore{windows: make(map[string]*memoryWindow)}
+	now := time.Unix(1_700_000_000, 0)
+
+	result := store.reserve("k", 100, 60, 0, now)
+	require.True(t, result.Allowed)
+	require.Equal(t, int64(40), result.Remaining())
+	bucket := result.Bucket
+
+	result = store.reserve("k", 100, 50, 0, now.Add(5*time.Second))
+	require.False(t, result.Allowed)
+	require.Equal(t, int64(60), result.Used)
+
+	// 结算时实际用量少于预估，归还差额后可以继续放行
+	store.reserve("k", 0, -30, bucket, now.Add(5*time.Second))
+	result = store.reserve("k", 100, 50, 0, now.Add(5*time.Second))
+	require.True(t, result.Allowed)
+	require.Equal(t, int64(80), result.Used)
+
+	// 窗口滑过后额度恢复
+	result = store.reserve("k", 100, 100, 0, now.Add(61*time.Second))
+	require.True(t, result.Allowed)
+}
+
+func TestMemoryWindowSettleInReservedBucket(t *testing.T) {
+	store := &memoryWindowStore{windows: make(map[string]*memoryWindow)}
+	now := time.Unix(1_700_000_000, 0)
+
+	reserved := store.reserve("k", 100, 80, 0, now)
+	require.True(t, reserved.Allowed)
+
+	// 跨桶结算：归还的额度记在预占所在的桶，随该桶一起滑出窗口
+	later := now.Add(30 * time.Second)
+	store.reserve("k", 0, -50, reserved.Bucket, later)
+	require.Equal(t, int64(30), store.windows["k"].buckets[reserved.Bucket])
+	require.Len(t, store.windows["k"].buckets, 1)
+
+	// 预占的桶滑出窗口后，退还直接丢弃，不会在当前桶留下负数
+	expired := now.Add(65 * time.Second)
+	result := store.reserve("k", 0, -30, reserved.Bucket, expired)
+	require.True(t, result.Allowed)
+	require.Equal(t, int64(0), result.Used)
+	result = store.reserve("k", 100, 100, 0, expired)
+	require.True(t, result.Allowed)
+
+	// 补扣的额度计入当前桶
+	result = store.reserve("k", 0, 20, reserved.Bucket, expired)
+	require.Equal(t, int64(120), result.Used)
+	require.Equal(t, expired.Unix()/slidingWindowBucketSeconds, result.Bucket)
+}
+
+func TestMemoryWindowEvictsIdleKeys(t *testing.T) {
+	store := &memoryWindowStore{windows: make(map[string]*memoryWindow)}
+	now := time.Unix(1_700_000_000, 0)
+
+	store.reserve("idle", 100, 10, 0, now)
+	store.reserve("active", 100, 10, 0, now)
+	store.reserve("active", 100, 10, 0, now.Add(2*memoryWindowSweepInterval))
+	require.NotContains(t, store.windows, "idle")
+	require.Contains(t, store.windows, "active")
+}
+
+func TestMemoryConcurrency(t *testing.T) {
+	store := &memoryConcurrencyStore{counters: make(map[string]*memoryConcurrencyCounter)}
+	now := time.Unix(1_700_000_000, 0)
+	ok, _ := store.acquire("k", 1, now)
+	require.True(t, ok)
+	ok, current := store.acquire("k", 1, now)
+	require.False(t, ok)
+	require.Equal(t, int64(1), current)
+	store.release("k", now)
+	ok, _ = store.acquire("k", 1, now)
+	require.True(t, ok)
+
+	// 未释放的名额与 Redis 一致在过期后自动回收
+	later := now.Add(2 * concurrencyKeyTTLSeconds * time.Second)
+	require.Equal(t, int64(0), store.current("k", later))
+	require.Empty(t, store.counters)
+	ok, _ = store.acquire("k", 1, later)
+	require.True(t, ok)
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+)
+
+// BuildClientTLSConfig 根据 PEM 格式的客户端证书、私钥与自定义 CA 构造 TLS 配置。
+// 证书与私钥需同时提供；未提供 CA 时使用系统根证书
+func BuildClientTLSConfig(certPEM, keyPEM, caPEM string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if TLSInsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	if certPEM != "" || keyPEM != "" {
+		if certPEM == "" || keyPEM == "" {
+			return nil, errors.New("tls client certificate and key must be provided together")
+		}
+		cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caPEM != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caPEM)) {
+			return nil, errors.New("no valid certificate found in tls ca bundle")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
@@ -19,6 +19,15 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenTpmLimit          ContextKey = "token_tpm_limit"
+	ContextKeyTokenConcurrencyLimit  ContextKey = "token_concurrency_limit"
+	ContextKeyTokenResponseCache     ContextKey = "token_response_cache"
+	ContextKeyTokenGuardProfile      ContextKey = "token_guard_profile"
+
+	/* client token (short-lived child token minted from a parent token) keys */
+	ContextKeyClientTokenId       ContextKey = "client_token_id"
+	ContextKeyClientTokenEndUser  ContextKey = "client_token_end_user"
+	ContextKeyClientTokenMaxQuota ContextKey = "client_token_max_quota"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -37,6 +46,9 @@ const (
 	ContextKeyChannelIsMultiKey        ContextKey = "channel_is_multi_key"
 	ContextKeyChannelMultiKeyIndex     ContextKey = "channel_multi_key_index"
 	ContextKeyChannelKey               ContextKey = "channel_key"
+	// ContextKeyChannelBreakerProbe / ContextKeyChannelKeyBreakerProbe 本次请求是否获得了渠道 / 密钥半开熔断器的探测名额
+	ContextKeyChannelBreakerProbe    ContextKey = "channel_breaker_probe"
+	ContextKeyChannelKeyBreakerProbe ContextKey = "channel_key_breaker_probe"
 
 	ContextKeyAutoGroup           ContextKey = "auto_group"
 	ContextKeyAutoGroupIndex      ContextKey = "auto_group_index"
@@ -53,6 +65,9 @@ const (
 	ContextKeyUserName      ContextKey = "username"
 	ContextKeyPersonalRatio ContextKey = "personal_ratio"
 
+	ContextKeyUserTpmLimit         ContextKey = "user_tpm_limit"
+	ContextKeyUserConcurrencyLimit ContextKey = "user_concurrency_limit"
+
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
@@ -69,4 +84,16 @@ const (
 
 	// ContextKeySessionId stores the real session_id extracted from client headers for sticky session binding
 	ContextKeySessionId ContextKey = "session_id"
+
+	// ContextKeyBatchId is stored on the http.Request context (not the gin context) of requests
+	// replayed by the batch worker, so that consume logs can be attributed to the batch.
+	ContextKeyBatchId ContextKey = "batch_id"
+
+	// ContextKeyResponsesPinnedChannelId / ContextKeyResponsesPinnedKeyIndex 记录 previous_response_id
+	// 或响应检索请求必须使用的渠道与 key，渠道选择时优先使用该 key 且不再重试其他渠道
+	ContextKeyResponsesPinnedChannelId ContextKey = "responses_pinned_channel_id"
+	ContextKeyResponsesPinnedKeyIndex  ContextKey = "responses_pinned_key_index"
+
+	// ContextKeyStreamFailoverAttempts 记录流式请求首字前失败的尝试，写入消费日志
+	ContextKeyStreamFailoverAttempts ContextKey = "stream_failover_attempts"
 )
@@ -17,6 +17,8 @@ var GenerateDefaultToken bool
 var ErrorLogEnabled bool
 var TaskQueryLimit int
 var TaskTimeoutMinutes int
+var BatchMaxFileMB int
+var BatchConcurrency int
 
 // temporary variable for sora patch, will be removed in future
 var TaskPricePatches []string
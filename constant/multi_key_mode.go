@@ -3,6 +3,23 @@ package constant
 type MultiKeyMode string
 
 const (
-	MultiKeyModeRandom  MultiKeyMode = "random"  // 随机
-	MultiKeyModePolling MultiKeyMode = "polling" // 轮询
+	MultiKeyModeRandom     MultiKeyMode = "random"      // 随机
+	MultiKeyModePolling    MultiKeyMode = "polling"     // 轮询
+	MultiKeyModeWeighted   MultiKeyMode = "weighted"    // 按每个key的权重随机
+	MultiKeyModeLeastUsed  MultiKeyMode = "least_used"  // 滚动窗口内请求数或token数最少的key优先
+	MultiKeyModeQuotaAware MultiKeyMode = "quota_aware" // 按周期额度余量选择，额度用尽的key临时禁用
+)
+
+// IsValidMultiKeyMode 是否为支持的多key选择模式
+func IsValidMultiKeyMode(mode MultiKeyMode) bool {
+	switch mode {
+	case MultiKeyModeRandom, MultiKeyModePolling, MultiKeyModeWeighted, MultiKeyModeLeastUsed, MultiKeyModeQuotaAware:
+		return true
+	}
+	return false
+}
+
+const (
+	MultiKeyUsageMetricRequests = "requests"
+	MultiKeyUsageMetricTokens   = "tokens"
 )
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const batchCompletionWindow = "24h"
+
+// CreateBatch POST /v1/batches
+func CreateBatch(c *gin.Context) {
+	var req dto.OpenAIBatchCreateRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if !service.IsSupportedBatchEndpoint(req.Endpoint) {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_endpoint", fmt.Sprintf("endpoint must be one of %v", service.SupportedBatchEndpoints))
+		return
+	}
+	if req.CompletionWindow == "" {
+		req.CompletionWindow = batchCompletionWindow
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_completion_window", "completion_window must be 24h")
+		return
+	}
+	if len(req.Metadata) > 16 {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_metadata", "metadata can have at most 16 keys")
+		return
+	}
+
+	userId := c.GetInt("id")
+	inputFile, err := model.GetUserFileById(userId, req.InputFileID, false)
+	if err != nil {
+		respondOpenAIDBError(c, err, fmt.Sprintf("No such File object: %s", req.InputFileID))
+		return
+	}
+	if inputFile.Purpose != dto.FilePurposeBatch {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_input_file", "input file must be uploaded with purpose batch")
+		return
+	}
+
+	now := common.GetTimestamp()
+	batch := &model.Batch{
+		BatchId:          "batch_" + common.GetRandomString(24),
+		UserId:           userId,
+		TokenId:          c.GetInt("token_id"),
+		Endpoint:         req.Endpoint,
+		InputFileId:      req.InputFileID,
+		CompletionWindow: req.CompletionWindow,
+		Status:           dto.BatchStatusValidating,
+		CreatedAt:        now,
+		ExpiresAt:        now + 24*60*60,
+		ClientIp:         c.ClientIP(),
+	}
+	if len(req.Metadata) > 0 {
+		batch.Metadata = common.GetJsonString(req.Metadata)
+	}
+	if err := batch.Insert(); err != nil {
+		respondOpenAIDBError(c, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+// RetrieveBatch GET /v1/batches/:id
+func RetrieveBatch(c *gin.Context) {
+	batchId := c.Param("id")
+	batch, err := model.GetUserBatchById(c.GetInt("id"), batchId)
+	if err != nil {
+		respondOpenAIDBError(c, err, fmt.Sprintf("No such Batch object: %s", batchId))
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+// CancelBatch POST /v1/batches/:id/cancel
+func CancelBatch(c *gin.Context) {
+	batchId := c.Param("id")
+	batch, err := model.CancelUserBatch(c.GetInt("id"), batchId)
+	if err != nil {
+		if errors.Is(err, model.ErrBatchNotCancellable) {
+			respondOpenAIError(c, http.StatusConflict, "batch_not_cancellable", err.Error())
+			return
+		}
+		respondOpenAIDBError(c, err, fmt.Sprintf("No such Batch object: %s", batchId))
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+// ListBatches GET /v1/batches
+func ListBatches(c *gin.Context) {
+	limit := getListLimit(c)
+	batches, err := model.GetUserBatches(c.GetInt("id"), service.SupportedBatchEndpoints, c.Query("after"), "", limit+1)
+	if err != nil {
+		respondOpenAIDBError(c, err, fmt.Sprintf("No such Batch object: %s", c.Query("after")))
+		return
+	}
+	resp := dto.OpenAIListResponse[*dto.OpenAIBatch]{
+		Object:  "list",
+		Data:    make([]*dto.OpenAIBatch, 0, len(batches)),
+		HasMore: len(batches) > limit,
+	}
+	for i, batch := range batches {
+		if i >= limit {
+			break
+		}
+		resp.Data = append(resp.Data, batch.ToOpenAIBatch())
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstID = resp.Data[0].ID
+		resp.LastID = resp.Data[len(resp.Data)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
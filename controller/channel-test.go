@@ -490,6 +490,7 @@ func testChannel(channel *model.Channel, testModel string, endpointType string,
 		ModelName:        info.OriginModelName,
 		TokenName:        "模型测试",
 		Quota:            quota,
+		UpstreamCost:     service.CalculateUpstreamCost(info, priceData, quota),
 		Content:          "模型测试",
 		UseTimeSeconds:   int(consumedTime),
 		IsStream:         info.IsStream,
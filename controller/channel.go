@@ -17,6 +17,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel/gemini"
 	"github.com/QuantumNous/new-api/relay/channel/ollama"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 )
@@ -62,6 +63,7 @@ func parseStatusFilter(statusParam string) int {
 }
 
 func clearChannelInfo(channel *model.Channel) {
+	channel.ClearSettingSecrets()
 	if channel.ChannelInfo.IsMultiKey {
 		channel.ChannelInfo.MultiKeyDisabledReason = nil
 		channel.ChannelInfo.MultiKeyDisabledTime = nil
@@ -165,14 +167,20 @@ func GetAllChannels(c *gin.Context) {
 	}
 	// Get active user binding counts for channels with max_users > 0
 	bindingCounts := model.CacheGetAllActiveBindingCounts()
+	// Live selection statistics (latency EWMA, outstanding requests, price ratio) on this node
+	selectionScores := make(map[int]model.ChannelSelectionScore, len(channelData))
+	for _, score := range model.GetChannelSelectionScores(channelData, "", "", "") {
+		selectionScores[score.ChannelId] = score
+	}
 
 	common.ApiSuccess(c, gin.H{
-		"items":          channelData,
-		"total":          total,
-		"page":           pageInfo.GetPage(),
-		"page_size":      pageInfo.GetPageSize(),
-		"type_counts":    typeCounts,
-		"binding_counts": bindingCounts,
+		"items":            channelData,
+		"total":            total,
+		"page":             pageInfo.GetPage(),
+		"page_size":        pageInfo.GetPageSize(),
+		"type_counts":      typeCounts,
+		"binding_counts":   bindingCounts,
+		"selection_scores": selectionScores,
 	})
 	return
 }
@@ -351,6 +359,11 @@ func SearchChannels(c *gin.Context) {
 	}
 
 	bindingCounts := model.CacheGetAllActiveBindingCounts()
+	// Live selection statistics (latency EWMA, outstanding requests, price ratio) on this node
+	selectionScores := make(map[int]model.ChannelSelectionScore, len(channelData))
+	for _, score := range model.GetChannelSelectionScores(channelData, "", "", "") {
+		selectionScores[score.ChannelId] = score
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -412,13 +425,21 @@ func GetChannelKey(c *gin.Context) {
 	// 记录操作日志
 	model.RecordLog(userId, model.LogTypeSystem, fmt.Sprintf("查看渠道密钥信息 (渠道ID: %d)", channelId))
 
-	// 返回渠道密钥
+	// 返回渠道密钥，以及渠道设置中的 mTLS 私钥与请求签名密钥
+	data := map[string]interface{}{
+		"key": channel.Key,
+	}
+	setting := channel.GetSetting()
+	if setting.TLSClientKey != "" {
+		data["tls_client_key"] = setting.TLSClientKey
+	}
+	if setting.RequestSigning != nil && setting.RequestSigning.Secret != "" {
+		data["request_signing_secret"] = setting.RequestSigning.Secret
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "获取成功",
-		"data": map[string]interface{}{
-			"key": channel.Key,
-		},
+		"data":    data,
 	})
 }
 
@@ -879,9 +900,18 @@ func UpdateChannel(c *gin.Context) {
 
 	// Always copy the original ChannelInfo so that fields like IsMultiKey and MultiKeySize are retained.
 	channel.ChannelInfo = originChannel.ChannelInfo
+	// 渠道列表与详情不返回设置中的密钥，提交时未填写则沿用原值
+	channel.KeepSettingSecrets(originChannel)
 
 	// If the request explicitly specifies a new MultiKeyMode, apply it on top of the original info.
 	if channel.MultiKeyMode != nil && *channel.MultiKeyMode != "" {
+		if !constant.IsValidMultiKeyMode(constant.MultiKeyMode(*channel.MultiKeyMode)) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不支持的多密钥模式: " + *channel.MultiKeyMode,
+			})
+			return
+		}
 		channel.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(*channel.MultiKeyMode)
 	}
 
@@ -1222,11 +1252,17 @@ func CopyChannel(c *gin.Context) {
 // MultiKeyManageRequest represents the request for multi-key management operations
 type MultiKeyManageRequest struct {
 	ChannelId int    `json:"channel_id"`
-	Action    string `json:"action"`              // "disable_key", "enable_key", "delete_key", "delete_disabled_keys", "get_key_status"
-	KeyIndex  *int   `json:"key_index,omitempty"` // for disable_key, enable_key, and delete_key actions
+	Action    string `json:"action"`              // "disable_key", "enable_key", "delete_key", "delete_disabled_keys", "get_key_status", "update_key_config", "update_strategy"
+	KeyIndex  *int   `json:"key_index,omitempty"` // for disable_key, enable_key, delete_key and update_key_config actions
 	Page      int    `json:"page,omitempty"`      // for get_key_status pagination
 	PageSize  int    `json:"page_size,omitempty"` // for get_key_status pagination
 	Status    *int   `json:"status,omitempty"`    // for get_key_status filtering: 1=enabled, 2=manual_disabled, 3=auto_disabled, nil=all
+	// for update_key_config: weight used by weighted mode, per-period quota cap used by quota_aware mode (0 = unlimited)
+	Weight     *int   `json:"weight,omitempty"`
+	QuotaLimit *int64 `json:"quota_limit,omitempty"`
+	// for update_strategy: least_used metric (requests / tokens) and quota_aware period (daily / weekly / monthly)
+	UsageMetric string `json:"usage_metric,omitempty"`
+	QuotaPeriod string `json:"quota_period,omitempty"`
 }
 
 // MultiKeyStatusResponse represents the response for key status query
@@ -1240,6 +1276,10 @@ type MultiKeyStatusResponse struct {
 	EnabledCount        int `json:"enabled_count"`
 	ManualDisabledCount int `json:"manual_disabled_count"`
 	AutoDisabledCount   int `json:"auto_disabled_count"`
+	// Strategy
+	Mode        constant.MultiKeyMode `json:"mode"`
+	UsageMetric string                `json:"usage_metric,omitempty"`
+	QuotaPeriod string                `json:"quota_period,omitempty"`
 }
 
 type KeyStatus struct {
@@ -1248,6 +1288,14 @@ type KeyStatus struct {
 	DisabledTime int64  `json:"disabled_time,omitempty"`
 	Reason       string `json:"reason,omitempty"`
 	KeyPreview   string `json:"key_preview"` // first 10 chars of key for identification
+	// Strategy config and usage counters recorded from consume logs
+	Weight       int   `json:"weight"`
+	QuotaLimit   int64 `json:"quota_limit,omitempty"`
+	ReenableTime int64 `json:"reenable_time,omitempty"`
+	RequestCount int64 `json:"request_count"`
+	UsedTokens   int64 `json:"used_tokens"`
+	UsedQuota    int64 `json:"used_quota"`
+	PeriodQuota  int64 `json:"period_quota"`
 }
 
 // ManageMultiKeys handles multi-key management operations
@@ -1283,6 +1331,11 @@ func ManageMultiKeys(c *gin.Context) {
 	switch request.Action {
 	case "get_key_status":
 		keys := channel.GetKeys()
+		usages, err := model.GetChannelKeyUsages(channel)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
 
 		// Default pagination parameters
 		page := request.Page
@@ -1335,12 +1388,24 @@ func ManageMultiKeys(c *gin.Context) {
 				keyPreview = key[:10] + "..."
 			}
 
+			weight := 1
+			if w, exists := channel.ChannelInfo.MultiKeyWeights[i]; exists {
+				weight = w
+			}
+			usage := usages[i]
 			allKeyStatusList = append(allKeyStatusList, KeyStatus{
 				Index:        i,
 				Status:       status,
 				DisabledTime: disabledTime,
 				Reason:       reason,
 				KeyPreview:   keyPreview,
+				Weight:       weight,
+				QuotaLimit:   channel.ChannelInfo.MultiKeyQuotaLimits[i],
+				ReenableTime: channel.ChannelInfo.MultiKeyReenableTime[i],
+				RequestCount: usage.RequestCount,
+				UsedTokens:   usage.UsedTokens,
+				UsedQuota:    usage.UsedQuota,
+				PeriodQuota:  usage.PeriodQuota,
 			})
 		}
 
@@ -1391,6 +1456,9 @@ func ManageMultiKeys(c *gin.Context) {
 				EnabledCount:        enabledCount,        // Overall statistics
 				ManualDisabledCount: manualDisabledCount, // Overall statistics
 				AutoDisabledCount:   autoDisabledCount,   // Overall statistics
+				Mode:                channel.ChannelInfo.MultiKeyMode,
+				UsageMetric:         channel.ChannelInfo.MultiKeyUsageMetric,
+				QuotaPeriod:         channel.ChannelInfo.MultiKeyQuotaPeriod,
 			},
 		})
 		return
@@ -1466,6 +1534,9 @@ func ManageMultiKeys(c *gin.Context) {
 		if channel.ChannelInfo.MultiKeyDisabledReason != nil {
 			delete(channel.ChannelInfo.MultiKeyDisabledReason, keyIndex)
 		}
+		if channel.ChannelInfo.MultiKeyReenableTime != nil {
+			delete(channel.ChannelInfo.MultiKeyReenableTime, keyIndex)
+		}
 
 		err = channel.Update()
 		if err != nil {
@@ -1490,6 +1561,7 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = make(map[int]int)
 		channel.ChannelInfo.MultiKeyDisabledTime = make(map[int]int64)
 		channel.ChannelInfo.MultiKeyDisabledReason = make(map[int]string)
+		channel.ChannelInfo.MultiKeyReenableTime = nil
 
 		err = channel.Update()
 		if err != nil {
@@ -1574,6 +1646,7 @@ func ManageMultiKeys(c *gin.Context) {
 		var newStatusList = make(map[int]int)
 		var newDisabledTime = make(map[int]int64)
 		var newDisabledReason = make(map[int]string)
+		var indexMapping = make(map[int]int)
 
 		newIndex := 0
 		for i, key := range keys {
@@ -1583,6 +1656,7 @@ func ManageMultiKeys(c *gin.Context) {
 			}
 
 			remainingKeys = append(remainingKeys, key)
+			indexMapping[i] = newIndex
 
 			// 保留其他密钥的状态信息，重新索引
 			if channel.ChannelInfo.MultiKeyStatusList != nil {
@@ -1617,12 +1691,16 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = newStatusList
 		channel.ChannelInfo.MultiKeyDisabledTime = newDisabledTime
 		channel.ChannelInfo.MultiKeyDisabledReason = newDisabledReason
+		channel.ChannelInfo.RemapKeyConfigs(indexMapping)
 
 		err = channel.Update()
 		if err != nil {
 			common.ApiError(c, err)
 			return
 		}
+		if err = model.RemapChannelKeyUsages(channel.Id, indexMapping); err != nil {
+			common.SysError(fmt.Sprintf("failed to remap key usage of channel #%d: %v", channel.Id, err))
+		}
 
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
@@ -1638,6 +1716,7 @@ func ManageMultiKeys(c *gin.Context) {
 		var newStatusList = make(map[int]int)
 		var newDisabledTime = make(map[int]int64)
 		var newDisabledReason = make(map[int]string)
+		var indexMapping = make(map[int]int)
 
 		newIndex := 0
 		for i, key := range keys {
@@ -1653,6 +1732,7 @@ func ManageMultiKeys(c *gin.Context) {
 				deletedCount++
 			} else {
 				remainingKeys = append(remainingKeys, key)
+				indexMapping[i] = newIndex
 				// 保留非自动禁用密钥的状态信息，重新索引
 				if status != 1 {
 					newStatusList[newIndex] = status
@@ -1685,12 +1765,16 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = newStatusList
 		channel.ChannelInfo.MultiKeyDisabledTime = newDisabledTime
 		channel.ChannelInfo.MultiKeyDisabledReason = newDisabledReason
+		channel.ChannelInfo.RemapKeyConfigs(indexMapping)
 
 		err = channel.Update()
 		if err != nil {
 			common.ApiError(c, err)
 			return
 		}
+		if err = model.RemapChannelKeyUsages(channel.Id, indexMapping); err != nil {
+			common.SysError(fmt.Sprintf("failed to remap key usage of channel #%d: %v", channel.Id, err))
+		}
 
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
@@ -1700,6 +1784,101 @@ func ManageMultiKeys(c *gin.Context) {
 		})
 		return
 
+	case "update_key_config":
+		if request.KeyIndex == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "未指定要配置的密钥索引",
+			})
+			return
+		}
+
+		keyIndex := *request.KeyIndex
+		if keyIndex < 0 || keyIndex >= channel.ChannelInfo.MultiKeySize {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "密钥索引超出范围",
+			})
+			return
+		}
+		if (request.Weight != nil && *request.Weight < 0) || (request.QuotaLimit != nil && *request.QuotaLimit < 0) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "权重和额度上限不能为负数",
+			})
+			return
+		}
+
+		if request.Weight != nil {
+			if channel.ChannelInfo.MultiKeyWeights == nil {
+				channel.ChannelInfo.MultiKeyWeights = make(map[int]int)
+			}
+			channel.ChannelInfo.MultiKeyWeights[keyIndex] = *request.Weight
+		}
+		if request.QuotaLimit != nil {
+			if *request.QuotaLimit == 0 {
+				delete(channel.ChannelInfo.MultiKeyQuotaLimits, keyIndex)
+			} else {
+				if channel.ChannelInfo.MultiKeyQuotaLimits == nil {
+					channel.ChannelInfo.MultiKeyQuotaLimits = make(map[int]int64)
+				}
+				channel.ChannelInfo.MultiKeyQuotaLimits[keyIndex] = *request.QuotaLimit
+			}
+		}
+
+		err = channel.Update()
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+
+		model.InitChannelCache()
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "密钥配置已更新",
+		})
+		return
+
+	case "update_strategy":
+		switch request.UsageMetric {
+		case "", constant.MultiKeyUsageMetricRequests, constant.MultiKeyUsageMetricTokens:
+		default:
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不支持的用量统计口径",
+			})
+			return
+		}
+		switch request.QuotaPeriod {
+		case "", model.SubscriptionResetDaily, model.SubscriptionResetWeekly, model.SubscriptionResetMonthly:
+		default:
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不支持的额度周期",
+			})
+			return
+		}
+
+		if request.UsageMetric != "" {
+			channel.ChannelInfo.MultiKeyUsageMetric = request.UsageMetric
+		}
+		if request.QuotaPeriod != "" {
+			channel.ChannelInfo.MultiKeyQuotaPeriod = request.QuotaPeriod
+		}
+
+		err = channel.Update()
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+
+		model.InitChannelCache()
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "多密钥策略已更新",
+		})
+		return
+
 	default:
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -2124,3 +2303,72 @@ func GetChannelSpoofSessionId(c *gin.Context) {
 		},
 	})
 }
+
+// GetChannelBreakers 返回所有处于熔断或半开状态的渠道/密钥
+func GetChannelBreakers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"enabled":  operation_setting.GetCircuitBreakerSetting().Enabled,
+			"breakers": model.GetChannelBreakerStatuses(0),
+		},
+	})
+}
+
+// GetChannelBreaker 返回单个渠道及其各密钥的熔断器状态
+func GetChannelBreaker(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"enabled":  operation_setting.GetCircuitBreakerSetting().Enabled,
+			"breakers": model.GetChannelBreakerStatuses(id),
+		},
+	})
+}
+
+// ResetChannelBreaker 手动关闭渠道及其各密钥的熔断器
+func ResetChannelBreaker(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.ResetChannelBreakers(id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetChannelSelection 返回指定分组与模型当前生效的选路策略及各候选渠道的实时得分，用于排查渠道为何被选中
+func GetChannelSelection(c *gin.Context) {
+	group := c.DefaultQuery("group", "default")
+	modelName := c.Query("model")
+	if modelName == "" {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "model 参数不能为空"})
+		return
+	}
+	if !common.MemoryCacheEnabled {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "未启用内存缓存，无法获取渠道选路信息"})
+		return
+	}
+	strategy := operation_setting.GetChannelSelectionSetting().GetChannelStrategy(group, modelName)
+	candidates := model.GetChannelSelectionCandidates(group, modelName)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"group":      group,
+			"model":      modelName,
+			"strategy":   strategy,
+			"candidates": model.GetChannelSelectionScores(candidates, strategy, modelName, c.Query("hash_key")),
+		},
+	})
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type CreateClientTokenRequest struct {
+	// Models 允许调用的模型，为空时与父令牌一致
+	Models []string `json:"models"`
+	// MaxQuota 客户端令牌累计可用额度，0 表示不单独限制（仍受父令牌与用户额度限制）
+	MaxQuota int `json:"max_quota"`
+	// ExpiresIn 有效期（秒），默认 10 分钟，最长 24 小时且不超过父令牌的过期时间
+	ExpiresIn int64 `json:"expires_in"`
+	// AllowedOrigins 允许的请求来源（Origin 头），如 https://app.example.com
+	AllowedOrigins []string `json:"allowed_origins"`
+	// AllowedIps 允许的客户端 IP 或 CIDR，父令牌设置了 IP 限制时两者须同时满足
+	AllowedIps []string `json:"allowed_ips"`
+	// EndUser 终端用户 id，会记录在消费日志中
+	EndUser string `json:"end_user"`
+}
+
+func (req *CreateClientTokenRequest) validate(parent *model.Token) error {
+	if req.ExpiresIn == 0 {
+		req.ExpiresIn = model.ClientTokenDefaultTTL
+	}
+	if req.ExpiresIn < 0 || req.ExpiresIn > model.ClientTokenMaxTTL {
+		return fmt.Errorf("expires_in 需在 1 到 %d 秒之间", model.ClientTokenMaxTTL)
+	}
+	if parent.ExpiredTime != -1 {
+		remain := parent.ExpiredTime - common.GetTimestamp()
+		if remain <= 0 {
+			return fmt.Errorf("父令牌已过期")
+		}
+		req.ExpiresIn = min(req.ExpiresIn, remain)
+	}
+	if req.MaxQuota < 0 {
+		return fmt.Errorf("max_quota 不能为负数")
+	}
+	if len(req.EndUser) > 64 {
+		return fmt.Errorf("end_user 长度不能超过 64")
+	}
+	if parent.ModelLimitsEnabled {
+		limits := parent.GetModelLimitsMap()
+		for _, modelName := range req.Models {
+			if !limits[modelName] {
+				return fmt.Errorf("父令牌无权使用模型 %s", modelName)
+			}
+		}
+	}
+	for i, origin := range req.AllowedOrigins {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("无效的来源 %s", origin)
+		}
+		req.AllowedOrigins[i] = origin
+	}
+	for _, ip := range req.AllowedIps {
+		if net.ParseIP(ip) == nil {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return fmt.Errorf("无效的 IP 或 CIDR %s", ip)
+			}
+		}
+	}
+	return nil
+}
+
+// CreateClientToken 使用当前 sk- 令牌签发短期客户端令牌（JWT），供浏览器或移动端直接调用网关。
+// 客户端令牌的计费记在父令牌及其用户名下，客户端令牌不能再签发新的客户端令牌。
+func CreateClientToken(c *gin.Context) {
+	if common.GetContextKeyString(c, constant.ContextKeyClientTokenId) != "" {
+		common.ApiErrorMsg(c, "客户端令牌不能签发新的客户端令牌")
+		return
+	}
+	var req CreateClientTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	parent, err := model.GetTokenByIds(c.GetInt("token_id"), c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := req.validate(parent); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	signed, claims, err := model.MintClientToken(parent, model.ClientTokenClaims{
+		Models:         req.Models,
+		MaxQuota:       req.MaxQuota,
+		AllowedOrigins: req.AllowedOrigins,
+		AllowedIps:     req.AllowedIps,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: req.EndUser,
+		},
+	}, req.ExpiresIn)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"id":         claims.ID,
+		"token":      signed,
+		"expires_at": claims.ExpiresAt.Unix(),
+	})
+}
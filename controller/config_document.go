@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFormatYAML = "yaml"
+	configFormatJSON = "json"
+
+	// configImportMaxSize 导入文档的大小上限
+	configImportMaxSize = 32 << 20
+	// configPassphraseHeader 导入加密文档时通过请求头传递口令，避免出现在访问日志中
+	configPassphraseHeader = "X-Config-Passphrase"
+)
+
+type ExportConfigRequest struct {
+	// Format yaml（默认）或 json
+	Format string `json:"format"`
+	// Secrets 密钥的导出方式：redact（默认，脱敏）或 encrypt（使用 passphrase 加密）
+	Secrets    string `json:"secrets"`
+	Passphrase string `json:"passphrase"`
+}
+
+// ExportConfig 导出渠道、倍率、预填分组、分片分组、订阅套餐与全部配置项，用于纳入 git 管理
+func ExportConfig(c *gin.Context) {
+	var req ExportConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if req.Format == "" {
+		req.Format = configFormatYAML
+	}
+	if req.Format != configFormatYAML && req.Format != configFormatJSON {
+		common.ApiErrorMsg(c, "不支持的导出格式")
+		return
+	}
+	doc, err := model.ExportConfigDocument(req.Secrets, req.Passphrase)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	data, err := marshalConfigDocument(doc, req.Format)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeSystem, fmt.Sprintf("导出网关配置 (密钥: %s)", common.GetStringIfEmpty(req.Secrets, model.ConfigSecretRedact)))
+
+	contentType := "application/yaml; charset=utf-8"
+	if req.Format == configFormatJSON {
+		contentType = "application/json; charset=utf-8"
+	}
+	filename := fmt.Sprintf("gateway-config-%s.%s", time.Now().Format("20060102-150405"), req.Format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ImportConfig 导入配置文档（YAML 或 JSON），默认只返回变更计划；dry_run=false 时在一个事务中执行全部变更。
+// prune=true 时删除文档中没有的渠道、预填分组与分片分组，并停用文档中没有的订阅套餐。
+func ImportConfig(c *gin.Context) {
+	dryRun := c.DefaultQuery("dry_run", "true") != "false"
+	prune, _ := strconv.ParseBool(c.Query("prune"))
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, configImportMaxSize))
+	if err != nil {
+		common.ApiErrorMsg(c, "配置文档过大或读取失败")
+		return
+	}
+	doc, err := unmarshalConfigDocument(body)
+	if err != nil {
+		common.ApiErrorMsg(c, "配置文档解析失败: "+err.Error())
+		return
+	}
+	plan, err := model.PlanConfigImport(doc, model.ConfigImportOptions{
+		Prune:      prune,
+		Passphrase: c.GetHeader(configPassphraseHeader),
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	summary := make(map[string]int)
+	for _, change := range plan.Changes {
+		summary[change.Action]++
+	}
+	if !dryRun {
+		if err := plan.Apply(); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		model.RecordLog(c.GetInt("id"), model.LogTypeSystem, fmt.Sprintf("导入网关配置 (新增 %d, 修改 %d, 删除 %d)",
+			summary[model.ConfigChangeCreate], summary[model.ConfigChangeUpdate], summary[model.ConfigChangeDelete]))
+	}
+	common.ApiSuccess(c, gin.H{
+		"dry_run": dryRun,
+		"summary": summary,
+		"changes": plan.Changes,
+	})
+}
+
+// marshalConfigDocument 以 json 标签为准输出文档，YAML 由 JSON 转换得到，保持字段名与顺序一致
+func marshalConfigDocument(doc *model.ConfigDocument, format string) ([]byte, error) {
+	data, err := common.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if format == configFormatJSON {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&node)
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resetYAMLStyle 去掉从 JSON 解析得到的流式与引号样式，输出块状 YAML
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+func unmarshalConfigDocument(data []byte) (*model.ConfigDocument, error) {
+	doc := &model.ConfigDocument{}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("配置文档为空")
+	}
+	if trimmed[0] != '{' {
+		var value interface{}
+		if err := yaml.Unmarshal(trimmed, &value); err != nil {
+			return nil, err
+		}
+		converted, err := common.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		trimmed = converted
+	}
+	if err := common.Unmarshal(trimmed, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
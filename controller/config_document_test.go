@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigDocumentYAMLRoundTrip(t *testing.T) {
+	doc := &model.ConfigDocument{
+		Version: model.ConfigDocumentVersion,
+		Options: map[string]string{
+			"ChannelDisableThreshold": "5",
+			"GroupRatio":              `{"default":1,"vip":0.8}`,
+			"LogConsumeEnabled":       "true",
+		},
+		Channels: []*model.ConfigChannel{{
+			Id:      3,
+			Name:    "openai",
+			Key:     "sk-1\nsk-2",
+			Models:  "gpt-4,gpt-4o",
+			Group:   "default",
+			AutoBan: 1,
+			MultiKey: &model.ConfigChannelMultiKey{
+				Mode:    "weighted",
+				Weights: map[int]int{0: 2, 1: 1},
+			},
+		}},
+		GroupShards: []*model.ConfigGroupShard{},
+	}
+
+	data, err := marshalConfigDocument(doc, configFormatYAML)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(data), "version: 1\n"))
+	require.NotContains(t, string(data), "{\"version\"")
+
+	decoded, err := unmarshalConfigDocument(data)
+	require.NoError(t, err)
+	require.Equal(t, doc, decoded)
+
+	data, err = marshalConfigDocument(doc, configFormatJSON)
+	require.NoError(t, err)
+	decoded, err = unmarshalConfigDocument(data)
+	require.NoError(t, err)
+	require.Equal(t, doc, decoded)
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+var uploadableFilePurposes = []string{dto.FilePurposeBatch, dto.FilePurposeUserData}
+
+func respondOpenAIError(c *gin.Context, statusCode int, code string, message string) {
+	errType := "invalid_request_error"
+	if statusCode >= http.StatusInternalServerError {
+		errType = "new_api_error"
+	}
+	c.JSON(statusCode, gin.H{
+		"error": types.OpenAIError{
+			Message: common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
+			Type:    errType,
+			Code:    code,
+		},
+	})
+}
+
+func respondOpenAIDBError(c *gin.Context, err error, notFoundMessage string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondOpenAIError(c, http.StatusNotFound, "not_found", notFoundMessage)
+		return
+	}
+	common.SysError("relay file/batch db error: " + err.Error())
+	respondOpenAIError(c, http.StatusInternalServerError, "server_error", "database error")
+}
+
+func getListLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultListLimit
+	}
+	return min(limit, maxListLimit)
+}
+
+// UploadFile POST /v1/files
+func UploadFile(c *gin.Context) {
+	purpose := c.PostForm("purpose")
+	if !common.StringsContains(uploadableFilePurposes, purpose) {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_purpose", fmt.Sprintf("purpose must be one of %v", uploadableFilePurposes))
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "missing_required_parameter", "file is required")
+		return
+	}
+	maxBytes := int64(constant.BatchMaxFileMB) << 20
+	if header.Size > maxBytes {
+		respondOpenAIError(c, http.StatusRequestEntityTooLarge, "file_too_large", fmt.Sprintf("file exceeds the maximum size of %d MB", constant.BatchMaxFileMB))
+		return
+	}
+	src, err := header.Open()
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_file", err.Error())
+		return
+	}
+	defer src.Close()
+	content, err := io.ReadAll(io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_file", err.Error())
+		return
+	}
+	if int64(len(content)) > maxBytes {
+		respondOpenAIError(c, http.StatusRequestEntityTooLarge, "file_too_large", fmt.Sprintf("file exceeds the maximum size of %d MB", constant.BatchMaxFileMB))
+		return
+	}
+
+	file := &model.File{
+		FileId:   "file-" + common.GetRandomString(24),
+		UserId:   c.GetInt("id"),
+		TokenId:  c.GetInt("token_id"),
+		Purpose:  purpose,
+		Filename: header.Filename,
+		Content:  content,
+	}
+	if err := file.Insert(); err != nil {
+		respondOpenAIDBError(c, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, file.ToOpenAIFile())
+}
+
+// ListFiles GET /v1/files
+func ListFiles(c *gin.Context) {
+	limit := getListLimit(c)
+	files, err := model.GetUserFiles(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1, c.Query("order") == "asc")
+	if err != nil {
+		respondOpenAIDBError(c, err, fmt.Sprintf("No such File object: %s", c.Query("after")))
+		return
+	}
+	resp := dto.OpenAIListResponse[*dto.OpenAIFile]{
+		Object:  "list",
+		Data:    make([]*dto.OpenAIFile, 0, len(files)),
+		HasMore: len(files) > limit,
+	}
+	for i, file := range files {
+		if i >= limit {
+			break
+		}
+		resp.Data = append(resp.Data, file.ToOpenAIFile())
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstID = resp.Data[0].ID
+		resp.LastID = resp.Data[len(resp.Data)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetrieveFile GET /v1/files/:id
+func RetrieveFile(c *gin.Context) {
+	fileId := c.Param("id")
+	file, err := model.GetUserFileById(c.GetInt("id"), fileId, false)
+	if err != nil {
+		respondOpenAIDBError(c, err, fmt.Sprintf("No such File object: %s", fileId))
+		return
+	}
+	c.JSON(http.StatusOK, file.ToOpenAIFile())
+}
+
+// RetrieveFileContent GET /v1/files/:id/content
+func RetrieveFileContent(c *gin.Context) {
+	fileId := c.Param("id")
+	file, err := model.GetUserFileById(c.GetInt("id"), fileId, true)
+	if err != nil {
+		respondOpenAIDBError(c, err, fmt.Sprintf("No such File object: %s", fileId))
+		return
+	}
+	contentType := "application/octet-stream"
+	if file.Purpose == dto.FilePurposeBatch || file.Purpose == dto.FilePurposeBatchOutput {
+		contentType = "application/jsonl"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Data(http.StatusOK, contentType, file.Content)
+}
+
+// DeleteFile DELETE /v1/files/:id
+func DeleteFile(c *gin.Context) {
+	fileId := c.Param("id")
+	if err := model.DeleteUserFile(c.GetInt("id"), fileId); err != nil {
+		respondOpenAIDBError(c, err, fmt.Sprintf("No such File object: %s", fileId))
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleted{
+		ID:      fileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
@@ -148,6 +148,30 @@ func GetLogsSelfStat(c *gin.Context) {
 	return
 }
 
+// GetLogsMargin 按渠道、模型、分组或天汇总消费日志的收入、上游成本与毛利
+func GetLogsMargin(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	dimension := c.DefaultQuery("dimension", model.MarginDimensionChannel)
+	stats, err := model.GetMarginReport(dimension, model.MarginReportFilter{
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		Channel:        channel,
+		ModelName:      c.Query("model_name"),
+		Group:          c.Query("group"),
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    stats,
+	})
+}
+
 func DeleteHistoryLogs(c *gin.Context) {
 	targetTimestamp, _ := strconv.ParseInt(c.Query("target_timestamp"), 10, 64)
 	if targetTimestamp == 0 {
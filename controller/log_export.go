@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	logExportFormatCSV   = "csv"
+	logExportFormatJSONL = "jsonl"
+
+	// userLogExportMaxSpan 用户自助导出单次允许的最大时间跨度
+	userLogExportMaxSpan = 31 * 24 * 3600
+)
+
+var (
+	logExportColumns = []string{"id", "created_at", "time", "type", "user_id", "username", "token_id", "token_name",
+		"group", "model_name", "channel", "channel_name", "quota", "upstream_cost", "prompt_tokens", "completion_tokens",
+		"use_time", "is_stream", "ip", "request_id", "content", "other"}
+	userLogExportColumns = []string{"id", "created_at", "time", "type", "token_id", "token_name",
+		"group", "model_name", "quota", "prompt_tokens", "completion_tokens", "use_time",
+		"is_stream", "ip", "request_id", "content", "other"}
+)
+
+// logExportWriter 将日志逐批写入响应，首批数据到达时才写出响应头，之前出错仍可返回 JSON 错误
+type logExportWriter struct {
+	c       *gin.Context
+	format  string
+	self    bool
+	csv     *csv.Writer
+	started bool
+}
+
+func (w *logExportWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	filename := fmt.Sprintf("logs-%s.%s", time.Now().Format("20060102-150405"), w.format)
+	w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.c.Header("Cache-Control", "no-cache")
+	w.c.Header("X-Accel-Buffering", "no")
+	if w.format == logExportFormatJSONL {
+		w.c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+		w.c.Status(http.StatusOK)
+		return
+	}
+	w.c.Header("Content-Type", "text/csv; charset=utf-8")
+	w.c.Status(http.StatusOK)
+	// UTF-8 BOM，便于表格软件正确识别中文
+	_, _ = w.c.Writer.Write([]byte("\xEF\xBB\xBF"))
+	w.csv = csv.NewWriter(w.c.Writer)
+	if w.self {
+		_ = w.csv.Write(userLogExportColumns)
+	} else {
+		_ = w.csv.Write(logExportColumns)
+	}
+}
+
+func (w *logExportWriter) writeBatch(logs []*model.Log, _ int) error {
+	w.start()
+	for _, log := range logs {
+		if w.format == logExportFormatJSONL {
+			data, err := common.Marshal(log)
+			if err != nil {
+				return err
+			}
+			if _, err = w.c.Writer.Write(append(data, '\n')); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.csv.Write(w.csvRecord(log)); err != nil {
+			return err
+		}
+	}
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	w.c.Writer.Flush()
+	return w.c.Request.Context().Err()
+}
+
+func (w *logExportWriter) csvRecord(log *model.Log) []string {
+	record := []string{
+		strconv.Itoa(log.Id),
+		strconv.FormatInt(log.CreatedAt, 10),
+		time.Unix(log.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+		strconv.Itoa(log.Type),
+	}
+	if !w.self {
+		record = append(record, strconv.Itoa(log.UserId), log.Username)
+	}
+	record = append(record, strconv.Itoa(log.TokenId), log.TokenName, log.Group, log.ModelName)
+	if !w.self {
+		record = append(record, strconv.Itoa(log.ChannelId), log.ChannelName)
+	}
+	record = append(record, strconv.Itoa(log.Quota))
+	if !w.self {
+		// 0 表示上游成本未知
+		cost := ""
+		if log.UpstreamCost > 0 {
+			cost = strconv.Itoa(log.UpstreamCost)
+		}
+		record = append(record, cost)
+	}
+	return append(record,
+		strconv.Itoa(log.PromptTokens),
+		strconv.Itoa(log.CompletionTokens),
+		strconv.Itoa(log.UseTime),
+		strconv.FormatBool(log.IsStream),
+		log.Ip,
+		log.RequestId,
+		log.Content,
+		log.Other,
+	)
+}
+
+func exportLogs(c *gin.Context, filter model.LogExportFilter, self bool) {
+	format := c.DefaultQuery("format", logExportFormatCSV)
+	if format != logExportFormatCSV && format != logExportFormatJSONL {
+		common.ApiErrorMsg(c, "不支持的导出格式，仅支持 csv 和 jsonl")
+		return
+	}
+	w := &logExportWriter{c: c, format: format, self: self}
+	err := model.StreamLogs(c.Request.Context(), filter, model.LogExportBatchSize, w.writeBatch)
+	if err != nil {
+		if !w.started {
+			common.ApiError(c, err)
+			return
+		}
+		// 响应头已写出，只能中断传输
+		logger.LogError(c, fmt.Sprintf("failed to export logs: %s", err.Error()))
+		return
+	}
+	w.start()
+	if w.csv != nil {
+		w.csv.Flush()
+	}
+}
+
+// ExportAllLogs 以 CSV / JSONL 流式导出日志，筛选参数与 GetAllLogs 一致，cursor 为续传起点（不含）
+func ExportAllLogs(c *gin.Context) {
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	cursor, _ := strconv.Atoi(c.Query("cursor"))
+	exportLogs(c, model.LogExportFilter{
+		LogType:        logType,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		ModelName:      c.Query("model_name"),
+		Username:       c.Query("username"),
+		TokenName:      c.Query("token_name"),
+		Channel:        channel,
+		Group:          c.Query("group"),
+		RequestId:      c.Query("request_id"),
+		Cursor:         cursor,
+	}, false)
+}
+
+// ExportUserLogs 导出当前用户自己的日志，渠道与管理员信息会被去除，单次最多导出 31 天
+func ExportUserLogs(c *gin.Context) {
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp == 0 {
+		endTimestamp = common.GetTimestamp()
+	}
+	if startTimestamp == 0 {
+		startTimestamp = endTimestamp - userLogExportMaxSpan
+	}
+	if endTimestamp-startTimestamp > userLogExportMaxSpan {
+		common.ApiErrorMsg(c, "时间跨度不能超过 31 天")
+		return
+	}
+	exportLogs(c, model.LogExportFilter{
+		UserId:         c.GetInt("id"),
+		LogType:        logType,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		ModelName:      c.Query("model_name"),
+		TokenName:      c.Query("token_name"),
+		Group:          c.Query("group"),
+		RequestId:      c.Query("request_id"),
+	}, true)
+}
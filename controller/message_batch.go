@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func respondClaudeError(c *gin.Context, statusCode int, errType string, message string) {
+	c.JSON(statusCode, gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    errType,
+			"message": common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
+		},
+	})
+}
+
+func respondClaudeDBError(c *gin.Context, err error, notFoundMessage string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondClaudeError(c, http.StatusNotFound, "not_found_error", notFoundMessage)
+		return
+	}
+	common.SysError("relay message batch db error: " + err.Error())
+	respondClaudeError(c, http.StatusInternalServerError, "api_error", "database error")
+}
+
+func getUserMessageBatch(c *gin.Context) (*model.Batch, bool) {
+	batchId := c.Param("id")
+	batch, err := model.GetUserBatchById(c.GetInt("id"), batchId)
+	if err == nil && batch.Endpoint != service.ClaudeMessageBatchEndpoint {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		respondClaudeDBError(c, err, fmt.Sprintf("message batch %s not found", batchId))
+		return nil, false
+	}
+	return batch, true
+}
+
+func messageBatchResultsURL(batch *model.Batch) string {
+	return fmt.Sprintf("%s/v1/messages/batches/%s/results", system_setting.ServerAddress, batch.BatchId)
+}
+
+// CreateMessageBatch POST /v1/messages/batches
+func CreateMessageBatch(c *gin.Context) {
+	var req dto.ClaudeMessageBatchCreateRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		respondClaudeError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	content, err := service.BuildClaudeMessageBatchInput(req.Requests)
+	if err != nil {
+		respondClaudeError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	userId := c.GetInt("id")
+	tokenId := c.GetInt("token_id")
+	now := common.GetTimestamp()
+	batchId := "msgbatch_" + common.GetRandomString(24)
+	inputFile := &model.File{
+		FileId:   "file-" + common.GetRandomString(24),
+		UserId:   userId,
+		TokenId:  tokenId,
+		Purpose:  dto.FilePurposeBatch,
+		Filename: batchId + "_input.jsonl",
+		Content:  content,
+	}
+	if err := inputFile.Insert(); err != nil {
+		respondClaudeDBError(c, err, "")
+		return
+	}
+	batch := &model.Batch{
+		BatchId:          batchId,
+		UserId:           userId,
+		TokenId:          tokenId,
+		Endpoint:         service.ClaudeMessageBatchEndpoint,
+		InputFileId:      inputFile.FileId,
+		CompletionWindow: batchCompletionWindow,
+		Status:           dto.BatchStatusValidating,
+		TotalCount:       len(req.Requests),
+		CreatedAt:        now,
+		ExpiresAt:        now + 24*60*60,
+		ClientIp:         c.ClientIP(),
+	}
+	if err := batch.Insert(); err != nil {
+		respondClaudeDBError(c, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToClaudeMessageBatch(messageBatchResultsURL(batch)))
+}
+
+// RetrieveMessageBatch GET /v1/messages/batches/:id
+func RetrieveMessageBatch(c *gin.Context) {
+	batch, ok := getUserMessageBatch(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToClaudeMessageBatch(messageBatchResultsURL(batch)))
+}
+
+// ListMessageBatches GET /v1/messages/batches
+func ListMessageBatches(c *gin.Context) {
+	limit := getListLimit(c)
+	cursor := c.Query("after_id") + c.Query("before_id")
+	batches, err := model.GetUserBatches(c.GetInt("id"), []string{service.ClaudeMessageBatchEndpoint},
+		c.Query("after_id"), c.Query("before_id"), limit+1)
+	if err != nil {
+		respondClaudeDBError(c, err, fmt.Sprintf("message batch %s not found", cursor))
+		return
+	}
+	resp := dto.ClaudeListResponse[*dto.ClaudeMessageBatch]{
+		Data:    make([]*dto.ClaudeMessageBatch, 0, len(batches)),
+		HasMore: len(batches) > limit,
+	}
+	if len(batches) > limit {
+		if c.Query("before_id") != "" {
+			// before_id 分页时多取的一条位于列表开头
+			batches = batches[1:]
+		} else {
+			batches = batches[:limit]
+		}
+	}
+	for _, batch := range batches {
+		resp.Data = append(resp.Data, batch.ToClaudeMessageBatch(messageBatchResultsURL(batch)))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstID = common.GetPointer(resp.Data[0].ID)
+		resp.LastID = common.GetPointer(resp.Data[len(resp.Data)-1].ID)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CancelMessageBatch POST /v1/messages/batches/:id/cancel
+func CancelMessageBatch(c *gin.Context) {
+	batch, ok := getUserMessageBatch(c)
+	if !ok {
+		return
+	}
+	batch, err := model.CancelUserBatch(batch.UserId, batch.BatchId)
+	if err != nil {
+		if errors.Is(err, model.ErrBatchNotCancellable) {
+			respondClaudeError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		respondClaudeDBError(c, err, fmt.Sprintf("message batch %s not found", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToClaudeMessageBatch(messageBatchResultsURL(batch)))
+}
+
+// DeleteMessageBatch DELETE /v1/messages/batches/:id
+func DeleteMessageBatch(c *gin.Context) {
+	batch, ok := getUserMessageBatch(c)
+	if !ok {
+		return
+	}
+	if err := model.DeleteUserBatch(batch.UserId, batch.BatchId); err != nil {
+		if errors.Is(err, model.ErrBatchNotEnded) {
+			respondClaudeError(c, http.StatusBadRequest, "invalid_request_error", "message batch must be ended before it can be deleted; cancel it first")
+			return
+		}
+		respondClaudeDBError(c, err, fmt.Sprintf("message batch %s not found", batch.BatchId))
+		return
+	}
+	c.JSON(http.StatusOK, dto.ClaudeMessageBatchDeleted{ID: batch.BatchId, Type: "message_batch_deleted"})
+}
+
+// RetrieveMessageBatchResults GET /v1/messages/batches/:id/results
+func RetrieveMessageBatchResults(c *gin.Context) {
+	batch, ok := getUserMessageBatch(c)
+	if !ok {
+		return
+	}
+	if !batch.IsEnded() {
+		respondClaudeError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("message batch %s has not finished processing", batch.BatchId))
+		return
+	}
+	contents := make([][]byte, 0, 2)
+	for _, fileId := range []string{batch.OutputFileId, batch.ErrorFileId} {
+		if fileId == "" {
+			continue
+		}
+		file, err := model.GetUserFileById(batch.UserId, fileId, true)
+		if err != nil {
+			respondClaudeDBError(c, err, fmt.Sprintf("results of message batch %s not found", batch.BatchId))
+			return
+		}
+		contents = append(contents, file.Content)
+	}
+	c.Data(http.StatusOK, "application/binary", service.ConvertBatchResultsToClaude(contents...))
+}
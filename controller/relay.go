@@ -15,6 +15,7 @@ import (
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
@@ -29,6 +30,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
@@ -105,7 +107,9 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	validateSpan := tracing.StartSpan(c, "relay.validate_request")
 	request, err := helper.GetAndValidateRequest(c, relayFormat)
+	validateSpan.End(err)
 	if err != nil {
 		// Map "request body too large" to 413 so clients can handle it correctly
 		if common.IsRequestBodyTooLargeError(err) || errors.Is(err, common.ErrRequestBodyTooLarge) {
@@ -122,6 +126,11 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	// 输入防护在格式转换前执行，mask 规则会同时改写请求体
+	if newAPIError = service.ApplyInputGuard(c, relayInfo, request); newAPIError != nil {
+		return
+	}
+
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
 	needCountToken := constant.CountToken
 	// Avoid building huge CombineText (strings.Join) when token counting and sensitive check are both disabled.
@@ -141,7 +150,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}
 
+	estimateSpan := tracing.StartSpan(c, "relay.estimate_tokens")
 	tokens, err := service.EstimateRequestToken(c, meta, relayInfo)
+	estimateSpan.SetAttributes(attribute.Int("tokens.estimated_prompt", tokens))
+	estimateSpan.End(err)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeCountTokenFailed)
 		return
@@ -149,6 +161,16 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	relayInfo.SetEstimatePromptTokens(tokens)
 
+	// 按预估 token 数预占 TPM 额度，结算时按实际用量修正
+	if newAPIError = service.ReserveTokenRateLimit(c, tokens); newAPIError != nil {
+		return
+	}
+	defer func() {
+		if newAPIError != nil {
+			service.ReleaseTokenRateLimit(c)
+		}
+	}()
+
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, tokens, meta)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeModelPriceError)
@@ -160,7 +182,9 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	if priceData.FreeModel {
 		logger.LogInfo(c, fmt.Sprintf("模型 %s 免费，跳过预扣费", relayInfo.OriginModelName))
 	} else {
+		preConsumeSpan := tracing.StartSpan(c, "billing.pre_consume", attribute.Int("quota.pre_consumed", priceData.QuotaToPreConsume))
 		newAPIError = service.PreConsumeBilling(c, priceData.QuotaToPreConsume, relayInfo)
+		preConsumeSpan.EndAPIError(newAPIError)
 		if newAPIError != nil {
 			return
 		}
@@ -208,17 +232,23 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 		c.Request.Body = io.NopCloser(bodyStorage)
 
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
+		// 并发 / RPM / TPM 名额已被并发请求占满时直接换下一个渠道，不计入熔断和自动禁用
+		releaseUpstreamLimit, limitErr := service.AcquireUpstreamLimit(c, relayInfo)
+		if limitErr != nil {
+			newAPIError = limitErr
+			relayInfo.LastError = limitErr
+			if !shouldRetry(c, limitErr, common.RetryTimes-retryParam.GetRetry()) {
+				break
+			}
+			continue
 		}
 
+		attemptStart := time.Now()
+		newAPIError = relayAttempt(c, relayFormat, relayInfo, channel.Id, releaseUpstreamLimit)
+		service.RecordChannelBreakerResult(c, channel.Id, relayInfo, attemptStart, newAPIError)
+		service.RecordChannelLatency(channel.Id, relayInfo, attemptStart, newAPIError)
+		service.ObserveRelayAttempt(c, channel.Id, relayInfo, attemptStart, newAPIError)
+
 		if newAPIError == nil {
 			relayInfo.LastError = nil
 			return
@@ -241,6 +271,37 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 }
 
+// relayAttempt 将请求中继到已选中的渠道，并在请求期间计入渠道的在途请求数，结束时释放上游限制名额
+func relayAttempt(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, channelId int, releaseUpstreamLimit func(*types.NewAPIError)) (newAPIError *types.NewAPIError) {
+	defer model.BeginChannelRequest(channelId)()
+	defer func() {
+		releaseUpstreamLimit(newAPIError)
+	}()
+	span := tracing.StartSpan(c, "relay.attempt",
+		attribute.Int("relay.retry_index", relayInfo.RetryIndex),
+		attribute.Int("channel.id", channelId),
+		attribute.Int("channel.type", common.GetContextKeyInt(c, constant.ContextKeyChannelType)),
+		attribute.String("model", relayInfo.OriginModelName),
+		attribute.String("group", relayInfo.UsingGroup),
+	)
+	defer func() {
+		if relayInfo.ChannelMeta != nil {
+			span.SetAttributes(attribute.String("model.upstream", relayInfo.UpstreamModelName))
+		}
+		span.EndAPIError(newAPIError)
+	}()
+	switch relayFormat {
+	case types.RelayFormatOpenAIRealtime:
+		return relay.WssHelper(c, relayInfo)
+	case types.RelayFormatClaude:
+		return relay.ClaudeHelper(c, relayInfo)
+	case types.RelayFormatGemini:
+		return geminiRelayHandler(c, relayInfo)
+	default:
+		return relayHandler(c, relayInfo)
+	}
+}
+
 var upgrader = websocket.Upgrader{
 	Subprotocols: []string{"realtime"}, // WS 握手支持的协议，如果有使用 Sec-WebSocket-Protocol，则必须在此声明对应的 Protocol TODO add other protocol
 	CheckOrigin: func(r *http.Request) bool {
@@ -301,6 +362,9 @@ func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service
 
 	info.PriceData.GroupRatioInfo = helper.HandleGroupRatio(c, info)
 
+	if errors.Is(err, model.ErrChannelUpstreamSaturated) {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("分组 %s 下模型 %s 的可用渠道均已达到上游限制（retry）: %s", selectGroup, info.OriginModelName, err.Error()), types.ErrorCodeGetChannelFailed, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+	}
 	if err != nil {
 		return nil, types.NewError(fmt.Errorf("获取分组 %s 下模型 %s 的可用渠道失败（retry）: %s", selectGroup, info.OriginModelName, err.Error()), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 	}
@@ -326,6 +390,9 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if service.ShouldSkipRetryAfterChannelAffinityFailure(c) {
 		return false
 	}
+	if service.IsResponsesChannelPinned(c) {
+		return false
+	}
 	if types.IsChannelError(openaiErr) {
 		return true
 	}
@@ -338,6 +405,10 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	// 流式响应尚未向客户端输出任何内容，总是可以切换渠道
+	if openaiErr.GetErrorCode() == types.ErrorCodeStreamFirstTokenFailed {
+		return true
+	}
 	code := openaiErr.StatusCode
 	if code >= 200 && code < 300 {
 		return false
@@ -355,7 +426,8 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
-	if service.ShouldDisableChannel(channelError.ChannelType, err) && channelError.AutoBan {
+	// 上游 429 已转为对应密钥的冷却时不再禁用整个渠道
+	if service.ShouldDisableChannel(channelError.ChannelType, err) && channelError.AutoBan && !service.IsUpstreamCooldownApplied(c) {
 		gopool.Go(func() {
 			service.DisableChannel(channelError, err.ErrorWithStatusCode())
 		})
@@ -661,3 +733,20 @@ func shouldRetryTaskRelay(c *gin.Context, channelId int, taskErr *dto.TaskError,
 	}
 	return true
 }
+
+// RelayClaudeCountTokens POST /v1/messages/count_tokens，不计费
+func RelayClaudeCountTokens(c *gin.Context) {
+	request := &dto.ClaudeRequest{}
+	if err := common.UnmarshalBodyReusable(c, request); err != nil {
+		respondClaudeError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if request.Model == "" || len(request.Messages) == 0 {
+		respondClaudeError(c, http.StatusBadRequest, "invalid_request_error", "model and messages are required")
+		return
+	}
+	info := relaycommon.GenRelayInfoClaude(c, request)
+	c.JSON(http.StatusOK, dto.ClaudeCountTokensResponse{
+		InputTokens: relay.ClaudeCountTokensHelper(c, info, request),
+	})
+}
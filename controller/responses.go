@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getUserResponsesRecord 查询当前用户的响应记录，其他用户的响应按不存在处理
+func getUserResponsesRecord(c *gin.Context) (*model.ResponsesRecord, bool) {
+	responseId := c.Param("id")
+	record, err := model.GetUserResponsesRecord(c.GetInt("id"), responseId)
+	if err != nil {
+		respondOpenAIDBError(c, err, fmt.Sprintf("Response with id '%s' not found.", responseId))
+		return nil, false
+	}
+	return record, true
+}
+
+// relayResponsesResource 将请求转发到创建该响应的渠道与 key，返回 nil 时错误已写出
+func relayResponsesResource(c *gin.Context, record *model.ResponsesRecord, subPath string) *http.Response {
+	channel, err := model.CacheGetChannel(record.ChannelId)
+	if err != nil {
+		respondOpenAIError(c, http.StatusNotFound, "not_found", fmt.Sprintf("Response with id '%s' is no longer available: its channel has been removed.", record.ResponseId))
+		return nil
+	}
+	service.PinResponsesChannel(c, record)
+	if newAPIError := middleware.SetupContextForSelectedChannel(c, channel, record.ModelName); newAPIError != nil {
+		c.JSON(newAPIError.StatusCode, gin.H{"error": newAPIError.ToOpenAIError()})
+		return nil
+	}
+	resp, newAPIError := relay.RelayResponsesResource(c, subPath)
+	if newAPIError != nil {
+		c.JSON(newAPIError.StatusCode, gin.H{"error": newAPIError.ToOpenAIError()})
+		return nil
+	}
+	return resp
+}
+
+// writeResponsesResource 将上游响应写回客户端；syncStatus 为 true 时根据返回的响应对象更新记录状态并结算后台响应
+func writeResponsesResource(c *gin.Context, record *model.ResponsesRecord, resp *http.Response, syncStatus bool) {
+	defer service.CloseResponseBodyGracefully(resp)
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		streamResponsesResource(c, record, resp, syncStatus)
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadGateway, "bad_response", err.Error())
+		return
+	}
+	if syncStatus {
+		var responsesResponse dto.OpenAIResponsesResponse
+		if err := common.Unmarshal(body, &responsesResponse); err == nil {
+			service.SyncResponsesRecordStatus(c, record, &responsesResponse)
+		} else {
+			logger.LogError(c, "failed to unmarshal responses resource: "+err.Error())
+		}
+	}
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+}
+
+// streamResponsesResource 透传后台响应的 SSE 流（GET ?stream=true），终态事件同样用于更新记录
+func streamResponsesResource(c *gin.Context, record *model.ResponsesRecord, resp *http.Response, syncStatus bool) {
+	c.Header("Content-Type", resp.Header.Get("Content-Type"))
+	c.Header("Cache-Control", "no-cache")
+	c.Status(resp.StatusCode)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if _, err := c.Writer.WriteString(line + "\n"); err != nil {
+			return
+		}
+		if line == "" {
+			c.Writer.Flush()
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data:")
+		if !syncStatus || !ok {
+			continue
+		}
+		var event dto.ResponsesStreamResponse
+		if err := common.UnmarshalJsonStr(strings.TrimSpace(data), &event); err != nil || event.Response == nil {
+			continue
+		}
+		if service.IsResponsesStatusEnded(event.Response.GetStatus()) {
+			service.SyncResponsesRecordStatus(c, record, event.Response)
+		}
+	}
+	c.Writer.Flush()
+}
+
+// RetrieveResponse GET /v1/responses/:id
+func RetrieveResponse(c *gin.Context) {
+	record, ok := getUserResponsesRecord(c)
+	if !ok {
+		return
+	}
+	if record.Local {
+		c.Data(http.StatusOK, "application/json", []byte(record.Response))
+		return
+	}
+	if resp := relayResponsesResource(c, record, "/"+record.ResponseId); resp != nil {
+		writeResponsesResource(c, record, resp, true)
+	}
+}
+
+// DeleteResponse DELETE /v1/responses/:id
+func DeleteResponse(c *gin.Context) {
+	record, ok := getUserResponsesRecord(c)
+	if !ok {
+		return
+	}
+	var resp *http.Response
+	if !record.Local {
+		if resp = relayResponsesResource(c, record, "/"+record.ResponseId); resp == nil {
+			return
+		}
+	} else if err := service.DeleteResponsesConversation(record.ResponseId); err != nil {
+		logger.LogError(c, "failed to delete responses conversation: "+err.Error())
+	}
+	if err := model.DeleteUserResponsesRecord(record.UserId, record.ResponseId); err != nil {
+		logger.LogError(c, "failed to delete responses record: "+err.Error())
+	}
+	if resp != nil {
+		writeResponsesResource(c, record, resp, false)
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIResponsesDeleted{
+		ID:      record.ResponseId,
+		Object:  "response",
+		Deleted: true,
+	})
+}
+
+// ListResponseInputItems GET /v1/responses/:id/input_items
+func ListResponseInputItems(c *gin.Context) {
+	record, ok := getUserResponsesRecord(c)
+	if !ok {
+		return
+	}
+	if !record.Local {
+		if resp := relayResponsesResource(c, record, "/"+record.ResponseId+"/input_items"); resp != nil {
+			writeResponsesResource(c, record, resp, false)
+		}
+		return
+	}
+
+	items, err := service.LocalResponsesInputItems(record)
+	if err != nil {
+		respondOpenAIError(c, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	// 与 OpenAI 一致，默认按倒序返回
+	if c.Query("order") != "asc" {
+		slices.Reverse(items)
+	}
+	if after := c.Query("after"); after != "" {
+		idx := slices.IndexFunc(items, func(item map[string]any) bool {
+			return item["id"] == after
+		})
+		if idx < 0 {
+			respondOpenAIError(c, http.StatusNotFound, "not_found", fmt.Sprintf("Item with id '%s' not found.", after))
+			return
+		}
+		items = items[idx+1:]
+	}
+	limit := getListLimit(c)
+	resp := dto.OpenAIListResponse[map[string]any]{
+		Object:  "list",
+		Data:    items[:min(limit, len(items))],
+		HasMore: len(items) > limit,
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstID = common.Interface2String(resp.Data[0]["id"])
+		resp.LastID = common.Interface2String(resp.Data[len(resp.Data)-1]["id"])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CancelResponse POST /v1/responses/:id/cancel
+func CancelResponse(c *gin.Context) {
+	record, ok := getUserResponsesRecord(c)
+	if !ok {
+		return
+	}
+	if record.Local || !record.Background {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", "Only responses created with background=true can be cancelled.")
+		return
+	}
+	if resp := relayResponsesResource(c, record, "/"+record.ResponseId+"/cancel"); resp != nil {
+		writeResponsesResource(c, record, resp, true)
+	}
+}
@@ -89,8 +89,50 @@ func GetTokenKey(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	// 密钥只保存摘要，仅创建与轮换时返回一次明文；尚未迁移的旧令牌仍可查看
+	key := token.GetFullKey()
+	if key == "" {
+		// revealable=false 供前端区分，引导用户轮换密钥
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": common.TranslateMessage(c, i18n.MsgTokenKeyNotRevealable),
+			"data":    gin.H{"revealable": false},
+		})
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"key": key,
+	})
+}
+
+type RotateTokenKeyRequest struct {
+	// GracePeriod 旧密钥继续可用的秒数，0 表示立即失效
+	GracePeriod int64 `json:"grace_period"`
+}
+
+// RotateTokenKey 为令牌生成新密钥，响应中返回一次新密钥明文
+func RotateTokenKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req RotateTokenKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+			return
+		}
+	}
+	key, token, err := model.RotateTokenKey(id, c.GetInt("id"), req.GracePeriod)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	common.ApiSuccess(c, gin.H{
-		"key": token.GetFullKey(),
+		"key":                   key,
+		"token":                 buildMaskedTokenResponse(token),
+		"prev_key_expired_time": token.PrevKeyExpiredTime,
 	})
 }
 
@@ -187,6 +229,10 @@ func AddToken(c *gin.Context) {
 			return
 		}
 	}
+	if token.GuardProfile != "" && !operation_setting.GetPromptGuardSetting().HasProfile(token.GuardProfile) {
+		common.ApiErrorI18n(c, i18n.MsgTokenGuardProfileInvalid)
+		return
+	}
 	// 检查用户令牌数量是否已达上限
 	maxTokens := operation_setting.GetMaxUserTokens()
 	count, err := model.CountUserTokens(c.GetInt("id"))
@@ -208,28 +254,37 @@ func AddToken(c *gin.Context) {
 		return
 	}
 	cleanToken := model.Token{
-		UserId:             c.GetInt("id"),
-		Name:               token.Name,
-		Key:                key,
-		CreatedTime:        common.GetTimestamp(),
-		AccessedTime:       common.GetTimestamp(),
-		ExpiredTime:        token.ExpiredTime,
-		RemainQuota:        token.RemainQuota,
-		UnlimitedQuota:     token.UnlimitedQuota,
-		ModelLimitsEnabled: token.ModelLimitsEnabled,
-		ModelLimits:        token.ModelLimits,
-		AllowIps:           token.AllowIps,
-		Group:              token.Group,
-		CrossGroupRetry:    token.CrossGroupRetry,
-	}
+		UserId:               c.GetInt("id"),
+		Name:                 token.Name,
+		CreatedTime:          common.GetTimestamp(),
+		AccessedTime:         common.GetTimestamp(),
+		ExpiredTime:          token.ExpiredTime,
+		RemainQuota:          token.RemainQuota,
+		UnlimitedQuota:       token.UnlimitedQuota,
+		ModelLimitsEnabled:   token.ModelLimitsEnabled,
+		ModelLimits:          token.ModelLimits,
+		AllowIps:             token.AllowIps,
+		Group:                token.Group,
+		CrossGroupRetry:      token.CrossGroupRetry,
+		TpmLimit:             token.TpmLimit,
+		ConcurrencyLimit:     token.ConcurrencyLimit,
+		ResponseCacheEnabled: token.ResponseCacheEnabled,
+		GuardProfile:         token.GuardProfile,
+	}
+	cleanToken.SetKey(key)
 	err = cleanToken.Insert()
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	// 密钥明文只在创建时返回这一次
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
+		"data": gin.H{
+			"id":  cleanToken.Id,
+			"key": key,
+		},
 	})
 }
 
@@ -271,6 +326,10 @@ func UpdateToken(c *gin.Context) {
 			return
 		}
 	}
+	if statusOnly == "" && token.GuardProfile != "" && !operation_setting.GetPromptGuardSetting().HasProfile(token.GuardProfile) {
+		common.ApiErrorI18n(c, i18n.MsgTokenGuardProfileInvalid)
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -299,6 +358,10 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.TpmLimit = token.TpmLimit
+		cleanToken.ConcurrencyLimit = token.ConcurrencyLimit
+		cleanToken.ResponseCacheEnabled = token.ResponseCacheEnabled
+		cleanToken.GuardProfile = token.GuardProfile
 	}
 	err = cleanToken.Update()
 	if err != nil {
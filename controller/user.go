@@ -202,7 +202,6 @@ func Register(c *gin.Context) {
 		token := model.Token{
 			UserId:             insertedUser.Id, // 使用插入后的用户ID
 			Name:               cleanUser.Username + "的初始令牌",
-			Key:                key,
 			CreatedTime:        common.GetTimestamp(),
 			AccessedTime:       common.GetTimestamp(),
 			ExpiredTime:        -1,     // 永不过期
@@ -210,6 +209,8 @@ func Register(c *gin.Context) {
 			UnlimitedQuota:     true,
 			ModelLimitsEnabled: false,
 		}
+		// 初始令牌的明文不会返回给用户，需要使用时通过轮换获取新密钥
+		token.SetKey(key)
 		if setting.DefaultUseAutoGroup {
 			token.Group = "auto"
 		}
@@ -1,5 +1,11 @@
 package dto
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 type ChannelSettings struct {
 	ForceFormat            bool              `json:"force_format,omitempty"`
 	ThinkingToContent      bool              `json:"thinking_to_content,omitempty"`
@@ -10,6 +16,114 @@ type ChannelSettings struct {
 	HeaderAuditEnabled     bool              `json:"header_audit_enabled,omitempty"`
 	HeaderAuditRules       map[string]string `json:"header_audit_rules,omitempty"`
 	SessionIdSpoofEnabled  bool              `json:"session_id_spoof_enabled,omitempty"`
+	// ResponseOverride 响应覆盖规则，格式与参数覆盖相同，作用于上游响应体、SSE 数据块与响应头
+	ResponseOverride map[string]interface{} `json:"response_override,omitempty"`
+	// TLSClientCert/TLSClientKey 为 PEM 格式的 mTLS 客户端证书与私钥，TLSCACert 为校验上游证书的自定义 CA
+	TLSClientCert  string                  `json:"tls_client_cert,omitempty"`
+	TLSClientKey   string                  `json:"tls_client_key,omitempty"`
+	TLSCACert      string                  `json:"tls_ca_cert,omitempty"`
+	RequestSigning *RequestSigningSettings `json:"request_signing,omitempty"`
+	// UpstreamLimit 发往上游的并发 / RPM / TPM 限制，多密钥渠道按每个密钥分别计数
+	UpstreamLimit *UpstreamLimitSettings `json:"upstream_limit,omitempty"`
+}
+
+// HasCustomTLS 是否配置了 mTLS 客户端证书或自定义 CA
+func (s *ChannelSettings) HasCustomTLS() bool {
+	return s.TLSClientCert != "" || s.TLSClientKey != "" || s.TLSCACert != ""
+}
+
+// RequestSigningSettings 上游请求 HMAC 签名，签名原文为
+// METHOD + "\n" + PATH?QUERY + "\n" + TIMESTAMP + "\n" + BODY，时间戳为 Unix 秒
+type RequestSigningSettings struct {
+	Enabled         bool   `json:"enabled"`
+	Secret          string `json:"secret"`
+	Algorithm       string `json:"algorithm,omitempty"`        // sha256（默认）、sha512、sha1
+	Encoding        string `json:"encoding,omitempty"`         // hex（默认）、base64
+	SignatureHeader string `json:"signature_header,omitempty"` // 默认 X-Signature
+	TimestampHeader string `json:"timestamp_header,omitempty"` // 默认 X-Timestamp
+	SignaturePrefix string `json:"signature_prefix,omitempty"` // 签名值前缀，如 "sha256="
+	KeyId           string `json:"key_id,omitempty"`
+	KeyIdHeader     string `json:"key_id_header,omitempty"` // 默认 X-Key-Id，仅在 key_id 非空时发送
+}
+
+func (s *RequestSigningSettings) IsEnabled() bool {
+	return s != nil && s.Enabled
+}
+
+func (s *RequestSigningSettings) GetAlgorithm() string {
+	if s.Algorithm == "" {
+		return "sha256"
+	}
+	return strings.ToLower(s.Algorithm)
+}
+
+func (s *RequestSigningSettings) GetEncoding() string {
+	if s.Encoding == "" {
+		return "hex"
+	}
+	return strings.ToLower(s.Encoding)
+}
+
+func (s *RequestSigningSettings) GetSignatureHeader() string {
+	if s.SignatureHeader == "" {
+		return "X-Signature"
+	}
+	return s.SignatureHeader
+}
+
+func (s *RequestSigningSettings) GetTimestampHeader() string {
+	if s.TimestampHeader == "" {
+		return "X-Timestamp"
+	}
+	return s.TimestampHeader
+}
+
+func (s *RequestSigningSettings) GetKeyIdHeader() string {
+	if s.KeyIdHeader == "" {
+		return "X-Key-Id"
+	}
+	return s.KeyIdHeader
+}
+
+func (s *RequestSigningSettings) Validate() error {
+	if !s.IsEnabled() {
+		return nil
+	}
+	if s.Secret == "" {
+		return errors.New("request signing is enabled but secret is empty")
+	}
+	switch s.GetAlgorithm() {
+	case "sha256", "sha512", "sha1":
+	default:
+		return fmt.Errorf("unsupported request signing algorithm: %s", s.Algorithm)
+	}
+	switch s.GetEncoding() {
+	case "hex", "base64":
+	default:
+		return fmt.Errorf("unsupported request signing encoding: %s", s.Encoding)
+	}
+	return nil
+}
+
+// UpstreamLimitSettings 渠道上游限制，0 表示不限制
+type UpstreamLimitSettings struct {
+	Concurrency int `json:"concurrency,omitempty"`
+	RPM         int `json:"rpm,omitempty"`
+	TPM         int `json:"tpm,omitempty"`
+}
+
+func (s *UpstreamLimitSettings) IsEnabled() bool {
+	return s != nil && (s.Concurrency > 0 || s.RPM > 0 || s.TPM > 0)
+}
+
+func (s *UpstreamLimitSettings) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Concurrency < 0 || s.RPM < 0 || s.TPM < 0 {
+		return errors.New("upstream limits must not be negative")
+	}
+	return nil
 }
 
 type VertexKeyType string
@@ -27,22 +141,52 @@ const (
 )
 
 type ChannelOtherSettings struct {
-	AzureResponsesVersion                 string        `json:"azure_responses_version,omitempty"`
-	VertexKeyType                         VertexKeyType `json:"vertex_key_type,omitempty"` // "json" or "api_key"
-	OpenRouterEnterprise                  *bool         `json:"openrouter_enterprise,omitempty"`
-	ClaudeBetaQuery                       bool          `json:"claude_beta_query,omitempty"`         // Claude 渠道是否强制追加 ?beta=true
-	AllowServiceTier                      bool          `json:"allow_service_tier,omitempty"`        // 是否允许 service_tier 透传（默认过滤以避免额外计费）
-	AllowInferenceGeo                     bool          `json:"allow_inference_geo,omitempty"`       // 是否允许 inference_geo 透传（仅 Claude，默认过滤以满足数据驻留合规
-	AllowSafetyIdentifier                 bool          `json:"allow_safety_identifier,omitempty"`   // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
-	DisableStore                          bool          `json:"disable_store,omitempty"`             // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
-	AllowIncludeObfuscation               bool          `json:"allow_include_obfuscation,omitempty"` // 是否允许 stream_options.include_obfuscation 透传（默认过滤以避免关闭流混淆保护）
-	AwsKeyType                            AwsKeyType    `json:"aws_key_type,omitempty"`
-	UpstreamModelUpdateCheckEnabled       bool          `json:"upstream_model_update_check_enabled,omitempty"`        // 是否检测上游模型更新
-	UpstreamModelUpdateAutoSyncEnabled    bool          `json:"upstream_model_update_auto_sync_enabled,omitempty"`    // 是否自动同步上游模型更新
-	UpstreamModelUpdateLastCheckTime      int64         `json:"upstream_model_update_last_check_time,omitempty"`      // 上次检测时间
-	UpstreamModelUpdateLastDetectedModels []string      `json:"upstream_model_update_last_detected_models,omitempty"` // 上次检测到的可加入模型
-	UpstreamModelUpdateLastRemovedModels  []string      `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
-	UpstreamModelUpdateIgnoredModels      []string      `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
+	AzureResponsesVersion                 string             `json:"azure_responses_version,omitempty"`
+	VertexKeyType                         VertexKeyType      `json:"vertex_key_type,omitempty"` // "json" or "api_key"
+	OpenRouterEnterprise                  *bool              `json:"openrouter_enterprise,omitempty"`
+	ClaudeBetaQuery                       bool               `json:"claude_beta_query,omitempty"`         // Claude 渠道是否强制追加 ?beta=true
+	AllowServiceTier                      bool               `json:"allow_service_tier,omitempty"`        // 是否允许 service_tier 透传（默认过滤以避免额外计费）
+	AllowInferenceGeo                     bool               `json:"allow_inference_geo,omitempty"`       // 是否允许 inference_geo 透传（仅 Claude，默认过滤以满足数据驻留合规
+	AllowSafetyIdentifier                 bool               `json:"allow_safety_identifier,omitempty"`   // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
+	DisableStore                          bool               `json:"disable_store,omitempty"`             // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
+	AllowIncludeObfuscation               bool               `json:"allow_include_obfuscation,omitempty"` // 是否允许 stream_options.include_obfuscation 透传（默认过滤以避免关闭流混淆保护）
+	AwsKeyType                            AwsKeyType         `json:"aws_key_type,omitempty"`
+	UpstreamModelUpdateCheckEnabled       bool               `json:"upstream_model_update_check_enabled,omitempty"`        // 是否检测上游模型更新
+	UpstreamModelUpdateAutoSyncEnabled    bool               `json:"upstream_model_update_auto_sync_enabled,omitempty"`    // 是否自动同步上游模型更新
+	UpstreamModelUpdateLastCheckTime      int64              `json:"upstream_model_update_last_check_time,omitempty"`      // 上次检测时间
+	UpstreamModelUpdateLastDetectedModels []string           `json:"upstream_model_update_last_detected_models,omitempty"` // 上次检测到的可加入模型
+	UpstreamModelUpdateLastRemovedModels  []string           `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
+	UpstreamModelUpdateIgnoredModels      []string           `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
+	PriceRatio                            float64            `json:"price_ratio,omitempty"`                                // 渠道价格倍率（相对模型基础价格），用于最低成本选路（默认 1）与上游成本统计（未配置时成本未知）
+	ModelPriceRatio                       map[string]float64 `json:"model_price_ratio,omitempty"`                          // 按模型覆盖渠道价格倍率
+}
+
+// GetPriceRatio 返回渠道处理指定模型时的价格倍率，模型级配置优先于渠道级配置
+func (s *ChannelOtherSettings) GetPriceRatio(model string) float64 {
+	if s == nil {
+		return 1
+	}
+	if ratio, ok := s.ModelPriceRatio[model]; ok && ratio >= 0 {
+		return ratio
+	}
+	if s.PriceRatio > 0 {
+		return s.PriceRatio
+	}
+	return 1
+}
+
+// GetCostRatio 返回渠道处理指定模型时显式配置的价格倍率，未配置（或配置为 0）时 ok 为 false，表示上游成本未知
+func (s *ChannelOtherSettings) GetCostRatio(model string) (ratio float64, ok bool) {
+	if s == nil {
+		return 0, false
+	}
+	if ratio, ok := s.ModelPriceRatio[model]; ok && ratio > 0 {
+		return ratio, true
+	}
+	if s.PriceRatio > 0 {
+		return s.PriceRatio, true
+	}
+	return 0, false
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
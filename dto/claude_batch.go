@@ -0,0 +1,77 @@
+package dto
+
+import "encoding/json"
+
+const (
+	ClaudeBatchProcessingStatusInProgress = "in_progress"
+	ClaudeBatchProcessingStatusCanceling  = "canceling"
+	ClaudeBatchProcessingStatusEnded      = "ended"
+)
+
+const (
+	ClaudeBatchResultSucceeded = "succeeded"
+	ClaudeBatchResultErrored   = "errored"
+	ClaudeBatchResultCanceled  = "canceled"
+	ClaudeBatchResultExpired   = "expired"
+)
+
+// ClaudeCountTokensResponse https://docs.anthropic.com/en/api/messages-count-tokens
+type ClaudeCountTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+type ClaudeMessageBatchRequestItem struct {
+	CustomID string          `json:"custom_id"`
+	Params   json.RawMessage `json:"params"`
+}
+
+type ClaudeMessageBatchCreateRequest struct {
+	Requests []ClaudeMessageBatchRequestItem `json:"requests"`
+}
+
+type ClaudeMessageBatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// ClaudeMessageBatch https://docs.anthropic.com/en/api/creating-message-batches
+// 时间字段为 RFC 3339 格式
+type ClaudeMessageBatch struct {
+	ID                string                          `json:"id"`
+	Type              string                          `json:"type"`
+	ProcessingStatus  string                          `json:"processing_status"`
+	RequestCounts     ClaudeMessageBatchRequestCounts `json:"request_counts"`
+	EndedAt           *string                         `json:"ended_at"`
+	CreatedAt         string                          `json:"created_at"`
+	ExpiresAt         string                          `json:"expires_at"`
+	ArchivedAt        *string                         `json:"archived_at"`
+	CancelInitiatedAt *string                         `json:"cancel_initiated_at"`
+	ResultsURL        *string                         `json:"results_url"`
+}
+
+type ClaudeMessageBatchDeleted struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type ClaudeMessageBatchResultBody struct {
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// ClaudeMessageBatchResult 结果文件（JSONL）中的一行
+type ClaudeMessageBatchResult struct {
+	CustomID string                       `json:"custom_id"`
+	Result   ClaudeMessageBatchResultBody `json:"result"`
+}
+
+type ClaudeListResponse[T any] struct {
+	Data    []T     `json:"data"`
+	HasMore bool    `json:"has_more"`
+	FirstID *string `json:"first_id"`
+	LastID  *string `json:"last_id"`
+}
@@ -14,6 +14,7 @@ const (
 	NotifyTypeChannelUpdate = "channel_update"
 	NotifyTypeChannelTest   = "channel_test"
 	NotifyTypeTicket        = "ticket"
+	NotifyTypeUsageReport   = "usage_report"
 )
 
 func NewNotify(t string, title string, content string, values []interface{}) Notify {
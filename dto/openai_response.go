@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/types"
 )
 
@@ -329,6 +330,22 @@ func (o *OpenAIResponsesResponse) GetSize() string {
 	return ""
 }
 
+// GetStatus 返回响应状态（queued、in_progress、completed 等），状态缺失时返回空字符串
+func (o *OpenAIResponsesResponse) GetStatus() string {
+	var status string
+	if len(o.Status) > 0 {
+		_ = common.Unmarshal(o.Status, &status)
+	}
+	return status
+}
+
+// OpenAIResponsesDeleted DELETE /v1/responses/{id} 的响应
+type OpenAIResponsesDeleted struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
+
 type IncompleteDetails struct {
 	Reasoning string `json:"reasoning"`
 }
@@ -344,6 +361,9 @@ type ResponsesOutput struct {
 	CallId    string                   `json:"call_id,omitempty"`
 	Name      string                   `json:"name,omitempty"`
 	Arguments string                   `json:"arguments,omitempty"`
+	// reasoning
+	Summary          []ResponsesReasoningSummaryPart `json:"summary,omitempty"`
+	EncryptedContent string                          `json:"encrypted_content,omitempty"`
 }
 
 type ResponsesOutputContent struct {
@@ -384,6 +404,12 @@ type ResponsesStreamResponse struct {
 	SummaryIndex *int                           `json:"summary_index,omitempty"`
 	ItemID       string                         `json:"item_id,omitempty"`
 	Part         *ResponsesReasoningSummaryPart `json:"part,omitempty"`
+	// - response.output_text.done
+	// - response.reasoning_summary_text.done
+	Text      string `json:"text,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	// SequenceNumber 事件序号，仅在网关自行生成事件时填充
+	SequenceNumber int `json:"sequence_number"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
@@ -42,6 +42,8 @@ const (
 	MsgTokenExhausted            = "token.exhausted"
 	MsgTokenStatusUnavailable    = "token.status_unavailable"
 	MsgTokenDbError              = "token.db_error"
+	MsgTokenKeyNotRevealable     = "token.key_not_revealable"
+	MsgTokenGuardProfileInvalid  = "token.guard_profile_invalid"
 )
 
 // Redemption related messages
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"fmt"
 	"log"
@@ -19,6 +20,7 @@ import (
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay"
 	"github.com/QuantumNous/new-api/router"
 	"github.com/QuantumNous/new-api/service"
@@ -112,6 +114,15 @@ func main() {
 	// Subscription quota reset task (daily/weekly/monthly/custom)
 	service.StartSubscriptionQuotaResetTask()
 
+	// Re-enable multi-key channel keys whose period quota cap has reset
+	service.StartMultiKeyReenableTask()
+
+	// Daily/monthly usage reports delivered through the root user's notification channel
+	service.StartUsageReportTask()
+
+	// Remove usage records of expired client tokens
+	service.StartClientTokenCleanupTask()
+
 	// Wire task polling adaptor factory (breaks service -> relay import cycle)
 	service.GetTaskAdaptorFunc = func(platform constant.TaskPlatform) service.TaskPollingAdaptor {
 		a := relay.GetTaskAdaptor(platform)
@@ -151,6 +162,14 @@ func main() {
 		common.SysError(fmt.Sprintf("start pyroscope error : %v", err))
 	}
 
+	if tracingConfig := tracing.ConfigFromEnv(); tracingConfig.Endpoint != "" {
+		if _, err = tracing.Start(context.Background(), tracingConfig); err != nil {
+			common.SysError(fmt.Sprintf("start tracing error : %v", err))
+		} else {
+			common.SysLog(fmt.Sprintf("OpenTelemetry tracing enabled, exporting to %s (%s)", tracingConfig.Endpoint, tracingConfig.Protocol))
+		}
+	}
+
 	// Initialize HTTP server
 	server := gin.New()
 	server.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
@@ -165,6 +184,7 @@ func main() {
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
 	server.Use(middleware.RequestId())
+	server.Use(middleware.Tracing())
 	server.Use(middleware.PoweredBy())
 	server.Use(middleware.I18n())
 	middleware.SetUpLogger(server)
@@ -184,6 +204,10 @@ func main() {
 
 	// 设置路由
 	router.SetRouter(server, buildFS, indexPage)
+
+	// Batch lines are replayed through the full relay pipeline (breaks service -> router import cycle)
+	service.BatchRelayHandler = server
+	service.StartBatchProcessingTask()
 	var port = os.Getenv("PORT")
 	if port == "" {
 		port = strconv.Itoa(*common.Port)
@@ -296,12 +296,22 @@ func TokenAuth() func(c *gin.Context) {
 			key = strings.TrimPrefix(key, "sk-")
 			parts = strings.Split(key, "-")
 			key = parts[0]
-		} else {
+		} else if !model.IsClientTokenKey(key) {
 			key = strings.TrimPrefix(key, "sk-")
 			parts = strings.Split(key, "-")
 			key = parts[0]
 		}
-		token, err := model.ValidateUserToken(key)
+		var token *model.Token
+		var clientClaims *model.ClientTokenClaims
+		var err error
+		if batchId, ok := c.Request.Context().Value(constant.ContextKeyBatchId).(string); ok && batchId != "" {
+			// 批处理任务在服务端内部重放请求，携带的是令牌的密钥摘要
+			token, err = model.ValidateUserTokenByKeyHash(key)
+		} else if model.IsClientTokenKey(key) {
+			token, clientClaims, err = model.ValidateClientToken(key)
+		} else {
+			token, err = model.ValidateUserToken(key)
+		}
 		if token != nil {
 			id := c.GetInt("id")
 			if id == 0 {
@@ -313,8 +323,19 @@ func TokenAuth() func(c *gin.Context) {
 			return
 		}
 
-		allowIps := token.GetIpLimits()
-		if len(allowIps) > 0 {
+		// 客户端令牌须同时满足父令牌与签发时指定的 IP 限制，并校验请求来源
+		ipLimits := [][]string{token.GetIpLimits()}
+		if clientClaims != nil {
+			ipLimits = append(ipLimits, clientClaims.AllowedIps)
+			if !clientClaims.AllowOrigin(c.Request.Header.Get("Origin")) {
+				abortWithOpenAiMessage(c, http.StatusForbidden, "请求来源不在客户端令牌允许的列表中", types.ErrorCodeAccessDenied)
+				return
+			}
+		}
+		for _, allowIps := range ipLimits {
+			if len(allowIps) == 0 {
+				continue
+			}
 			clientIp := c.ClientIP()
 			logger.LogDebug(c, "Token has IP restrictions, checking client IP %s", clientIp)
 			ip := net.ParseIP(clientIp)
@@ -365,10 +386,24 @@ func TokenAuth() func(c *gin.Context) {
 		if err != nil {
 			return
 		}
+		if clientClaims != nil {
+			setupContextForClientToken(c, token, clientClaims)
+		}
 		c.Next()
 	}
 }
 
+// setupContextForClientToken 客户端令牌在父令牌上下文的基础上收紧模型限制，并记录客户端令牌 id 与终端用户
+func setupContextForClientToken(c *gin.Context, token *model.Token, claims *model.ClientTokenClaims) {
+	if limits := claims.ModelLimits(token); limits != nil {
+		c.Set("token_model_limit_enabled", true)
+		c.Set("token_model_limit", limits)
+	}
+	common.SetContextKey(c, constant.ContextKeyClientTokenId, claims.ID)
+	common.SetContextKey(c, constant.ContextKeyClientTokenEndUser, claims.Subject)
+	common.SetContextKey(c, constant.ContextKeyClientTokenMaxQuota, claims.MaxQuota)
+}
+
 func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) error {
 	if token == nil {
 		return fmt.Errorf("token is nil")
@@ -389,6 +424,10 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenTpmLimit, token.TpmLimit)
+	common.SetContextKey(c, constant.ContextKeyTokenConcurrencyLimit, token.ConcurrencyLimit)
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, token.ResponseCacheEnabled)
+	common.SetContextKey(c, constant.ContextKeyTokenGuardProfile, token.GuardProfile)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
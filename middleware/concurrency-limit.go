@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+)
+
+// ConcurrencyLimit 令牌/用户/分组维度的并发请求数限制，请求结束后释放名额
+func ConcurrencyLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		release, rejection := service.AcquireConcurrencyLimit(c)
+		if rejection != nil {
+			service.SetRetryAfterHeader(c, rejection.RetryAfter)
+			if strings.HasPrefix(c.Request.URL.Path, "/v1/messages") {
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"type": "error",
+					"error": gin.H{
+						"type":    "rate_limit_error",
+						"message": rejection.Message,
+					},
+				})
+				c.Abort()
+				return
+			}
+			abortWithOpenAiMessage(c, http.StatusTooManyRequests, rejection.Message, types.ErrorCodeRateLimitExceeded)
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
@@ -14,21 +14,26 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ModelRequest struct {
-	Model string `json:"model"`
-	Group string `json:"group,omitempty"`
+	Model              string `json:"model"`
+	Group              string `json:"group,omitempty"`
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
 }
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		span := tracing.StartSpan(c, "relay.distribute")
+		defer span.End(nil)
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
@@ -111,8 +116,20 @@ func Distribute() func(c *gin.Context) {
 					}
 				}
 
+				// Responses 续接：previous_response_id 对应的响应只存在于创建它的渠道
+				if modelRequest.PreviousResponseID != "" && strings.HasPrefix(c.Request.URL.Path, "/v1/responses") {
+					record, found := getResponsesRecordForUser(c, modelRequest.PreviousResponseID)
+					if record != nil && !found {
+						abortWithOpenAiMessage(c, http.StatusNotFound, fmt.Sprintf("Previous response with id '%s' not found.", modelRequest.PreviousResponseID))
+						return
+					}
+					if found {
+						channel, selectGroup = getResponsesPinnedChannel(c, record, modelRequest.Model, usingGroup)
+					}
+				}
+
 				// Sticky session binding lookup: check if session is already bound to a channel
-				if sessionId != "" {
+				if channel == nil && sessionId != "" {
 					if boundChannelId, found := model.CacheGetSessionChannel(sessionId); found {
 						boundChannel, chErr := model.CacheGetChannel(boundChannelId)
 						if chErr == nil && boundChannel != nil && boundChannel.Status == common.ChannelStatusEnabled {
@@ -188,6 +205,10 @@ func Distribute() func(c *gin.Context) {
 						//	common.SysError(fmt.Sprintf("渠道不存在：%d", channel.Id))
 						//	message = "数据库一致性已被破坏，请联系管理员"
 						//}
+						if errors.Is(err, model.ErrChannelUpstreamSaturated) {
+							abortWithOpenAiMessage(c, http.StatusTooManyRequests, message, types.ErrorCodeChannelUpstreamLimited)
+							return
+						}
 						abortWithOpenAiMessage(c, http.StatusServiceUnavailable, message, types.ErrorCodeModelNotFound)
 						return
 					}
@@ -210,6 +231,15 @@ func Distribute() func(c *gin.Context) {
 			return
 		}
 
+		if channel != nil {
+			span.SetAttributes(
+				attribute.Int("channel.id", channel.Id),
+				attribute.Int("channel.type", channel.Type),
+				attribute.String("model", modelRequest.Model),
+				attribute.String("group", common.GetContextKeyString(c, constant.ContextKeyUsingGroup)),
+			)
+		}
+		span.End(nil)
 		c.Next()
 		if channel != nil && c.Writer != nil && c.Writer.Status() < http.StatusBadRequest {
 			service.RecordChannelAffinity(c, channel.Id)
@@ -232,6 +262,42 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// getResponsesRecordForUser 查询响应记录，记录存在但属于其他用户时 found 为 false；
+// 没有记录（例如上线前创建的响应）时 record 为 nil，按普通请求选择渠道
+func getResponsesRecordForUser(c *gin.Context, responseId string) (record *model.ResponsesRecord, found bool) {
+	record, err := model.GetResponsesRecord(responseId)
+	if err != nil {
+		return nil, false
+	}
+	return record, record.UserId == common.GetContextKeyInt(c, constant.ContextKeyUserId)
+}
+
+// getResponsesPinnedChannel 返回创建响应的渠道，渠道已禁用或不再服务于当前分组/模型时返回 nil
+func getResponsesPinnedChannel(c *gin.Context, record *model.ResponsesRecord, modelName string, usingGroup string) (*model.Channel, string) {
+	pinned, err := model.CacheGetChannel(record.ChannelId)
+	if err != nil || pinned == nil || pinned.Status != common.ChannelStatusEnabled {
+		return nil, ""
+	}
+	selectGroup := ""
+	if usingGroup == "auto" {
+		userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+		for _, g := range service.GetUserAutoGroup(userGroup) {
+			if model.IsChannelEnabledForGroupModel(g, modelName, pinned.Id) {
+				selectGroup = g
+				common.SetContextKey(c, constant.ContextKeyAutoGroup, g)
+				break
+			}
+		}
+	} else if model.IsChannelEnabledForGroupModel(usingGroup, modelName, pinned.Id) {
+		selectGroup = usingGroup
+	}
+	if selectGroup == "" {
+		return nil, ""
+	}
+	service.PinResponsesChannel(c, record)
+	return pinned, selectGroup
+}
+
 // getModelFromRequest 从请求中读取模型信息
 // 根据 Content-Type 自动处理：
 // - application/json
@@ -435,10 +501,17 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	common.SetContextKey(c, constant.ContextKeyChannelModelMapping, channel.GetModelMapping())
 	common.SetContextKey(c, constant.ContextKeyChannelStatusCodeMapping, channel.GetStatusCodeMapping())
 
-	key, index, newAPIError := channel.GetNextEnabledKey()
+	key, index, keyProbe, newAPIError := channel.SelectNextEnabledKey()
 	if newAPIError != nil {
 		return newAPIError
 	}
+	// 续接/检索 Responses 时上游响应只对创建它的 key 可见
+	if pinnedIndex, ok := service.GetResponsesPinnedKeyIndex(c, channel.Id); ok {
+		if pinnedKey, enabled := channel.GetEnabledKeyByIndex(pinnedIndex); enabled && pinnedIndex != index {
+			key, index, keyProbe = pinnedKey, pinnedIndex, false
+		}
+	}
+	common.SetContextKey(c, constant.ContextKeyChannelKeyBreakerProbe, keyProbe)
 	if channel.ChannelInfo.IsMultiKey {
 		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
 		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, index)
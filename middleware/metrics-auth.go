@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsAuth 校验 /metrics 的 Bearer Token；未配置 MetricsToken 时该端点不可用
+func MetricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if common.MetricsToken == "" {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		token, ok := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(common.MetricsToken)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
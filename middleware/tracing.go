@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Tracing 为每个请求创建服务端 span，并延续调用方 traceparent 中的链路
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tracing.Enabled() {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		span := tracing.StartServerSpan(c, c.Request.Method+" "+route,
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("request.id", c.GetString(common.RequestIdKey)),
+		)
+		c.Next()
+		status := c.Writer.Status()
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", status),
+			attribute.Int("user.id", c.GetInt(string(constant.ContextKeyUserId))),
+		)
+		if status >= http.StatusInternalServerError {
+			span.End(fmt.Errorf("http status %d", status))
+			return
+		}
+		span.End(nil)
+	}
+}
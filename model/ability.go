@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/samber/lo"
 	"gorm.io/gorm"
@@ -111,7 +112,7 @@ func getChannelQuery(group string, model string, retry int) (*gorm.DB, error) {
 	return channelQuery, nil
 }
 
-func GetChannel(group string, model string, retry int, userId int) (*Channel, error) {
+func GetChannel(group string, model string, retry int, userId int) (*Channel, bool, error) {
 	// Try with the given group first; if it's a shard, also try the parent group
 	groupsToTry := []string{group}
 	if parentGroup := GetParentGroup(group); parentGroup != group {
@@ -119,24 +120,24 @@ func GetChannel(group string, model string, retry int, userId int) (*Channel, er
 	}
 
 	for _, tryGroup := range groupsToTry {
-		ch, err := getChannelForGroup(tryGroup, model, retry, userId)
+		ch, probe, err := getChannelForGroup(tryGroup, model, retry, userId)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		if ch != nil {
-			return ch, nil
+			return ch, probe, nil
 		}
 	}
-	return nil, nil
+	return nil, false, nil
 }
 
-func getChannelForGroup(group string, model string, retry int, userId int) (*Channel, error) {
+func getChannelForGroup(group string, model string, retry int, userId int) (*Channel, bool, error) {
 	var abilities []Ability
 
 	// Get all priority levels to support fallback when user binding limits filter out all channels
 	priorities, err := getAllPriorities(group, model)
 	if err != nil || len(priorities) == 0 {
-		return nil, err
+		return nil, false, err
 	}
 
 	startPri := retry
@@ -176,7 +177,7 @@ func getChannelForGroup(group string, model string, retry int, userId int) (*Cha
 				}
 				// User appears bound — atomically verify and refresh binding
 				if CacheBindUserIfRoom(fullChannel.Id, userId, maxUsers, expireMinutes) {
-					return &fullChannel, nil
+					return &fullChannel, false, nil
 				}
 				// Binding expired between DB check and atomic check, or channel is full.
 				// Fall through to normal priority-based selection.
@@ -194,7 +195,7 @@ func getChannelForGroup(group string, model string, retry int, userId int) (*Cha
 		abilities = nil
 		err = channelQuery.Order("weight DESC").Find(&abilities).Error
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		if len(abilities) == 0 {
 			continue
@@ -204,6 +205,8 @@ func getChannelForGroup(group string, model string, retry int, userId int) (*Cha
 		if userId > 0 {
 			abilities = filterAbilitiesByUserLimit(abilities, userId)
 		}
+		var probeChannelId int
+		abilities, probeChannelId = filterAbilitiesByBreaker(abilities)
 		if len(abilities) == 0 {
 			continue
 		}
@@ -229,7 +232,7 @@ func getChannelForGroup(group string, model string, retry int, userId int) (*Cha
 
 			err = DB.First(&channel, "id = ?", channel.Id).Error
 			if err != nil {
-				return nil, err
+				return nil, false, err
 			}
 
 			// Create binding if max_users is enabled (atomic check-and-bind)
@@ -243,12 +246,31 @@ func getChannelForGroup(group string, model string, retry int, userId int) (*Cha
 				}
 			}
 
-			return &channel, nil
+			return &channel, channel.Id == probeChannelId, nil
 		}
 		// All candidates at this priority exhausted, try next priority level
 	}
 
-	return nil, nil
+	return nil, false, nil
+}
+
+// filterAbilitiesByBreaker skips channels whose circuit breaker is open.
+// A half-open channel that wins a probe slot takes this request exclusively, and its id is returned.
+func filterAbilitiesByBreaker(abilities []Ability) ([]Ability, int) {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return abilities, 0
+	}
+	available := make([]Ability, 0, len(abilities))
+	for _, ability := range abilities {
+		allowed, probe := ChannelBreakerAllow(ability.ChannelId, ChannelBreakerAllKeys)
+		if probe {
+			return []Ability{ability}, ability.ChannelId
+		}
+		if allowed {
+			available = append(available, ability)
+		}
+	}
+	return available, 0
 }
 
 // filterAbilitiesByUserLimit filters abilities to exclude channels that have reached their user limit.
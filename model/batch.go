@@ -0,0 +1,314 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"gorm.io/gorm"
+)
+
+// Batch OpenAI 兼容的批处理任务，逐行通过中继管道执行并按行计费
+type Batch struct {
+	Id               int    `json:"id"`
+	BatchId          string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	TokenId          int    `json:"token_id" gorm:"index"`
+	Endpoint         string `json:"endpoint" gorm:"type:varchar(64)"`
+	InputFileId      string `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string `json:"output_file_id" gorm:"type:varchar(64)"`
+	ErrorFileId      string `json:"error_file_id" gorm:"type:varchar(64)"`
+	CompletionWindow string `json:"completion_window" gorm:"type:varchar(16)"`
+	Status           string `json:"status" gorm:"type:varchar(20);index"`
+	TotalCount       int    `json:"total_count"`
+	CompletedCount   int    `json:"completed_count"`
+	FailedCount      int    `json:"failed_count"`
+	// SkippedCount 因取消或过期而未执行的请求数（已计入 FailedCount）
+	SkippedCount int    `json:"skipped_count"`
+	Metadata     string `json:"metadata" gorm:"type:text"`
+	Errors       string `json:"errors" gorm:"type:text"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+	InProgressAt int64  `json:"in_progress_at" gorm:"bigint"`
+	ExpiresAt    int64  `json:"expires_at" gorm:"bigint"`
+	FinalizingAt int64  `json:"finalizing_at" gorm:"bigint"`
+	CompletedAt  int64  `json:"completed_at" gorm:"bigint"`
+	FailedAt     int64  `json:"failed_at" gorm:"bigint"`
+	ExpiredAt    int64  `json:"expired_at" gorm:"bigint"`
+	CancellingAt int64  `json:"cancelling_at" gorm:"bigint"`
+	CancelledAt  int64  `json:"cancelled_at" gorm:"bigint"`
+	// 以下字段仅供后台处理使用，禁止返回给用户
+	ClientIp string `json:"-" gorm:"type:varchar(64)"`
+	NextLine int    `json:"-"`
+}
+
+// BatchResult 批处理单个请求行的输出，处理过程中逐行追加，结束时汇总为输出/错误文件后删除
+type BatchResult struct {
+	Id      int    `json:"id"`
+	BatchId int    `json:"batch_id" gorm:"index"`
+	Line    int    `json:"line"`
+	Failed  bool   `json:"failed"`
+	Data    []byte `json:"-"`
+}
+
+var (
+	ErrBatchNotCancellable = errors.New("batch cannot be cancelled in its current status")
+	ErrBatchNotEnded       = errors.New("batch has not finished processing")
+)
+
+var batchProcessingStatuses = []string{
+	dto.BatchStatusValidating,
+	dto.BatchStatusInProgress,
+	dto.BatchStatusFinalizing,
+	dto.BatchStatusCancelling,
+}
+
+var batchEndedStatuses = []string{
+	dto.BatchStatusCompleted,
+	dto.BatchStatusFailed,
+	dto.BatchStatusExpired,
+	dto.BatchStatusCancelled,
+}
+
+func (b *Batch) IsEnded() bool {
+	return common.StringsContains(batchEndedStatuses, b.Status)
+}
+
+func optionalTimestamp(ts int64) *int64 {
+	if ts == 0 {
+		return nil
+	}
+	return common.GetPointer(ts)
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return common.GetPointer(s)
+}
+
+func (b *Batch) ToOpenAIBatch() *dto.OpenAIBatch {
+	batch := &dto.OpenAIBatch{
+		ID:               b.BatchId,
+		Object:           "batch",
+		Endpoint:         b.Endpoint,
+		InputFileID:      b.InputFileId,
+		CompletionWindow: b.CompletionWindow,
+		Status:           b.Status,
+		OutputFileID:     optionalString(b.OutputFileId),
+		ErrorFileID:      optionalString(b.ErrorFileId),
+		CreatedAt:        b.CreatedAt,
+		InProgressAt:     optionalTimestamp(b.InProgressAt),
+		ExpiresAt:        optionalTimestamp(b.ExpiresAt),
+		FinalizingAt:     optionalTimestamp(b.FinalizingAt),
+		CompletedAt:      optionalTimestamp(b.CompletedAt),
+		FailedAt:         optionalTimestamp(b.FailedAt),
+		ExpiredAt:        optionalTimestamp(b.ExpiredAt),
+		CancellingAt:     optionalTimestamp(b.CancellingAt),
+		CancelledAt:      optionalTimestamp(b.CancelledAt),
+		RequestCounts: dto.OpenAIBatchRequestCounts{
+			Total:     b.TotalCount,
+			Completed: b.CompletedCount,
+			Failed:    b.FailedCount,
+		},
+	}
+	if b.Metadata != "" {
+		_ = common.UnmarshalJsonStr(b.Metadata, &batch.Metadata)
+	}
+	if b.Errors != "" {
+		var errs []dto.OpenAIBatchError
+		if err := common.UnmarshalJsonStr(b.Errors, &errs); err == nil && len(errs) > 0 {
+			batch.Errors = &dto.OpenAIBatchErrors{Object: "list", Data: errs}
+		}
+	}
+	return batch
+}
+
+func rfc3339(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
+
+func optionalRFC3339(ts int64) *string {
+	if ts == 0 {
+		return nil
+	}
+	return common.GetPointer(rfc3339(ts))
+}
+
+// ToClaudeMessageBatch 转换为 Anthropic Message Batches 格式，resultsURL 仅在结束后返回
+func (b *Batch) ToClaudeMessageBatch(resultsURL string) *dto.ClaudeMessageBatch {
+	batch := &dto.ClaudeMessageBatch{
+		ID:                b.BatchId,
+		Type:              "message_batch",
+		ProcessingStatus:  dto.ClaudeBatchProcessingStatusInProgress,
+		CreatedAt:         rfc3339(b.CreatedAt),
+		ExpiresAt:         rfc3339(b.ExpiresAt),
+		CancelInitiatedAt: optionalRFC3339(b.CancellingAt),
+		RequestCounts: dto.ClaudeMessageBatchRequestCounts{
+			Succeeded: b.CompletedCount,
+			Errored:   b.FailedCount,
+		},
+	}
+	switch b.Status {
+	case dto.BatchStatusCancelling:
+		batch.ProcessingStatus = dto.ClaudeBatchProcessingStatusCanceling
+	case dto.BatchStatusCancelled:
+		batch.RequestCounts.Canceled = b.SkippedCount
+	case dto.BatchStatusExpired:
+		batch.RequestCounts.Expired = b.SkippedCount
+	}
+	batch.RequestCounts.Errored -= batch.RequestCounts.Canceled + batch.RequestCounts.Expired
+	if b.IsEnded() {
+		batch.ProcessingStatus = dto.ClaudeBatchProcessingStatusEnded
+		batch.EndedAt = optionalRFC3339(max(b.CompletedAt, b.FailedAt, b.CancelledAt, b.ExpiredAt))
+		batch.ResultsURL = optionalString(resultsURL)
+	} else {
+		batch.RequestCounts.Processing = max(b.TotalCount-b.CompletedCount-b.FailedCount, 0)
+	}
+	return batch
+}
+
+func (b *Batch) Insert() error {
+	if b.CreatedAt == 0 {
+		b.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(b).Error
+}
+
+// SaveProgress 持久化处理进度（游标、计数），并追加本轮产生的请求行输出
+func (b *Batch) SaveProgress(results []*BatchResult) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if len(results) > 0 {
+			if err := tx.Create(&results).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&Batch{}).Where("id = ?", b.Id).Updates(map[string]interface{}{
+			"next_line":       b.NextLine,
+			"total_count":     b.TotalCount,
+			"completed_count": b.CompletedCount,
+			"failed_count":    b.FailedCount,
+		}).Error
+	})
+}
+
+// GetBatchResults 按请求行顺序获取已保存的输出
+func GetBatchResults(batchId int) ([]*BatchResult, error) {
+	var results []*BatchResult
+	err := DB.Where("batch_id = ?", batchId).Order("line asc, id asc").Find(&results).Error
+	return results, err
+}
+
+func DeleteBatchResults(batchId int) error {
+	return DB.Where("batch_id = ?", batchId).Delete(&BatchResult{}).Error
+}
+
+// UpdateStatus 以 CAS 方式更新状态，避免覆盖并发的取消操作
+func (b *Batch) UpdateStatus(fromStatus string, fields map[string]interface{}) (bool, error) {
+	result := DB.Model(&Batch{}).Where("id = ? AND status = ?", b.Id, fromStatus).Updates(fields)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func GetBatchStatus(id int) (string, error) {
+	var status string
+	err := DB.Model(&Batch{}).Where("id = ?", id).Select("status").Scan(&status).Error
+	return status, err
+}
+
+func GetUserBatchById(userId int, batchId string) (*Batch, error) {
+	if batchId == "" {
+		return nil, errors.New("batch id is empty")
+	}
+	batch := &Batch{}
+	err := DB.Where("batch_id = ? AND user_id = ?", batchId, userId).First(batch).Error
+	return batch, err
+}
+
+// GetUserBatches 按创建时间倒序分页，仅返回 endpoints 内的批处理。
+// after 为上一页最后一个批处理 id，before 为下一页第一个批处理 id（游标，二选一）
+func GetUserBatches(userId int, endpoints []string, after string, before string, limit int) ([]*Batch, error) {
+	var batches []*Batch
+	query := DB.Where("user_id = ? AND endpoint IN ?", userId, endpoints)
+	order := "id desc"
+	if after != "" {
+		cursor, err := GetUserBatchById(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id < ?", cursor.Id)
+	} else if before != "" {
+		cursor, err := GetUserBatchById(userId, before)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id > ?", cursor.Id)
+		order = "id asc"
+	}
+	if err := query.Order(order).Limit(limit).Find(&batches).Error; err != nil {
+		return nil, err
+	}
+	if order == "id asc" {
+		slices.Reverse(batches)
+	}
+	return batches, nil
+}
+
+// GetPendingBatches 获取 id 大于 afterId 的需要后台处理的批处理任务
+func GetPendingBatches(afterId int, limit int) ([]*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("status IN ? AND id > ?", batchProcessingStatuses, afterId).Order("id asc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// CancelUserBatch 将未结束的批处理标记为 cancelling，由后台任务完成收尾
+func CancelUserBatch(userId int, batchId string) (*Batch, error) {
+	batch, err := GetUserBatchById(userId, batchId)
+	if err != nil {
+		return nil, err
+	}
+	now := common.GetTimestamp()
+	result := DB.Model(&Batch{}).
+		Where("id = ? AND status IN ?", batch.Id, []string{dto.BatchStatusValidating, dto.BatchStatusInProgress}).
+		Updates(map[string]interface{}{"status": dto.BatchStatusCancelling, "cancelling_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 && batch.Status != dto.BatchStatusCancelling {
+		return nil, ErrBatchNotCancellable
+	}
+	return GetUserBatchById(userId, batchId)
+}
+
+// DeleteUserBatch 删除已结束的批处理及其输入、输出文件
+func DeleteUserBatch(userId int, batchId string) error {
+	batch, err := GetUserBatchById(userId, batchId)
+	if err != nil {
+		return err
+	}
+	if !batch.IsEnded() {
+		return ErrBatchNotEnded
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		fileIds := make([]string, 0, 3)
+		for _, id := range []string{batch.InputFileId, batch.OutputFileId, batch.ErrorFileId} {
+			if id != "" {
+				fileIds = append(fileIds, id)
+			}
+		}
+		if len(fileIds) > 0 {
+			if err := tx.Where("user_id = ? AND file_id IN ?", userId, fileIds).Delete(&File{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("batch_id = ?", batch.Id).Delete(&BatchResult{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Batch{}, batch.Id).Error
+	})
+}
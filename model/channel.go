@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"math/rand"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/samber/lo"
@@ -70,6 +72,11 @@ type ChannelInfo struct {
 	MultiKeyDisabledTime   map[int]int64         `json:"multi_key_disabled_time,omitempty"`   // key禁用时间列表，key index -> time
 	MultiKeyPollingIndex   int                   `json:"multi_key_polling_index"`             // 多Key模式下轮询的key索引
 	MultiKeyMode           constant.MultiKeyMode `json:"multi_key_mode"`
+	MultiKeyWeights        map[int]int           `json:"multi_key_weights,omitempty"`       // 加权模式下每个key的权重，缺省为1
+	MultiKeyUsageMetric    string                `json:"multi_key_usage_metric,omitempty"`  // 最少使用模式的统计口径：requests / tokens
+	MultiKeyQuotaLimits    map[int]int64         `json:"multi_key_quota_limits,omitempty"`  // 额度感知模式下每个key在周期内的额度上限，缺省不限
+	MultiKeyQuotaPeriod    string                `json:"multi_key_quota_period,omitempty"`  // 额度周期：daily / weekly / monthly，默认 monthly
+	MultiKeyReenableTime   map[int]int64         `json:"multi_key_reenable_time,omitempty"` // 额度用尽被临时禁用的key的自动恢复时间
 }
 
 // Value implements driver.Valuer interface
@@ -108,16 +115,22 @@ func (channel *Channel) GetKeys() []string {
 }
 
 func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
+	key, index, _, err := channel.SelectNextEnabledKey()
+	return key, index, err
+}
+
+// SelectNextEnabledKey 与 GetNextEnabledKey 相同，另返回所选密钥是否为半开熔断器的探测请求
+func (channel *Channel) SelectNextEnabledKey() (string, int, bool, *types.NewAPIError) {
 	// If not in multi-key mode, return the original key string directly.
 	if !channel.ChannelInfo.IsMultiKey {
-		return channel.Key, 0, nil
+		return channel.Key, 0, false, nil
 	}
 
 	// Obtain all keys (split by \n)
 	keys := channel.GetKeys()
 	if len(keys) == 0 {
 		// No keys available, return error, should disable the channel
-		return "", 0, types.NewError(errors.New("no keys available"), types.ErrorCodeChannelNoAvailableKey)
+		return "", 0, false, types.NewError(errors.New("no keys available"), types.ErrorCodeChannelNoAvailableKey)
 	}
 
 	lock := GetChannelPollingLock(channel.Id)
@@ -147,20 +160,54 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	// properly handle a channel with no available keys (e.g. mark channel disabled).
 	// Returning the first key here caused requests to keep using an already-disabled key.
 	if len(enabledIdx) == 0 {
-		return "", 0, types.NewError(errors.New("no enabled keys"), types.ErrorCodeChannelNoAvailableKey)
+		return "", 0, false, types.NewError(errors.New("no enabled keys"), types.ErrorCodeChannelNoAvailableKey)
+	}
+
+	// Skip keys whose circuit breaker is open; a half-open key that wins a probe slot takes this request.
+	// If every key is open, fall back to all enabled keys and let the channel-level breaker decide.
+	if operation_setting.GetCircuitBreakerSetting().Enabled {
+		allowedIdx := make([]int, 0, len(enabledIdx))
+		for _, idx := range enabledIdx {
+			allowed, probe := ChannelBreakerAllow(channel.Id, idx)
+			if probe {
+				return keys[idx], idx, true, nil
+			}
+			if allowed {
+				allowedIdx = append(allowedIdx, idx)
+			}
+		}
+		if len(allowedIdx) > 0 {
+			enabledIdx = allowedIdx
+		}
+	}
+	// Skip keys that are cooling down or have reached their upstream limits
+	if availableIdx := channel.filterUpstreamAvailableKeys(enabledIdx); len(availableIdx) > 0 {
+		enabledIdx = availableIdx
+	}
+	isSelectable := func(idx int) bool {
+		return slices.Contains(enabledIdx, idx)
 	}
 
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
 		// Randomly pick one enabled key
 		selectedIdx := enabledIdx[rand.Intn(len(enabledIdx))]
-		return keys[selectedIdx], selectedIdx, nil
+		return keys[selectedIdx], selectedIdx, false, nil
+	case constant.MultiKeyModeWeighted:
+		selectedIdx := channel.selectWeightedKey(enabledIdx)
+		return keys[selectedIdx], selectedIdx, false, nil
+	case constant.MultiKeyModeLeastUsed:
+		selectedIdx := channel.selectLeastUsedKey(enabledIdx)
+		return keys[selectedIdx], selectedIdx, false, nil
+	case constant.MultiKeyModeQuotaAware:
+		selectedIdx := channel.selectQuotaAwareKey(enabledIdx)
+		return keys[selectedIdx], selectedIdx, false, nil
 	case constant.MultiKeyModePolling:
 		// Use channel-specific lock to ensure thread-safe polling
 
 		channelInfo, err := CacheGetChannelInfo(channel.Id)
 		if err != nil {
-			return "", 0, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
+			return "", 0, false, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 		}
 		//println("before polling index:", channel.ChannelInfo.MultiKeyPollingIndex)
 		defer func() {
@@ -180,18 +227,33 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}
 		for i := 0; i < len(keys); i++ {
 			idx := (start + i) % len(keys)
-			if getStatus(idx) == common.ChannelStatusEnabled {
+			if isSelectable(idx) {
 				// update polling index for next call (point to the next position)
 				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % len(keys)
-				return keys[idx], idx, nil
+				return keys[idx], idx, false, nil
 			}
 		}
 		// Fallback – should not happen, but return first enabled key
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return keys[enabledIdx[0]], enabledIdx[0], false, nil
 	default:
 		// Unknown mode, default to first enabled key (or original key string)
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return keys[enabledIdx[0]], enabledIdx[0], false, nil
+	}
+}
+
+// GetEnabledKeyByIndex 返回多 key 渠道指定下标的 key，下标越界或 key 已禁用时返回 false
+func (channel *Channel) GetEnabledKeyByIndex(idx int) (string, bool) {
+	if !channel.ChannelInfo.IsMultiKey {
+		return channel.Key, true
+	}
+	keys := channel.GetKeys()
+	if idx < 0 || idx >= len(keys) {
+		return "", false
+	}
+	if status, ok := channel.ChannelInfo.MultiKeyStatusList[idx]; ok && status != common.ChannelStatusEnabled {
+		return "", false
 	}
+	return keys[idx], true
 }
 
 func (channel *Channel) SaveChannelInfo() error {
@@ -599,6 +661,10 @@ func (channel *Channel) Delete() error {
 	CacheUnbindAllUsers(channel.Id)
 	// Clean up session bindings
 	CacheUnbindAllSessions(channel.Id)
+	// Clean up per-key usage counters
+	if err = DeleteChannelKeyUsages(channel.Id); err != nil {
+		common.SysError(fmt.Sprintf("failed to delete key usage of channel #%d: %v", channel.Id, err))
+	}
 	return nil
 }
 
@@ -928,6 +994,17 @@ func (channel *Channel) ValidateSettings() error {
 				}
 			}
 		}
+		if channelParams.HasCustomTLS() {
+			if _, err := common.BuildClientTLSConfig(channelParams.TLSClientCert, channelParams.TLSClientKey, channelParams.TLSCACert); err != nil {
+				return fmt.Errorf("invalid tls settings: %v", err)
+			}
+		}
+		if err := channelParams.RequestSigning.Validate(); err != nil {
+			return err
+		}
+		if err := channelParams.UpstreamLimit.Validate(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -954,6 +1031,78 @@ func (channel *Channel) SetSetting(setting dto.ChannelSettings) {
 	channel.Setting = common.GetPointer[string](string(settingBytes))
 }
 
+// ClearSettingSecrets 移除渠道设置中的 mTLS 私钥与请求签名密钥。
+// 与 key 一样，这些字段只能通过需要安全验证的渠道密钥接口读取。
+func (channel *Channel) ClearSettingSecrets() {
+	if channel.Setting == nil || *channel.Setting == "" {
+		return
+	}
+	var setting map[string]any
+	if err := common.UnmarshalJsonStr(*channel.Setting, &setting); err != nil {
+		return
+	}
+	changed := false
+	if _, ok := setting["tls_client_key"]; ok {
+		delete(setting, "tls_client_key")
+		changed = true
+	}
+	if signing, ok := setting["request_signing"].(map[string]any); ok {
+		if _, ok := signing["secret"]; ok {
+			delete(signing, "secret")
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	settingBytes, err := common.Marshal(setting)
+	if err != nil {
+		return
+	}
+	channel.Setting = common.GetPointer[string](string(settingBytes))
+}
+
+// KeepSettingSecrets 更新渠道时，提交的设置中未填写的 mTLS 私钥与请求签名密钥沿用 origin 中的值。
+// 清空客户端证书或移除请求签名配置时，对应的密钥随之删除。
+func (channel *Channel) KeepSettingSecrets(origin *Channel) {
+	if channel.Setting == nil || *channel.Setting == "" {
+		return
+	}
+	originSetting := origin.GetSetting()
+	originSecret := ""
+	if originSetting.RequestSigning != nil {
+		originSecret = originSetting.RequestSigning.Secret
+	}
+	if originSetting.TLSClientKey == "" && originSecret == "" {
+		return
+	}
+	var setting map[string]any
+	if err := common.UnmarshalJsonStr(*channel.Setting, &setting); err != nil {
+		return
+	}
+	changed := false
+	if key, _ := setting["tls_client_key"].(string); key == "" && originSetting.TLSClientKey != "" {
+		if cert, _ := setting["tls_client_cert"].(string); cert != "" {
+			setting["tls_client_key"] = originSetting.TLSClientKey
+			changed = true
+		}
+	}
+	if signing, ok := setting["request_signing"].(map[string]any); ok && originSecret != "" {
+		if secret, _ := signing["secret"].(string); secret == "" {
+			signing["secret"] = originSecret
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	settingBytes, err := common.Marshal(setting)
+	if err != nil {
+		return
+	}
+	channel.Setting = common.GetPointer[string](string(settingBytes))
+}
+
 func (channel *Channel) GetOtherSettings() dto.ChannelOtherSettings {
 	setting := dto.ChannelOtherSettings{}
 	if channel.OtherSettings != "" {
@@ -0,0 +1,361 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	BreakerStateClosed   = "closed"
+	BreakerStateOpen     = "open"
+	BreakerStateHalfOpen = "half_open"
+)
+
+// ChannelBreakerAllKeys 渠道级熔断器的密钥索引；多密钥渠道的每个密钥另有独立的熔断器
+const ChannelBreakerAllKeys = -1
+
+const (
+	breakerBucketCount = 6
+	// breakerRemoteSyncInterval 多节点部署时从 Redis 同步熔断状态的最小间隔
+	breakerRemoteSyncInterval = 2 * time.Second
+	breakerMinRemoteTTL       = 10 * time.Minute
+)
+
+type breakerBucket struct {
+	start    int64
+	total    int
+	failures int
+}
+
+type channelBreaker struct {
+	mu        sync.Mutex
+	channelId int
+	keyIndex  int
+	buckets   [breakerBucketCount]breakerBucket
+	open      bool
+	openedAt  time.Time
+	// lastProbeAt 半开状态下最近一次放行探测请求的时间（仅内存模式使用）
+	lastProbeAt    time.Time
+	remoteSyncedAt time.Time
+	lastError      string
+}
+
+// ChannelBreakerStatus 熔断器状态快照，用于管理接口展示
+type ChannelBreakerStatus struct {
+	ChannelId int     `json:"channel_id"`
+	KeyIndex  int     `json:"key_index"`
+	State     string  `json:"state"`
+	Requests  int     `json:"requests"`
+	Failures  int     `json:"failures"`
+	ErrorRate float64 `json:"error_rate"`
+	OpenedAt  int64   `json:"opened_at,omitempty"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+var channelBreakers sync.Map // map[string]*channelBreaker
+
+func channelBreakerKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("%d:%d", channelId, keyIndex)
+}
+
+func channelBreakerRedisKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("channel_breaker:%d:%d", channelId, keyIndex)
+}
+
+func channelBreakerProbeRedisKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("channel_breaker_probe:%d:%d", channelId, keyIndex)
+}
+
+func getChannelBreaker(channelId int, keyIndex int) *channelBreaker {
+	key := channelBreakerKey(channelId, keyIndex)
+	if b, ok := channelBreakers.Load(key); ok {
+		return b.(*channelBreaker)
+	}
+	b, _ := channelBreakers.LoadOrStore(key, &channelBreaker{channelId: channelId, keyIndex: keyIndex})
+	return b.(*channelBreaker)
+}
+
+func breakerBucketSeconds(setting *operation_setting.CircuitBreakerSetting) int64 {
+	return max(int64(setting.WindowSeconds)/breakerBucketCount, 1)
+}
+
+// stateLocked 根据熔断时间推导当前状态，调用方需持有锁
+func (b *channelBreaker) stateLocked(now time.Time, setting *operation_setting.CircuitBreakerSetting) string {
+	if !b.open {
+		return BreakerStateClosed
+	}
+	if now.Before(b.openedAt.Add(time.Duration(setting.OpenSeconds) * time.Second)) {
+		return BreakerStateOpen
+	}
+	return BreakerStateHalfOpen
+}
+
+func (b *channelBreaker) countsLocked(now time.Time, setting *operation_setting.CircuitBreakerSetting) (int, int) {
+	bucketSeconds := breakerBucketSeconds(setting)
+	first := now.Unix()/bucketSeconds - breakerBucketCount + 1
+	total, failures := 0, 0
+	for _, bucket := range b.buckets {
+		if bucket.start >= first {
+			total += bucket.total
+			failures += bucket.failures
+		}
+	}
+	return total, failures
+}
+
+func (b *channelBreaker) resetLocked() {
+	b.open = false
+	b.openedAt = time.Time{}
+	b.buckets = [breakerBucketCount]breakerBucket{}
+}
+
+func (b *channelBreaker) remoteTTL(setting *operation_setting.CircuitBreakerSetting) time.Duration {
+	return max(time.Duration(setting.OpenSeconds)*10*time.Second, breakerMinRemoteTTL)
+}
+
+// syncRemote 从 Redis 同步其他节点写入的熔断状态：键存在即为熔断，键不存在即为恢复
+func (b *channelBreaker) syncRemote(now time.Time) {
+	if !common.RedisEnabled {
+		return
+	}
+	b.mu.Lock()
+	if now.Sub(b.remoteSyncedAt) < breakerRemoteSyncInterval {
+		b.mu.Unlock()
+		return
+	}
+	b.remoteSyncedAt = now
+	b.mu.Unlock()
+
+	value, err := common.RedisGet(channelBreakerRedisKey(b.channelId, b.keyIndex))
+	if err != nil && !errors.Is(err, redis.Nil) {
+		common.SysError(fmt.Sprintf("failed to sync circuit breaker of channel #%d: %v", b.channelId, err))
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if errors.Is(err, redis.Nil) {
+		if b.open {
+			b.resetLocked()
+		}
+		return
+	}
+	openedAt, parseErr := strconv.ParseInt(value, 10, 64)
+	if parseErr != nil {
+		return
+	}
+	if !b.open || b.openedAt.Unix() < openedAt {
+		b.open = true
+		b.openedAt = time.Unix(openedAt, 0)
+	}
+}
+
+func (b *channelBreaker) publishOpen(setting *operation_setting.CircuitBreakerSetting, openedAt time.Time) {
+	if !common.RedisEnabled {
+		return
+	}
+	key := channelBreakerRedisKey(b.channelId, b.keyIndex)
+	if err := common.RedisSet(key, strconv.FormatInt(openedAt.Unix(), 10), b.remoteTTL(setting)); err != nil {
+		common.SysError(fmt.Sprintf("failed to publish circuit breaker of channel #%d: %v", b.channelId, err))
+	}
+}
+
+func (b *channelBreaker) publishClosed() {
+	if !common.RedisEnabled {
+		return
+	}
+	if err := common.RedisDel(channelBreakerRedisKey(b.channelId, b.keyIndex)); err != nil {
+		common.SysError(fmt.Sprintf("failed to publish circuit breaker of channel #%d: %v", b.channelId, err))
+	}
+}
+
+// tryProbe 半开状态下按 ProbeIntervalSeconds 放行一个探测请求，Redis 可用时多节点共享探测名额
+func (b *channelBreaker) tryProbe(now time.Time, setting *operation_setting.CircuitBreakerSetting) bool {
+	interval := time.Duration(max(setting.ProbeIntervalSeconds, 1)) * time.Second
+	if common.RedisEnabled {
+		ok, err := common.RDB.SetNX(context.Background(), channelBreakerProbeRedisKey(b.channelId, b.keyIndex), "1", interval).Result()
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to acquire circuit breaker probe of channel #%d: %v", b.channelId, err))
+			return false
+		}
+		return ok
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Sub(b.lastProbeAt) < interval {
+		return false
+	}
+	b.lastProbeAt = now
+	return true
+}
+
+// ChannelBreakerAllow 判断渠道（或密钥）是否可以接收请求。
+// probe 为 true 表示该请求是半开状态下的探测请求，调用方应优先将请求路由到该渠道。
+func ChannelBreakerAllow(channelId int, keyIndex int) (allowed bool, probe bool) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	if !setting.Enabled {
+		return true, false
+	}
+	now := time.Now()
+	b := getChannelBreaker(channelId, keyIndex)
+	b.syncRemote(now)
+
+	b.mu.Lock()
+	state := b.stateLocked(now, setting)
+	b.mu.Unlock()
+	switch state {
+	case BreakerStateClosed:
+		return true, false
+	case BreakerStateHalfOpen:
+		if b.tryProbe(now, setting) {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// RecordChannelBreakerResult 记录一次真实请求的结果并驱动状态迁移：
+// 关闭状态下窗口内失败率达到阈值则熔断；半开状态下探测成功则恢复，失败则重新熔断。
+// probe 表示该请求是否为 ChannelBreakerAllow 放行的探测请求，半开状态下只有探测请求的结果会驱动迁移，
+// 熔断前已发出、熔断后才返回的请求结果只计入统计。
+func RecordChannelBreakerResult(channelId int, keyIndex int, failed bool, probe bool, errMsg string) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	if !setting.Enabled {
+		return
+	}
+	now := time.Now()
+	b := getChannelBreaker(channelId, keyIndex)
+
+	b.mu.Lock()
+	bucketStart := now.Unix() / breakerBucketSeconds(setting)
+	bucket := &b.buckets[bucketStart%breakerBucketCount]
+	if bucket.start != bucketStart {
+		*bucket = breakerBucket{start: bucketStart}
+	}
+	bucket.total++
+	if failed {
+		bucket.failures++
+		b.lastError = errMsg
+	}
+
+	var opened, closed bool
+	switch b.stateLocked(now, setting) {
+	case BreakerStateHalfOpen:
+		if !probe {
+			break
+		}
+		if failed {
+			b.openedAt = now
+			opened = true
+		} else {
+			b.resetLocked()
+			closed = true
+		}
+	case BreakerStateClosed:
+		total, failures := b.countsLocked(now, setting)
+		if failed && total >= max(setting.MinRequests, 1) &&
+			float64(failures)*100 >= setting.ErrorRatePercent*float64(total) {
+			b.open = true
+			b.openedAt = now
+			opened = true
+		}
+	}
+	b.mu.Unlock()
+
+	if opened {
+		common.SysLog(fmt.Sprintf("circuit breaker of channel #%d (key %d) opened: %s", channelId, keyIndex, errMsg))
+		b.publishOpen(setting, now)
+	} else if closed {
+		common.SysLog(fmt.Sprintf("circuit breaker of channel #%d (key %d) closed after successful probe", channelId, keyIndex))
+		b.publishClosed()
+	}
+}
+
+// ResetChannelBreakers 手动恢复渠道及其所有密钥的熔断器
+func ResetChannelBreakers(channelId int) {
+	channelBreakers.Range(func(_, value any) bool {
+		b := value.(*channelBreaker)
+		if b.channelId != channelId {
+			return true
+		}
+		b.mu.Lock()
+		b.resetLocked()
+		b.lastError = ""
+		b.mu.Unlock()
+		b.publishClosed()
+		return true
+	})
+}
+
+// GetChannelBreakerStatuses 返回渠道及其密钥的熔断器状态，channelId 为 0 时返回所有非关闭状态的熔断器
+func GetChannelBreakerStatuses(channelId int) []ChannelBreakerStatus {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	now := time.Now()
+	if channelId != 0 {
+		// 确保渠道级熔断器存在，以便从 Redis 同步其他节点的状态
+		getChannelBreaker(channelId, ChannelBreakerAllKeys)
+	}
+	statuses := make([]ChannelBreakerStatus, 0)
+	channelBreakers.Range(func(_, value any) bool {
+		b := value.(*channelBreaker)
+		if channelId != 0 && b.channelId != channelId {
+			return true
+		}
+		b.syncRemote(now)
+		b.mu.Lock()
+		status := ChannelBreakerStatus{
+			ChannelId: b.channelId,
+			KeyIndex:  b.keyIndex,
+			State:     b.stateLocked(now, setting),
+			LastError: b.lastError,
+		}
+		status.Requests, status.Failures = b.countsLocked(now, setting)
+		if b.open {
+			status.OpenedAt = b.openedAt.Unix()
+		}
+		b.mu.Unlock()
+		if status.Requests > 0 {
+			status.ErrorRate = float64(status.Failures) / float64(status.Requests)
+		}
+		if channelId == 0 && status.State == BreakerStateClosed {
+			return true
+		}
+		statuses = append(statuses, status)
+		return true
+	})
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].ChannelId != statuses[j].ChannelId {
+			return statuses[i].ChannelId < statuses[j].ChannelId
+		}
+		return statuses[i].KeyIndex < statuses[j].KeyIndex
+	})
+	return statuses
+}
+
+// filterChannelsByBreaker 跳过熔断中的渠道；若某个半开渠道获得了探测名额，则本次请求只路由到该渠道，并返回其 id
+func filterChannelsByBreaker(channels []*Channel) ([]*Channel, int) {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return channels, 0
+	}
+	available := make([]*Channel, 0, len(channels))
+	for _, ch := range channels {
+		allowed, probe := ChannelBreakerAllow(ch.Id, ChannelBreakerAllKeys)
+		if probe {
+			return []*Channel{ch}, ch.Id
+		}
+		if allowed {
+			available = append(available, ch)
+		}
+	}
+	return available, 0
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelBreakerOpenProbeClose(t *testing.T) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+	setting.Enabled = true
+	setting.MinRequests = 3
+	setting.ErrorRatePercent = 50
+	setting.OpenSeconds = 3600
+	setting.ProbeIntervalSeconds = 3600
+
+	const channelId = 9001
+	t.Cleanup(func() { ResetChannelBreakers(channelId) })
+
+	RecordChannelBreakerResult(channelId, ChannelBreakerAllKeys, false, false, "")
+	RecordChannelBreakerResult(channelId, ChannelBreakerAllKeys, true, false, "upstream 500")
+	allowed, _ := ChannelBreakerAllow(channelId, ChannelBreakerAllKeys)
+	require.True(t, allowed, "below min requests must stay closed")
+
+	RecordChannelBreakerResult(channelId, ChannelBreakerAllKeys, true, false, "upstream 500")
+	allowed, probe := ChannelBreakerAllow(channelId, ChannelBreakerAllKeys)
+	require.False(t, allowed)
+	require.False(t, probe)
+
+	statuses := GetChannelBreakerStatuses(channelId)
+	require.Len(t, statuses, 1)
+	require.Equal(t, BreakerStateOpen, statuses[0].State)
+	require.Equal(t, "upstream 500", statuses[0].LastError)
+
+	// 缩短熔断时间进入半开状态：只放行一个探测请求
+	setting.OpenSeconds = 0
+	allowed, probe = ChannelBreakerAllow(channelId, ChannelBreakerAllKeys)
+	require.True(t, allowed)
+	require.True(t, probe)
+	allowed, _ = ChannelBreakerAllow(channelId, ChannelBreakerAllKeys)
+	require.False(t, allowed, "only one probe per interval")
+
+	// 熔断前发出的请求在半开期间返回，其结果不能驱动迁移
+	RecordChannelBreakerResult(channelId, ChannelBreakerAllKeys, false, false, "")
+	RecordChannelBreakerResult(channelId, ChannelBreakerAllKeys, true, false, "late failure")
+	statuses = GetChannelBreakerStatuses(channelId)
+	require.Len(t, statuses, 1)
+	require.Equal(t, BreakerStateHalfOpen, statuses[0].State)
+
+	RecordChannelBreakerResult(channelId, ChannelBreakerAllKeys, false, true, "")
+	allowed, probe = ChannelBreakerAllow(channelId, ChannelBreakerAllKeys)
+	require.True(t, allowed)
+	require.False(t, probe)
+	require.Empty(t, GetChannelBreakerStatuses(0))
+}
+
+func TestFilterChannelsByBreakerPrefersProbe(t *testing.T) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+	setting.Enabled = true
+	setting.MinRequests = 1
+	setting.OpenSeconds = 0
+	setting.ProbeIntervalSeconds = 3600
+
+	t.Cleanup(func() {
+		ResetChannelBreakers(9101)
+		ResetChannelBreakers(9102)
+	})
+	RecordChannelBreakerResult(9102, ChannelBreakerAllKeys, true, false, "timeout")
+
+	channels := []*Channel{{Id: 9101}, {Id: 9102}}
+	filtered, probeChannelId := filterChannelsByBreaker(channels)
+	require.Len(t, filtered, 1)
+	require.Equal(t, 9102, filtered[0].Id)
+	require.Equal(t, 9102, probeChannelId)
+
+	filtered, probeChannelId = filterChannelsByBreaker(channels)
+	require.Len(t, filtered, 1)
+	require.Equal(t, 9101, filtered[0].Id)
+	require.Zero(t, probeChannelId)
+}
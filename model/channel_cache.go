@@ -117,7 +117,9 @@ func SyncChannelCache(frequency int) {
 	}
 }
 
-func GetRandomSatisfiedChannel(group string, model string, retry int, userId int, sessionId string) (*Channel, error) {
+// hashKey 为一致性哈希策略使用的请求键，其他策略忽略。
+// probe 为 true 表示所选渠道处于半开状态且本次请求获得了探测名额，只有探测请求的结果会驱动熔断器迁移。
+func GetRandomSatisfiedChannel(group string, model string, retry int, userId int, sessionId string, hashKey string) (*Channel, bool, error) {
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
 		return GetChannel(group, model, retry, userId)
@@ -141,7 +143,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 
 	if len(channels) == 0 {
 		channelSyncLock.RUnlock()
-		return nil, nil
+		return nil, false, nil
 	}
 
 	if len(channels) == 1 {
@@ -153,7 +155,15 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 		channelSyncLock.RUnlock()
 
 		if channelErr != nil {
-			return nil, channelErr
+			return nil, false, channelErr
+		}
+
+		allowed, probe := ChannelBreakerAllow(singleChannel.Id, ChannelBreakerAllKeys)
+		if !allowed {
+			return nil, false, nil
+		}
+		if singleChannel.IsUpstreamSaturated() {
+			return nil, false, ErrChannelUpstreamSaturated
 		}
 
 		// Check user limit for single channel (outside lock, safe for Redis I/O)
@@ -162,7 +172,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 			if maxUsers > 0 {
 				expireMinutes := singleChannel.GetUserBindExpireMinutes()
 				if !CacheBindUserIfRoom(singleChannel.Id, userId, maxUsers, expireMinutes) {
-					return nil, nil
+					return nil, false, nil
 				}
 			}
 		}
@@ -171,11 +181,11 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 			maxSessions := singleChannel.GetMaxSessions()
 			if maxSessions > 0 {
 				if !CacheBindSessionIfRoom(singleChannel.Id, sessionId, userId, maxSessions, singleChannel.GetSessionBindExpireMinutes()) {
-					return nil, nil
+					return nil, false, nil
 				}
 			}
 		}
-		return singleChannel, nil
+		return singleChannel, probe, nil
 	}
 
 	// Multiple channels: filter by priority
@@ -185,7 +195,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 			uniquePriorities[int(channel.GetPriority())] = true
 		} else {
 			channelSyncLock.RUnlock()
-			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
+			return nil, false, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
 		}
 	}
 	var sortedUniquePriorities []int
@@ -212,7 +222,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 			}
 		} else {
 			channelSyncLock.RUnlock()
-			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
+			return nil, false, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
 		}
 	}
 
@@ -235,7 +245,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 			if ch, ok := allChannelsFlat[boundChannelId]; ok {
 				// Session appears bound to a candidate — atomically verify and refresh
 				if CacheBindSessionIfRoom(ch.Id, sessionId, userId, ch.GetMaxSessions(), ch.GetSessionBindExpireMinutes()) {
-					return ch, nil
+					return ch, false, nil
 				}
 				// Binding expired or channel full, fall through
 			}
@@ -266,7 +276,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 				if isUserBoundFromData(bindingData, chId, userId, expireMinutes) {
 					// User appears bound — atomically verify and refresh binding
 					if CacheBindUserIfRoom(chId, userId, ch.GetMaxUsers(), expireMinutes) {
-						return ch, nil
+						return ch, false, nil
 					}
 					// Binding expired between preload and atomic check, or channel is full.
 					// Fall through to normal priority-based selection.
@@ -280,6 +290,8 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 	// fall back to the next (lower) priority level.
 	// Note: bindingData may already be preloaded from the binding override check above.
 	var sessionBindingData map[int]map[string]sessionBindingEntry
+	probeChannelId := 0
+	upstreamSaturated := false
 	for pri := startPri; pri < len(sortedUniquePriorities); pri++ {
 		targetChannels = allPriorityChannels[pri]
 
@@ -332,6 +344,19 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 			continue
 		}
 
+		// Skip channels whose circuit breaker is open
+		targetChannels, probeChannelId = filterChannelsByBreaker(targetChannels)
+		if len(targetChannels) == 0 {
+			continue
+		}
+
+		// Skip channels whose upstream concurrency/RPM/TPM limits are saturated or whose keys are cooling down
+		targetChannels = filterChannelsByUpstreamLimit(targetChannels)
+		if len(targetChannels) == 0 {
+			upstreamSaturated = true
+			continue
+		}
+
 		// Among channels with max_users > 0, apply least-bindings load balancing
 		targetChannels = applyLeastBindingsBalance(targetChannels, bindingData)
 
@@ -341,7 +366,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 		// Atomic bind retry loop: select a channel and try to bind.
 		// If bind fails (channel became full due to concurrent bind), remove and retry.
 		for len(targetChannels) > 0 {
-			selected := weightedRandomSelect(targetChannels)
+			selected := selectChannelByStrategy(targetChannels, group, model, hashKey)
 			if selected == nil {
 				break
 			}
@@ -364,12 +389,15 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, userId int
 				}
 			}
 
-			return selected, nil
+			return selected, selected.Id == probeChannelId, nil
 		}
 		// All candidates at this priority exhausted, try next priority level
 	}
 
-	return nil, nil
+	if upstreamSaturated {
+		return nil, false, ErrChannelUpstreamSaturated
+	}
+	return nil, false, nil
 }
 
 // filterChannelsByUserLimit removes channels that have reached their user limit
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+)
+
+// multiKeyUsageWindowKey 最少使用模式下单个密钥的滚动窗口（一分钟）计数器
+func multiKeyUsageWindowKey(metric string, channelId int, keyIndex int) string {
+	return fmt.Sprintf("multi_key_usage:%s:%d:%d", metric, channelId, keyIndex)
+}
+
+func (info *ChannelInfo) usageMetric() string {
+	if info.MultiKeyUsageMetric == constant.MultiKeyUsageMetricTokens {
+		return constant.MultiKeyUsageMetricTokens
+	}
+	return constant.MultiKeyUsageMetricRequests
+}
+
+func (info *ChannelInfo) quotaPeriod() string {
+	switch info.MultiKeyQuotaPeriod {
+	case SubscriptionResetDaily, SubscriptionResetWeekly:
+		return info.MultiKeyQuotaPeriod
+	}
+	return SubscriptionResetMonthly
+}
+
+// multiKeyQuotaPeriodStart 返回 now 所在额度周期的起始时间：自然日、自然周（周一）或自然月
+func multiKeyQuotaPeriodStart(period string, now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case SubscriptionResetDaily:
+		return day
+	case SubscriptionResetWeekly:
+		weekday := int(day.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		return day.AddDate(0, 0, 1-weekday)
+	default:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+}
+
+// multiKeyQuotaNextPeriodStart 返回下一个额度周期的起始时间，即额度用尽的密钥自动恢复的时间
+func multiKeyQuotaNextPeriodStart(period string, now time.Time) time.Time {
+	start := multiKeyQuotaPeriodStart(period, now)
+	switch period {
+	case SubscriptionResetDaily:
+		return start.AddDate(0, 0, 1)
+	case SubscriptionResetWeekly:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// selectWeightedKey 按权重随机选择密钥，未配置权重的密钥权重为 1，权重为 0 的密钥不参与选择（全部为 0 时退化为随机）
+func (channel *Channel) selectWeightedKey(enabledIdx []int) int {
+	totalWeight := 0
+	for _, idx := range enabledIdx {
+		totalWeight += channel.ChannelInfo.keyWeight(idx)
+	}
+	if totalWeight <= 0 {
+		return enabledIdx[rand.Intn(len(enabledIdx))]
+	}
+	r := rand.Intn(totalWeight)
+	for _, idx := range enabledIdx {
+		r -= channel.ChannelInfo.keyWeight(idx)
+		if r < 0 {
+			return idx
+		}
+	}
+	return enabledIdx[len(enabledIdx)-1]
+}
+
+func (info *ChannelInfo) keyWeight(idx int) int {
+	if weight, ok := info.MultiKeyWeights[idx]; ok {
+		return max(weight, 0)
+	}
+	return 1
+}
+
+// selectLeastUsedKey 选择滚动窗口内请求数（或 token 数）最少的密钥，用量相同时随机。
+// 按请求数统计时在选中时计数；按 token 数统计时在记录消费日志时计数。
+func (channel *Channel) selectLeastUsedKey(enabledIdx []int) int {
+	ctx := context.Background()
+	metric := channel.ChannelInfo.usageMetric()
+	var candidates []int
+	var leastUsed int64 = -1
+	for _, idx := range enabledIdx {
+		result, err := limiter.PeekWindow(ctx, multiKeyUsageWindowKey(metric, channel.Id, idx), 0)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to read key usage of channel #%d: %v", channel.Id, err))
+			return enabledIdx[rand.Intn(len(enabledIdx))]
+		}
+		switch {
+		case leastUsed == -1 || result.Used < leastUsed:
+			leastUsed = result.Used
+			candidates = []int{idx}
+		case result.Used == leastUsed:
+			candidates = append(candidates, idx)
+		}
+	}
+	selected := candidates[rand.Intn(len(candidates))]
+	if metric == constant.MultiKeyUsageMetricRequests {
+		if _, err := limiter.ReserveWindow(ctx, multiKeyUsageWindowKey(metric, channel.Id, selected), 0, 1); err != nil {
+			common.SysError(fmt.Sprintf("failed to record key usage of channel #%d: %v", channel.Id, err))
+		}
+	}
+	return selected
+}
+
+// selectQuotaAwareKey 选择本周期额度使用比例最低的密钥，未设置上限的密钥视为比例 0；
+// 已达到上限但尚未被禁用的密钥（例如其他节点刚记账）会被跳过。
+func (channel *Channel) selectQuotaAwareKey(enabledIdx []int) int {
+	periodQuotas := getChannelKeyPeriodQuotas(channel.Id, channel.ChannelInfo.quotaPeriod())
+	var candidates []int
+	lowestRatio := -1.0
+	for _, idx := range enabledIdx {
+		ratio := 0.0
+		if limit := channel.ChannelInfo.MultiKeyQuotaLimits[idx]; limit > 0 {
+			used := periodQuotas[idx]
+			if used >= limit {
+				continue
+			}
+			ratio = float64(used) / float64(limit)
+		}
+		switch {
+		case lowestRatio < 0 || ratio < lowestRatio:
+			lowestRatio = ratio
+			candidates = []int{idx}
+		case ratio == lowestRatio:
+			candidates = append(candidates, idx)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = enabledIdx
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// RemapKeyConfigs 删除密钥后按新下标迁移每个密钥的权重、额度上限与恢复时间，mapping 中不存在的旧下标视为已删除
+func (info *ChannelInfo) RemapKeyConfigs(mapping map[int]int) {
+	info.MultiKeyWeights = remapKeyIndexes(info.MultiKeyWeights, mapping)
+	info.MultiKeyQuotaLimits = remapKeyIndexes(info.MultiKeyQuotaLimits, mapping)
+	info.MultiKeyReenableTime = remapKeyIndexes(info.MultiKeyReenableTime, mapping)
+}
+
+func remapKeyIndexes[T any](values map[int]T, mapping map[int]int) map[int]T {
+	if len(values) == 0 {
+		return values
+	}
+	remapped := make(map[int]T, len(values))
+	for idx, value := range values {
+		if newIdx, ok := mapping[idx]; ok {
+			remapped[newIdx] = value
+		}
+	}
+	return remapped
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectWeightedKeySkipsZeroWeight(t *testing.T) {
+	channel := &Channel{Id: 9401, ChannelInfo: ChannelInfo{
+		IsMultiKey:      true,
+		MultiKeyWeights: map[int]int{0: 0, 2: 5},
+	}}
+	seen := make(map[int]int)
+	for i := 0; i < 200; i++ {
+		seen[channel.selectWeightedKey([]int{0, 1, 2})]++
+	}
+	require.Zero(t, seen[0])
+	require.Greater(t, seen[2], seen[1])
+}
+
+func TestMultiKeyQuotaPeriod(t *testing.T) {
+	now := time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC) // Saturday
+	require.Equal(t, time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), multiKeyQuotaPeriodStart(SubscriptionResetDaily, now))
+	require.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), multiKeyQuotaPeriodStart(SubscriptionResetWeekly, now))
+	require.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), multiKeyQuotaPeriodStart(SubscriptionResetMonthly, now))
+	require.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), multiKeyQuotaNextPeriodStart(SubscriptionResetWeekly, now))
+	require.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), multiKeyQuotaNextPeriodStart(SubscriptionResetMonthly, now))
+}
+
+func TestReenableDueKeys(t *testing.T) {
+	info := ChannelInfo{
+		IsMultiKey:             true,
+		MultiKeyStatusList:     map[int]int{0: common.ChannelStatusAutoDisabled, 1: common.ChannelStatusManuallyDisabled, 2: common.ChannelStatusAutoDisabled},
+		MultiKeyDisabledReason: map[int]string{0: "quota", 1: "manual", 2: "quota"},
+		MultiKeyDisabledTime:   map[int]int64{0: 1, 1: 1, 2: 1},
+		MultiKeyReenableTime:   map[int]int64{0: 100, 1: 100, 2: 300},
+	}
+	require.Equal(t, 1, info.reenableDueKeys(200))
+	require.NotContains(t, info.MultiKeyStatusList, 0)
+	require.Equal(t, common.ChannelStatusManuallyDisabled, info.MultiKeyStatusList[1])
+	require.Equal(t, common.ChannelStatusAutoDisabled, info.MultiKeyStatusList[2])
+	require.Equal(t, map[int]int64{2: 300}, info.MultiKeyReenableTime)
+}
+
+func TestRemapKeyConfigs(t *testing.T) {
+	info := ChannelInfo{
+		MultiKeyWeights:     map[int]int{0: 1, 1: 2, 2: 3},
+		MultiKeyQuotaLimits: map[int]int64{2: 500},
+	}
+	info.RemapKeyConfigs(map[int]int{0: 0, 2: 1})
+	require.Equal(t, map[int]int{0: 1, 1: 3}, info.MultiKeyWeights)
+	require.Equal(t, map[int]int64{1: 500}, info.MultiKeyQuotaLimits)
+	require.Nil(t, info.MultiKeyReenableTime)
+}
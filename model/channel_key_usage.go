@@ -0,0 +1,323 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+
+	"gorm.io/gorm"
+)
+
+// ChannelKeyUsage 多密钥渠道中单个密钥的累计用量，以及当前额度周期内的消耗（额度感知模式使用）
+type ChannelKeyUsage struct {
+	Id           int   `json:"-"`
+	ChannelId    int   `json:"channel_id" gorm:"uniqueIndex:idx_channel_key_usage,priority:1"`
+	KeyIndex     int   `json:"key_index" gorm:"uniqueIndex:idx_channel_key_usage,priority:2"`
+	RequestCount int64 `json:"request_count" gorm:"bigint;default:0"`
+	UsedTokens   int64 `json:"used_tokens" gorm:"bigint;default:0"`
+	UsedQuota    int64 `json:"used_quota" gorm:"bigint;default:0"`
+	PeriodQuota  int64 `json:"period_quota" gorm:"bigint;default:0"`
+	PeriodStart  int64 `json:"period_start" gorm:"bigint;default:0"`
+	UpdatedAt    int64 `json:"updated_at" gorm:"bigint"`
+}
+
+// channelKeyPeriodQuotaCache 额度感知模式选路时使用的本周期用量缓存，记账时用数据库中的最新值刷新
+type channelKeyPeriodQuotaCache struct {
+	mu          sync.Mutex
+	periodStart int64
+	quotas      map[int]int64
+}
+
+var channelKeyPeriodQuotas sync.Map // map[int]*channelKeyPeriodQuotaCache
+
+func loadChannelKeyPeriodQuotaCache(channelId int, periodStart int64) *channelKeyPeriodQuotaCache {
+	if v, ok := channelKeyPeriodQuotas.Load(channelId); ok {
+		cache := v.(*channelKeyPeriodQuotaCache)
+		cache.mu.Lock()
+		if cache.periodStart >= periodStart {
+			cache.mu.Unlock()
+			return cache
+		}
+		cache.mu.Unlock()
+	}
+	cache := &channelKeyPeriodQuotaCache{periodStart: periodStart, quotas: make(map[int]int64)}
+	var usages []ChannelKeyUsage
+	if err := DB.Where("channel_id = ? AND period_start = ?", channelId, periodStart).Find(&usages).Error; err != nil {
+		common.SysError(fmt.Sprintf("failed to load key usage of channel #%d: %v", channelId, err))
+	}
+	for _, usage := range usages {
+		cache.quotas[usage.KeyIndex] = usage.PeriodQuota
+	}
+	channelKeyPeriodQuotas.Store(channelId, cache)
+	return cache
+}
+
+// getChannelKeyPeriodQuotas 返回渠道各密钥在当前额度周期内的消耗
+func getChannelKeyPeriodQuotas(channelId int, period string) map[int]int64 {
+	periodStart := multiKeyQuotaPeriodStart(period, time.Now()).Unix()
+	cache := loadChannelKeyPeriodQuotaCache(channelId, periodStart)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	quotas := make(map[int]int64, len(cache.quotas))
+	for idx, quota := range cache.quotas {
+		quotas[idx] = quota
+	}
+	return quotas
+}
+
+func setChannelKeyPeriodQuota(channelId int, keyIndex int, periodStart int64, quota int64) {
+	cache := loadChannelKeyPeriodQuotaCache(channelId, periodStart)
+	cache.mu.Lock()
+	if cache.periodStart == periodStart {
+		cache.quotas[keyIndex] = quota
+	}
+	cache.mu.Unlock()
+}
+
+// increaseChannelKeyUsage 累加密钥用量并返回本周期内的消耗；进入新周期时周期用量从本次消耗重新计算
+func increaseChannelKeyUsage(channelId int, keyIndex int, tokens int64, quota int64, periodStart int64) (int64, error) {
+	updates := map[string]interface{}{
+		"request_count": gorm.Expr("request_count + ?", 1),
+		"used_tokens":   gorm.Expr("used_tokens + ?", tokens),
+		"used_quota":    gorm.Expr("used_quota + ?", quota),
+		// MySQL 按顺序求值 SET 子句，gorm 按列名排序生成，period_quota 会先于 period_start 更新
+		"period_quota": gorm.Expr("CASE WHEN period_start = ? THEN period_quota + ? ELSE ? END", periodStart, quota, quota),
+		"period_start": periodStart,
+		"updated_at":   common.GetTimestamp(),
+	}
+	query := DB.Model(&ChannelKeyUsage{}).Where("channel_id = ? AND key_index = ?", channelId, keyIndex)
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		usage := &ChannelKeyUsage{
+			ChannelId:    channelId,
+			KeyIndex:     keyIndex,
+			RequestCount: 1,
+			UsedTokens:   tokens,
+			UsedQuota:    quota,
+			PeriodQuota:  quota,
+			PeriodStart:  periodStart,
+			UpdatedAt:    common.GetTimestamp(),
+		}
+		if err := DB.Create(usage).Error; err == nil {
+			return quota, nil
+		}
+		// 并发创建冲突时退回到累加
+		if err := DB.Model(&ChannelKeyUsage{}).Where("channel_id = ? AND key_index = ?", channelId, keyIndex).Updates(updates).Error; err != nil {
+			return 0, err
+		}
+	}
+	var usage ChannelKeyUsage
+	if err := DB.Select("period_quota").Where("channel_id = ? AND key_index = ?", channelId, keyIndex).First(&usage).Error; err != nil {
+		return 0, err
+	}
+	return usage.PeriodQuota, nil
+}
+
+// RecordChannelKeyUsage 在每条消费日志上累加多密钥渠道当前密钥的用量：
+// 最少使用模式（按 token）计入滚动窗口，额度感知模式在本周期额度用尽时临时禁用该密钥直至下个周期。
+func RecordChannelKeyUsage(channelId int, keyIndex int, tokens int, quota int) {
+	channel, err := CacheGetChannel(channelId)
+	if err != nil || channel == nil || !channel.ChannelInfo.IsMultiKey {
+		return
+	}
+	info := channel.ChannelInfo
+	if info.MultiKeyMode == constant.MultiKeyModeLeastUsed && info.usageMetric() == constant.MultiKeyUsageMetricTokens && tokens > 0 {
+		if _, err := limiter.ReserveWindow(context.Background(), multiKeyUsageWindowKey(constant.MultiKeyUsageMetricTokens, channelId, keyIndex), 0, int64(tokens)); err != nil {
+			common.SysError(fmt.Sprintf("failed to record key usage of channel #%d: %v", channelId, err))
+		}
+	}
+
+	now := time.Now()
+	period := info.quotaPeriod()
+	periodStart := multiKeyQuotaPeriodStart(period, now).Unix()
+	periodQuota, err := increaseChannelKeyUsage(channelId, keyIndex, int64(tokens), int64(quota), periodStart)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to record key usage of channel #%d: %v", channelId, err))
+		return
+	}
+	setChannelKeyPeriodQuota(channelId, keyIndex, periodStart, periodQuota)
+
+	limit := info.MultiKeyQuotaLimits[keyIndex]
+	if info.MultiKeyMode != constant.MultiKeyModeQuotaAware || limit <= 0 || periodQuota < limit {
+		return
+	}
+	reenableAt := multiKeyQuotaNextPeriodStart(period, now).Unix()
+	reason := fmt.Sprintf("key quota cap reached: %d/%d in current %s period", periodQuota, limit, period)
+	if err := disableChannelKeyUntil(channelId, keyIndex, reenableAt, reason); err != nil {
+		common.SysError(fmt.Sprintf("failed to disable key %d of channel #%d: %v", keyIndex, channelId, err))
+		return
+	}
+	common.SysLog(fmt.Sprintf("key %d of channel #%d disabled until %s: %s", keyIndex, channelId, time.Unix(reenableAt, 0).Format(time.RFC3339), reason))
+}
+
+// disableChannelKeyUntil 将密钥标记为自动禁用并记录恢复时间，同时更新内存缓存与数据库
+func disableChannelKeyUntil(channelId int, keyIndex int, reenableAt int64, reason string) error {
+	disable := func(info *ChannelInfo) bool {
+		if status, ok := info.MultiKeyStatusList[keyIndex]; ok && status != common.ChannelStatusEnabled {
+			return false
+		}
+		if info.MultiKeyStatusList == nil {
+			info.MultiKeyStatusList = make(map[int]int)
+		}
+		if info.MultiKeyDisabledReason == nil {
+			info.MultiKeyDisabledReason = make(map[int]string)
+		}
+		if info.MultiKeyDisabledTime == nil {
+			info.MultiKeyDisabledTime = make(map[int]int64)
+		}
+		if info.MultiKeyReenableTime == nil {
+			info.MultiKeyReenableTime = make(map[int]int64)
+		}
+		info.MultiKeyStatusList[keyIndex] = common.ChannelStatusAutoDisabled
+		info.MultiKeyDisabledReason[keyIndex] = reason
+		info.MultiKeyDisabledTime[keyIndex] = common.GetTimestamp()
+		info.MultiKeyReenableTime[keyIndex] = reenableAt
+		return true
+	}
+
+	lock := GetChannelPollingLock(channelId)
+	if common.MemoryCacheEnabled {
+		if cached, err := CacheGetChannel(channelId); err == nil && cached != nil {
+			lock.Lock()
+			disable(&cached.ChannelInfo)
+			lock.Unlock()
+		}
+	}
+	channel, err := GetChannelById(channelId, true)
+	if err != nil {
+		return err
+	}
+	lock.Lock()
+	changed := disable(&channel.ChannelInfo)
+	lock.Unlock()
+	if !changed {
+		return nil
+	}
+	return channel.SaveChannelInfo()
+}
+
+// reenableDueKeys 恢复恢复时间已到的额度禁用密钥，返回恢复的数量；手动禁用的密钥保持不变
+func (info *ChannelInfo) reenableDueKeys(now int64) int {
+	reenabled := 0
+	for idx, reenableAt := range info.MultiKeyReenableTime {
+		if reenableAt > now {
+			continue
+		}
+		delete(info.MultiKeyReenableTime, idx)
+		if info.MultiKeyStatusList[idx] != common.ChannelStatusAutoDisabled {
+			continue
+		}
+		delete(info.MultiKeyStatusList, idx)
+		delete(info.MultiKeyDisabledReason, idx)
+		delete(info.MultiKeyDisabledTime, idx)
+		reenabled++
+	}
+	return reenabled
+}
+
+// ReenableDueChannelKeys 恢复所有到期的额度禁用密钥；因密钥全部禁用而被自动禁用的渠道一并恢复。返回恢复的密钥数。
+func ReenableDueChannelKeys() (int, error) {
+	var channels []*Channel
+	if err := DB.Select("id", "channel_info").Find(&channels).Error; err != nil {
+		return 0, err
+	}
+	now := common.GetTimestamp()
+	total := 0
+	for _, candidate := range channels {
+		if !candidate.ChannelInfo.IsMultiKey || len(candidate.ChannelInfo.MultiKeyReenableTime) == 0 {
+			continue
+		}
+		due := false
+		for _, reenableAt := range candidate.ChannelInfo.MultiKeyReenableTime {
+			if reenableAt <= now {
+				due = true
+				break
+			}
+		}
+		if !due {
+			continue
+		}
+
+		channel, err := GetChannelById(candidate.Id, true)
+		if err != nil {
+			continue
+		}
+		lock := GetChannelPollingLock(channel.Id)
+		lock.Lock()
+		reenabled := channel.ChannelInfo.reenableDueKeys(now)
+		lock.Unlock()
+		channelReenabled := reenabled > 0 && channel.Status == common.ChannelStatusAutoDisabled
+		if channelReenabled {
+			channel.Status = common.ChannelStatusEnabled
+		}
+		if err := channel.SaveWithoutKey(); err != nil {
+			return total, err
+		}
+		if channelReenabled {
+			if err := UpdateAbilityStatus(channel.Id, true); err != nil {
+				common.SysError(fmt.Sprintf("failed to update ability status: channel_id=%d, error=%v", channel.Id, err))
+			}
+		}
+		total += reenabled
+	}
+	return total, nil
+}
+
+// GetChannelKeyUsages 返回渠道各密钥的累计用量，周期用量已按当前额度周期折算（不在本周期内的记为 0）
+func GetChannelKeyUsages(channel *Channel) (map[int]ChannelKeyUsage, error) {
+	var usages []ChannelKeyUsage
+	if err := DB.Where("channel_id = ?", channel.Id).Find(&usages).Error; err != nil {
+		return nil, err
+	}
+	periodStart := multiKeyQuotaPeriodStart(channel.ChannelInfo.quotaPeriod(), time.Now()).Unix()
+	result := make(map[int]ChannelKeyUsage, len(usages))
+	for _, usage := range usages {
+		if usage.PeriodStart != periodStart {
+			usage.PeriodQuota = 0
+		}
+		result[usage.KeyIndex] = usage
+	}
+	return result, nil
+}
+
+// RemapChannelKeyUsages 删除密钥后按新下标迁移用量记录，mapping 中不存在的旧下标视为已删除。
+// 删除只会让下标变小，按旧下标升序迁移不会与尚未迁移的记录冲突。
+func RemapChannelKeyUsages(channelId int, mapping map[int]int) error {
+	defer channelKeyPeriodQuotas.Delete(channelId)
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var usages []ChannelKeyUsage
+		if err := tx.Where("channel_id = ?", channelId).Order("key_index asc").Find(&usages).Error; err != nil {
+			return err
+		}
+		for _, usage := range usages {
+			newIdx, ok := mapping[usage.KeyIndex]
+			if !ok {
+				if err := tx.Delete(&ChannelKeyUsage{}, usage.Id).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if newIdx == usage.KeyIndex {
+				continue
+			}
+			if err := tx.Model(&ChannelKeyUsage{}).Where("id = ?", usage.Id).Update("key_index", newIdx).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteChannelKeyUsages 删除渠道的全部密钥用量记录
+func DeleteChannelKeyUsages(channelId int) error {
+	channelKeyPeriodQuotas.Delete(channelId)
+	return DB.Where("channel_id = ?", channelId).Delete(&ChannelKeyUsage{}).Error
+}
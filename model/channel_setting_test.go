@@ -0,0 +1,31 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelSettingSecrets(t *testing.T) {
+	origin := &Channel{Setting: common.GetPointer(`{"proxy":"http://proxy","tls_client_cert":"CERT","tls_client_key":"PRIVATE","request_signing":{"enabled":true,"secret":"hmac"}}`)}
+
+	cleared := &Channel{Setting: common.GetPointer(*origin.Setting)}
+	cleared.ClearSettingSecrets()
+	require.NotContains(t, *cleared.Setting, "PRIVATE")
+	require.NotContains(t, *cleared.Setting, "hmac")
+	require.Contains(t, *cleared.Setting, "http://proxy")
+
+	// 管理员提交读取到的设置时沿用原有密钥
+	cleared.KeepSettingSecrets(origin)
+	setting := cleared.GetSetting()
+	require.Equal(t, "PRIVATE", setting.TLSClientKey)
+	require.Equal(t, "hmac", setting.RequestSigning.Secret)
+
+	// 移除客户端证书与请求签名后不再保留密钥
+	removed := &Channel{Setting: common.GetPointer(`{"proxy":""}`)}
+	removed.KeepSettingSecrets(origin)
+	setting = removed.GetSetting()
+	require.Empty(t, setting.TLSClientKey)
+	require.Nil(t, setting.RequestSigning)
+}
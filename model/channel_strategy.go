@@ -0,0 +1,274 @@
+package model
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+// channelRuntimeStats 渠道运行时统计（仅本节点），用于最低延迟与最少在途请求策略
+type channelRuntimeStats struct {
+	mu             sync.Mutex
+	firstTokenEWMA float64 // 首字延迟，毫秒
+	totalEWMA      float64 // 总耗时，毫秒
+	samples        int64
+	updatedAt      time.Time
+	outstanding    atomic.Int64
+}
+
+var channelRuntimeStatsMap sync.Map // map[int]*channelRuntimeStats
+
+func getChannelRuntimeStats(channelId int) *channelRuntimeStats {
+	if s, ok := channelRuntimeStatsMap.Load(channelId); ok {
+		return s.(*channelRuntimeStats)
+	}
+	s, _ := channelRuntimeStatsMap.LoadOrStore(channelId, &channelRuntimeStats{})
+	return s.(*channelRuntimeStats)
+}
+
+// BeginChannelRequest 记录一个发往渠道的在途请求，返回的函数在请求结束时调用
+func BeginChannelRequest(channelId int) func() {
+	stats := getChannelRuntimeStats(channelId)
+	stats.outstanding.Add(1)
+	return func() {
+		stats.outstanding.Add(-1)
+	}
+}
+
+func ewma(current float64, sample float64, alpha float64) float64 {
+	if current <= 0 {
+		return sample
+	}
+	return alpha*sample + (1-alpha)*current
+}
+
+// RecordChannelLatency 记录一次成功请求的延迟，firstToken 为 0 表示非流式请求没有首字时间
+func RecordChannelLatency(channelId int, firstToken time.Duration, total time.Duration) {
+	recordChannelLatencySample(channelId, firstToken, total, false)
+}
+
+// RecordChannelFailure 将一次可归因于渠道的失败计入延迟统计，样本取实际耗时与 FailurePenaltyMs 中的较大值
+func RecordChannelFailure(channelId int, elapsed time.Duration) {
+	penalty := time.Duration(operation_setting.GetChannelSelectionSetting().FailurePenaltyMs) * time.Millisecond
+	sample := max(elapsed, penalty)
+	recordChannelLatencySample(channelId, sample, sample, true)
+}
+
+func recordChannelLatencySample(channelId int, firstToken time.Duration, total time.Duration, failed bool) {
+	setting := operation_setting.GetChannelSelectionSetting()
+	alpha := setting.LatencyEWMAAlpha
+	if alpha <= 0 || alpha > 1 {
+		alpha = 0.3
+	}
+	stats := getChannelRuntimeStats(channelId)
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if stats.expiredLocked(time.Now(), setting) {
+		stats.firstTokenEWMA = 0
+		stats.totalEWMA = 0
+		stats.samples = 0
+	}
+	// 失败样本只计入已有的首字延迟统计，避免非流式渠道因一次失败开始以首字延迟计分
+	if firstToken > 0 && (!failed || stats.firstTokenEWMA > 0) {
+		stats.firstTokenEWMA = ewma(stats.firstTokenEWMA, float64(firstToken.Milliseconds()), alpha)
+	}
+	stats.totalEWMA = ewma(stats.totalEWMA, float64(total.Milliseconds()), alpha)
+	stats.samples++
+	stats.updatedAt = time.Now()
+}
+
+func (s *channelRuntimeStats) expiredLocked(now time.Time, setting *operation_setting.ChannelSelectionSetting) bool {
+	if setting.StatsExpireSeconds <= 0 || s.updatedAt.IsZero() {
+		return false
+	}
+	return now.Sub(s.updatedAt) > time.Duration(setting.StatsExpireSeconds)*time.Second
+}
+
+// latencyScoreLocked 优先使用首字延迟；无数据（或数据过期）时得分为 0，此类渠道由 filterChannelsByScore 按探测比例分配流量
+func (s *channelRuntimeStats) latencyScoreLocked(now time.Time, setting *operation_setting.ChannelSelectionSetting) float64 {
+	if s.samples == 0 || s.expiredLocked(now, setting) {
+		return 0
+	}
+	if s.firstTokenEWMA > 0 {
+		return s.firstTokenEWMA
+	}
+	return s.totalEWMA
+}
+
+// ChannelSelectionScore 渠道在选择策略中的实时得分，用于管理接口展示选路依据
+type ChannelSelectionScore struct {
+	ChannelId           int     `json:"channel_id"`
+	Weight              int     `json:"weight"`
+	Priority            int64   `json:"priority"`
+	FirstTokenLatencyMs float64 `json:"first_token_latency_ms"`
+	TotalLatencyMs      float64 `json:"total_latency_ms"`
+	LatencySamples      int64   `json:"latency_samples"`
+	Outstanding         int64   `json:"outstanding"`
+	PriceRatio          float64 `json:"price_ratio"`
+	// Score 当前策略下的得分，越小越优先；一致性哈希策略下为哈希权重，越大越优先
+	Score float64 `json:"score"`
+}
+
+func channelHashScore(hashKey string, channelId int) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(hashKey))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(strconv.Itoa(channelId)))
+	return float64(h.Sum64())
+}
+
+func buildChannelSelectionScore(channel *Channel, strategy string, model string, hashKey string, now time.Time, setting *operation_setting.ChannelSelectionSetting) ChannelSelectionScore {
+	stats := getChannelRuntimeStats(channel.Id)
+	score := ChannelSelectionScore{
+		ChannelId:   channel.Id,
+		Weight:      channel.GetWeight(),
+		Priority:    channel.GetPriority(),
+		Outstanding: stats.outstanding.Load(),
+	}
+	stats.mu.Lock()
+	latency := stats.latencyScoreLocked(now, setting)
+	if !stats.expiredLocked(now, setting) {
+		score.FirstTokenLatencyMs = stats.firstTokenEWMA
+		score.TotalLatencyMs = stats.totalEWMA
+		score.LatencySamples = stats.samples
+	}
+	stats.mu.Unlock()
+	otherSettings := channel.GetOtherSettings()
+	score.PriceRatio = otherSettings.GetPriceRatio(model)
+
+	switch strategy {
+	case operation_setting.ChannelStrategyLeastLatency:
+		score.Score = latency
+	case operation_setting.ChannelStrategyLeastCost:
+		score.Score = score.PriceRatio
+	case operation_setting.ChannelStrategyLeastOutstanding:
+		score.Score = float64(score.Outstanding)
+	case operation_setting.ChannelStrategyConsistentHash:
+		if hashKey != "" {
+			score.Score = channelHashScore(hashKey, channel.Id)
+		}
+	}
+	return score
+}
+
+// selectChannelByStrategy 按分组/模型配置的策略从同一优先级的候选渠道中选出一个
+func selectChannelByStrategy(channels []*Channel, group string, model string, hashKey string) *Channel {
+	if len(channels) <= 1 {
+		return weightedRandomSelect(channels)
+	}
+	setting := operation_setting.GetChannelSelectionSetting()
+	strategy := setting.GetChannelStrategy(group, model)
+	switch strategy {
+	case operation_setting.ChannelStrategyLeastLatency,
+		operation_setting.ChannelStrategyLeastCost,
+		operation_setting.ChannelStrategyLeastOutstanding:
+		return weightedRandomSelect(filterChannelsByScore(channels, strategy, model, setting))
+	case operation_setting.ChannelStrategyConsistentHash:
+		if hashKey == "" {
+			return weightedRandomSelect(channels)
+		}
+		// 最高随机权重（rendezvous）哈希：候选集合变化时只有落在变化渠道上的请求会迁移
+		var selected *Channel
+		best := -1.0
+		for _, ch := range channels {
+			if score := channelHashScore(hashKey, ch.Id); score > best {
+				best = score
+				selected = ch
+			}
+		}
+		return selected
+	default:
+		return weightedRandomSelect(channels)
+	}
+}
+
+// filterChannelsByScore 保留得分在最优得分容差范围内的渠道。
+// 最低延迟策略下没有延迟数据的渠道不参与比较，按 ExplorationPercent 的比例获得请求；所有渠道均无数据时全部保留。
+func filterChannelsByScore(channels []*Channel, strategy string, model string, setting *operation_setting.ChannelSelectionSetting) []*Channel {
+	now := time.Now()
+	scores := make([]float64, 0, len(channels))
+	if strategy == operation_setting.ChannelStrategyLeastLatency {
+		sampled := make([]*Channel, 0, len(channels))
+		unsampled := make([]*Channel, 0)
+		for _, ch := range channels {
+			score := buildChannelSelectionScore(ch, strategy, model, "", now, setting)
+			if score.LatencySamples == 0 {
+				unsampled = append(unsampled, ch)
+				continue
+			}
+			sampled = append(sampled, ch)
+			scores = append(scores, score.Score)
+		}
+		if len(sampled) == 0 || (len(unsampled) > 0 && rand.Float64()*100 < setting.ExplorationPercent) {
+			return unsampled
+		}
+		channels = sampled
+	} else {
+		for _, ch := range channels {
+			scores = append(scores, buildChannelSelectionScore(ch, strategy, model, "", now, setting).Score)
+		}
+	}
+	best := math.MaxFloat64
+	for _, score := range scores {
+		best = min(best, score)
+	}
+	threshold := best
+	if setting.ScoreTolerancePercent > 0 && strategy != operation_setting.ChannelStrategyLeastOutstanding {
+		threshold = best * (1 + setting.ScoreTolerancePercent/100)
+	}
+	result := make([]*Channel, 0, len(channels))
+	for i, ch := range channels {
+		if scores[i] <= threshold {
+			result = append(result, ch)
+		}
+	}
+	return result
+}
+
+// GetChannelSelectionScores 返回指定渠道在给定策略下的实时得分，strategy 为空时仅返回统计数据
+func GetChannelSelectionScores(channels []*Channel, strategy string, model string, hashKey string) []ChannelSelectionScore {
+	setting := operation_setting.GetChannelSelectionSetting()
+	now := time.Now()
+	scores := make([]ChannelSelectionScore, 0, len(channels))
+	for _, ch := range channels {
+		scores = append(scores, buildChannelSelectionScore(ch, strategy, model, hashKey, now, setting))
+	}
+	if strategy == "" || strategy == operation_setting.ChannelStrategyWeighted {
+		return scores
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Priority != scores[j].Priority {
+			return scores[i].Priority > scores[j].Priority
+		}
+		if strategy == operation_setting.ChannelStrategyConsistentHash {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].Score < scores[j].Score
+	})
+	return scores
+}
+
+// GetChannelSelectionCandidates 返回分组与模型对应的已启用候选渠道（需启用内存缓存）
+func GetChannelSelectionCandidates(group string, model string) []*Channel {
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+	channelIds := group2model2channels[group][model]
+	if len(channelIds) == 0 {
+		channelIds = group2model2channels[group][ratio_setting.FormatMatchingModelName(model)]
+	}
+	channels := make([]*Channel, 0, len(channelIds))
+	for _, id := range channelIds {
+		if ch, ok := channelsIDM[id]; ok {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func withChannelStrategy(t *testing.T, strategy string) {
+	setting := operation_setting.GetChannelSelectionSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+	setting.DefaultStrategy = strategy
+	setting.GroupStrategies = map[string]string{}
+	setting.ModelStrategies = map[string]string{}
+	setting.ScoreTolerancePercent = 10
+	setting.StatsExpireSeconds = 300
+	setting.FailurePenaltyMs = 10000
+	setting.ExplorationPercent = 0
+}
+
+func TestSelectChannelLeastCost(t *testing.T) {
+	withChannelStrategy(t, operation_setting.ChannelStrategyLeastCost)
+	channels := []*Channel{
+		{Id: 9201, OtherSettings: `{"price_ratio":1.2}`},
+		{Id: 9202, OtherSettings: `{"price_ratio":0.8,"model_price_ratio":{"gpt-4o":1.5}}`},
+		{Id: 9203, OtherSettings: `{"price_ratio":0.9}`},
+	}
+	for i := 0; i < 20; i++ {
+		require.Equal(t, 9202, selectChannelByStrategy(channels, "default", "gpt-4o-mini", "").Id)
+		require.Equal(t, 9203, selectChannelByStrategy(channels, "default", "gpt-4o", "").Id)
+	}
+}
+
+func TestSelectChannelLeastLatencyExploresUnsampled(t *testing.T) {
+	withChannelStrategy(t, operation_setting.ChannelStrategyLeastLatency)
+	channels := []*Channel{{Id: 9301}, {Id: 9302}, {Id: 9303}}
+	RecordChannelLatency(9301, 300*time.Millisecond, time.Second)
+	RecordChannelLatency(9302, 100*time.Millisecond, 2*time.Second)
+	t.Cleanup(func() {
+		for _, ch := range channels {
+			channelRuntimeStatsMap.Delete(ch.Id)
+		}
+	})
+
+	// 无数据的渠道只获得探测比例内的流量
+	require.Equal(t, 9302, selectChannelByStrategy(channels, "default", "m", "").Id)
+	operation_setting.GetChannelSelectionSetting().ExplorationPercent = 100
+	require.Equal(t, 9303, selectChannelByStrategy(channels, "default", "m", "").Id)
+	RecordChannelLatency(9303, 500*time.Millisecond, time.Second)
+	require.Equal(t, 9302, selectChannelByStrategy(channels, "default", "m", "").Id)
+}
+
+func TestSelectChannelLeastLatencyPenalizesFailures(t *testing.T) {
+	withChannelStrategy(t, operation_setting.ChannelStrategyLeastLatency)
+	channels := []*Channel{{Id: 9311}, {Id: 9312}}
+	t.Cleanup(func() {
+		for _, ch := range channels {
+			channelRuntimeStatsMap.Delete(ch.Id)
+		}
+	})
+	RecordChannelLatency(9311, 100*time.Millisecond, time.Second)
+	RecordChannelLatency(9312, 300*time.Millisecond, time.Second)
+	// 快速失败按惩罚延迟计分，不会因耗时短而被优先选择
+	for i := 0; i < 3; i++ {
+		RecordChannelFailure(9311, 10*time.Millisecond)
+	}
+	for i := 0; i < 20; i++ {
+		require.Equal(t, 9312, selectChannelByStrategy(channels, "default", "m", "").Id)
+	}
+}
+
+func TestSelectChannelLeastOutstanding(t *testing.T) {
+	withChannelStrategy(t, operation_setting.ChannelStrategyLeastOutstanding)
+	channels := []*Channel{{Id: 9401}, {Id: 9402}}
+	t.Cleanup(func() {
+		for _, ch := range channels {
+			channelRuntimeStatsMap.Delete(ch.Id)
+		}
+	})
+	done := BeginChannelRequest(9401)
+	require.Equal(t, 9402, selectChannelByStrategy(channels, "default", "m", "").Id)
+	done()
+	done = BeginChannelRequest(9402)
+	defer done()
+	require.Equal(t, 9401, selectChannelByStrategy(channels, "default", "m", "").Id)
+}
+
+func TestSelectChannelConsistentHash(t *testing.T) {
+	withChannelStrategy(t, operation_setting.ChannelStrategyConsistentHash)
+	channels := []*Channel{{Id: 9501}, {Id: 9502}, {Id: 9503}, {Id: 9504}}
+	moved := 0
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("session-%d", i)
+		first := selectChannelByStrategy(channels, "default", "m", key)
+		require.Equal(t, first.Id, selectChannelByStrategy(channels, "default", "m", key).Id)
+		if first.Id == 9504 {
+			continue
+		}
+		// 移除一个渠道后，原本不在该渠道上的请求键不应迁移
+		if selectChannelByStrategy(channels[:3], "default", "m", key).Id != first.Id {
+			moved++
+		}
+	}
+	require.Zero(t, moved)
+}
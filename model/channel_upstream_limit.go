@@ -0,0 +1,213 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrChannelUpstreamSaturated 候选渠道均因上游并发 / RPM / TPM 限制或密钥冷却而被跳过
+var ErrChannelUpstreamSaturated = errors.New("all candidate channels have reached their upstream limits")
+
+const (
+	UpstreamLimitConcurrency = "concurrency"
+	UpstreamLimitRPM         = "rpm"
+	UpstreamLimitTPM         = "tpm"
+)
+
+// upstreamCooldownSyncInterval 多节点部署时从 Redis 同步密钥冷却状态的最小间隔
+const upstreamCooldownSyncInterval = 2 * time.Second
+
+// UpstreamLimitKey 渠道（多密钥渠道为单个密钥）上游限制计数器的键，单密钥渠道的 keyIndex 为 0
+func UpstreamLimitKey(kind string, channelId int, keyIndex int) string {
+	return fmt.Sprintf("upstream_limit:%s:%d:%d", kind, channelId, keyIndex)
+}
+
+func upstreamCooldownRedisKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("upstream_cooldown:%d:%d", channelId, keyIndex)
+}
+
+type upstreamCooldown struct {
+	mu             sync.Mutex
+	until          time.Time
+	remoteSyncedAt time.Time
+}
+
+var upstreamCooldowns sync.Map // map[string]*upstreamCooldown
+
+func getUpstreamCooldown(channelId int, keyIndex int) *upstreamCooldown {
+	key := channelBreakerKey(channelId, keyIndex)
+	if v, ok := upstreamCooldowns.Load(key); ok {
+		return v.(*upstreamCooldown)
+	}
+	v, _ := upstreamCooldowns.LoadOrStore(key, &upstreamCooldown{})
+	return v.(*upstreamCooldown)
+}
+
+// syncRemote 从 Redis 同步其他节点写入的冷却截止时间
+func (cd *upstreamCooldown) syncRemote(channelId int, keyIndex int, now time.Time) {
+	if !common.RedisEnabled {
+		return
+	}
+	cd.mu.Lock()
+	if now.Sub(cd.remoteSyncedAt) < upstreamCooldownSyncInterval {
+		cd.mu.Unlock()
+		return
+	}
+	cd.remoteSyncedAt = now
+	cd.mu.Unlock()
+
+	value, err := common.RedisGet(upstreamCooldownRedisKey(channelId, keyIndex))
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			common.SysError(fmt.Sprintf("failed to sync upstream cooldown of channel #%d: %v", channelId, err))
+		}
+		return
+	}
+	untilMs, parseErr := strconv.ParseInt(value, 10, 64)
+	if parseErr != nil {
+		return
+	}
+	cd.mu.Lock()
+	if until := time.UnixMilli(untilMs); until.After(cd.until) {
+		cd.until = until
+	}
+	cd.mu.Unlock()
+}
+
+// SetChannelKeyCooldown 在 duration 内暂停使用渠道的某个密钥，已有更长的冷却时不缩短
+func SetChannelKeyCooldown(channelId int, keyIndex int, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	until := time.Now().Add(duration)
+	cd := getUpstreamCooldown(channelId, keyIndex)
+	cd.mu.Lock()
+	if !until.After(cd.until) {
+		cd.mu.Unlock()
+		return
+	}
+	cd.until = until
+	cd.mu.Unlock()
+
+	if common.RedisEnabled {
+		key := upstreamCooldownRedisKey(channelId, keyIndex)
+		if err := common.RedisSet(key, strconv.FormatInt(until.UnixMilli(), 10), duration); err != nil {
+			common.SysError(fmt.Sprintf("failed to publish upstream cooldown of channel #%d: %v", channelId, err))
+		}
+	}
+}
+
+// ChannelKeyCoolingDown 渠道的某个密钥是否仍处于上游限流冷却期
+func ChannelKeyCoolingDown(channelId int, keyIndex int) bool {
+	if !operation_setting.GetUpstreamLimitSetting().CooldownEnabled {
+		return false
+	}
+	now := time.Now()
+	cd := getUpstreamCooldown(channelId, keyIndex)
+	cd.syncRemote(channelId, keyIndex, now)
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	return now.Before(cd.until)
+}
+
+// GetUpstreamLimit 返回渠道配置的上游限制，未配置时返回 nil；
+// 先做字符串匹配以避免在选路热路径上为每个候选渠道反序列化设置
+func (channel *Channel) GetUpstreamLimit() *dto.UpstreamLimitSettings {
+	if channel.Setting == nil || !strings.Contains(*channel.Setting, "upstream_limit") {
+		return nil
+	}
+	return channel.GetSetting().UpstreamLimit
+}
+
+// upstreamKeyIndexes 返回参与上游限制计数的密钥下标：多密钥渠道为所有启用的密钥，其余渠道为 0
+func (channel *Channel) upstreamKeyIndexes() []int {
+	if !channel.ChannelInfo.IsMultiKey {
+		return []int{0}
+	}
+	keyCount := len(channel.GetKeys())
+	indexes := make([]int, 0, keyCount)
+	for i := 0; i < keyCount; i++ {
+		if status, ok := channel.ChannelInfo.MultiKeyStatusList[i]; ok && status != common.ChannelStatusEnabled {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// UpstreamKeySaturated 判断渠道的某个密钥是否处于冷却期，或已达到并发 / RPM / TPM 上限。
+// 计数存储异常时视为未饱和，避免影响正常请求。
+func UpstreamKeySaturated(ctx context.Context, channelId int, keyIndex int, limit *dto.UpstreamLimitSettings) bool {
+	if ChannelKeyCoolingDown(channelId, keyIndex) {
+		return true
+	}
+	if !limit.IsEnabled() {
+		return false
+	}
+	if limit.Concurrency > 0 {
+		current, err := limiter.CurrentConcurrency(ctx, UpstreamLimitKey(UpstreamLimitConcurrency, channelId, keyIndex))
+		if err == nil && current >= int64(limit.Concurrency) {
+			return true
+		}
+	}
+	if limit.RPM > 0 {
+		result, err := limiter.PeekWindow(ctx, UpstreamLimitKey(UpstreamLimitRPM, channelId, keyIndex), int64(limit.RPM))
+		if err == nil && result.Used >= int64(limit.RPM) {
+			return true
+		}
+	}
+	if limit.TPM > 0 {
+		result, err := limiter.PeekWindow(ctx, UpstreamLimitKey(UpstreamLimitTPM, channelId, keyIndex), int64(limit.TPM))
+		if err == nil && result.Used >= int64(limit.TPM) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterUpstreamAvailableKeys 从候选密钥下标中去掉冷却中或已饱和的密钥
+func (channel *Channel) filterUpstreamAvailableKeys(indexes []int) []int {
+	limit := channel.GetUpstreamLimit()
+	if !limit.IsEnabled() && !operation_setting.GetUpstreamLimitSetting().CooldownEnabled {
+		return indexes
+	}
+	available := make([]int, 0, len(indexes))
+	for _, idx := range indexes {
+		if !UpstreamKeySaturated(context.Background(), channel.Id, idx, limit) {
+			available = append(available, idx)
+		}
+	}
+	return available
+}
+
+// IsUpstreamSaturated 渠道的所有可用密钥均冷却中或已达到上游限制
+func (channel *Channel) IsUpstreamSaturated() bool {
+	indexes := channel.upstreamKeyIndexes()
+	if len(indexes) == 0 {
+		return false
+	}
+	return len(channel.filterUpstreamAvailableKeys(indexes)) == 0
+}
+
+// filterChannelsByUpstreamLimit 跳过上游限制已饱和的渠道
+func filterChannelsByUpstreamLimit(channels []*Channel) []*Channel {
+	available := make([]*Channel, 0, len(channels))
+	for _, ch := range channels {
+		if !ch.IsUpstreamSaturated() {
+			available = append(available, ch)
+		}
+	}
+	return available
+}
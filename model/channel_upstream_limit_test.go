@@ -0,0 +1,43 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterChannelsByUpstreamLimit(t *testing.T) {
+	limited := &Channel{Id: 9301, Setting: common.GetPointer(`{"upstream_limit":{"concurrency":1}}`)}
+	unlimited := &Channel{Id: 9302}
+	channels := []*Channel{limited, unlimited}
+	require.Len(t, filterChannelsByUpstreamLimit(channels), 2)
+
+	key := UpstreamLimitKey(UpstreamLimitConcurrency, limited.Id, 0)
+	allowed, _, err := limiter.AcquireConcurrency(context.Background(), key, 1)
+	require.NoError(t, err)
+	require.True(t, allowed)
+	filtered := filterChannelsByUpstreamLimit(channels)
+	require.Len(t, filtered, 1)
+	require.Equal(t, unlimited.Id, filtered[0].Id)
+
+	limiter.ReleaseConcurrency(context.Background(), key)
+	require.Len(t, filterChannelsByUpstreamLimit(channels), 2)
+}
+
+func TestChannelKeyCooldownSkipsKey(t *testing.T) {
+	channel := &Channel{Id: 9303, Key: "k0\nk1", ChannelInfo: ChannelInfo{IsMultiKey: true}}
+	require.Equal(t, []int{0, 1}, channel.filterUpstreamAvailableKeys(channel.upstreamKeyIndexes()))
+
+	SetChannelKeyCooldown(channel.Id, 0, time.Hour)
+	t.Cleanup(func() { upstreamCooldowns.Delete(channelBreakerKey(channel.Id, 0)) })
+	require.Equal(t, []int{1}, channel.filterUpstreamAvailableKeys(channel.upstreamKeyIndexes()))
+	require.False(t, channel.IsUpstreamSaturated())
+
+	SetChannelKeyCooldown(channel.Id, 1, time.Hour)
+	t.Cleanup(func() { upstreamCooldowns.Delete(channelBreakerKey(channel.Id, 1)) })
+	require.True(t, channel.IsUpstreamSaturated())
+}
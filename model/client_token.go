@@ -0,0 +1,184 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	clientTokenIssuer = "new-api"
+	// ClientTokenDefaultTTL / ClientTokenMaxTTL 客户端令牌默认与最长有效期（秒）
+	ClientTokenDefaultTTL = 10 * 60
+	ClientTokenMaxTTL     = 24 * 3600
+)
+
+// ClientToken 设置了额度上限的客户端令牌的用量记录，未设置上限的客户端令牌不落库
+type ClientToken struct {
+	Id          string `json:"id" gorm:"type:varchar(64);primaryKey"`
+	TokenId     int    `json:"token_id" gorm:"index"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	EndUser     string `json:"end_user" gorm:"type:varchar(64);default:''"`
+	MaxQuota    int    `json:"max_quota" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	ExpiredTime int64  `json:"expired_time" gorm:"bigint;index"`
+}
+
+// ClientTokenClaims 客户端令牌（JWT）的声明。ID 为客户端令牌 id，Subject 为终端用户 id，
+// 其余限制在签发时确定，请求时由 TokenAuth 校验。
+type ClientTokenClaims struct {
+	TokenId        int      `json:"tid"`
+	Models         []string `json:"models,omitempty"`
+	MaxQuota       int      `json:"max_quota,omitempty"`
+	AllowedOrigins []string `json:"origins,omitempty"`
+	AllowedIps     []string `json:"ips,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IsClientTokenKey 客户端令牌为三段式 JWT，与 sk- 密钥格式不会冲突
+func IsClientTokenKey(key string) bool {
+	return strings.HasPrefix(key, "eyJ") && strings.Count(key, ".") == 2
+}
+
+// clientTokenSigningKey 签名密钥由父令牌的密钥摘要派生，父令牌轮换密钥后已签发的客户端令牌随之失效
+func clientTokenSigningKey(parent *Token) []byte {
+	h := hmac.New(sha256.New, []byte(tokenKeySecret))
+	h.Write([]byte("client_token:" + parent.Key))
+	return h.Sum(nil)
+}
+
+// AllowOrigin 未限制来源时允许任意请求；限制来源时请求必须携带匹配的 Origin 头
+func (claims *ClientTokenClaims) AllowOrigin(origin string) bool {
+	if len(claims.AllowedOrigins) == 0 {
+		return true
+	}
+	origin = strings.TrimSuffix(origin, "/")
+	return origin != "" && slices.Contains(claims.AllowedOrigins, origin)
+}
+
+// ModelLimits 返回客户端令牌可用的模型：与父令牌当前的模型限制取交集，均未限制时返回 nil
+func (claims *ClientTokenClaims) ModelLimits(parent *Token) map[string]bool {
+	if len(claims.Models) == 0 {
+		if parent.ModelLimitsEnabled {
+			return parent.GetModelLimitsMap()
+		}
+		return nil
+	}
+	var parentLimits map[string]bool
+	if parent.ModelLimitsEnabled {
+		parentLimits = parent.GetModelLimitsMap()
+	}
+	limits := make(map[string]bool, len(claims.Models))
+	for _, model := range claims.Models {
+		if parentLimits == nil || parentLimits[model] {
+			limits[model] = true
+		}
+	}
+	return limits
+}
+
+// MintClientToken 以 parent 为父令牌签发客户端令牌，claims 中的限制由调用方校验后传入
+func MintClientToken(parent *Token, claims ClientTokenClaims, ttl int64) (string, *ClientTokenClaims, error) {
+	now := time.Now()
+	claims.TokenId = parent.Id
+	claims.ID = "ct-" + common.GetUUID()
+	claims.Issuer = clientTokenIssuer
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(time.Duration(ttl) * time.Second))
+	if claims.MaxQuota > 0 {
+		record := &ClientToken{
+			Id:          claims.ID,
+			TokenId:     parent.Id,
+			UserId:      parent.UserId,
+			EndUser:     claims.Subject,
+			MaxQuota:    claims.MaxQuota,
+			CreatedTime: now.Unix(),
+			ExpiredTime: claims.ExpiresAt.Unix(),
+		}
+		if err := DB.Create(record).Error; err != nil {
+			return "", nil, err
+		}
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(clientTokenSigningKey(parent))
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &claims, nil
+}
+
+// ValidateClientToken 校验客户端令牌的签名、有效期与额度上限，并按 ValidateUserToken 的规则校验父令牌状态。
+// 返回的是父令牌，计费与日志均记在父令牌及其用户名下。父令牌与已用额度均优先读取缓存。
+func ValidateClientToken(raw string) (*Token, *ClientTokenClaims, error) {
+	var parent *Token
+	claims := &ClientTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		token, err := getClientTokenParent(claims.TokenId)
+		if err != nil {
+			return nil, err
+		}
+		parent = token
+		return clientTokenSigningKey(parent), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(clientTokenIssuer), jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, nil, errors.New("客户端令牌已过期")
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("无效的令牌")
+		}
+		common.SysLog("ValidateClientToken: " + err.Error())
+		return nil, nil, errors.New("无效的客户端令牌")
+	}
+	token, err := validateUserToken(parent, nil, parent.GetMaskedKey())
+	if err != nil {
+		return token, nil, err
+	}
+	if claims.MaxQuota > 0 {
+		used, err := getClientTokenUsedQuota(claims.ID)
+		if err != nil || used == clientTokenUsedQuotaMissing {
+			return token, nil, errors.New("无效的客户端令牌")
+		}
+		if used >= claims.MaxQuota {
+			return token, nil, fmt.Errorf("客户端令牌额度已用尽（上限 %d）", claims.MaxQuota)
+		}
+	}
+	return token, claims, nil
+}
+
+// ReserveClientTokenQuota 在额度上限内预占客户端令牌额度，以条件更新保证并发请求不会超出上限；
+// 剩余额度不足时返回 false
+func ReserveClientTokenQuota(id string, quota int) (bool, error) {
+	result := DB.Model(&ClientToken{}).
+		Where("id = ? AND used_quota < max_quota AND used_quota + ? <= max_quota", id, quota).
+		Update("used_quota", gorm.Expr("used_quota + ?", quota))
+	return result.RowsAffected > 0, result.Error
+}
+
+// IncreaseClientTokenUsedQuota 累加客户端令牌的已用额度（quota 为负时退还），仅设置了额度上限的客户端令牌有记录
+func IncreaseClientTokenUsedQuota(id string, quota int) error {
+	if quota == 0 {
+		return nil
+	}
+	err := DB.Model(&ClientToken{}).Where("id = ?", id).
+		Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+	if err == nil {
+		invalidateClientTokenUsedQuota(id)
+	}
+	return err
+}
+
+// DeleteExpiredClientTokens 删除在 before 之前已过期的客户端令牌用量记录
+func DeleteExpiredClientTokens(before int64) (int64, error) {
+	result := DB.Where("expired_time < ?", before).Delete(&ClientToken{})
+	return result.RowsAffected, result.Error
+}
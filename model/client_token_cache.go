@@ -0,0 +1,146 @@
+package model
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	"github.com/samber/hot"
+)
+
+const (
+	clientTokenParentKeyCacheNamespace = "new-api:client_token_parent_key:v1"
+	clientTokenUsedQuotaCacheNamespace = "new-api:client_token_used_quota:v1"
+
+	// clientTokenUsedQuotaMissing 表示客户端令牌的用量记录不存在（已清理或未签发）
+	clientTokenUsedQuotaMissing = -1
+)
+
+var (
+	clientTokenParentKeyCacheOnce sync.Once
+	clientTokenUsedQuotaCacheOnce sync.Once
+
+	clientTokenParentKeyCache *cachex.HybridCache[string]
+	clientTokenUsedQuotaCache *cachex.HybridCache[int]
+)
+
+// clientTokenParentKeyCacheTTL 父令牌 id 到密钥摘要的映射，映射过期或失效（父令牌轮换密钥）时回源数据库
+func clientTokenParentKeyCacheTTL() time.Duration {
+	ttlSeconds := common.GetEnvOrDefault("CLIENT_TOKEN_PARENT_CACHE_TTL", 300)
+	if ttlSeconds <= 0 {
+		ttlSeconds = 300
+	}
+	return time.Duration(ttlSeconds) * time.Second
+}
+
+// clientTokenUsedQuotaCacheTTL 客户端令牌已用额度只用于快速拒绝，精确的上限由 ReserveClientTokenQuota 保证，
+// 因此只缓存很短的时间
+func clientTokenUsedQuotaCacheTTL() time.Duration {
+	ttlSeconds := common.GetEnvOrDefault("CLIENT_TOKEN_QUOTA_CACHE_TTL", 5)
+	if ttlSeconds <= 0 {
+		ttlSeconds = 5
+	}
+	return time.Duration(ttlSeconds) * time.Second
+}
+
+func clientTokenCacheCapacity() int {
+	capacity := common.GetEnvOrDefault("CLIENT_TOKEN_CACHE_CAP", 10000)
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return capacity
+}
+
+func getClientTokenParentKeyCache() *cachex.HybridCache[string] {
+	clientTokenParentKeyCacheOnce.Do(func() {
+		ttl := clientTokenParentKeyCacheTTL()
+		clientTokenParentKeyCache = cachex.NewHybridCache[string](cachex.HybridCacheConfig[string]{
+			Namespace: cachex.Namespace(clientTokenParentKeyCacheNamespace),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.StringCodec{},
+			Memory: func() *hot.HotCache[string, string] {
+				return hot.NewHotCache[string, string](hot.LRU, clientTokenCacheCapacity()).
+					WithTTL(ttl).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return clientTokenParentKeyCache
+}
+
+func getClientTokenUsedQuotaCache() *cachex.HybridCache[int] {
+	clientTokenUsedQuotaCacheOnce.Do(func() {
+		ttl := clientTokenUsedQuotaCacheTTL()
+		clientTokenUsedQuotaCache = cachex.NewHybridCache[int](cachex.HybridCacheConfig[int]{
+			Namespace: cachex.Namespace(clientTokenUsedQuotaCacheNamespace),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.IntCodec{},
+			Memory: func() *hot.HotCache[string, int] {
+				return hot.NewHotCache[string, int](hot.LRU, clientTokenCacheCapacity()).
+					WithTTL(ttl).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return clientTokenUsedQuotaCache
+}
+
+// getClientTokenParent 获取客户端令牌的父令牌：先按缓存的密钥摘要走令牌缓存，
+// 摘要未缓存或已失效时按 id 查询数据库并刷新映射
+func getClientTokenParent(tokenId int) (*Token, error) {
+	cacheKey := strconv.Itoa(tokenId)
+	cache := getClientTokenParentKeyCache()
+	if keyHash, found, err := cache.Get(cacheKey); err == nil && found {
+		token, err := GetTokenByKeyHash(keyHash, false)
+		if err == nil && token.Id == tokenId {
+			return token, nil
+		}
+	}
+	token, err := GetTokenById(tokenId)
+	if err != nil {
+		return nil, err
+	}
+	if token.IsKeyHashed() {
+		_ = cache.SetWithTTL(cacheKey, token.Key, clientTokenParentKeyCacheTTL())
+	}
+	return token, nil
+}
+
+// invalidateClientTokenParent 父令牌轮换密钥后删除 id 到旧摘要的映射
+func invalidateClientTokenParent(tokenId int) {
+	_, _ = getClientTokenParentKeyCache().DeleteMany([]string{strconv.Itoa(tokenId)})
+}
+
+// getClientTokenUsedQuota 获取客户端令牌的已用额度，记录不存在时返回 clientTokenUsedQuotaMissing
+func getClientTokenUsedQuota(id string) (int, error) {
+	cache := getClientTokenUsedQuotaCache()
+	if used, found, err := cache.Get(id); err == nil && found {
+		return used, nil
+	}
+	var record ClientToken
+	result := DB.Select("used_quota").Where("id = ?", id).Limit(1).Find(&record)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	used := clientTokenUsedQuotaMissing
+	if result.RowsAffected > 0 {
+		used = record.UsedQuota
+	}
+	_ = cache.SetWithTTL(id, used, clientTokenUsedQuotaCacheTTL())
+	return used, nil
+}
+
+// invalidateClientTokenUsedQuota 已用额度结算或退还后删除缓存，下次校验时回源数据库
+func invalidateClientTokenUsedQuota(id string) {
+	_, _ = getClientTokenUsedQuotaCache().DeleteMany([]string{id})
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientTokenMintAndValidate(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() { DB.Exec("DELETE FROM client_tokens") })
+	parent := insertHashedToken(t, 1, "parent01234567890abcdefgh1234567890abcdefgh1234")
+
+	signed, claims, err := MintClientToken(parent, ClientTokenClaims{
+		Models:           []string{"gpt-4o"},
+		MaxQuota:         100,
+		AllowedOrigins:   []string{"https://app.example.com"},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "end-user-1"},
+	}, 60)
+	require.NoError(t, err)
+	require.True(t, IsClientTokenKey(signed))
+	require.False(t, IsClientTokenKey("sk-abc"))
+
+	token, parsed, err := ValidateClientToken(signed)
+	require.NoError(t, err)
+	require.Equal(t, parent.Id, token.Id)
+	require.Equal(t, claims.ID, parsed.ID)
+	require.Equal(t, "end-user-1", parsed.Subject)
+	require.True(t, parsed.AllowOrigin("https://app.example.com/"))
+	require.False(t, parsed.AllowOrigin(""))
+	require.Equal(t, map[string]bool{"gpt-4o": true}, parsed.ModelLimits(token))
+
+	// 篡改签名
+	_, _, err = ValidateClientToken(signed[:strings.LastIndex(signed, ".")+1] + "invalid")
+	require.Error(t, err)
+
+	// 额度上限
+	require.NoError(t, IncreaseClientTokenUsedQuota(claims.ID, 100))
+	_, _, err = ValidateClientToken(signed)
+	require.ErrorContains(t, err, "额度已用尽")
+}
+
+func TestReserveClientTokenQuota(t *testing.T) {
+	t.Cleanup(func() { DB.Exec("DELETE FROM client_tokens") })
+	require.NoError(t, DB.Create(&ClientToken{Id: "ct-reserve", MaxQuota: 100}).Error)
+
+	ok, err := ReserveClientTokenQuota("ct-reserve", 60)
+	require.NoError(t, err)
+	require.True(t, ok)
+	// 并发请求的预占合计不能超过上限
+	ok, err = ReserveClientTokenQuota("ct-reserve", 60)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// 结算时按实际消耗修正
+	require.NoError(t, IncreaseClientTokenUsedQuota("ct-reserve", 40-60))
+	ok, err = ReserveClientTokenQuota("ct-reserve", 60)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = ReserveClientTokenQuota("ct-reserve", 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestClientTokenRevokedByParentRotation(t *testing.T) {
+	truncateTables(t)
+	parent := insertHashedToken(t, 1, "parent11234567890abcdefgh1234567890abcdefgh1234")
+	signed, _, err := MintClientToken(parent, ClientTokenClaims{}, 60)
+	require.NoError(t, err)
+	_, _, err = ValidateClientToken(signed)
+	require.NoError(t, err)
+
+	_, _, err = RotateTokenKey(parent.Id, 1, 3600)
+	require.NoError(t, err)
+	_, _, err = ValidateClientToken(signed)
+	require.Error(t, err)
+}
+
+func TestClientTokenModelLimitsIntersectParent(t *testing.T) {
+	parent := &Token{ModelLimitsEnabled: true, ModelLimits: "gpt-4o,claude"}
+	require.Equal(t, map[string]bool{"claude": true}, (&ClientTokenClaims{Models: []string{"claude", "gemini"}}).ModelLimits(parent))
+	require.Equal(t, map[string]bool{"gpt-4o": true, "claude": true}, (&ClientTokenClaims{}).ModelLimits(parent))
+	require.Nil(t, (&ClientTokenClaims{}).ModelLimits(&Token{}))
+}
@@ -0,0 +1,602 @@
+package model
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/samber/lo"
+)
+
+const (
+	ConfigDocumentVersion = 1
+
+	// ConfigSecretRedact / ConfigSecretEncrypt 导出时密钥的处理方式：脱敏或使用口令加密
+	ConfigSecretRedact  = "redact"
+	ConfigSecretEncrypt = "encrypt"
+
+	// ConfigRedactedValue 脱敏后的密钥占位符，导入时保留数据库中的原值
+	ConfigRedactedValue = "<redacted>"
+
+	configEncryptedPrefix      = "enc:"
+	configEncryptionKDF        = "pbkdf2-sha256"
+	configEncryptionIterations = 200000
+)
+
+// ConfigDocument 网关配置的声明式描述，可导出为 YAML / JSON 保存在 git 中，导入时与数据库比对生成变更计划。
+// 某一部分未出现（null）时导入不处理该部分；abilities 由渠道的分组与模型重新生成，不单独导出。
+type ConfigDocument struct {
+	Version           int                       `json:"version"`
+	Encryption        *ConfigEncryption         `json:"encryption,omitempty"`
+	Options           map[string]string         `json:"options"`
+	Channels          []*ConfigChannel          `json:"channels"`
+	PrefillGroups     []*ConfigPrefillGroup     `json:"prefill_groups"`
+	GroupShards       []*ConfigGroupShard       `json:"group_shards"`
+	SubscriptionPlans []*ConfigSubscriptionPlan `json:"subscription_plans"`
+}
+
+// ConfigEncryption 加密导出时的密钥派生参数，密文格式为 enc:base64(nonce + AES-256-GCM 密文)
+type ConfigEncryption struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+}
+
+// ConfigChannel 渠道的可配置字段，余额、用量、测速结果与多 key 的运行状态不导出。
+// json 字段名与数据库列名一致，导入时按变更的字段更新。
+type ConfigChannel struct {
+	Id                       int                    `json:"id,omitempty"`
+	Name                     string                 `json:"name"`
+	Type                     int                    `json:"type"`
+	Key                      string                 `json:"key"`
+	Status                   int                    `json:"status"`
+	Group                    string                 `json:"group"`
+	Models                   string                 `json:"models"`
+	Tag                      string                 `json:"tag,omitempty"`
+	BaseURL                  string                 `json:"base_url,omitempty"`
+	Priority                 int64                  `json:"priority,omitempty"`
+	Weight                   uint                   `json:"weight,omitempty"`
+	AutoBan                  int                    `json:"auto_ban"`
+	TestModel                string                 `json:"test_model,omitempty"`
+	OpenAIOrganization       string                 `json:"openai_organization,omitempty"`
+	ModelMapping             string                 `json:"model_mapping,omitempty"`
+	StatusCodeMapping        string                 `json:"status_code_mapping,omitempty"`
+	Setting                  string                 `json:"setting,omitempty"`
+	ParamOverride            string                 `json:"param_override,omitempty"`
+	HeaderOverride           string                 `json:"header_override,omitempty"`
+	Remark                   string                 `json:"remark,omitempty"`
+	MaxUsers                 int                    `json:"max_users,omitempty"`
+	UserBindExpireMinutes    int                    `json:"user_bind_expire_minutes,omitempty"`
+	MaxSessions              int                    `json:"max_sessions,omitempty"`
+	SessionBindExpireMinutes int                    `json:"session_bind_expire_minutes,omitempty"`
+	Other                    string                 `json:"other,omitempty"`
+	OtherInfo                string                 `json:"other_info,omitempty"`
+	Settings                 string                 `json:"settings,omitempty"`
+	MultiKey                 *ConfigChannelMultiKey `json:"multi_key,omitempty"`
+}
+
+// ConfigChannelMultiKey 多 key 渠道的选择策略，对应 ChannelInfo 中的配置部分
+type ConfigChannelMultiKey struct {
+	Mode        constant.MultiKeyMode `json:"mode"`
+	Weights     map[int]int           `json:"weights,omitempty"`
+	UsageMetric string                `json:"usage_metric,omitempty"`
+	QuotaLimits map[int]int64         `json:"quota_limits,omitempty"`
+	QuotaPeriod string                `json:"quota_period,omitempty"`
+}
+
+type ConfigPrefillGroup struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Items       JSONValue `json:"items"`
+	Description string    `json:"description,omitempty"`
+}
+
+type ConfigGroupShard struct {
+	ParentGroup string `json:"parent_group"`
+	ShardGroup  string `json:"shard_group"`
+	MaxUsers    int    `json:"max_users"`
+	Enabled     bool   `json:"enabled"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+type ConfigSubscriptionPlan struct {
+	Id                      int     `json:"id,omitempty"`
+	Title                   string  `json:"title"`
+	Subtitle                string  `json:"subtitle,omitempty"`
+	PriceAmount             float64 `json:"price_amount"`
+	Currency                string  `json:"currency"`
+	DurationUnit            string  `json:"duration_unit"`
+	DurationValue           int     `json:"duration_value"`
+	CustomSeconds           int64   `json:"custom_seconds,omitempty"`
+	Enabled                 bool    `json:"enabled"`
+	SortOrder               int     `json:"sort_order"`
+	StripePriceId           string  `json:"stripe_price_id,omitempty"`
+	CreemProductId          string  `json:"creem_product_id,omitempty"`
+	MaxPurchasePerUser      int     `json:"max_purchase_per_user"`
+	UpgradeGroup            string  `json:"upgrade_group,omitempty"`
+	TotalAmount             int64   `json:"total_amount"`
+	FiveHourAmount          int64   `json:"five_hour_amount"`
+	WeeklyAmount            int64   `json:"weekly_amount"`
+	QuotaResetPeriod        string  `json:"quota_reset_period"`
+	QuotaResetCustomSeconds int64   `json:"quota_reset_custom_seconds,omitempty"`
+}
+
+// IsSensitiveOptionKey 是否为密钥类配置项，与配置接口隐藏的配置项规则一致
+func IsSensitiveOptionKey(key string) bool {
+	return strings.HasSuffix(key, "Token") ||
+		strings.HasSuffix(key, "Secret") ||
+		strings.HasSuffix(key, "Key") ||
+		strings.HasSuffix(key, "secret") ||
+		strings.HasSuffix(key, "api_key")
+}
+
+// configSecretCodec 按导出方式处理密钥：脱敏，或以口令派生的密钥做 AES-GCM 加解密
+type configSecretCodec struct {
+	aead cipher.AEAD
+}
+
+func newConfigSecretCodec(encryption *ConfigEncryption, passphrase string) (*configSecretCodec, error) {
+	if encryption == nil {
+		return &configSecretCodec{}, nil
+	}
+	if encryption.KDF != configEncryptionKDF || encryption.Iterations <= 0 {
+		return nil, fmt.Errorf("不支持的加密参数 %s", encryption.KDF)
+	}
+	if passphrase == "" {
+		return nil, errors.New("配置文件中的密钥已加密，需要提供口令")
+	}
+	salt, err := base64.StdEncoding.DecodeString(encryption.Salt)
+	if err != nil {
+		return nil, errors.New("无效的加密盐值")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, encryption.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &configSecretCodec{aead: aead}, nil
+}
+
+func (codec *configSecretCodec) encode(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if codec.aead == nil {
+		return ConfigRedactedValue, nil
+	}
+	nonce := make([]byte, codec.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := codec.aead.Seal(nonce, nonce, []byte(value), nil)
+	return configEncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decode 还原导入文档中的密钥，返回 false 表示密钥已脱敏，应保留数据库中的原值
+func (codec *configSecretCodec) decode(value string) (string, bool, error) {
+	if value == ConfigRedactedValue {
+		return "", false, nil
+	}
+	if !strings.HasPrefix(value, configEncryptedPrefix) {
+		return value, true, nil
+	}
+	if codec.aead == nil {
+		return "", false, errors.New("配置文件缺少 encryption 参数，无法解密密钥")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, configEncryptedPrefix))
+	if err != nil || len(sealed) < codec.aead.NonceSize() {
+		return "", false, errors.New("无效的加密密钥")
+	}
+	nonceSize := codec.aead.NonceSize()
+	plain, err := codec.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", false, errors.New("密钥解密失败，请检查口令")
+	}
+	return string(plain), true, nil
+}
+
+// configChannelSettingSecretPaths 渠道设置（setting）中的密钥类字段：mTLS 私钥、请求签名密钥与代理地址。
+// 代理地址只在包含账号密码时按密钥处理。
+var configChannelSettingSecretPaths = [][]string{
+	{"tls_client_key"},
+	{"request_signing", "secret"},
+	{"proxy"},
+}
+
+// configSettingLookup 返回 setting 中 path 指向的对象与字段名，路径不存在时返回 nil
+func configSettingLookup(setting map[string]any, path []string) (map[string]any, string) {
+	node := setting
+	for _, field := range path[:len(path)-1] {
+		child, ok := node[field].(map[string]any)
+		if !ok {
+			return nil, ""
+		}
+		node = child
+	}
+	return node, path[len(path)-1]
+}
+
+func isConfigProxyWithCredentials(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && parsed.User != nil
+}
+
+// encodeChannelSetting 按导出方式处理渠道设置中的密钥类字段，其余字段原样保留
+func (codec *configSecretCodec) encodeChannelSetting(setting string) (string, error) {
+	if setting == "" {
+		return "", nil
+	}
+	values := make(map[string]any)
+	if err := common.UnmarshalJsonStr(setting, &values); err != nil {
+		// 无法解析的设置可能包含任意内容，整体按密钥处理
+		return codec.encode(setting)
+	}
+	changed := false
+	for _, path := range configChannelSettingSecretPaths {
+		node, field := configSettingLookup(values, path)
+		if node == nil {
+			continue
+		}
+		value, _ := node[field].(string)
+		if value == "" || (field == "proxy" && !isConfigProxyWithCredentials(value)) {
+			continue
+		}
+		encoded, err := codec.encode(value)
+		if err != nil {
+			return "", err
+		}
+		node[field] = encoded
+		changed = true
+	}
+	if !changed {
+		return setting, nil
+	}
+	data, err := common.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeChannelSetting 还原导入文档中渠道设置的密钥类字段，已脱敏的字段取 current 中的值；
+// 还原后与 current 等价时返回 current，避免格式差异产生变更
+func (codec *configSecretCodec) decodeChannelSetting(setting string, current string) (string, error) {
+	if setting == "" {
+		return "", nil
+	}
+	if setting == ConfigRedactedValue || strings.HasPrefix(setting, configEncryptedPrefix) {
+		decoded, ok, err := codec.decode(setting)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			if current == "" {
+				return "", errors.New("设置已脱敏")
+			}
+			return current, nil
+		}
+		return decoded, nil
+	}
+	values := make(map[string]any)
+	if err := common.UnmarshalJsonStr(setting, &values); err != nil {
+		return setting, nil
+	}
+	currentValues := make(map[string]any)
+	if current != "" {
+		_ = common.UnmarshalJsonStr(current, &currentValues)
+	}
+	changed := false
+	for _, path := range configChannelSettingSecretPaths {
+		node, field := configSettingLookup(values, path)
+		if node == nil {
+			continue
+		}
+		value, _ := node[field].(string)
+		decoded, ok, err := codec.decode(value)
+		if err != nil {
+			return "", fmt.Errorf("设置 %s: %w", strings.Join(path, "."), err)
+		}
+		if !ok {
+			currentNode, _ := configSettingLookup(currentValues, path)
+			if currentNode == nil || currentNode[field] == nil {
+				return "", fmt.Errorf("设置 %s 已脱敏", strings.Join(path, "."))
+			}
+			decoded, _ = currentNode[field].(string)
+		}
+		if decoded != value {
+			node[field] = decoded
+			changed = true
+		}
+	}
+	if !changed {
+		return setting, nil
+	}
+	if current != "" && reflect.DeepEqual(values, currentValues) {
+		return current, nil
+	}
+	data, err := common.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ExportConfigDocument 导出当前数据库中的网关配置，secretMode 为 encrypt 时使用 passphrase 加密密钥，否则脱敏。
+// 密钥包括密钥类配置项、渠道密钥、渠道设置中的密钥类字段以及参数与请求头覆盖。
+func ExportConfigDocument(secretMode string, passphrase string) (*ConfigDocument, error) {
+	doc := &ConfigDocument{Version: ConfigDocumentVersion}
+	switch secretMode {
+	case "", ConfigSecretRedact:
+	case ConfigSecretEncrypt:
+		if len(passphrase) < 8 {
+			return nil, errors.New("加密口令长度不能少于 8 位")
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		doc.Encryption = &ConfigEncryption{
+			KDF:        configEncryptionKDF,
+			Iterations: configEncryptionIterations,
+			Salt:       base64.StdEncoding.EncodeToString(salt),
+		}
+	default:
+		return nil, fmt.Errorf("不支持的密钥导出方式 %s", secretMode)
+	}
+	codec, err := newConfigSecretCodec(doc.Encryption, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := AllOption()
+	if err != nil {
+		return nil, err
+	}
+	doc.Options = make(map[string]string, len(options))
+	for _, option := range options {
+		if option.Key == TokenKeySecretOption {
+			continue
+		}
+		value := option.Value
+		if IsSensitiveOptionKey(option.Key) {
+			if value, err = codec.encode(value); err != nil {
+				return nil, err
+			}
+		}
+		doc.Options[option.Key] = value
+	}
+
+	var channels []*Channel
+	if err := DB.Order("id asc").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	doc.Channels = make([]*ConfigChannel, 0, len(channels))
+	for _, channel := range channels {
+		config := channelToConfig(channel)
+		if config.Key, err = codec.encode(config.Key); err != nil {
+			return nil, err
+		}
+		if config.Setting, err = codec.encodeChannelSetting(config.Setting); err != nil {
+			return nil, err
+		}
+		// 参数与请求头覆盖常包含鉴权信息，整体按密钥处理
+		if config.ParamOverride, err = codec.encode(config.ParamOverride); err != nil {
+			return nil, err
+		}
+		if config.HeaderOverride, err = codec.encode(config.HeaderOverride); err != nil {
+			return nil, err
+		}
+		doc.Channels = append(doc.Channels, config)
+	}
+
+	var prefillGroups []*PrefillGroup
+	if err := DB.Order("name asc").Find(&prefillGroups).Error; err != nil {
+		return nil, err
+	}
+	doc.PrefillGroups = lo.Map(prefillGroups, func(g *PrefillGroup, _ int) *ConfigPrefillGroup {
+		return prefillGroupToConfig(g)
+	})
+
+	var shards []*GroupShard
+	if err := DB.Order("parent_group asc, sort_order asc, id asc").Find(&shards).Error; err != nil {
+		return nil, err
+	}
+	doc.GroupShards = lo.Map(shards, func(s *GroupShard, _ int) *ConfigGroupShard {
+		return groupShardToConfig(s)
+	})
+
+	var plans []*SubscriptionPlan
+	if err := DB.Order("sort_order desc, id desc").Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	doc.SubscriptionPlans = lo.Map(plans, func(p *SubscriptionPlan, _ int) *ConfigSubscriptionPlan {
+		return subscriptionPlanToConfig(p)
+	})
+	return doc, nil
+}
+
+func channelToConfig(channel *Channel) *ConfigChannel {
+	config := &ConfigChannel{
+		Id:                       channel.Id,
+		Name:                     channel.Name,
+		Type:                     channel.Type,
+		Key:                      channel.Key,
+		Status:                   channel.Status,
+		Group:                    channel.Group,
+		Models:                   channel.Models,
+		Tag:                      lo.FromPtr(channel.Tag),
+		BaseURL:                  lo.FromPtr(channel.BaseURL),
+		Priority:                 lo.FromPtr(channel.Priority),
+		Weight:                   lo.FromPtr(channel.Weight),
+		AutoBan:                  lo.FromPtrOr(channel.AutoBan, 1),
+		TestModel:                lo.FromPtr(channel.TestModel),
+		OpenAIOrganization:       lo.FromPtr(channel.OpenAIOrganization),
+		ModelMapping:             lo.FromPtr(channel.ModelMapping),
+		StatusCodeMapping:        lo.FromPtr(channel.StatusCodeMapping),
+		Setting:                  lo.FromPtr(channel.Setting),
+		ParamOverride:            lo.FromPtr(channel.ParamOverride),
+		HeaderOverride:           lo.FromPtr(channel.HeaderOverride),
+		Remark:                   lo.FromPtr(channel.Remark),
+		MaxUsers:                 lo.FromPtr(channel.MaxUsers),
+		UserBindExpireMinutes:    lo.FromPtr(channel.UserBindExpireMinutes),
+		MaxSessions:              lo.FromPtr(channel.MaxSessions),
+		SessionBindExpireMinutes: lo.FromPtr(channel.SessionBindExpireMinutes),
+		Other:                    channel.Other,
+		OtherInfo:                channel.OtherInfo,
+		Settings:                 channel.OtherSettings,
+	}
+	if info := channel.ChannelInfo; info.IsMultiKey {
+		config.MultiKey = &ConfigChannelMultiKey{
+			Mode:        info.MultiKeyMode,
+			Weights:     info.MultiKeyWeights,
+			UsageMetric: info.MultiKeyUsageMetric,
+			QuotaLimits: info.MultiKeyQuotaLimits,
+			QuotaPeriod: info.MultiKeyQuotaPeriod,
+		}
+	}
+	return config
+}
+
+// toChannel 生成写入数据库的渠道，existing 不为空时保留其多 key 运行状态
+func (config *ConfigChannel) toChannel(existing *Channel) *Channel {
+	channel := &Channel{
+		Type:                     config.Type,
+		Key:                      config.Key,
+		Status:                   config.Status,
+		Name:                     config.Name,
+		Group:                    config.Group,
+		Models:                   config.Models,
+		Tag:                      configStringPtr(config.Tag),
+		BaseURL:                  common.GetPointer(config.BaseURL),
+		Priority:                 common.GetPointer(config.Priority),
+		Weight:                   common.GetPointer(config.Weight),
+		AutoBan:                  common.GetPointer(config.AutoBan),
+		TestModel:                configStringPtr(config.TestModel),
+		OpenAIOrganization:       configStringPtr(config.OpenAIOrganization),
+		ModelMapping:             configStringPtr(config.ModelMapping),
+		StatusCodeMapping:        common.GetPointer(config.StatusCodeMapping),
+		Setting:                  configStringPtr(config.Setting),
+		ParamOverride:            configStringPtr(config.ParamOverride),
+		HeaderOverride:           configStringPtr(config.HeaderOverride),
+		Remark:                   configStringPtr(config.Remark),
+		MaxUsers:                 common.GetPointer(config.MaxUsers),
+		UserBindExpireMinutes:    common.GetPointer(config.UserBindExpireMinutes),
+		MaxSessions:              common.GetPointer(config.MaxSessions),
+		SessionBindExpireMinutes: common.GetPointer(config.SessionBindExpireMinutes),
+		Other:                    config.Other,
+		OtherInfo:                config.OtherInfo,
+		OtherSettings:            config.Settings,
+	}
+	if existing != nil {
+		channel.Id = existing.Id
+		channel.ChannelInfo = existing.ChannelInfo
+	}
+	info := &channel.ChannelInfo
+	info.IsMultiKey = config.MultiKey != nil
+	if config.MultiKey != nil {
+		info.MultiKeyMode = config.MultiKey.Mode
+		info.MultiKeyWeights = config.MultiKey.Weights
+		info.MultiKeyUsageMetric = config.MultiKey.UsageMetric
+		info.MultiKeyQuotaLimits = config.MultiKey.QuotaLimits
+		info.MultiKeyQuotaPeriod = config.MultiKey.QuotaPeriod
+		info.MultiKeySize = len(channel.GetKeys())
+		for idx := range info.MultiKeyStatusList {
+			if idx >= info.MultiKeySize {
+				delete(info.MultiKeyStatusList, idx)
+			}
+		}
+	}
+	return channel
+}
+
+func configStringPtr(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func prefillGroupToConfig(group *PrefillGroup) *ConfigPrefillGroup {
+	return &ConfigPrefillGroup{
+		Name:        group.Name,
+		Type:        group.Type,
+		Items:       group.Items,
+		Description: group.Description,
+	}
+}
+
+func groupShardToConfig(shard *GroupShard) *ConfigGroupShard {
+	return &ConfigGroupShard{
+		ParentGroup: shard.ParentGroup,
+		ShardGroup:  shard.ShardGroup,
+		MaxUsers:    shard.MaxUsers,
+		Enabled:     shard.Enabled,
+		SortOrder:   shard.SortOrder,
+	}
+}
+
+func subscriptionPlanToConfig(plan *SubscriptionPlan) *ConfigSubscriptionPlan {
+	return &ConfigSubscriptionPlan{
+		Id:                      plan.Id,
+		Title:                   plan.Title,
+		Subtitle:                plan.Subtitle,
+		PriceAmount:             plan.PriceAmount,
+		Currency:                plan.Currency,
+		DurationUnit:            plan.DurationUnit,
+		DurationValue:           plan.DurationValue,
+		CustomSeconds:           plan.CustomSeconds,
+		Enabled:                 plan.Enabled,
+		SortOrder:               plan.SortOrder,
+		StripePriceId:           plan.StripePriceId,
+		CreemProductId:          plan.CreemProductId,
+		MaxPurchasePerUser:      plan.MaxPurchasePerUser,
+		UpgradeGroup:            plan.UpgradeGroup,
+		TotalAmount:             plan.TotalAmount,
+		FiveHourAmount:          plan.FiveHourAmount,
+		WeeklyAmount:            plan.WeeklyAmount,
+		QuotaResetPeriod:        plan.QuotaResetPeriod,
+		QuotaResetCustomSeconds: plan.QuotaResetCustomSeconds,
+	}
+}
+
+func (config *ConfigSubscriptionPlan) toPlan() *SubscriptionPlan {
+	return &SubscriptionPlan{
+		Id:                      config.Id,
+		Title:                   config.Title,
+		Subtitle:                config.Subtitle,
+		PriceAmount:             config.PriceAmount,
+		Currency:                config.Currency,
+		DurationUnit:            config.DurationUnit,
+		DurationValue:           config.DurationValue,
+		CustomSeconds:           config.CustomSeconds,
+		Enabled:                 config.Enabled,
+		SortOrder:               config.SortOrder,
+		StripePriceId:           config.StripePriceId,
+		CreemProductId:          config.CreemProductId,
+		MaxPurchasePerUser:      config.MaxPurchasePerUser,
+		UpgradeGroup:            config.UpgradeGroup,
+		TotalAmount:             config.TotalAmount,
+		FiveHourAmount:          config.FiveHourAmount,
+		WeeklyAmount:            config.WeeklyAmount,
+		QuotaResetPeriod:        config.QuotaResetPeriod,
+		QuotaResetCustomSeconds: config.QuotaResetCustomSeconds,
+	}
+}
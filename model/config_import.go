@@ -0,0 +1,670 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ConfigChangeCreate = "create"
+	ConfigChangeUpdate = "update"
+	ConfigChangeDelete = "delete"
+
+	ConfigSectionOptions           = "options"
+	ConfigSectionChannels          = "channels"
+	ConfigSectionPrefillGroups     = "prefill_groups"
+	ConfigSectionGroupShards       = "group_shards"
+	ConfigSectionSubscriptionPlans = "subscription_plans"
+)
+
+// ConfigFieldDiff 单个字段的变更，密钥类字段的前后值均以占位符显示
+type ConfigFieldDiff struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// ConfigChange 导入计划中的一项变更
+type ConfigChange struct {
+	Section string             `json:"section"`
+	Action  string             `json:"action"`
+	Key     string             `json:"key"`
+	Fields  []*ConfigFieldDiff `json:"fields,omitempty"`
+
+	apply func(tx *gorm.DB) error
+}
+
+type ConfigImportOptions struct {
+	// Prune 删除数据库中存在而文档中没有的渠道、预填分组与分片分组；订阅套餐被订单引用，只会停用
+	Prune bool
+	// Passphrase 解密文档中加密密钥的口令
+	Passphrase string
+}
+
+// ConfigImportPlan 导入文档与当前数据库的差异，Apply 在一个事务中执行全部变更
+type ConfigImportPlan struct {
+	Changes []*ConfigChange `json:"changes"`
+
+	options         map[string]string
+	channelsChanged bool
+	// deletedChannelIds 被删除的渠道，提交后清理其绑定与用量缓存
+	deletedChannelIds []int
+	shardsChanged     bool
+	planIds           []int
+}
+
+// PlanConfigImport 比对导入文档与当前数据库，生成变更计划，不修改数据库。
+// 渠道与订阅套餐优先按 id 匹配（渠道要求名称与类型一致，订阅套餐要求标题一致），
+// 否则按唯一的名称匹配，便于在不同环境间复用同一份文档；
+// 预填分组按名称匹配，分片分组按分片名匹配。
+func PlanConfigImport(doc *ConfigDocument, opts ConfigImportOptions) (*ConfigImportPlan, error) {
+	if doc == nil {
+		return nil, errors.New("配置文档为空")
+	}
+	if doc.Version != ConfigDocumentVersion {
+		return nil, fmt.Errorf("不支持的配置文档版本 %d", doc.Version)
+	}
+	codec, err := newConfigSecretCodec(doc.Encryption, opts.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	plan := &ConfigImportPlan{Changes: []*ConfigChange{}}
+	if doc.Options != nil {
+		if err := plan.planOptions(doc.Options, codec); err != nil {
+			return nil, err
+		}
+	}
+	if doc.Channels != nil {
+		if err := plan.planChannels(doc.Channels, codec, opts.Prune); err != nil {
+			return nil, err
+		}
+	}
+	if doc.PrefillGroups != nil {
+		if err := plan.planPrefillGroups(doc.PrefillGroups, opts.Prune); err != nil {
+			return nil, err
+		}
+	}
+	if doc.GroupShards != nil {
+		if err := plan.planGroupShards(doc.GroupShards, opts.Prune); err != nil {
+			return nil, err
+		}
+	}
+	if doc.SubscriptionPlans != nil {
+		if err := plan.planSubscriptionPlans(doc.SubscriptionPlans, opts.Prune); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// Apply 在一个事务中执行计划，任一变更失败时全部回滚；提交后刷新配置与缓存
+func (plan *ConfigImportPlan) Apply() error {
+	if len(plan.Changes) == 0 {
+		return nil
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, change := range plan.Changes {
+			if err := change.apply(tx); err != nil {
+				return fmt.Errorf("%s %s %s: %w", change.Section, change.Action, change.Key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for key, value := range plan.options {
+		if err := updateOptionMap(key, value); err != nil {
+			common.SysLog(fmt.Sprintf("failed to update option map: key=%s, error=%v", key, err))
+		}
+	}
+	for _, id := range plan.deletedChannelIds {
+		CacheUnbindAllUsers(id)
+		CacheUnbindAllSessions(id)
+		channelKeyPeriodQuotas.Delete(id)
+	}
+	if plan.channelsChanged {
+		InitChannelCache()
+	}
+	if plan.shardsChanged {
+		InitGroupShardCache()
+	}
+	for _, id := range plan.planIds {
+		InvalidateSubscriptionPlanCache(id)
+	}
+	return nil
+}
+
+func (plan *ConfigImportPlan) add(section string, action string, key string, fields []*ConfigFieldDiff, apply func(tx *gorm.DB) error) {
+	plan.Changes = append(plan.Changes, &ConfigChange{
+		Section: section,
+		Action:  action,
+		Key:     key,
+		Fields:  fields,
+		apply:   apply,
+	})
+}
+
+func (plan *ConfigImportPlan) planOptions(options map[string]string, codec *configSecretCodec) error {
+	current, err := AllOption()
+	if err != nil {
+		return err
+	}
+	currentValues := make(map[string]string, len(current))
+	for _, option := range current {
+		currentValues[option.Key] = option.Value
+	}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// 先解密全部配置项，校验时依赖的配置项（如登录方式的 Client Id）以文档中的值为准
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if key == TokenKeySecretOption {
+			return fmt.Errorf("配置项 %s 不允许导入", key)
+		}
+		value := options[key]
+		if IsSensitiveOptionKey(key) {
+			decoded, ok, err := codec.decode(value)
+			if err != nil {
+				return fmt.Errorf("配置项 %s: %w", key, err)
+			}
+			if !ok {
+				continue
+			}
+			value = decoded
+		}
+		values[key] = value
+	}
+
+	plan.options = make(map[string]string)
+	for _, key := range keys {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		sensitive := IsSensitiveOptionKey(key)
+		before, exists := currentValues[key]
+		if exists && before == value {
+			continue
+		}
+		// 原值为 JSON 的配置项（倍率、分组等）要求新值也是合法的 JSON，避免导入后配置无法加载
+		if exists && isConfigJSONValue(before) && !json.Valid([]byte(value)) {
+			return fmt.Errorf("配置项 %s 的值不是合法的 JSON", key)
+		}
+		if err := ValidateOptionValue(key, value, values); err != nil {
+			return fmt.Errorf("配置项 %s: %w", key, err)
+		}
+		action := ConfigChangeUpdate
+		if !exists {
+			action = ConfigChangeCreate
+		}
+		diff := &ConfigFieldDiff{Field: "value", Before: before, After: value}
+		if sensitive {
+			diff.Before, diff.After = ConfigRedactedValue, ConfigRedactedValue
+		}
+		option := Option{Key: key, Value: value}
+		plan.options[key] = value
+		plan.add(ConfigSectionOptions, action, key, []*ConfigFieldDiff{diff}, func(tx *gorm.DB) error {
+			return tx.Save(&option).Error
+		})
+	}
+	return nil
+}
+
+func (plan *ConfigImportPlan) planChannels(configs []*ConfigChannel, codec *configSecretCodec, prune bool) error {
+	var existing []*Channel
+	if err := DB.Order("id asc").Find(&existing).Error; err != nil {
+		return err
+	}
+	byId := make(map[int]*Channel, len(existing))
+	byName := make(map[string][]*Channel)
+	for _, channel := range existing {
+		byId[channel.Id] = channel
+		byName[channel.Name] = append(byName[channel.Name], channel)
+	}
+	matched := make(map[int]bool)
+	for i, config := range configs {
+		if config == nil || strings.TrimSpace(config.Name) == "" {
+			return fmt.Errorf("第 %d 个渠道缺少名称", i+1)
+		}
+		// id 在不同环境间可能指向无关的渠道，名称或类型不一致时不按 id 匹配
+		current := byId[config.Id]
+		if current != nil && (current.Name != config.Name || current.Type != config.Type) {
+			current = nil
+		}
+		if current == nil {
+			candidates := byName[config.Name]
+			if len(candidates) == 1 && !matched[candidates[0].Id] {
+				current = candidates[0]
+			}
+		}
+		if current != nil && matched[current.Id] {
+			return fmt.Errorf("渠道 #%d %s 在文档中重复出现", current.Id, current.Name)
+		}
+		desired := *config
+		desired.Id = 0
+		key, ok, err := codec.decode(config.Key)
+		if err != nil {
+			return fmt.Errorf("渠道 %s: %w", config.Name, err)
+		}
+		if !ok {
+			if current == nil {
+				return fmt.Errorf("渠道 %s 的密钥已脱敏，无法新建", config.Name)
+			}
+			key = current.Key
+		}
+		if key == "" {
+			return fmt.Errorf("渠道 %s 的密钥不能为空", config.Name)
+		}
+		desired.Key = key
+		var currentConfig *ConfigChannel
+		if current != nil {
+			currentConfig = channelToConfig(current)
+		} else {
+			currentConfig = &ConfigChannel{}
+		}
+		if desired.Setting, err = codec.decodeChannelSetting(config.Setting, currentConfig.Setting); err != nil {
+			return fmt.Errorf("渠道 %s: %w", config.Name, err)
+		}
+		if desired.ParamOverride, err = decodeConfigChannelSecret(codec, config.ParamOverride, currentConfig.ParamOverride); err != nil {
+			return fmt.Errorf("渠道 %s 的参数覆盖: %w", config.Name, err)
+		}
+		if desired.HeaderOverride, err = decodeConfigChannelSecret(codec, config.HeaderOverride, currentConfig.HeaderOverride); err != nil {
+			return fmt.Errorf("渠道 %s 的请求头覆盖: %w", config.Name, err)
+		}
+
+		if current == nil {
+			plan.add(ConfigSectionChannels, ConfigChangeCreate, desired.Name, nil, func(tx *gorm.DB) error {
+				channel := desired.toChannel(nil)
+				channel.CreatedTime = common.GetTimestamp()
+				if err := tx.Create(channel).Error; err != nil {
+					return err
+				}
+				return channel.AddAbilities(tx)
+			})
+			plan.channelsChanged = true
+			continue
+		}
+
+		matched[current.Id] = true
+		desired.Id = current.Id
+		fields, err := diffConfigFields(currentConfig, &desired, "key", "setting", "param_override", "header_override")
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		columns := make([]string, 0, len(fields)+1)
+		for _, field := range fields {
+			if field.Field != "multi_key" {
+				columns = append(columns, field.Field)
+			}
+		}
+		if desired.MultiKey != nil || current.ChannelInfo.IsMultiKey {
+			columns = append(columns, "channel_info")
+		}
+		currentChannel := current
+		plan.add(ConfigSectionChannels, ConfigChangeUpdate, fmt.Sprintf("#%d %s", current.Id, desired.Name), fields, func(tx *gorm.DB) error {
+			channel := desired.toChannel(currentChannel)
+			if err := tx.Model(channel).Select(columns).Updates(channel).Error; err != nil {
+				return err
+			}
+			return channel.UpdateAbilities(tx)
+		})
+		plan.channelsChanged = true
+	}
+	if !prune {
+		return nil
+	}
+	for _, channel := range existing {
+		if matched[channel.Id] {
+			continue
+		}
+		id := channel.Id
+		// 与 Channel.Delete 一致，同时清理能力、用户与会话绑定以及按 key 的用量记录，缓存在提交后清理
+		plan.add(ConfigSectionChannels, ConfigChangeDelete, fmt.Sprintf("#%d %s", id, channel.Name), nil, func(tx *gorm.DB) error {
+			if err := tx.Delete(&Channel{}, id).Error; err != nil {
+				return err
+			}
+			for _, related := range []any{&Ability{}, &ChannelUserBinding{}, &ChannelSessionBinding{}, &ChannelKeyUsage{}} {
+				if err := tx.Where("channel_id = ?", id).Delete(related).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		plan.deletedChannelIds = append(plan.deletedChannelIds, id)
+		plan.channelsChanged = true
+	}
+	return nil
+}
+
+func (plan *ConfigImportPlan) planPrefillGroups(configs []*ConfigPrefillGroup, prune bool) error {
+	var existing []*PrefillGroup
+	if err := DB.Order("id asc").Find(&existing).Error; err != nil {
+		return err
+	}
+	byName := make(map[string]*PrefillGroup, len(existing))
+	for _, group := range existing {
+		byName[group.Name] = group
+	}
+	seen := make(map[string]bool)
+	for i, config := range configs {
+		if config == nil || config.Name == "" || config.Type == "" {
+			return fmt.Errorf("第 %d 个预填分组缺少名称或类型", i+1)
+		}
+		if seen[config.Name] {
+			return fmt.Errorf("预填分组 %s 在文档中重复出现", config.Name)
+		}
+		seen[config.Name] = true
+		desired := *config
+		current := byName[config.Name]
+		if current == nil {
+			plan.add(ConfigSectionPrefillGroups, ConfigChangeCreate, desired.Name, nil, func(tx *gorm.DB) error {
+				now := common.GetTimestamp()
+				return tx.Create(&PrefillGroup{
+					Name:        desired.Name,
+					Type:        desired.Type,
+					Items:       desired.Items,
+					Description: desired.Description,
+					CreatedTime: now,
+					UpdatedTime: now,
+				}).Error
+			})
+			continue
+		}
+		fields, err := diffConfigFields(prefillGroupToConfig(current), &desired)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		columns := append(configDiffColumns(fields), "updated_time")
+		id := current.Id
+		plan.add(ConfigSectionPrefillGroups, ConfigChangeUpdate, desired.Name, fields, func(tx *gorm.DB) error {
+			group := &PrefillGroup{
+				Id:          id,
+				Name:        desired.Name,
+				Type:        desired.Type,
+				Items:       desired.Items,
+				Description: desired.Description,
+				UpdatedTime: common.GetTimestamp(),
+			}
+			return tx.Model(group).Select(columns).Updates(group).Error
+		})
+	}
+	if !prune {
+		return nil
+	}
+	for _, group := range existing {
+		if seen[group.Name] {
+			continue
+		}
+		id := group.Id
+		plan.add(ConfigSectionPrefillGroups, ConfigChangeDelete, group.Name, nil, func(tx *gorm.DB) error {
+			return tx.Delete(&PrefillGroup{}, id).Error
+		})
+	}
+	return nil
+}
+
+func (plan *ConfigImportPlan) planGroupShards(configs []*ConfigGroupShard, prune bool) error {
+	var existing []*GroupShard
+	if err := DB.Order("id asc").Find(&existing).Error; err != nil {
+		return err
+	}
+	byShard := make(map[string]*GroupShard, len(existing))
+	for _, shard := range existing {
+		byShard[shard.ShardGroup] = shard
+	}
+	seen := make(map[string]bool)
+	for i, config := range configs {
+		if config == nil || config.ParentGroup == "" || config.ShardGroup == "" {
+			return fmt.Errorf("第 %d 个分片分组缺少父分组或分片名", i+1)
+		}
+		if config.ParentGroup == config.ShardGroup {
+			return fmt.Errorf("分片分组 %s 不能与父分组同名", config.ShardGroup)
+		}
+		if seen[config.ShardGroup] {
+			return fmt.Errorf("分片分组 %s 在文档中重复出现", config.ShardGroup)
+		}
+		seen[config.ShardGroup] = true
+		desired := *config
+		current := byShard[config.ShardGroup]
+		if current == nil {
+			plan.add(ConfigSectionGroupShards, ConfigChangeCreate, desired.ShardGroup, nil, func(tx *gorm.DB) error {
+				return tx.Create(&GroupShard{
+					ParentGroup: desired.ParentGroup,
+					ShardGroup:  desired.ShardGroup,
+					MaxUsers:    desired.MaxUsers,
+					Enabled:     desired.Enabled,
+					SortOrder:   desired.SortOrder,
+				}).Error
+			})
+			plan.shardsChanged = true
+			continue
+		}
+		fields, err := diffConfigFields(groupShardToConfig(current), &desired)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		columns := append(configDiffColumns(fields), "updated_at")
+		id := current.Id
+		plan.add(ConfigSectionGroupShards, ConfigChangeUpdate, desired.ShardGroup, fields, func(tx *gorm.DB) error {
+			shard := &GroupShard{
+				Id:          id,
+				ParentGroup: desired.ParentGroup,
+				ShardGroup:  desired.ShardGroup,
+				MaxUsers:    desired.MaxUsers,
+				Enabled:     desired.Enabled,
+				SortOrder:   desired.SortOrder,
+			}
+			return tx.Model(shard).Select(columns).Updates(shard).Error
+		})
+		plan.shardsChanged = true
+	}
+	if !prune {
+		return nil
+	}
+	for _, shard := range existing {
+		if seen[shard.ShardGroup] {
+			continue
+		}
+		if shard.CurrentUsers > 0 {
+			return fmt.Errorf("分片分组 %s 仍有 %d 个用户，不能删除", shard.ShardGroup, shard.CurrentUsers)
+		}
+		id := shard.Id
+		plan.add(ConfigSectionGroupShards, ConfigChangeDelete, shard.ShardGroup, nil, func(tx *gorm.DB) error {
+			return tx.Delete(&GroupShard{}, "id = ?", id).Error
+		})
+		plan.shardsChanged = true
+	}
+	return nil
+}
+
+func (plan *ConfigImportPlan) planSubscriptionPlans(configs []*ConfigSubscriptionPlan, prune bool) error {
+	var existing []*SubscriptionPlan
+	if err := DB.Order("id asc").Find(&existing).Error; err != nil {
+		return err
+	}
+	byId := make(map[int]*SubscriptionPlan, len(existing))
+	byTitle := make(map[string][]*SubscriptionPlan)
+	for _, p := range existing {
+		byId[p.Id] = p
+		byTitle[p.Title] = append(byTitle[p.Title], p)
+	}
+	matched := make(map[int]bool)
+	for i, config := range configs {
+		if config == nil || strings.TrimSpace(config.Title) == "" {
+			return fmt.Errorf("第 %d 个订阅套餐缺少标题", i+1)
+		}
+		if config.PriceAmount < 0 || config.PriceAmount > 9999 {
+			return fmt.Errorf("订阅套餐 %s 的价格需在 0 到 9999 之间", config.Title)
+		}
+		if config.MaxPurchasePerUser < 0 || config.TotalAmount < 0 || config.FiveHourAmount < 0 || config.WeeklyAmount < 0 {
+			return fmt.Errorf("订阅套餐 %s 的额度与购买上限不能为负数", config.Title)
+		}
+		current := byId[config.Id]
+		if current != nil && current.Title != config.Title {
+			current = nil
+		}
+		if current == nil {
+			candidates := byTitle[config.Title]
+			if len(candidates) == 1 && !matched[candidates[0].Id] {
+				current = candidates[0]
+			}
+		}
+		if current != nil && matched[current.Id] {
+			return fmt.Errorf("订阅套餐 #%d %s 在文档中重复出现", current.Id, current.Title)
+		}
+		desired := *config
+		desired.Id = 0
+		if desired.Currency == "" {
+			desired.Currency = "USD"
+		}
+		if desired.DurationUnit == "" {
+			desired.DurationUnit = SubscriptionDurationMonth
+		}
+		desired.QuotaResetPeriod = NormalizeResetPeriod(desired.QuotaResetPeriod)
+
+		if current == nil {
+			plan.add(ConfigSectionSubscriptionPlans, ConfigChangeCreate, desired.Title, nil, func(tx *gorm.DB) error {
+				subscriptionPlan := desired.toPlan()
+				if err := tx.Create(subscriptionPlan).Error; err != nil {
+					return err
+				}
+				plan.planIds = append(plan.planIds, subscriptionPlan.Id)
+				return nil
+			})
+			continue
+		}
+
+		matched[current.Id] = true
+		desired.Id = current.Id
+		fields, err := diffConfigFields(subscriptionPlanToConfig(current), &desired)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		plan.addSubscriptionPlanUpdate(desired.toPlan(), fields)
+	}
+	if !prune {
+		return nil
+	}
+	for _, p := range existing {
+		if matched[p.Id] || !p.Enabled {
+			continue
+		}
+		disabled := *p
+		disabled.Enabled = false
+		plan.addSubscriptionPlanUpdate(&disabled, []*ConfigFieldDiff{{Field: "enabled", Before: true, After: false}})
+	}
+	return nil
+}
+
+func (plan *ConfigImportPlan) addSubscriptionPlanUpdate(subscriptionPlan *SubscriptionPlan, fields []*ConfigFieldDiff) {
+	columns := append(configDiffColumns(fields), "updated_at")
+	plan.planIds = append(plan.planIds, subscriptionPlan.Id)
+	key := fmt.Sprintf("#%d %s", subscriptionPlan.Id, subscriptionPlan.Title)
+	plan.add(ConfigSectionSubscriptionPlans, ConfigChangeUpdate, key, fields, func(tx *gorm.DB) error {
+		return tx.Model(subscriptionPlan).Select(columns).Updates(subscriptionPlan).Error
+	})
+}
+
+// decodeConfigChannelSecret 还原整体按密钥处理的渠道字段，已脱敏时取 current 中的值
+func decodeConfigChannelSecret(codec *configSecretCodec, value string, current string) (string, error) {
+	decoded, ok, err := codec.decode(value)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		if current == "" {
+			return "", errors.New("内容已脱敏，无法新建")
+		}
+		return current, nil
+	}
+	return decoded, nil
+}
+
+func isConfigJSONValue(value string) bool {
+	value = strings.TrimSpace(value)
+	return (strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[")) && json.Valid([]byte(value))
+}
+
+func configDiffColumns(fields []*ConfigFieldDiff) []string {
+	columns := make([]string, 0, len(fields))
+	for _, field := range fields {
+		columns = append(columns, field.Field)
+	}
+	return columns
+}
+
+// diffConfigFields 按 json 字段比较两个配置，返回有变化的字段，secretFields 中的字段不输出明文
+func diffConfigFields(current any, desired any, secretFields ...string) ([]*ConfigFieldDiff, error) {
+	before, err := configToMap(current)
+	if err != nil {
+		return nil, err
+	}
+	after, err := configToMap(desired)
+	if err != nil {
+		return nil, err
+	}
+	fieldSet := make(map[string]bool, len(before)+len(after))
+	for field := range before {
+		fieldSet[field] = true
+	}
+	for field := range after {
+		fieldSet[field] = true
+	}
+	fieldNames := make([]string, 0, len(fieldSet))
+	for field := range fieldSet {
+		fieldNames = append(fieldNames, field)
+	}
+	sort.Strings(fieldNames)
+
+	var diffs []*ConfigFieldDiff
+	for _, field := range fieldNames {
+		if reflect.DeepEqual(before[field], after[field]) {
+			continue
+		}
+		diff := &ConfigFieldDiff{Field: field, Before: before[field], After: after[field]}
+		for _, secret := range secretFields {
+			if field == secret {
+				diff.Before, diff.After = ConfigRedactedValue, ConfigRedactedValue
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+func configToMap(config any) (map[string]any, error) {
+	data, err := common.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any)
+	if err := common.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
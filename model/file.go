@@ -0,0 +1,100 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"gorm.io/gorm"
+)
+
+// File 通过 /v1/files 上传的文件，内容直接存储在数据库中，便于多节点共享
+type File struct {
+	Id        int    `json:"id"`
+	FileId    string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	TokenId   int    `json:"token_id" gorm:"index"`
+	Purpose   string `json:"purpose" gorm:"type:varchar(32);index"`
+	Filename  string `json:"filename" gorm:"type:varchar(255)"`
+	Bytes     int64  `json:"bytes" gorm:"bigint"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt int64  `json:"expires_at" gorm:"bigint"`
+	Content   []byte `json:"-"`
+}
+
+func (f *File) ToOpenAIFile() *dto.OpenAIFile {
+	file := &dto.OpenAIFile{
+		ID:        f.FileId,
+		Object:    "file",
+		Bytes:     f.Bytes,
+		CreatedAt: f.CreatedAt,
+		Filename:  f.Filename,
+		Purpose:   f.Purpose,
+		Status:    "processed",
+	}
+	if f.ExpiresAt > 0 {
+		file.ExpiresAt = common.GetPointer(f.ExpiresAt)
+	}
+	return file
+}
+
+func (f *File) Insert() error {
+	if f.CreatedAt == 0 {
+		f.CreatedAt = common.GetTimestamp()
+	}
+	f.Bytes = int64(len(f.Content))
+	return DB.Create(f).Error
+}
+
+// GetUserFileById 获取用户文件元数据，withContent 为 true 时同时加载文件内容
+func GetUserFileById(userId int, fileId string, withContent bool) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id is empty")
+	}
+	file := &File{}
+	query := DB.Where("file_id = ? AND user_id = ?", fileId, userId)
+	if !withContent {
+		query = query.Omit("content")
+	}
+	err := query.First(file).Error
+	return file, err
+}
+
+// GetUserFiles 按创建时间倒序分页，after 为上一页最后一个文件 id（游标）
+func GetUserFiles(userId int, purpose string, after string, limit int, ascending bool) ([]*File, error) {
+	var files []*File
+	query := DB.Omit("content").Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	if after != "" {
+		cursor, err := GetUserFileById(userId, after, false)
+		if err != nil {
+			return nil, err
+		}
+		if ascending {
+			query = query.Where("id > ?", cursor.Id)
+		} else {
+			query = query.Where("id < ?", cursor.Id)
+		}
+	}
+	if ascending {
+		query = query.Order("id asc")
+	} else {
+		query = query.Order("id desc")
+	}
+	err := query.Limit(limit).Find(&files).Error
+	return files, err
+}
+
+func DeleteUserFile(userId int, fileId string) error {
+	result := DB.Where("file_id = ? AND user_id = ?", fileId, userId).Delete(&File{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// LogExportBatchSize 导出日志时每批读取的条数
+const LogExportBatchSize = 1000
+
+// LogExportFilter 日志导出的筛选条件，与 GetAllLogs / GetUserLogs 的查询参数一致；UserId 非 0 时为用户自助导出
+type LogExportFilter struct {
+	UserId         int
+	LogType        int
+	StartTimestamp int64
+	EndTimestamp   int64
+	ModelName      string
+	Username       string
+	TokenName      string
+	Channel        int
+	Group          string
+	RequestId      string
+	// Cursor 只导出 id 小于该值的日志，用于中断后续传；为 0 时从最新的日志开始
+	Cursor int
+}
+
+func (f *LogExportFilter) query(ctx context.Context) (*gorm.DB, error) {
+	tx := LOG_DB.WithContext(ctx).Model(&Log{})
+	if f.UserId != 0 {
+		tx = tx.Where("logs.user_id = ?", f.UserId)
+	}
+	if f.LogType != LogTypeUnknown {
+		tx = tx.Where("logs.type = ?", f.LogType)
+	}
+	if f.ModelName != "" {
+		if f.UserId != 0 {
+			modelNamePattern, err := sanitizeLikePattern(f.ModelName)
+			if err != nil {
+				return nil, err
+			}
+			tx = tx.Where("logs.model_name LIKE ? ESCAPE '!'", modelNamePattern)
+		} else {
+			tx = tx.Where("logs.model_name like ?", f.ModelName)
+		}
+	}
+	if f.Username != "" && f.UserId == 0 {
+		tx = tx.Where("logs.username = ?", f.Username)
+	}
+	if f.TokenName != "" {
+		tx = tx.Where("logs.token_name = ?", f.TokenName)
+	}
+	if f.RequestId != "" {
+		tx = tx.Where("logs.request_id = ?", f.RequestId)
+	}
+	if f.StartTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", f.StartTimestamp)
+	}
+	if f.EndTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", f.EndTimestamp)
+	}
+	if f.Channel != 0 && f.UserId == 0 {
+		tx = tx.Where("logs.channel_id = ?", f.Channel)
+	}
+	if f.Group != "" {
+		tx = tx.Where("logs."+logGroupCol+" = ?", f.Group)
+	}
+	return tx, nil
+}
+
+// StreamLogs 按 id 倒序分批读取符合条件的日志并交给 handle，每批以上一批最小的 id 作为游标，
+// 避免深分页时 OFFSET 的全表扫描。handle 的 cursor 为本批最后一条日志的原始 id，可作为下次导出的 Cursor。
+// 用户自助导出会按 formatUserLogs 去除渠道与管理员信息，日志 id 改为从 1 开始的序号。
+func StreamLogs(ctx context.Context, filter LogExportFilter, batchSize int, handle func(logs []*Log, cursor int) error) error {
+	if batchSize <= 0 {
+		batchSize = LogExportBatchSize
+	}
+	base, err := filter.query(ctx)
+	if err != nil {
+		return err
+	}
+	cursor := filter.Cursor
+	exported := 0
+	for {
+		tx := base.Session(&gorm.Session{})
+		if cursor > 0 {
+			tx = tx.Where("logs.id < ?", cursor)
+		}
+		var logs []*Log
+		if err := tx.Order("logs.id desc").Limit(batchSize).Find(&logs).Error; err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		cursor = logs[len(logs)-1].Id
+		if filter.UserId != 0 {
+			formatUserLogs(logs, exported)
+		} else if err := fillLogChannelNames(logs); err != nil {
+			return err
+		}
+		exported += len(logs)
+		if err := handle(logs, cursor); err != nil {
+			return err
+		}
+		if len(logs) < batchSize {
+			return nil
+		}
+	}
+}
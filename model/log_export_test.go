@@ -0,0 +1,80 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamLogsCursor(t *testing.T) {
+	truncateTables(t)
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, LOG_DB.Create(&Log{
+			UserId:    1,
+			Username:  "alice",
+			CreatedAt: int64(1000 + i),
+			Type:      LogTypeConsume,
+			ModelName: "gpt-4o",
+			ChannelId: 3,
+			Other:     `{"admin_info":{"x":1},"channel_name":"c"}`,
+		}).Error)
+	}
+	require.NoError(t, LOG_DB.Create(&Log{UserId: 2, Username: "bob", CreatedAt: 1010, Type: LogTypeConsume}).Error)
+
+	var ids []int
+	var cursors []int
+	err := StreamLogs(context.Background(), LogExportFilter{Username: "alice"}, 2, func(logs []*Log, cursor int) error {
+		for _, log := range logs {
+			ids = append(ids, log.Id)
+		}
+		cursors = append(cursors, cursor)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 5)
+	require.Len(t, cursors, 3)
+	for i := 1; i < len(ids); i++ {
+		require.Greater(t, ids[i-1], ids[i])
+	}
+
+	// 从游标续传只返回更早的日志
+	var resumed []int
+	err = StreamLogs(context.Background(), LogExportFilter{Username: "alice", Cursor: cursors[0]}, 10, func(logs []*Log, cursor int) error {
+		for _, log := range logs {
+			resumed = append(resumed, log.Id)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, ids[2:], resumed)
+}
+
+func TestStreamLogsSelfSanitized(t *testing.T) {
+	truncateTables(t)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, LOG_DB.Create(&Log{
+			UserId:    7,
+			CreatedAt: int64(2000 + i),
+			Type:      LogTypeConsume,
+			ChannelId: 3,
+			Other:     `{"admin_info":{"x":1},"channel_name":"c","model_price":1}`,
+		}).Error)
+	}
+	require.NoError(t, LOG_DB.Create(&Log{UserId: 8, CreatedAt: 2005, Type: LogTypeConsume}).Error)
+
+	var exported []*Log
+	err := StreamLogs(context.Background(), LogExportFilter{UserId: 7, Username: "ignored"}, 2, func(logs []*Log, cursor int) error {
+		exported = append(exported, logs...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, exported, 3)
+	for i, log := range exported {
+		require.Equal(t, i+1, log.Id)
+		require.Zero(t, log.ChannelId)
+		require.NotContains(t, log.Other, "admin_info")
+		require.NotContains(t, log.Other, "channel_name")
+		require.Contains(t, log.Other, "model_price")
+	}
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	MarginDimensionChannel = "channel"
+	MarginDimensionModel   = "model"
+	MarginDimensionGroup   = "group"
+	MarginDimensionDay     = "day"
+)
+
+// MarginStat 按维度汇总的收入（扣费额度）、上游成本与毛利，单位均为额度。
+// upstream_cost 为 0 的日志成本未知（渠道未配置价格倍率），不参与成本与毛利计算；
+// 全部未知时 cost、margin、margin_rate 为 null
+type MarginStat struct {
+	ChannelId   int    `json:"channel_id,omitempty" gorm:"column:channel_id"`
+	ChannelName string `json:"channel_name,omitempty" gorm:"-"`
+	ModelName   string `json:"model_name,omitempty" gorm:"column:model_name"`
+	Group       string `json:"group,omitempty" gorm:"column:group_name"`
+	Day         int64  `json:"day,omitempty" gorm:"column:day"`
+	Requests    int64  `json:"requests" gorm:"column:requests"`
+	Revenue     int64  `json:"revenue" gorm:"column:revenue"`
+	// UnknownCostRequests 成本未知的请求数
+	UnknownCostRequests int64 `json:"unknown_cost_requests" gorm:"column:unknown_cost_requests"`
+	// CostedRevenue 成本已知的请求的收入，毛利按此计算
+	CostedRevenue int64    `json:"costed_revenue" gorm:"column:costed_revenue"`
+	Cost          *int64   `json:"cost" gorm:"column:cost"`
+	Margin        *int64   `json:"margin" gorm:"-"`
+	MarginRate    *float64 `json:"margin_rate" gorm:"-"`
+}
+
+// MarginReportFilter 毛利报表的筛选条件
+type MarginReportFilter struct {
+	StartTimestamp int64
+	EndTimestamp   int64
+	Channel        int
+	ModelName      string
+	Group          string
+}
+
+// GetMarginReport 汇总消费日志的收入与上游成本，dimension 为 channel / model / group / day。
+// 按天统计时以服务器本地时区的零点作为分组起点，结果按日期升序，其余维度按收入降序。
+func GetMarginReport(dimension string, filter MarginReportFilter) ([]*MarginStat, error) {
+	var groupExpr string
+	switch dimension {
+	case MarginDimensionChannel:
+		groupExpr = "logs.channel_id"
+	case MarginDimensionModel:
+		groupExpr = "logs.model_name"
+	case MarginDimensionGroup:
+		groupExpr = "logs." + logGroupCol
+	case MarginDimensionDay:
+		// 偏移量直接写入 SQL，保证 SELECT 与 GROUP BY 的表达式完全一致
+		_, offset := time.Now().Zone()
+		groupExpr = fmt.Sprintf("(logs.created_at - ((logs.created_at + %d) %% 86400))", offset)
+	default:
+		return nil, errors.New("不支持的统计维度")
+	}
+	columns := map[string]string{
+		MarginDimensionChannel: "channel_id",
+		MarginDimensionModel:   "model_name",
+		MarginDimensionGroup:   "group_name",
+		MarginDimensionDay:     "day",
+	}
+
+	tx := LOG_DB.Table("logs").
+		Select(groupExpr+" as "+columns[dimension]+", count(*) as requests, sum(logs.quota) as revenue, "+
+			"sum(case when logs.upstream_cost > 0 then 0 else 1 end) as unknown_cost_requests, "+
+			"sum(case when logs.upstream_cost > 0 then logs.quota else 0 end) as costed_revenue, "+
+			"sum(case when logs.upstream_cost > 0 then logs.upstream_cost end) as cost").
+		Where("logs.type = ?", LogTypeConsume)
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", filter.EndTimestamp)
+	}
+	if filter.Channel != 0 {
+		tx = tx.Where("logs.channel_id = ?", filter.Channel)
+	}
+	if filter.ModelName != "" {
+		tx = tx.Where("logs.model_name = ?", filter.ModelName)
+	}
+	if filter.Group != "" {
+		tx = tx.Where("logs."+logGroupCol+" = ?", filter.Group)
+	}
+	tx = tx.Group(groupExpr)
+	if dimension == MarginDimensionDay {
+		tx = tx.Order(groupExpr + " asc")
+	} else {
+		tx = tx.Order("revenue desc")
+	}
+
+	var stats []*MarginStat
+	if err := tx.Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	for _, stat := range stats {
+		if stat.Cost == nil {
+			continue
+		}
+		stat.Margin = common.GetPointer(stat.CostedRevenue - *stat.Cost)
+		if stat.CostedRevenue > 0 {
+			stat.MarginRate = common.GetPointer(float64(*stat.Margin) / float64(stat.CostedRevenue))
+		}
+	}
+
+	if dimension == MarginDimensionChannel && len(stats) > 0 {
+		channelIds := make([]int, 0, len(stats))
+		for _, stat := range stats {
+			if stat.ChannelId != 0 {
+				channelIds = append(channelIds, stat.ChannelId)
+			}
+		}
+		if len(channelIds) > 0 {
+			channelMap, err := getChannelNameMap(channelIds)
+			if err != nil {
+				return nil, err
+			}
+			for _, stat := range stats {
+				stat.ChannelName = channelMap[stat.ChannelId]
+			}
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMarginReportByModel(t *testing.T) {
+	truncateTables(t)
+	logs := []*Log{
+		{CreatedAt: 1000, Type: LogTypeConsume, ModelName: "gpt-4o", ChannelId: 1, Quota: 100, UpstreamCost: 60},
+		{CreatedAt: 1001, Type: LogTypeConsume, ModelName: "gpt-4o", ChannelId: 2, Quota: 200, UpstreamCost: 150},
+		{CreatedAt: 1002, Type: LogTypeConsume, ModelName: "claude", ChannelId: 1, Quota: 50, UpstreamCost: 10},
+		{CreatedAt: 1003, Type: LogTypeConsume, ModelName: "gpt-4o", ChannelId: 3, Quota: 40},
+		{CreatedAt: 1004, Type: LogTypeConsume, ModelName: "llama", ChannelId: 3, Quota: 20},
+		{CreatedAt: 1005, Type: LogTypeTopup, ModelName: "gpt-4o", Quota: 1000},
+	}
+	for _, log := range logs {
+		require.NoError(t, LOG_DB.Create(log).Error)
+	}
+
+	stats, err := GetMarginReport(MarginDimensionModel, MarginReportFilter{})
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+	require.Equal(t, "gpt-4o", stats[0].ModelName)
+	require.EqualValues(t, 3, stats[0].Requests)
+	require.EqualValues(t, 340, stats[0].Revenue)
+	require.EqualValues(t, 1, stats[0].UnknownCostRequests)
+	require.EqualValues(t, 300, stats[0].CostedRevenue)
+	require.EqualValues(t, 210, *stats[0].Cost)
+	require.EqualValues(t, 90, *stats[0].Margin)
+	require.InDelta(t, 0.3, *stats[0].MarginRate, 1e-9)
+
+	// 未配置价格倍率的渠道成本未知，不计算毛利
+	require.Equal(t, "llama", stats[2].ModelName)
+	require.Nil(t, stats[2].Cost)
+	require.Nil(t, stats[2].Margin)
+	require.Nil(t, stats[2].MarginRate)
+
+	stats, err = GetMarginReport(MarginDimensionChannel, MarginReportFilter{ModelName: "gpt-4o"})
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+	require.Equal(t, 2, stats[0].ChannelId)
+}
+
+func TestGetMarginReportByDay(t *testing.T) {
+	truncateTables(t)
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+	for _, ts := range []time.Time{day.Add(time.Hour), day.Add(23 * time.Hour), day.Add(25 * time.Hour)} {
+		require.NoError(t, LOG_DB.Create(&Log{CreatedAt: ts.Unix(), Type: LogTypeConsume, Quota: 10, UpstreamCost: 4}).Error)
+	}
+
+	stats, err := GetMarginReport(MarginDimensionDay, MarginReportFilter{})
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	require.Equal(t, day.Unix(), stats[0].Day)
+	require.EqualValues(t, 2, stats[0].Requests)
+	require.Equal(t, day.AddDate(0, 0, 1).Unix(), stats[1].Day)
+
+	_, err = GetMarginReport("unknown", MarginReportFilter{})
+	require.Error(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 
+	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -196,14 +197,22 @@ func InitDB() (err error) {
 		sqlDB.SetConnMaxLifetime(time.Second * time.Duration(common.GetEnvOrDefault("SQL_MAX_LIFETIME", 60)))
 
 		if !common.IsMasterNode {
-			return nil
+			return initTokenKeySecret()
 		}
 		if common.UsingMySQL {
 			//_, _ = sqlDB.Exec("ALTER TABLE channels MODIFY model_mapping TEXT;") // TODO: delete this line when most users have upgraded
 		}
 		common.SysLog("database migration started")
 		err = migrateDB()
-		return err
+		if err != nil {
+			return err
+		}
+		if err = initTokenKeySecret(); err != nil {
+			return err
+		}
+		// 旧版本明文保存的令牌密钥在后台迁移为摘要，迁移期间仍可正常鉴权
+		gopool.Go(migrateTokenKeysToHash)
+		return nil
 	} else {
 		common.FatalLog(err)
 	}
@@ -286,6 +295,12 @@ func migrateDB() error {
 		&Ticket{},
 		&TicketMessage{},
 		&GroupShard{},
+		&File{},
+		&Batch{},
+		&BatchResult{},
+		&ResponsesRecord{},
+		&ChannelKeyUsage{},
+		&ClientToken{},
 	)
 	if err != nil {
 		return err
@@ -339,6 +354,12 @@ func migrateDBFast() error {
 		{&Commission{}, "Commission"},
 		{&Ticket{}, "Ticket"},
 		{&TicketMessage{}, "TicketMessage"},
+		{&File{}, "File"},
+		{&Batch{}, "Batch"},
+		{&BatchResult{}, "BatchResult"},
+		{&ResponsesRecord{}, "ResponsesRecord"},
+		{&ChannelKeyUsage{}, "ChannelKeyUsage"},
+		{&ClientToken{}, "ClientToken"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/console_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// ValidateOptionValue 校验配置项的新值，不修改当前配置。配置接口与配置导入共用这一套规则。
+// pending 为同时写入的其他配置项（如导入文档中的配置），启用登录方式等依赖其他配置项的校验优先使用其中的值。
+func ValidateOptionValue(key string, value string, pending map[string]string) error {
+	dependency := func(dependencyKey string, current string) string {
+		if v, ok := pending[dependencyKey]; ok {
+			return v
+		}
+		return current
+	}
+	switch key {
+	case "GitHubOAuthEnabled":
+		if value == "true" && dependency("GitHubClientId", common.GitHubClientId) == "" {
+			return errors.New("无法启用 GitHub OAuth，请先填入 GitHub Client Id 以及 GitHub Client Secret！")
+		}
+	case "discord.enabled":
+		if value == "true" && dependency("discord.client_id", system_setting.GetDiscordSettings().ClientId) == "" {
+			return errors.New("无法启用 Discord OAuth，请先填入 Discord Client Id 以及 Discord Client Secret！")
+		}
+	case "oidc.enabled":
+		if value == "true" && dependency("oidc.client_id", system_setting.GetOIDCSettings().ClientId) == "" {
+			return errors.New("无法启用 OIDC 登录，请先填入 OIDC Client Id 以及 OIDC Client Secret！")
+		}
+	case "LinuxDOOAuthEnabled":
+		if value == "true" && dependency("LinuxDOClientId", common.LinuxDOClientId) == "" {
+			return errors.New("无法启用 LinuxDO OAuth，请先填入 LinuxDO Client Id 以及 LinuxDO Client Secret！")
+		}
+	case "EmailDomainRestrictionEnabled":
+		if value == "true" {
+			whitelist, ok := pending["EmailDomainWhitelist"]
+			if (ok && strings.TrimSpace(whitelist) == "") || (!ok && len(common.EmailDomainWhitelist) == 0) {
+				return errors.New("无法启用邮箱域名限制，请先填入限制的邮箱域名！")
+			}
+		}
+	case "WeChatAuthEnabled":
+		if value == "true" && dependency("WeChatServerAddress", common.WeChatServerAddress) == "" {
+			return errors.New("无法启用微信登录，请先填入微信登录相关配置信息！")
+		}
+	case "TurnstileCheckEnabled":
+		if value == "true" && dependency("TurnstileSiteKey", common.TurnstileSiteKey) == "" {
+			return errors.New("无法启用 Turnstile 校验，请先填入 Turnstile 校验相关配置信息！")
+		}
+	case "TelegramOAuthEnabled":
+		if value == "true" && dependency("TelegramBotToken", common.TelegramBotToken) == "" {
+			return errors.New("无法启用 Telegram OAuth，请先填入 Telegram Bot Token！")
+		}
+	case "GroupRatio":
+		return ratio_setting.CheckGroupRatio(value)
+	case "ImageRatio":
+		if err := ratio_setting.CheckRatioJSONString(value); err != nil {
+			return errors.New("图片倍率设置失败: " + err.Error())
+		}
+	case "AudioRatio":
+		if err := ratio_setting.CheckRatioJSONString(value); err != nil {
+			return errors.New("音频倍率设置失败: " + err.Error())
+		}
+	case "AudioCompletionRatio":
+		if err := ratio_setting.CheckRatioJSONString(value); err != nil {
+			return errors.New("音频补全倍率设置失败: " + err.Error())
+		}
+	case "CreateCacheRatio":
+		if err := ratio_setting.CheckRatioJSONString(value); err != nil {
+			return errors.New("缓存创建倍率设置失败: " + err.Error())
+		}
+	case TokenKeySecretOption:
+		// 修改盐值会使所有令牌失效
+		return errors.New("该配置项不允许修改")
+	case "ModelPriceTiers":
+		if err := ratio_setting.CheckModelPriceTiers(value); err != nil {
+			return errors.New("阶梯价格设置失败: " + err.Error())
+		}
+	case "ModelRequestRateLimitGroup":
+		return setting.CheckModelRequestRateLimitGroup(value)
+	case "ModelTokenRateLimitGroup":
+		return setting.CheckModelTokenRateLimitGroup(value)
+	case "AutomaticDisableStatusCodes", "AutomaticRetryStatusCodes":
+		_, err := operation_setting.ParseHTTPStatusCodeRanges(value)
+		return err
+	case "console_setting.api_info":
+		return console_setting.ValidateConsoleSettings(value, "ApiInfo")
+	case "console_setting.announcements":
+		return console_setting.ValidateConsoleSettings(value, "Announcements")
+	case "console_setting.faq":
+		return console_setting.ValidateConsoleSettings(value, "FAQ")
+	case "console_setting.uptime_kuma_groups":
+		return console_setting.ValidateConsoleSettings(value, "UptimeKumaGroups")
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"database/sql/driver"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// ResponsesRecord 记录 /v1/responses 响应由哪个渠道、哪个 key 处理，
+// 用于 previous_response_id 续写、检索/删除/取消等后续请求路由回同一上游，并做归属校验
+type ResponsesRecord struct {
+	Id         int    `json:"id"`
+	ResponseId string `json:"response_id" gorm:"type:varchar(128);uniqueIndex"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	TokenId    int    `json:"token_id" gorm:"index"`
+	ChannelId  int    `json:"channel_id" gorm:"index"`
+	KeyIndex   int    `json:"key_index"`
+	Group      string `json:"group" gorm:"type:varchar(64)"`
+	ModelName  string `json:"model_name" gorm:"type:varchar(255)"`
+	// Local 为 true 表示响应由网关经 Chat Completions 转换生成，上游并不知道该 id，后续请求由网关自行处理
+	Local      bool   `json:"local"`
+	Background bool   `json:"background"`
+	Status     string `json:"status" gorm:"type:varchar(32)"`
+	// Settled 后台响应完成并补扣费用后置为 true；非后台响应在创建时已结算
+	Settled   bool  `json:"settled"`
+	CreatedAt int64 `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt int64 `json:"updated_at" gorm:"bigint"`
+	// 以下字段仅供网关内部使用，禁止返回给用户
+	Billing      ResponsesBillingContext `json:"-" gorm:"type:json"`
+	Conversation string                  `json:"-" gorm:"type:text"`
+	Response     string                  `json:"-" gorm:"type:text"`
+}
+
+// ResponsesBillingContext 记录后台响应创建时的计费参数，轮询到完成状态时据此补扣费用
+type ResponsesBillingContext struct {
+	BillingSource   string  `json:"billing_source,omitempty"`
+	SubscriptionId  int     `json:"subscription_id,omitempty"`
+	ModelRatio      float64 `json:"model_ratio,omitempty"`
+	CompletionRatio float64 `json:"completion_ratio,omitempty"`
+	CacheRatio      float64 `json:"cache_ratio,omitempty"`
+	GroupRatio      float64 `json:"group_ratio,omitempty"`
+	ModelPrice      float64 `json:"model_price,omitempty"`
+	UsePrice        bool    `json:"use_price,omitempty"`
+}
+
+func (b *ResponsesBillingContext) Scan(val interface{}) error {
+	bytesValue, _ := val.([]byte)
+	if len(bytesValue) == 0 {
+		return nil
+	}
+	return common.Unmarshal(bytesValue, b)
+}
+
+func (b ResponsesBillingContext) Value() (driver.Value, error) {
+	if (b == ResponsesBillingContext{}) {
+		return nil, nil
+	}
+	return common.Marshal(b)
+}
+
+func (r *ResponsesRecord) Insert() error {
+	now := common.GetTimestamp()
+	if r.CreatedAt == 0 {
+		r.CreatedAt = now
+	}
+	r.UpdatedAt = now
+	return DB.Create(r).Error
+}
+
+func GetResponsesRecord(responseId string) (*ResponsesRecord, error) {
+	record := &ResponsesRecord{}
+	err := DB.Where("response_id = ?", responseId).Omit("conversation", "response").First(record).Error
+	return record, err
+}
+
+// GetUserResponsesRecord 按响应 id 查询记录，其他用户的记录视为不存在
+func GetUserResponsesRecord(userId int, responseId string) (*ResponsesRecord, error) {
+	record := &ResponsesRecord{}
+	err := DB.Where("response_id = ? AND user_id = ?", responseId, userId).First(record).Error
+	return record, err
+}
+
+// UpdateStatus 更新响应状态，settle 为 true 时以 CAS 方式标记结算，返回是否由本次调用完成结算
+func (r *ResponsesRecord) UpdateStatus(status string, settle bool) (bool, error) {
+	fields := map[string]interface{}{
+		"status":     status,
+		"updated_at": common.GetTimestamp(),
+	}
+	query := DB.Model(&ResponsesRecord{}).Where("id = ?", r.Id)
+	if settle {
+		fields["settled"] = true
+		query = query.Where("settled = ?", false)
+	}
+	result := query.Updates(fields)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	r.Status = status
+	if settle && result.RowsAffected > 0 {
+		r.Settled = true
+		return true, nil
+	}
+	return false, nil
+}
+
+func DeleteUserResponsesRecord(userId int, responseId string) error {
+	return DB.Where("response_id = ? AND user_id = ?", responseId, userId).Delete(&ResponsesRecord{}).Error
+}
@@ -26,6 +26,7 @@ func TestMain(m *testing.M) {
 	common.RedisEnabled = false
 	common.BatchUpdateEnabled = false
 	common.LogConsumeEnabled = true
+	initCol()
 
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -33,7 +34,9 @@ func TestMain(m *testing.M) {
 	}
 	sqlDB.SetMaxOpenConns(1)
 
-	if err := db.AutoMigrate(&Task{}, &User{}, &Token{}, &Log{}, &Channel{}); err != nil {
+	if err := db.AutoMigrate(&Task{}, &User{}, &Token{}, &Log{}, &Channel{}, &ClientToken{},
+		&Option{}, &Ability{}, &PrefillGroup{}, &GroupShard{}, &SubscriptionPlan{},
+		&ChannelUserBinding{}, &ChannelSessionBinding{}, &ChannelKeyUsage{}); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
 
@@ -48,6 +51,12 @@ func truncateTables(t *testing.T) {
 		DB.Exec("DELETE FROM tokens")
 		DB.Exec("DELETE FROM logs")
 		DB.Exec("DELETE FROM channels")
+		DB.Exec("DELETE FROM options")
+		DB.Exec("DELETE FROM abilities")
+		DB.Exec("DELETE FROM prefill_groups")
+		DB.Exec("DELETE FROM group_shards")
+		DB.Exec("DELETE FROM subscription_plans")
+		DB.Exec("DELETE FROM channel_key_usages")
 	})
 }
 
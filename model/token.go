@@ -12,23 +12,30 @@ import (
 )
 
 type Token struct {
-	Id                 int            `json:"id"`
-	UserId             int            `json:"user_id" gorm:"index"`
-	Key                string         `json:"key" gorm:"type:char(48);uniqueIndex"`
-	Status             int            `json:"status" gorm:"default:1"`
-	Name               string         `json:"name" gorm:"index" `
-	CreatedTime        int64          `json:"created_time" gorm:"bigint"`
-	AccessedTime       int64          `json:"accessed_time" gorm:"bigint"`
-	ExpiredTime        int64          `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
-	RemainQuota        int            `json:"remain_quota" gorm:"default:0"`
-	UnlimitedQuota     bool           `json:"unlimited_quota"`
-	ModelLimitsEnabled bool           `json:"model_limits_enabled"`
-	ModelLimits        string         `json:"model_limits" gorm:"type:text"`
-	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
-	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
-	Group              string         `json:"group" gorm:"default:''"`
-	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
-	DeletedAt          gorm.DeletedAt `gorm:"index"`
+	Id                   int            `json:"id"`
+	UserId               int            `json:"user_id" gorm:"index"`
+	Key                  string         `json:"key" gorm:"type:char(48);uniqueIndex"` // 密钥摘要，见 HashTokenKey；旧数据迁移前为明文
+	KeyPrefix            string         `json:"key_prefix" gorm:"type:varchar(16);default:''"`
+	Status               int            `json:"status" gorm:"default:1"`
+	Name                 string         `json:"name" gorm:"index" `
+	CreatedTime          int64          `json:"created_time" gorm:"bigint"`
+	AccessedTime         int64          `json:"accessed_time" gorm:"bigint"`
+	ExpiredTime          int64          `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	RemainQuota          int            `json:"remain_quota" gorm:"default:0"`
+	UnlimitedQuota       bool           `json:"unlimited_quota"`
+	ModelLimitsEnabled   bool           `json:"model_limits_enabled"`
+	ModelLimits          string         `json:"model_limits" gorm:"type:text"`
+	AllowIps             *string        `json:"allow_ips" gorm:"default:''"`
+	UsedQuota            int            `json:"used_quota" gorm:"default:0"` // used quota
+	Group                string         `json:"group" gorm:"default:''"`
+	CrossGroupRetry      bool           `json:"cross_group_retry"`                                // 跨分组重试，仅auto分组有效
+	TpmLimit             int            `json:"tpm_limit" gorm:"default:0"`                       // 每分钟令牌用量上限，0 表示不限制
+	ConcurrencyLimit     int            `json:"concurrency_limit" gorm:"default:0"`               // 最大并发请求数，0 表示不限制
+	ResponseCacheEnabled bool           `json:"response_cache_enabled"`                           // 开启上游响应缓存（需全局启用响应缓存）
+	GuardProfile         string         `json:"guard_profile" gorm:"type:varchar(64);default:''"` // 输入/输出防护方案，空表示使用分组配置
+	PrevKey              string         `json:"-" gorm:"type:varchar(48);index;default:''"`       // 轮换前的密钥摘要，宽限期内仍可使用
+	PrevKeyExpiredTime   int64          `json:"prev_key_expired_time" gorm:"bigint;default:0"`
+	DeletedAt            gorm.DeletedAt `gorm:"index"`
 }
 
 func (token *Token) Clean() {
@@ -48,11 +55,18 @@ func MaskTokenKey(key string) string {
 	return key[:4] + "**********" + key[len(key)-4:]
 }
 
+// GetFullKey 返回明文密钥，仅未迁移的旧令牌可用，已摘要保存的令牌返回空字符串
 func (token *Token) GetFullKey() string {
+	if token.IsKeyHashed() {
+		return ""
+	}
 	return token.Key
 }
 
 func (token *Token) GetMaskedKey() string {
+	if token.IsKeyHashed() {
+		return token.KeyPrefix + "**********"
+	}
 	return MaskTokenKey(token.Key)
 }
 
@@ -161,12 +175,17 @@ func SearchUserTokens(userId int, keyword string, token string, offset int, limi
 		}
 		baseQuery = baseQuery.Where("name LIKE ? ESCAPE '!'", keywordPattern)
 	}
+	// 密钥只保存摘要：完整密钥按摘要精确匹配，其余按展示前缀匹配
 	if token != "" {
 		tokenPattern, err := sanitizeLikePattern(token)
 		if err != nil {
 			return nil, 0, err
 		}
-		baseQuery = baseQuery.Where(commonKeyCol+" LIKE ? ESCAPE '!'", tokenPattern)
+		if strings.Contains(tokenPattern, "%") {
+			baseQuery = baseQuery.Where("key_prefix LIKE ? ESCAPE '!'", tokenPattern)
+		} else {
+			baseQuery = baseQuery.Where(commonKeyCol+" = ? OR key_prefix = ?", HashTokenKey(token), token)
+		}
 	}
 
 	// 先查匹配总数（用于分页，受 maxTokens 上限保护，避免全表 COUNT）
@@ -190,6 +209,19 @@ func ValidateUserToken(key string) (token *Token, err error) {
 		return nil, errors.New("未提供令牌")
 	}
 	token, err = GetTokenByKey(key, false)
+	return validateUserToken(token, err, key)
+}
+
+// ValidateUserTokenByKeyHash 按已保存的密钥摘要校验令牌，仅供批处理等服务端内部重放的请求使用
+func ValidateUserTokenByKeyHash(keyHash string) (token *Token, err error) {
+	if keyHash == "" {
+		return nil, errors.New("未提供令牌")
+	}
+	token, err = GetTokenByKeyHash(keyHash, false)
+	return validateUserToken(token, err, keyHash)
+}
+
+func validateUserToken(token *Token, err error, key string) (*Token, error) {
 	if err == nil {
 		if token.Status == common.TokenStatusExhausted {
 			keyPrefix := key[:3]
@@ -261,7 +293,34 @@ func GetTokenById(id int) (*Token, error) {
 	return &token, err
 }
 
+// GetTokenByKey 按用户提交的明文密钥获取令牌，缓存以密钥摘要为键。
+// 轮换宽限期内的旧密钥与尚未迁移的明文令牌只查询数据库，不写入缓存。
 func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
+	keyHash := HashTokenKey(key)
+	defer func() {
+		// Update Redis cache asynchronously on successful DB read
+		if shouldUpdateRedis(fromDB, err) && token != nil && token.IsKeyHashed() {
+			gopool.Go(func() {
+				if err := cacheSetToken(*token); err != nil {
+					common.SysLog("failed to update user status cache: " + err.Error())
+				}
+			})
+		}
+	}()
+	if !fromDB && common.RedisEnabled {
+		// Try Redis first
+		token, err := cacheGetTokenByKey(keyHash)
+		if err == nil {
+			return token, nil
+		}
+		// Don't return error - fall through to DB
+	}
+	fromDB = true
+	return getTokenByKeyFromDB(key, keyHash)
+}
+
+// GetTokenByKeyHash 按数据库中保存的 key（即 Token.Key、上下文中的 token_key）获取令牌
+func GetTokenByKeyHash(keyHash string, fromDB bool) (token *Token, err error) {
 	defer func() {
 		// Update Redis cache asynchronously on successful DB read
 		if shouldUpdateRedis(fromDB, err) && token != nil {
@@ -274,14 +333,14 @@ func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
 	}()
 	if !fromDB && common.RedisEnabled {
 		// Try Redis first
-		token, err := cacheGetTokenByKey(key)
+		token, err := cacheGetTokenByKey(keyHash)
 		if err == nil {
 			return token, nil
 		}
 		// Don't return error - fall through to DB
 	}
 	fromDB = true
-	err = DB.Where(commonKeyCol+" = ?", key).First(&token).Error
+	err = DB.Where(commonKeyCol+" = ?", keyHash).First(&token).Error
 	return token, err
 }
 
@@ -304,7 +363,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "tpm_limit", "concurrency_limit", "response_cache_enabled", "guard_profile").Updates(token).Error
 	return err
 }
 
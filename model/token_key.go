@@ -0,0 +1,185 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+const (
+	// TokenKeySecretOption 令牌摘要使用的部署级盐值，首次启动时生成并保存在 options 表中，不可通过配置接口修改
+	TokenKeySecretOption = "TokenKeySecret"
+	// TokenKeyPrefixLength 明文密钥保留用于展示的前缀长度
+	TokenKeyPrefixLength = 8
+	// TokenKeyRotateMaxGracePeriod 轮换密钥时旧密钥最长的宽限时间（秒）
+	TokenKeyRotateMaxGracePeriod = 7 * 24 * 3600
+
+	tokenKeyMigrateBatchSize = 500
+)
+
+var tokenKeySecret string
+
+// HashTokenKey 计算令牌密钥的摘要：以部署级盐值做 HMAC-SHA256，取前 48 位十六进制，
+// 与原 char(48) 的 key 列长度一致，无需变更列类型即可复用唯一索引。
+func HashTokenKey(key string) string {
+	h := hmac.New(sha256.New, []byte(tokenKeySecret))
+	h.Write([]byte(key))
+	return hex.EncodeToString(h.Sum(nil))[:48]
+}
+
+func tokenKeyPrefix(key string) string {
+	if len(key) <= TokenKeyPrefixLength {
+		return ""
+	}
+	return key[:TokenKeyPrefixLength]
+}
+
+// initTokenKeySecret 读取令牌摘要盐值，不存在时生成。多个节点同时启动时只有一个节点能写入成功，其余节点重新读取。
+func initTokenKeySecret() error {
+	secret, err := common.GenerateRandomCharsKey(64)
+	if err != nil {
+		return err
+	}
+	option := Option{}
+	err = DB.Where(Option{Key: TokenKeySecretOption}).Attrs(Option{Value: secret}).FirstOrCreate(&option).Error
+	if err != nil {
+		if err = DB.Where(Option{Key: TokenKeySecretOption}).First(&option).Error; err != nil {
+			return err
+		}
+	}
+	if option.Value == "" {
+		return errors.New("token key secret is empty")
+	}
+	tokenKeySecret = option.Value
+	return nil
+}
+
+// SetKey 为令牌设置新的明文密钥，数据库中只保存摘要与展示前缀
+func (token *Token) SetKey(key string) {
+	token.Key = HashTokenKey(key)
+	token.KeyPrefix = tokenKeyPrefix(key)
+}
+
+// IsKeyHashed 旧版本创建、尚未迁移的令牌 key 列仍为明文，KeyPrefix 为空
+func (token *Token) IsKeyHashed() bool {
+	return token.KeyPrefix != ""
+}
+
+// matchKey 校验按 key / prev_key 查询出的令牌是否确实与明文密钥匹配。
+// 明文回退只对未迁移的令牌生效，避免泄露的摘要被当作密钥使用。
+func (token *Token) matchKey(key string, keyHash string) bool {
+	if token.IsKeyHashed() {
+		if token.Key == keyHash {
+			return true
+		}
+		return token.PrevKey == keyHash && token.PrevKeyExpiredTime > common.GetTimestamp()
+	}
+	return token.Key == key
+}
+
+// RotateTokenKey 为令牌生成新的密钥并返回明文，gracePeriod 秒内旧密钥仍可使用，为 0 时旧密钥立即失效
+func RotateTokenKey(id int, userId int, gracePeriod int64) (string, *Token, error) {
+	if gracePeriod < 0 || gracePeriod > TokenKeyRotateMaxGracePeriod {
+		return "", nil, fmt.Errorf("宽限时间需在 0 到 %d 秒之间", TokenKeyRotateMaxGracePeriod)
+	}
+	token, err := GetTokenByIds(id, userId)
+	if err != nil {
+		return "", nil, err
+	}
+	key, err := common.GenerateKey()
+	if err != nil {
+		return "", nil, err
+	}
+	oldKey := token.Key
+	prevKey := ""
+	var prevKeyExpiredTime int64
+	if gracePeriod > 0 {
+		prevKey = oldKey
+		if !token.IsKeyHashed() {
+			prevKey = HashTokenKey(oldKey)
+		}
+		prevKeyExpiredTime = common.GetTimestamp() + gracePeriod
+	}
+	token.SetKey(key)
+	token.PrevKey = prevKey
+	token.PrevKeyExpiredTime = prevKeyExpiredTime
+	err = DB.Model(token).Select("key", "key_prefix", "prev_key", "prev_key_expired_time").Updates(token).Error
+	if err != nil {
+		return "", nil, err
+	}
+	invalidateClientTokenParent(token.Id)
+	if common.RedisEnabled {
+		// 同步删除旧缓存，避免宽限期结束后旧密钥仍能命中缓存
+		if err := cacheDeleteToken(oldKey); err != nil {
+			common.SysLog("failed to delete token cache: " + err.Error())
+		}
+	}
+	return key, token, nil
+}
+
+// migrateTokenKeysToHash 将旧版本明文保存的令牌密钥改为摘要，按 id 分批处理，包括已软删除的令牌。
+// 迁移期间未处理的令牌通过 GetTokenByKey 的明文回退继续可用；旧的 Redis 缓存不再被查询，随过期时间自然清理。
+func migrateTokenKeysToHash() {
+	lastId := 0
+	migrated := 0
+	for {
+		var tokens []Token
+		err := DB.Unscoped().Select("id", commonKeyCol).
+			Where("id > ? AND (key_prefix = ? OR key_prefix IS NULL)", lastId, "").
+			Order("id").Limit(tokenKeyMigrateBatchSize).Find(&tokens).Error
+		if err != nil {
+			common.SysError("failed to migrate token keys: " + err.Error())
+			return
+		}
+		if len(tokens) == 0 {
+			break
+		}
+		for _, token := range tokens {
+			lastId = token.Id
+			hashed := Token{}
+			hashed.SetKey(token.Key)
+			if hashed.KeyPrefix == "" {
+				continue
+			}
+			err = DB.Unscoped().Model(&Token{}).
+				Where("id = ? AND "+commonKeyCol+" = ?", token.Id, token.Key).
+				Updates(map[string]interface{}{
+					"key":        hashed.Key,
+					"key_prefix": hashed.KeyPrefix,
+				}).Error
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to migrate key of token %d: %s", token.Id, err.Error()))
+				continue
+			}
+			migrated++
+		}
+		if len(tokens) < tokenKeyMigrateBatchSize {
+			break
+		}
+	}
+	if migrated > 0 {
+		common.SysLog(fmt.Sprintf("migrated %d plaintext token keys to hash", migrated))
+	}
+}
+
+// getTokenByKeyFromDB 按明文密钥查询令牌：依次匹配摘要、轮换宽限期内的旧摘要以及未迁移的明文
+func getTokenByKeyFromDB(key string, keyHash string) (*Token, error) {
+	var tokens []*Token
+	err := DB.Where(commonKeyCol+" IN ?", []string{keyHash, key}).
+		Or("prev_key = ? AND prev_key_expired_time > ?", keyHash, common.GetTimestamp()).
+		Limit(3).Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if token.matchKey(key, keyHash) {
+			return token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func insertHashedToken(t *testing.T, userId int, key string) *Token {
+	t.Helper()
+	token := &Token{UserId: userId, Name: "t", Status: common.TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true}
+	token.SetKey(key)
+	require.NoError(t, DB.Create(token).Error)
+	return token
+}
+
+func TestTokenKeyStoredAsHash(t *testing.T) {
+	truncateTables(t)
+	key := "abcdefgh1234567890abcdefgh1234567890abcdefgh1234"
+	token := insertHashedToken(t, 1, key)
+	require.NotEqual(t, key, token.Key)
+	require.Len(t, token.Key, 48)
+	require.Equal(t, "abcdefgh**********", token.GetMaskedKey())
+	require.Empty(t, token.GetFullKey())
+
+	got, err := GetTokenByKey(key, false)
+	require.NoError(t, err)
+	require.Equal(t, token.Id, got.Id)
+
+	// 泄露的摘要不能当作密钥使用
+	_, err = GetTokenByKey(token.Key, false)
+	require.Error(t, err)
+
+	got, err = GetTokenByKeyHash(token.Key, false)
+	require.NoError(t, err)
+	require.Equal(t, token.Id, got.Id)
+}
+
+func TestRotateTokenKeyGracePeriod(t *testing.T) {
+	truncateTables(t)
+	oldKey := "oldkey001234567890abcdefgh1234567890abcdefgh1234"
+	token := insertHashedToken(t, 1, oldKey)
+
+	_, _, err := RotateTokenKey(token.Id, 2, 0)
+	require.Error(t, err)
+	_, _, err = RotateTokenKey(token.Id, 1, TokenKeyRotateMaxGracePeriod+1)
+	require.Error(t, err)
+
+	newKey, rotated, err := RotateTokenKey(token.Id, 1, 3600)
+	require.NoError(t, err)
+	require.NotEqual(t, oldKey, newKey)
+	require.Equal(t, HashTokenKey(newKey), rotated.Key)
+
+	for _, key := range []string{oldKey, newKey} {
+		got, err := GetTokenByKey(key, false)
+		require.NoError(t, err)
+		require.Equal(t, token.Id, got.Id)
+	}
+
+	// 宽限期结束后旧密钥失效
+	require.NoError(t, DB.Model(&Token{}).Where("id = ?", token.Id).Update("prev_key_expired_time", common.GetTimestamp()-1).Error)
+	_, err = GetTokenByKey(oldKey, false)
+	require.Error(t, err)
+
+	latestKey, _, err := RotateTokenKey(token.Id, 1, 0)
+	require.NoError(t, err)
+	_, err = GetTokenByKey(newKey, false)
+	require.Error(t, err)
+	_, err = GetTokenByKey(latestKey, false)
+	require.NoError(t, err)
+}
+
+func TestMigrateTokenKeysToHash(t *testing.T) {
+	truncateTables(t)
+	legacyKey := "legacy01234567890abcdefgh1234567890abcdefgh1234"
+	legacy := &Token{UserId: 1, Name: "legacy", Key: legacyKey, Status: common.TokenStatusEnabled, ExpiredTime: -1}
+	require.NoError(t, DB.Create(legacy).Error)
+	require.Equal(t, legacyKey, legacy.GetFullKey())
+
+	// 迁移前按明文回退查询
+	got, err := GetTokenByKey(legacyKey, false)
+	require.NoError(t, err)
+	require.Equal(t, legacy.Id, got.Id)
+
+	migrateTokenKeysToHash()
+
+	var stored Token
+	require.NoError(t, DB.First(&stored, legacy.Id).Error)
+	require.Equal(t, HashTokenKey(legacyKey), stored.Key)
+	require.Equal(t, legacyKey[:TokenKeyPrefixLength], stored.KeyPrefix)
+	got, err = GetTokenByKey(legacyKey, false)
+	require.NoError(t, err)
+	require.Equal(t, legacy.Id, got.Id)
+}
@@ -126,3 +126,40 @@ func GetAllQuotaDates(startTime int64, endTime int64, username string) (quotaDat
 	err = DB.Table("quota_data").Select("model_name, sum(count) as count, sum(quota) as quota, sum(token_used) as token_used, created_at").Where("created_at >= ? and created_at <= ?", startTime, endTime).Group("model_name, created_at").Find(&quotaDatas).Error
 	return quotaDatas, err
 }
+
+// QuotaDataSummary 一段时间内按用户或模型汇总的用量
+type QuotaDataSummary struct {
+	Name      string `json:"name"`
+	Count     int64  `json:"count"`
+	Quota     int64  `json:"quota"`
+	TokenUsed int64  `json:"token_used"`
+}
+
+const (
+	QuotaDataDimensionUser  = "username"
+	QuotaDataDimensionModel = "model_name"
+)
+
+// SumQuotaData 汇总 [startTime, endTime) 内的总用量
+func SumQuotaData(startTime int64, endTime int64) (summary QuotaDataSummary, err error) {
+	err = DB.Table("quota_data").
+		Select("COALESCE(sum(count), 0) as count, COALESCE(sum(quota), 0) as quota, COALESCE(sum(token_used), 0) as token_used").
+		Where("created_at >= ? and created_at < ?", startTime, endTime).
+		Scan(&summary).Error
+	return summary, err
+}
+
+// GetQuotaDataSummary 按用户或模型汇总 [startTime, endTime) 内的用量，按消耗额度倒序返回前 limit 条
+func GetQuotaDataSummary(startTime int64, endTime int64, dimension string, limit int) (summaries []*QuotaDataSummary, err error) {
+	if dimension != QuotaDataDimensionUser && dimension != QuotaDataDimensionModel {
+		return nil, fmt.Errorf("unsupported quota data dimension: %s", dimension)
+	}
+	err = DB.Table("quota_data").
+		Select(dimension+" as name, sum(count) as count, sum(quota) as quota, sum(token_used) as token_used").
+		Where("created_at >= ? and created_at < ?", startTime, endTime).
+		Group(dimension).
+		Order("quota desc").
+		Limit(limit).
+		Scan(&summaries).Error
+	return summaries, err
+}
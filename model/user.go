@@ -51,18 +51,22 @@ type User struct {
 	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
 	StripeCustomer   string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
 	PersonalRatio    float64        `json:"personal_ratio" gorm:"type:double precision;default:1;column:personal_ratio"`
+	TpmLimit         int            `json:"tpm_limit" gorm:"type:int;default:0"`         // 每分钟令牌用量上限，0 表示不限制
+	ConcurrencyLimit int            `json:"concurrency_limit" gorm:"type:int;default:0"` // 最大并发请求数，0 表示不限制
 }
 
 func (user *User) ToBaseUser() *UserBase {
 	cache := &UserBase{
-		Id:            user.Id,
-		Group:         user.Group,
-		Quota:         user.Quota,
-		Status:        user.Status,
-		Username:      user.Username,
-		Setting:       user.Setting,
-		Email:         user.Email,
-		PersonalRatio: user.PersonalRatio,
+		Id:               user.Id,
+		Group:            user.Group,
+		Quota:            user.Quota,
+		Status:           user.Status,
+		Username:         user.Username,
+		Setting:          user.Setting,
+		Email:            user.Email,
+		PersonalRatio:    user.PersonalRatio,
+		TpmLimit:         user.TpmLimit,
+		ConcurrencyLimit: user.ConcurrencyLimit,
 	}
 	return cache
 }
@@ -533,12 +537,14 @@ func (user *User) Edit(updatePassword bool) error {
 	oldGroup := oldUser.Group
 
 	updates := map[string]interface{}{
-		"username":       newUser.Username,
-		"display_name":   newUser.DisplayName,
-		"group":          newUser.Group,
-		"quota":          newUser.Quota,
-		"remark":         newUser.Remark,
-		"personal_ratio": newUser.PersonalRatio,
+		"username":          newUser.Username,
+		"display_name":      newUser.DisplayName,
+		"group":             newUser.Group,
+		"quota":             newUser.Quota,
+		"remark":            newUser.Remark,
+		"personal_ratio":    newUser.PersonalRatio,
+		"tpm_limit":         newUser.TpmLimit,
+		"concurrency_limit": newUser.ConcurrencyLimit,
 	}
 	if updatePassword {
 		updates["password"] = newUser.Password
@@ -15,14 +15,16 @@ import (
 
 // UserBase struct remains the same as it represents the cached data structure
 type UserBase struct {
-	Id            int     `json:"id"`
-	Group         string  `json:"group"`
-	Email         string  `json:"email"`
-	Quota         int     `json:"quota"`
-	Status        int     `json:"status"`
-	Username      string  `json:"username"`
-	Setting       string  `json:"setting"`
-	PersonalRatio float64 `json:"personal_ratio"`
+	Id               int     `json:"id"`
+	Group            string  `json:"group"`
+	Email            string  `json:"email"`
+	Quota            int     `json:"quota"`
+	Status           int     `json:"status"`
+	Username         string  `json:"username"`
+	Setting          string  `json:"setting"`
+	PersonalRatio    float64 `json:"personal_ratio"`
+	TpmLimit         int     `json:"tpm_limit"`
+	ConcurrencyLimit int     `json:"concurrency_limit"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -33,6 +35,8 @@ func (user *UserBase) WriteContext(c *gin.Context) {
 	common.SetContextKey(c, constant.ContextKeyUserName, user.Username)
 	common.SetContextKey(c, constant.ContextKeyUserSetting, user.GetSetting())
 	common.SetContextKey(c, constant.ContextKeyPersonalRatio, user.PersonalRatio)
+	common.SetContextKey(c, constant.ContextKeyUserTpmLimit, user.TpmLimit)
+	common.SetContextKey(c, constant.ContextKeyUserConcurrencyLimit, user.ConcurrencyLimit)
 }
 
 func (user *UserBase) GetSetting() dto.UserSetting {
@@ -102,14 +106,16 @@ func GetUserCache(userId int) (userCache *UserBase, err error) {
 
 	// Create cache object from user data
 	userCache = &UserBase{
-		Id:            user.Id,
-		Group:         user.Group,
-		Quota:         user.Quota,
-		Status:        user.Status,
-		Username:      user.Username,
-		Setting:       user.Setting,
-		Email:         user.Email,
-		PersonalRatio: user.PersonalRatio,
+		Id:               user.Id,
+		Group:            user.Group,
+		Quota:            user.Quota,
+		Status:           user.Status,
+		Username:         user.Username,
+		Setting:          user.Setting,
+		Email:            user.Email,
+		PersonalRatio:    user.PersonalRatio,
+		TpmLimit:         user.TpmLimit,
+		ConcurrencyLimit: user.ConcurrencyLimit,
 	}
 
 	return userCache, nil
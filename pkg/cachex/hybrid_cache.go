@@ -7,6 +7,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/QuantumNous/new-api/pkg/metrics"
+
 	"github.com/go-redis/redis/v8"
 	"github.com/samber/hot"
 )
@@ -83,6 +85,9 @@ func (c *HybridCache[V]) Get(key string) (value V, found bool, err error) {
 		var zero V
 		return zero, false, nil
 	}
+	defer func() {
+		c.observeGet(found, err)
+	}()
 
 	if c.redisOn() {
 		ctx, cancel := context.WithTimeout(context.Background(), defaultRedisOpTimeout)
@@ -108,6 +113,17 @@ func (c *HybridCache[V]) Get(key string) (value V, found bool, err error) {
 	return c.memCache().Get(full)
 }
 
+func (c *HybridCache[V]) observeGet(found bool, err error) {
+	result := "miss"
+	switch {
+	case err != nil:
+		result = "error"
+	case found:
+		result = "hit"
+	}
+	metrics.IncCacheRequest(strings.TrimRight(string(c.ns), ":"), result)
+}
+
 func (c *HybridCache[V]) SetWithTTL(key string, v V, ttl time.Duration) error {
 	full := c.ns.FullKey(key)
 	if full == "" {
@@ -173,6 +189,15 @@ func (c *HybridCache[V]) Purge() error {
 	return nil
 }
 
+// StopJanitor stops the background janitor of the in-memory cache, if one was built.
+// Call it when the cache is being replaced; the cache stays usable but expired entries are no longer swept.
+func (c *HybridCache[V]) StopJanitor() {
+	c.memOnce.Do(func() {})
+	if c.mem != nil {
+		c.mem.StopJanitor()
+	}
+}
+
 func (c *HybridCache[V]) DeleteByPrefix(prefix string) (int, error) {
 	fullPrefix := c.ns.FullKey(prefix)
 	if fullPrefix == "" {
@@ -0,0 +1,168 @@
+// Package metrics exposes gateway metrics in the Prometheus / OpenMetrics format.
+//
+// All collectors live in a dedicated registry so the /metrics output only contains
+// gateway metrics plus the standard Go runtime and process collectors.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "newapi"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300}
+	ttftBuckets    = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30}
+
+	relayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_requests_total",
+		Help:      "Relay attempts sent to upstream channels, by response status code.",
+	}, []string{"model", "channel", "group", "status"})
+
+	relayErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_errors_total",
+		Help:      "Failed relay attempts by error code.",
+	}, []string{"model", "channel", "group", "error_code"})
+
+	relayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_request_duration_seconds",
+		Help:      "Total duration of relay attempts.",
+		Buckets:   latencyBuckets,
+	}, []string{"model", "channel", "group"})
+
+	relayTTFT = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_time_to_first_token_seconds",
+		Help:      "Time to first token of successful streaming relay attempts.",
+		Buckets:   ttftBuckets,
+	}, []string{"model", "channel", "group"})
+
+	relayRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_retries_total",
+		Help:      "Relay attempts that were retries of a previously failed attempt.",
+	}, []string{"model", "group"})
+
+	tokensConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_consumed_total",
+		Help:      "Billed tokens by type (prompt or completion).",
+	}, []string{"model", "channel", "group", "type"})
+
+	quotaConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_consumed_total",
+		Help:      "Billed quota.",
+	}, []string{"model", "channel", "group"})
+
+	channelStatusEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_status_events_total",
+		Help:      "Automatic channel enable and disable events.",
+	}, []string{"channel", "event"})
+
+	taskPollingBacklog = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "task_polling_backlog",
+		Help:      "Unfinished async tasks seen by the last task polling round, by platform.",
+	}, []string{"platform"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Hybrid cache lookups by result (hit, miss or error).",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		relayRequests,
+		relayErrors,
+		relayDuration,
+		relayTTFT,
+		relayRetries,
+		tokensConsumed,
+		quotaConsumed,
+		channelStatusEvents,
+		taskPollingBacklog,
+		cacheRequests,
+	)
+}
+
+// Handler returns the HTTP handler serving the registry in the Prometheus text or OpenMetrics format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// RegisterGaugeFunc registers a gauge whose value is read on every scrape.
+func RegisterGaugeFunc(name string, help string, fn func() float64) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+func channelLabel(channelId int) string {
+	return strconv.Itoa(channelId)
+}
+
+// ObserveRelayAttempt records one relay attempt. errorCode is empty for successful attempts
+// and ttft is zero when the attempt did not stream a response.
+func ObserveRelayAttempt(model string, group string, channelId int, statusCode int, errorCode string, duration time.Duration, ttft time.Duration) {
+	channel := channelLabel(channelId)
+	relayRequests.WithLabelValues(model, channel, group, strconv.Itoa(statusCode)).Inc()
+	relayDuration.WithLabelValues(model, channel, group).Observe(duration.Seconds())
+	if errorCode != "" {
+		relayErrors.WithLabelValues(model, channel, group, errorCode).Inc()
+	}
+	if ttft > 0 {
+		relayTTFT.WithLabelValues(model, channel, group).Observe(ttft.Seconds())
+	}
+}
+
+func IncRelayRetry(model string, group string) {
+	relayRetries.WithLabelValues(model, group).Inc()
+}
+
+func AddConsumption(model string, group string, channelId int, promptTokens int, completionTokens int, quota int) {
+	channel := channelLabel(channelId)
+	if promptTokens > 0 {
+		tokensConsumed.WithLabelValues(model, channel, group, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		tokensConsumed.WithLabelValues(model, channel, group, "completion").Add(float64(completionTokens))
+	}
+	if quota > 0 {
+		quotaConsumed.WithLabelValues(model, channel, group).Add(float64(quota))
+	}
+}
+
+func IncChannelStatusEvent(channelId int, event string) {
+	channelStatusEvents.WithLabelValues(channelLabel(channelId), event).Inc()
+}
+
+// SetTaskPollingBacklog replaces the backlog gauge with the counts of the latest polling round.
+func SetTaskPollingBacklog(backlog map[string]int) {
+	taskPollingBacklog.Reset()
+	for platform, count := range backlog {
+		taskPollingBacklog.WithLabelValues(platform).Set(float64(count))
+	}
+}
+
+func IncCacheRequest(cache string, result string) {
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerExportsRelayMetrics(t *testing.T) {
+	ObserveRelayAttempt("gpt-4o", "default", 7, http.StatusTooManyRequests, "rate_limit_exceeded", 2*time.Second, 0)
+	AddConsumption("gpt-4o", "default", 7, 100, 20, 300)
+	SetTaskPollingBacklog(map[string]int{"suno": 3})
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	body := recorder.Body.String()
+	require.Contains(t, body, `newapi_relay_requests_total{channel="7",group="default",model="gpt-4o",status="429"} 1`)
+	require.Contains(t, body, `newapi_relay_errors_total{channel="7",error_code="rate_limit_exceeded",group="default",model="gpt-4o"} 1`)
+	require.Contains(t, body, `newapi_tokens_consumed_total{channel="7",group="default",model="gpt-4o",type="completion"} 20`)
+	require.Contains(t, body, `newapi_task_polling_backlog{platform="suno"} 3`)
+}
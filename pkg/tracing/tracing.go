@@ -0,0 +1,252 @@
+// Package tracing wires OpenTelemetry tracing into the relay pipeline.
+//
+// Spans are carried in the gin request context: StartSpan replaces c.Request's context with the
+// child span context and End restores the parent, so sequential stages become siblings under the
+// server span and nested stages (e.g. the upstream request inside a retry attempt) become children.
+// When tracing is not started every helper is a no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/QuantumNous/new-api"
+
+const (
+	ProtocolHTTP = "http/protobuf"
+	ProtocolGRPC = "grpc"
+)
+
+type Config struct {
+	// Endpoint OTLP 接收端地址，如 http://otel-collector:4318 或 otel-collector:4317
+	Endpoint    string
+	Protocol    string
+	Insecure    bool
+	Headers     map[string]string
+	SampleRatio float64
+	ServiceName string
+	Version     string
+}
+
+var enabled atomic.Bool
+
+// Enabled reports whether a tracer provider has been started.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// ConfigFromEnv reads the standard OTEL_* variables. Tracing is disabled when
+// OTEL_EXPORTER_OTLP_ENDPOINT is empty.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Endpoint:    common.GetEnvOrDefaultString("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		Protocol:    common.GetEnvOrDefaultString("OTEL_EXPORTER_OTLP_PROTOCOL", ProtocolHTTP),
+		Insecure:    common.GetEnvOrDefaultBool("OTEL_EXPORTER_OTLP_INSECURE", false),
+		Headers:     map[string]string{},
+		SampleRatio: 1,
+		ServiceName: common.GetEnvOrDefaultString("OTEL_SERVICE_NAME", "new-api"),
+		Version:     common.Version,
+	}
+	for _, pair := range strings.Split(common.GetEnvOrDefaultString("OTEL_EXPORTER_OTLP_HEADERS", ""), ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok && strings.TrimSpace(key) != "" {
+			cfg.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if ratio, err := strconv.ParseFloat(common.GetEnvOrDefaultString("OTEL_TRACES_SAMPLER_ARG", "1"), 64); err == nil {
+		cfg.SampleRatio = ratio
+	}
+	return cfg
+}
+
+// Start installs a global tracer provider exporting spans over OTLP and the W3C trace context
+// propagator. The returned function flushes and stops the exporter.
+func Start(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "new-api"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(cfg.Version),
+	))
+	if err != nil {
+		return nil, err
+	}
+	ratio := cfg.SampleRatio
+	if ratio < 0 || ratio > 1 {
+		ratio = 1
+	}
+	return StartWithExporter(exporter, res, sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))), nil
+}
+
+// StartWithExporter installs a tracer provider using the given exporter; used by Start and by tests
+// that export to an in-memory or local collector.
+func StartWithExporter(exporter sdktrace.SpanExporter, res *resource.Resource, sampler sdktrace.Sampler) func(context.Context) error {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+	}
+	if res != nil {
+		opts = append(opts, sdktrace.WithResource(res))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	enabled.Store(true)
+	return func(ctx context.Context) error {
+		enabled.Store(false)
+		return provider.Shutdown(ctx)
+	}
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	endpoint := cfg.Endpoint
+	insecure := cfg.Insecure
+	if strings.HasPrefix(endpoint, "http://") {
+		insecure = true
+	}
+	switch strings.ToLower(cfg.Protocol) {
+	case ProtocolGRPC:
+		endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://")
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithHeaders(cfg.Headers)}
+		if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case "", "http", ProtocolHTTP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithHeaders(cfg.Headers)}
+		if strings.Contains(endpoint, "://") {
+			opts = append(opts, otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/traces"))
+		} else {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+			if insecure {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol: %s", cfg.Protocol)
+	}
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Span is a pipeline stage span bound to a gin request. A nil *Span is valid and does nothing.
+type Span struct {
+	c         *gin.Context
+	span      trace.Span
+	parentCtx context.Context
+	ended     atomic.Bool
+}
+
+// StartServerSpan starts the root span of an incoming request, continuing a trace from the
+// caller's traceparent header when present.
+func StartServerSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) *Span {
+	if !Enabled() {
+		return nil
+	}
+	ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+	return startSpan(c, ctx, name, trace.SpanKindServer, attrs)
+}
+
+// StartSpan starts a child span of the span currently carried by the request.
+func StartSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) *Span {
+	if !Enabled() || c == nil || c.Request == nil {
+		return nil
+	}
+	return startSpan(c, c.Request.Context(), name, trace.SpanKindInternal, attrs)
+}
+
+// StartClientSpan starts a span for an outbound request to an upstream provider.
+func StartClientSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) *Span {
+	if !Enabled() || c == nil || c.Request == nil {
+		return nil
+	}
+	return startSpan(c, c.Request.Context(), name, trace.SpanKindClient, attrs)
+}
+
+func startSpan(c *gin.Context, parent context.Context, name string, kind trace.SpanKind, attrs []attribute.KeyValue) *Span {
+	ctx, span := tracer().Start(parent, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+	s := &Span{c: c, span: span, parentCtx: c.Request.Context()}
+	c.Request = c.Request.WithContext(ctx)
+	return s
+}
+
+func (s *Span) SetAttributes(attrs ...attribute.KeyValue) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attrs...)
+}
+
+// Inject writes the span's trace context into outbound request headers (W3C traceparent).
+func (s *Span) Inject(header http.Header) {
+	if s == nil {
+		return
+	}
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpan(context.Background(), s.span), propagation.HeaderCarrier(header))
+}
+
+// End ends the span, marks it failed when err is not nil, and restores the parent span on the
+// request. Calling End more than once is allowed; only the first call has effect.
+func (s *Span) End(err error) {
+	if s == nil || !s.ended.CompareAndSwap(false, true) {
+		return
+	}
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+	s.c.Request = s.c.Request.WithContext(s.parentCtx)
+}
+
+// EndAPIError ends the span with a relay error, recording its status code and error code.
+func (s *Span) EndAPIError(err *types.NewAPIError) {
+	if s == nil {
+		return
+	}
+	if err == nil {
+		s.End(nil)
+		return
+	}
+	s.span.SetAttributes(
+		attribute.Int("http.response.status_code", err.StatusCode),
+		attribute.String("error.code", string(err.GetErrorCode())),
+	)
+	s.End(err)
+}
+
+// SetAttributes adds attributes to the span currently carried by the request.
+func SetAttributes(c *gin.Context, attrs ...attribute.KeyValue) {
+	if !Enabled() || c == nil || c.Request == nil {
+		return
+	}
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attrs...)
+}
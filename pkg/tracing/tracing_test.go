@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collectorStandIn 模拟 OTLP/HTTP 接收端，记录收到的 span 名称
+type collectorStandIn struct {
+	mu    sync.Mutex
+	spans map[string]string // span name -> parent span id (hex)
+	ids   map[string]string // span name -> span id (hex)
+}
+
+func (s *collectorStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				s.spans[span.GetName()] = string(span.GetParentSpanId())
+				s.ids[span.GetName()] = string(span.GetSpanId())
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestSpansExportedAndPropagated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	collector := &collectorStandIn{spans: map[string]string{}, ids: map[string]string{}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	shutdown, err := Start(context.Background(), Config{Endpoint: server.URL, Protocol: ProtocolHTTP, SampleRatio: 1})
+	require.NoError(t, err)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	root := StartServerSpan(c, "POST /v1/chat/completions")
+	attempt := StartSpan(c, "relay.attempt")
+	upstream := StartClientSpan(c, "upstream.request")
+	outbound := http.Header{}
+	upstream.Inject(outbound)
+	upstream.End(nil)
+	attempt.End(nil)
+	attempt.End(nil) // 重复调用不应产生影响
+	root.End(nil)
+
+	traceparent := outbound.Get("traceparent")
+	require.Regexp(t, `^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01$`, traceparent)
+
+	require.NoError(t, shutdown(context.Background()))
+	require.False(t, Enabled())
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	require.Len(t, collector.spans, 3)
+	require.Equal(t, collector.ids["POST /v1/chat/completions"], collector.spans["relay.attempt"])
+	require.Equal(t, collector.ids["relay.attempt"], collector.spans["upstream.request"])
+}
+
+func TestHelpersAreNoopWhenDisabled(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	original := c.Request
+
+	span := StartSpan(c, "noop")
+	require.Nil(t, span)
+	span.SetAttributes()
+	span.EndAPIError(nil)
+	span.End(nil)
+	require.Same(t, original, c.Request)
+}
@@ -81,3 +81,8 @@ type TaskAdaptor interface {
 type OpenAIVideoConverter interface {
 	ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error)
 }
+
+// ClaudeTokenCounter 支持上游 count_tokens 接口的渠道实现，用于 /v1/messages/count_tokens
+type ClaudeTokenCounter interface {
+	CountClaudeTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (int, error)
+}
@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -12,7 +13,9 @@ import (
 	"time"
 
 	common2 "github.com/QuantumNous/new-api/common"
+	appconstant "github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
@@ -23,6 +26,7 @@ import (
 	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Header) {
@@ -323,6 +327,9 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
 	}
+	if err = applyResponseOverride(c, info, resp); err != nil {
+		return nil, fmt.Errorf("apply response override failed: %w", err)
+	}
 	return resp, nil
 }
 
@@ -356,9 +363,41 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
 	}
+	if err = applyResponseOverride(c, info, resp); err != nil {
+		return nil, fmt.Errorf("apply response override failed: %w", err)
+	}
 	return resp, nil
 }
 
+// applyResponseOverride 按渠道响应覆盖规则改写上游响应头与非流式 JSON 响应体，
+// 流式响应的 SSE 数据块由 helper.StreamScannerHandler 逐块改写
+func applyResponseOverride(c *gin.Context, info *common.RelayInfo, resp *http.Response) error {
+	if resp == nil || (!common.HasResponseOverride(info) && !info.ShouldGuardOutput()) {
+		return nil
+	}
+	var body []byte
+	if resp.StatusCode/100 == 2 && resp.Body != nil && strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		data, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		body, err = common.ApplyResponseOverride(data, info)
+		if err != nil {
+			return err
+		}
+		body = info.ApplyOutputGuard(body)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		resp.Header.Del("Content-Length")
+	}
+	// 上游响应头会被透传到客户端，流式响应的头则直接写在 c.Writer 上，两处都需要改写
+	if err := common.ApplyResponseHeaderOverride(resp.Header, body, info); err != nil {
+		return err
+	}
+	return common.ApplyResponseHeaderOverride(c.Writer.Header(), body, info)
+}
+
 func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*websocket.Conn, error) {
 	fullRequestURL, err := a.GetRequestURL(info)
 	if err != nil {
@@ -379,7 +418,21 @@ func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 		targetHeader.Set(key, value)
 	}
 	targetHeader.Set("Content-Type", c.Request.Header.Get("Content-Type"))
-	targetConn, _, err := websocket.DefaultDialer.Dial(fullRequestURL, targetHeader)
+	span := tracing.StartClientSpan(c, "upstream.websocket_dial", attribute.Int("channel.id", common2.GetContextKeyInt(c, appconstant.ContextKeyChannelId)))
+	span.Inject(targetHeader)
+	dialer := websocket.DefaultDialer
+	tlsConfig, err := service.GetChannelTLSConfig(info.ChannelSetting)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	if tlsConfig != nil {
+		customDialer := *websocket.DefaultDialer
+		customDialer.TLSClientConfig = tlsConfig
+		dialer = &customDialer
+	}
+	targetConn, _, err := dialer.Dial(fullRequestURL, targetHeader)
+	span.End(err)
 	if err != nil {
 		return nil, fmt.Errorf("dial failed to %s: %w", fullRequestURL, err)
 	}
@@ -492,15 +545,14 @@ func DoRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	return doRequest(c, req, info)
 }
 func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http.Response, error) {
-	var client *http.Client
-	var err error
-	if info.ChannelSetting.Proxy != "" {
-		client, err = service.NewProxyHttpClient(info.ChannelSetting.Proxy)
-		if err != nil {
-			return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	client, err := service.GetChannelHttpClient(info.ChannelSetting)
+	if err != nil {
+		return nil, fmt.Errorf("new channel http client failed: %w", err)
+	}
+	if info.ChannelSetting.RequestSigning.IsEnabled() {
+		if err = service.SignRequest(req, info.ChannelSetting.RequestSigning); err != nil {
+			return nil, fmt.Errorf("sign request failed: %w", err)
 		}
-	} else {
-		client = service.GetHttpClient()
 	}
 
 	var stopPinger context.CancelFunc
@@ -523,17 +575,41 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	span := tracing.StartClientSpan(c, "upstream.request",
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+		attribute.Int("channel.id", common2.GetContextKeyInt(c, appconstant.ContextKeyChannelId)),
+	)
+	span.Inject(req.Header)
+	startTime := time.Now()
+	// 首字时限在发出请求前开始计时，连接与等待响应头的时间同样计入
+	deadline := helper.NewStreamFirstTokenDeadline(req.Context(), info, startTime)
+	if deadline != nil {
+		req = req.WithContext(deadline.Context())
+	}
 	resp, err := client.Do(req)
 	if err != nil {
+		span.End(err)
+		if timeoutErr := helper.StreamFirstTokenTimeoutError(c, info, deadline, startTime); timeoutErr != nil {
+			return nil, timeoutErr
+		}
 		logger.LogError(c, "do request failed: "+err.Error())
 		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed, types.ErrOptionWithHideErrMsg("upstream error: do request failed"))
 	}
 	if resp == nil {
+		span.End(errors.New("resp is nil"))
 		return nil, errors.New("resp is nil")
 	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	span.End(nil)
+	service.ApplyUpstreamCooldown(c, resp)
 
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
+	// 首字前等待期间仍由上面的 ping 保活
+	if err = helper.WaitStreamFirstToken(c, info, resp, startTime, deadline); err != nil {
+		return nil, err
+	}
 	return resp, nil
 }
 
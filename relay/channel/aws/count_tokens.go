@@ -0,0 +1,53 @@
+package aws
+
+import (
+	"bytes"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	bedrockruntimeTypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// CountClaudeTokens 通过 Bedrock CountTokens 接口计算 Claude 请求的输入 token 数
+func (a *Adaptor) CountClaudeTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (int, error) {
+	awsModelId := getAwsModelID(info.UpstreamModelName)
+	if isNovaModel(awsModelId) {
+		return 0, errors.New("count tokens is not supported for nova models")
+	}
+	awsCli, err := newAwsClient(c, info)
+	if err != nil {
+		return 0, err
+	}
+
+	requestBody, err := common.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+	awsClaudeReq, err := formatRequest(bytes.NewReader(requestBody), c.Request.Header)
+	if err != nil {
+		return 0, errors.Wrap(err, "format aws request fail")
+	}
+	body, err := common.Marshal(awsClaudeReq)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := newAwsInvokeContext()
+	defer cancel()
+	output, err := awsCli.CountTokens(ctx, &bedrockruntime.CountTokensInput{
+		ModelId: aws.String(awsModelId),
+		Input: &bedrockruntimeTypes.CountTokensInputMemberInvokeModel{
+			Value: bedrockruntimeTypes.InvokeModelTokensRequest{Body: body},
+		},
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "aws count tokens failed")
+	}
+	return int(aws.ToInt32(output.InputTokens)), nil
+}
@@ -0,0 +1,81 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countTokensFields count_tokens 接口接受的字段，其余生成参数（max_tokens、stream 等）会被上游拒绝
+var countTokensFields = []string{"model", "messages", "system", "tools", "tool_choice", "thinking", "mcp_servers"}
+
+// BuildCountTokensBody 从完整的 Messages 请求中提取 count_tokens 接口需要的字段
+func BuildCountTokensBody(request *dto.ClaudeRequest) (map[string]json.RawMessage, error) {
+	data, err := common.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := common.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	body := make(map[string]json.RawMessage, len(countTokensFields))
+	for _, key := range countTokensFields {
+		if value, ok := fields[key]; ok {
+			body[key] = value
+		}
+	}
+	return body, nil
+}
+
+// DoCountTokensRequest 使用 adaptor 的鉴权头发送 count_tokens 请求并解析 input_tokens
+func DoCountTokensRequest(a channel.Adaptor, c *gin.Context, info *relaycommon.RelayInfo, body any) (int, error) {
+	payload, err := common.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := channel.DoApiRequest(a, c, info, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("count tokens failed: status %d, body %s", resp.StatusCode, string(respBody))
+	}
+	var result struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := common.Unmarshal(respBody, &result); err != nil {
+		return 0, err
+	}
+	return result.InputTokens, nil
+}
+
+type countTokensAdaptor struct {
+	*Adaptor
+}
+
+func (a *countTokensAdaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return fmt.Sprintf("%s/v1/messages/count_tokens", info.ChannelBaseUrl), nil
+}
+
+func (a *Adaptor) CountClaudeTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (int, error) {
+	body, err := BuildCountTokensBody(request)
+	if err != nil {
+		return 0, err
+	}
+	return DoCountTokensRequest(&countTokensAdaptor{a}, c, info, body)
+}
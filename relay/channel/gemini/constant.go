@@ -19,6 +19,8 @@ var ModelList = []string{
 	"gemini-2.0-flash-thinking-exp",
 	"gemini-2.5-pro-exp-03-25",
 	"gemini-2.5-pro-preview-03-25",
+	// tts models
+	"gemini-2.5-flash-preview-tts", "gemini-2.5-pro-preview-tts",
 	// imagen models
 	"imagen-3.0-generate-002",
 	// embedding models
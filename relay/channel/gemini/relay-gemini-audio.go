@@ -0,0 +1,474 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contextKeyAudioResponseFormat = "response_format"
+
+// Gemini TTS 返回 24kHz 单声道 16bit PCM（audio/L16;codec=pcm;rate=24000）
+const geminiTTSDefaultSampleRate = 24000
+
+var geminiAudioMimeTypes = map[string]string{
+	".mp3":  "audio/mp3",
+	".wav":  "audio/wav",
+	".aiff": "audio/aiff",
+	".aif":  "audio/aiff",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".mp4":  "audio/mp4",
+	".webm": "audio/webm",
+}
+
+// 需要时间戳的格式要求模型按分段返回 JSON，再由网关拼装字幕
+var audioSegmentFormats = map[string]struct{}{
+	"srt":          {},
+	"vtt":          {},
+	"verbose_json": {},
+}
+
+var audioSegmentsSchema = map[string]any{
+	"type": "OBJECT",
+	"properties": map[string]any{
+		"language": map[string]any{"type": "STRING"},
+		"segments": map[string]any{
+			"type": "ARRAY",
+			"items": map[string]any{
+				"type": "OBJECT",
+				"properties": map[string]any{
+					"start": map[string]any{"type": "NUMBER"},
+					"end":   map[string]any{"type": "NUMBER"},
+					"text":  map[string]any{"type": "STRING"},
+				},
+				"required": []string{"start", "end", "text"},
+			},
+		},
+	},
+	"required": []string{"segments"},
+}
+
+type geminiAudioSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+type geminiAudioSegments struct {
+	Language string               `json:"language"`
+	Segments []geminiAudioSegment `json:"segments"`
+}
+
+func audioFileMimeType(fileHeaderContentType string, filename string) string {
+	if strings.HasPrefix(fileHeaderContentType, "audio/") || strings.HasPrefix(fileHeaderContentType, "video/") {
+		return fileHeaderContentType
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	if mimeType, ok := geminiAudioMimeTypes[ext]; ok {
+		return mimeType
+	}
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return mimeType
+	}
+	return "audio/mp3"
+}
+
+func buildTranscriptionPrompt(translate bool, segments bool, language string, hint string) string {
+	var sb strings.Builder
+	if translate {
+		sb.WriteString("Translate the speech in this audio into English.")
+	} else {
+		sb.WriteString("Generate a verbatim transcript of the speech in this audio.")
+		if language != "" {
+			sb.WriteString(fmt.Sprintf(" The spoken language is %s.", language))
+		}
+	}
+	if segments {
+		sb.WriteString(" Split the result into consecutive segments of one or two sentences, with start and end times in seconds from the beginning of the audio, and report the detected spoken language.")
+	} else {
+		sb.WriteString(" Output only the resulting text without any commentary, labels or timestamps.")
+	}
+	if hint != "" {
+		sb.WriteString(" Use the following context for names and spelling: ")
+		sb.WriteString(hint)
+	}
+	return sb.String()
+}
+
+// convertTranscriptionRequest 将 multipart 音频文件作为内联数据发送给多模态 generateContent
+func convertTranscriptionRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.GeminiChatRequest, error) {
+	formData, err := common.ParseMultipartFormReusable(c)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing multipart form: %w", err)
+	}
+	fileHeaders := formData.File["file"]
+	if len(fileHeaders) == 0 {
+		return nil, errors.New("file is required")
+	}
+	fileHeader := fileHeaders[0]
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening audio file: %v", err)
+	}
+	defer file.Close()
+	audioData, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audio file: %v", err)
+	}
+
+	formValue := func(key string) string {
+		if values := formData.Value[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	responseFormat := formValue("response_format")
+	if responseFormat == "" {
+		responseFormat = "json"
+	}
+	c.Set(contextKeyAudioResponseFormat, responseFormat)
+	_, segments := audioSegmentFormats[responseFormat]
+
+	prompt := buildTranscriptionPrompt(info.RelayMode == constant.RelayModeAudioTranslation, segments, formValue("language"), formValue("prompt"))
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{
+			Role: "user",
+			Parts: []dto.GeminiPart{
+				{Text: prompt},
+				{InlineData: &dto.GeminiInlineData{
+					MimeType: audioFileMimeType(fileHeader.Header.Get("Content-Type"), fileHeader.Filename),
+					Data:     base64.StdEncoding.EncodeToString(audioData),
+				}},
+			},
+		}},
+	}
+	if temperature, err := strconv.ParseFloat(formValue("temperature"), 64); err == nil {
+		geminiRequest.GenerationConfig.Temperature = &temperature
+	}
+	if segments {
+		geminiRequest.GenerationConfig.ResponseMimeType = "application/json"
+		geminiRequest.GenerationConfig.ResponseSchema = audioSegmentsSchema
+	}
+	return geminiRequest, nil
+}
+
+// convertSpeechRequest 使用 Gemini TTS 模型生成语音，instructions 作为朗读风格提示放在正文之前
+func convertSpeechRequest(c *gin.Context, request dto.AudioRequest) (*dto.GeminiChatRequest, error) {
+	if request.Input == "" {
+		return nil, errors.New("input is required")
+	}
+	text := request.Input
+	if request.Instructions != "" {
+		text = request.Instructions + "\n\n" + request.Input
+	}
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{
+			Role:  "user",
+			Parts: []dto.GeminiPart{{Text: text}},
+		}},
+	}
+	geminiRequest.GenerationConfig.ResponseModalities = []string{"AUDIO"}
+	if _, ok := openaiVoices[request.Voice]; !ok && request.Voice != "" {
+		speechConfig := &dto.GeminiLiveSpeechConfig{}
+		speechConfig.VoiceConfig.PrebuiltVoiceConfig.VoiceName = request.Voice
+		speechConfigJson, err := common.Marshal(speechConfig)
+		if err != nil {
+			return nil, err
+		}
+		geminiRequest.GenerationConfig.SpeechConfig = speechConfigJson
+	}
+	// 同步扩展字段的厂商自定义 metadata，例如多说话人 speechConfig
+	if len(request.Metadata) > 0 {
+		if err := common.Unmarshal(request.Metadata, geminiRequest); err != nil {
+			return nil, fmt.Errorf("error unmarshalling metadata to gemini request: %w", err)
+		}
+	}
+	c.Set(contextKeyAudioResponseFormat, request.ResponseFormat)
+	return geminiRequest, nil
+}
+
+// ConvertAudioRequestToGemini 将 /v1/audio/* 请求转换为 generateContent 请求体，Gemini 与 Vertex 渠道共用
+func ConvertAudioRequestToGemini(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	// Gemini 语音接口不支持流式输出
+	info.IsStream = false
+	var (
+		geminiRequest *dto.GeminiChatRequest
+		err           error
+	)
+	switch info.RelayMode {
+	case constant.RelayModeAudioSpeech:
+		geminiRequest, err = convertSpeechRequest(c, request)
+	case constant.RelayModeAudioTranscription, constant.RelayModeAudioTranslation:
+		geminiRequest, err = convertTranscriptionRequest(c, info)
+	default:
+		return nil, errors.New("unsupported audio relay mode")
+	}
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := common.Marshal(geminiRequest)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling gemini request: %w", err)
+	}
+	return bytes.NewReader(jsonData), nil
+}
+
+func audioUsageFromGeminiMetadata(metadata dto.GeminiUsageMetadata, info *relaycommon.RelayInfo, audioOutput bool) *dto.Usage {
+	usage := buildUsageFromGeminiMetadata(metadata, info.GetEstimatePromptTokens())
+	for _, detail := range metadata.CandidatesTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.CompletionTokenDetails.AudioTokens += detail.TokenCount
+		} else {
+			usage.CompletionTokenDetails.TextTokens += detail.TokenCount
+		}
+	}
+	if usage.CompletionTokenDetails.AudioTokens == 0 && usage.CompletionTokenDetails.TextTokens == 0 {
+		if audioOutput {
+			usage.CompletionTokenDetails.AudioTokens = metadata.CandidatesTokenCount
+		} else {
+			usage.CompletionTokenDetails.TextTokens = metadata.CandidatesTokenCount
+		}
+	}
+	// 推理 token 按文本输出计费
+	usage.CompletionTokenDetails.TextTokens += metadata.ThoughtsTokenCount
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return &usage
+}
+
+func readGeminiAudioResponse(resp *http.Response) (*dto.GeminiChatResponse, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if len(geminiResponse.Candidates) == 0 {
+		if geminiResponse.PromptFeedback != nil && geminiResponse.PromptFeedback.BlockReason != nil {
+			return nil, types.NewOpenAIError(errors.New("request blocked by Gemini API: "+*geminiResponse.PromptFeedback.BlockReason), types.ErrorCodePromptBlocked, http.StatusBadRequest)
+		}
+		return nil, types.NewOpenAIError(errors.New("empty response from Gemini API"), types.ErrorCodeEmptyResponse, http.StatusInternalServerError)
+	}
+	return &geminiResponse, nil
+}
+
+// pcmSampleRate 从 audio/L16;codec=pcm;rate=24000 中解析采样率
+func pcmSampleRate(mimeType string) int {
+	for _, param := range strings.Split(mimeType, ";") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(param), "rate="); ok {
+			if rate, err := strconv.Atoi(value); err == nil && rate > 0 {
+				return rate
+			}
+		}
+	}
+	return geminiTTSDefaultSampleRate
+}
+
+// pcmToWav 为 16bit 单声道 PCM 加上 WAV 文件头
+func pcmToWav(pcm []byte, sampleRate int) []byte {
+	const (
+		channels      = 1
+		bitsPerSample = 16
+	)
+	byteRate := sampleRate * channels * bitsPerSample / 8
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(byteRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(channels*bitsPerSample/8))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// GeminiSpeechHandler 将 TTS 返回的 PCM 按 response_format 输出为 pcm 或 wav。
+// 依赖中没有纯 Go 的 mp3 编码器，mp3 等其他格式（包括 OpenAI 默认的 mp3）统一以 wav 返回
+func GeminiSpeechHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	geminiResponse, newAPIError := readGeminiAudioResponse(resp)
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	var audioPart *dto.GeminiInlineData
+	for _, candidate := range geminiResponse.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+				audioPart = part.InlineData
+				break
+			}
+		}
+	}
+	if audioPart == nil {
+		return nil, types.NewOpenAIError(errors.New("no audio in Gemini response"), types.ErrorCodeEmptyResponse, http.StatusInternalServerError)
+	}
+	pcm, err := base64.StdEncoding.DecodeString(audioPart.Data)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	responseFormat := c.GetString(contextKeyAudioResponseFormat)
+	switch responseFormat {
+	case "pcm":
+		c.Data(http.StatusOK, "audio/pcm", pcm)
+	default:
+		if responseFormat != "" && responseFormat != "wav" {
+			logger.LogWarn(c, fmt.Sprintf("gemini tts: response_format %s is not supported, returning wav", responseFormat))
+		}
+		c.Data(http.StatusOK, "audio/wav", pcmToWav(pcm, pcmSampleRate(audioPart.MimeType)))
+	}
+	return audioUsageFromGeminiMetadata(geminiResponse.UsageMetadata, info, true), nil
+}
+
+func formatSubtitleTimestamp(seconds float64, decimalSeparator string) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	totalMillis := int64(seconds*1000 + 0.5)
+	hours := totalMillis / 3600000
+	minutes := totalMillis / 60000 % 60
+	secs := totalMillis / 1000 % 60
+	millis := totalMillis % 1000
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", hours, minutes, secs, decimalSeparator, millis)
+}
+
+func segmentsToText(segments []geminiAudioSegment) string {
+	texts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if text := strings.TrimSpace(segment.Text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+func segmentsToSRT(segments []geminiAudioSegment) string {
+	var sb strings.Builder
+	for i, segment := range segments {
+		sb.WriteString(fmt.Sprintf("%d\n%s --> %s\n%s\n\n", i+1,
+			formatSubtitleTimestamp(segment.Start, ","), formatSubtitleTimestamp(segment.End, ","), strings.TrimSpace(segment.Text)))
+	}
+	return sb.String()
+}
+
+func segmentsToVTT(segments []geminiAudioSegment) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n\n")
+	for _, segment := range segments {
+		sb.WriteString(fmt.Sprintf("%s --> %s\n%s\n\n",
+			formatSubtitleTimestamp(segment.Start, "."), formatSubtitleTimestamp(segment.End, "."), strings.TrimSpace(segment.Text)))
+	}
+	return sb.String()
+}
+
+func segmentsToVerboseJSON(result *geminiAudioSegments, task string) map[string]any {
+	segments := make([]map[string]any, 0, len(result.Segments))
+	duration := 0.0
+	for i, segment := range result.Segments {
+		segments = append(segments, map[string]any{
+			"id":    i,
+			"seek":  0,
+			"start": segment.Start,
+			"end":   segment.End,
+			"text":  strings.TrimSpace(segment.Text),
+		})
+		if segment.End > duration {
+			duration = segment.End
+		}
+	}
+	return map[string]any{
+		"task":     task,
+		"language": result.Language,
+		"duration": duration,
+		"text":     segmentsToText(result.Segments),
+		"segments": segments,
+	}
+}
+
+// GeminiTranscriptionHandler 按 response_format 输出 json/text/srt/vtt/verbose_json 格式的转写或翻译结果
+func GeminiTranscriptionHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	geminiResponse, newAPIError := readGeminiAudioResponse(resp)
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	var sb strings.Builder
+	for _, part := range geminiResponse.Candidates[0].Content.Parts {
+		if !part.Thought {
+			sb.WriteString(part.Text)
+		}
+	}
+	text := strings.TrimSpace(sb.String())
+
+	responseFormat := c.GetString(contextKeyAudioResponseFormat)
+	if _, ok := audioSegmentFormats[responseFormat]; ok {
+		var result geminiAudioSegments
+		if err := common.UnmarshalJsonStr(text, &result); err != nil {
+			return nil, types.NewOpenAIError(fmt.Errorf("invalid transcription segments: %w", err), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		switch responseFormat {
+		case "srt":
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(segmentsToSRT(result.Segments)))
+		case "vtt":
+			c.Data(http.StatusOK, "text/vtt; charset=utf-8", []byte(segmentsToVTT(result.Segments)))
+		default:
+			task := "transcribe"
+			if info.RelayMode == constant.RelayModeAudioTranslation {
+				task = "translate"
+			}
+			c.JSON(http.StatusOK, segmentsToVerboseJSON(&result, task))
+		}
+	} else if responseFormat == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+	} else {
+		c.JSON(http.StatusOK, gin.H{"text": text})
+	}
+	return audioUsageFromGeminiMetadata(geminiResponse.UsageMetadata, info, false), nil
+}
+
+// GeminiAudioHandler 处理 /v1/audio/* 的 generateContent 响应
+func GeminiAudioHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	if info.RelayMode == constant.RelayModeAudioSpeech {
+		return GeminiSpeechHandler(c, info, resp)
+	}
+	return GeminiTranscriptionHandler(c, info, resp)
+}
+
+// IsAudioRelayMode 供复用 Gemini 请求格式的渠道（如 Vertex）判断是否为音频接口
+func IsAudioRelayMode(relayMode int) bool {
+	return relayMode == constant.RelayModeAudioSpeech ||
+		relayMode == constant.RelayModeAudioTranscription ||
+		relayMode == constant.RelayModeAudioTranslation
+}
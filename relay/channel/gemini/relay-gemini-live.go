@@ -0,0 +1,693 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/tidwall/gjson"
+)
+
+// OpenAI realtime 的 pcm16 为 24kHz 单声道小端 PCM，Gemini Live 输出同样是 24kHz PCM
+const geminiLiveInputAudioMimeType = "audio/pcm;rate=24000"
+
+// OpenAI 预置音色在 Gemini 中不存在，遇到时使用上游默认音色，realtime 与 TTS 共用
+var openaiVoices = map[string]struct{}{
+	"alloy": {}, "ash": {}, "ballad": {}, "coral": {}, "echo": {},
+	"sage": {}, "shimmer": {}, "verse": {}, "marin": {}, "cedar": {},
+}
+
+// geminiLiveBridge 在 OpenAI realtime 客户端与 Gemini Live (BidiGenerateContent) 之间转换事件。
+// Gemini 的会话配置只能在首条 setup 消息中指定，因此 setup 延迟到收到客户端首个事件后发送
+type geminiLiveBridge struct {
+	c          *gin.Context
+	info       *relaycommon.RelayInfo
+	clientConn *websocket.Conn
+	targetConn *websocket.Conn
+
+	// mu 保护以下状态以及对客户端连接的写入
+	mu             sync.Mutex
+	session        dto.RealtimeSession
+	setupSent      bool
+	manualActivity bool
+	activityOpen   bool
+	pendingContent bool
+	callNames      map[string]string
+
+	responseId      string
+	itemId          string
+	interrupted     bool
+	awaitingUsage   bool
+	turnUsage       *dto.RealtimeUsage
+	inputTranscript strings.Builder
+
+	localUsage *dto.RealtimeUsage
+	sumUsage   *dto.RealtimeUsage
+}
+
+func newGeminiLiveBridge(c *gin.Context, info *relaycommon.RelayInfo) *geminiLiveBridge {
+	return &geminiLiveBridge{
+		c:          c,
+		info:       info,
+		clientConn: info.ClientWs,
+		targetConn: info.TargetWs,
+		session: dto.RealtimeSession{
+			Modalities:        []string{"text", "audio"},
+			InputAudioFormat:  "pcm16",
+			OutputAudioFormat: "pcm16",
+			ToolChoice:        "auto",
+		},
+		callNames:  make(map[string]string),
+		localUsage: &dto.RealtimeUsage{},
+		sumUsage:   &dto.RealtimeUsage{},
+	}
+}
+
+func (b *geminiLiveBridge) sendClient(event map[string]any) error {
+	if _, ok := event["event_id"]; !ok {
+		event["event_id"] = "event_" + common.GetUUID()
+	}
+	return helper.WssObject(b.c, b.clientConn, event)
+}
+
+func (b *geminiLiveBridge) sendTarget(message *dto.GeminiLiveClientMessage) error {
+	return helper.WssObject(b.c, b.targetConn, message)
+}
+
+func (b *geminiLiveBridge) sendClientError(message string) error {
+	return b.sendClient(map[string]any{
+		"type": dto.RealtimeEventTypeError,
+		"error": map[string]any{
+			"type":    "invalid_request_error",
+			"message": message,
+		},
+	})
+}
+
+func (b *geminiLiveBridge) sessionEvent(eventType string) map[string]any {
+	return map[string]any{
+		"type":    eventType,
+		"session": b.session,
+	}
+}
+
+func (b *geminiLiveBridge) addLocalUsage(input bool, textTokens int, audioTokens int) {
+	b.localUsage.TotalTokens += textTokens + audioTokens
+	if input {
+		b.localUsage.InputTokens += textTokens + audioTokens
+		b.localUsage.InputTokenDetails.TextTokens += textTokens
+		b.localUsage.InputTokenDetails.AudioTokens += audioTokens
+	} else {
+		b.localUsage.OutputTokens += textTokens + audioTokens
+		b.localUsage.OutputTokenDetails.TextTokens += textTokens
+		b.localUsage.OutputTokenDetails.AudioTokens += audioTokens
+	}
+}
+
+// applySessionUpdate 合并 session.update 中显式指定的字段；Gemini 仅支持 pcm16 音频
+func (b *geminiLiveBridge) applySessionUpdate(session *dto.RealtimeSession, message []byte) error {
+	if session == nil {
+		return nil
+	}
+	if len(session.Modalities) > 0 {
+		b.session.Modalities = session.Modalities
+	}
+	if session.Instructions != "" {
+		b.session.Instructions = session.Instructions
+	}
+	if session.Voice != "" {
+		b.session.Voice = session.Voice
+	}
+	if session.InputAudioTranscription.Model != "" {
+		b.session.InputAudioTranscription = session.InputAudioTranscription
+	}
+	if session.Tools != nil {
+		b.session.Tools = session.Tools
+		b.info.RealtimeTools = session.Tools
+	}
+	if session.ToolChoice != "" {
+		b.session.ToolChoice = session.ToolChoice
+	}
+	if session.Temperature > 0 {
+		b.session.Temperature = session.Temperature
+	}
+	if turnDetection := gjson.GetBytes(message, "session.turn_detection"); turnDetection.Exists() {
+		b.session.TurnDetection = session.TurnDetection
+		if !b.setupSent {
+			b.manualActivity = turnDetection.Type == gjson.Null
+		}
+	}
+	for _, format := range []string{session.InputAudioFormat, session.OutputAudioFormat} {
+		if format != "" && format != "pcm16" {
+			return fmt.Errorf("audio format %s is not supported, only pcm16 is available", format)
+		}
+	}
+	return nil
+}
+
+func (b *geminiLiveBridge) buildSetup() *dto.GeminiLiveSetup {
+	setup := &dto.GeminiLiveSetup{
+		Model:            "models/" + b.info.UpstreamModelName,
+		GenerationConfig: &dto.GeminiLiveGenerationConfig{},
+	}
+	// Gemini Live 每个会话只能输出一种模态
+	modality := "TEXT"
+	for _, m := range b.session.Modalities {
+		if m == "audio" {
+			modality = "AUDIO"
+		}
+	}
+	setup.GenerationConfig.ResponseModalities = []string{modality}
+	if modality == "AUDIO" {
+		// 输出转写用于 response.audio_transcript.delta
+		setup.OutputAudioTranscription = &struct{}{}
+		if _, ok := openaiVoices[b.session.Voice]; !ok && b.session.Voice != "" {
+			speechConfig := &dto.GeminiLiveSpeechConfig{}
+			speechConfig.VoiceConfig.PrebuiltVoiceConfig.VoiceName = b.session.Voice
+			setup.GenerationConfig.SpeechConfig = speechConfig
+		}
+	}
+	if b.session.Temperature > 0 {
+		temperature := b.session.Temperature
+		setup.GenerationConfig.Temperature = &temperature
+	}
+	if b.session.Instructions != "" {
+		setup.SystemInstruction = &dto.GeminiChatContent{
+			Parts: []dto.GeminiPart{{Text: b.session.Instructions}},
+		}
+	}
+	if b.session.InputAudioTranscription.Model != "" {
+		setup.InputAudioTranscription = &struct{}{}
+	}
+	if len(b.session.Tools) > 0 && b.session.ToolChoice != "none" {
+		declarations := make([]dto.GeminiLiveFunctionDeclaration, 0, len(b.session.Tools))
+		for _, tool := range b.session.Tools {
+			if tool.Type != "" && tool.Type != "function" {
+				continue
+			}
+			declarations = append(declarations, dto.GeminiLiveFunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  cleanFunctionParameters(tool.Parameters),
+			})
+		}
+		if len(declarations) > 0 {
+			setup.Tools = []dto.GeminiLiveTool{{FunctionDeclarations: declarations}}
+		}
+	}
+	if b.manualActivity {
+		setup.RealtimeInputConfig = &dto.GeminiLiveRealtimeInputConfig{
+			AutomaticActivityDetection: &dto.GeminiLiveActivityDetection{Disabled: true},
+		}
+	}
+	return setup
+}
+
+func realtimeItemText(item *dto.RealtimeItem) string {
+	var sb strings.Builder
+	for _, content := range item.Content {
+		switch content.Type {
+		case "input_text", "text", "output_text":
+			sb.WriteString(content.Text)
+		case "input_audio":
+			sb.WriteString(content.Transcript)
+		}
+	}
+	return sb.String()
+}
+
+// handleClientMessage 将一条 OpenAI realtime 客户端事件转换为 Gemini Live 消息
+func (b *geminiLiveBridge) handleClientMessage(message []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := &dto.RealtimeEvent{}
+	if err := common.Unmarshal(message, event); err != nil {
+		return fmt.Errorf("error unmarshalling message: %v", err)
+	}
+	textToken, audioToken, err := service.CountTokenRealtime(b.info, *event, b.info.UpstreamModelName)
+	if err != nil {
+		return fmt.Errorf("error counting text token: %v", err)
+	}
+	b.addLocalUsage(true, textToken, audioToken)
+
+	if event.Type == dto.RealtimeEventTypeSessionUpdate {
+		if err := b.applySessionUpdate(event.Session, message); err != nil {
+			return b.sendClientError(err.Error())
+		}
+	}
+	if !b.setupSent {
+		b.setupSent = true
+		if err := b.sendTarget(&dto.GeminiLiveClientMessage{Setup: b.buildSetup()}); err != nil {
+			return fmt.Errorf("error writing to target: %v", err)
+		}
+		// session.updated 在上游返回 setupComplete 后发送
+		if event.Type == dto.RealtimeEventTypeSessionUpdate {
+			return nil
+		}
+	}
+
+	switch event.Type {
+	case dto.RealtimeEventTypeSessionUpdate:
+		// 上游会话配置在 setup 后无法修改，仅回显
+		logger.LogWarn(b.c, "gemini live: session.update after setup only takes effect locally")
+		return b.sendClient(b.sessionEvent(dto.RealtimeEventTypeSessionUpdated))
+	case dto.RealtimeEventInputAudioBufferAppend:
+		if event.Audio == "" {
+			return nil
+		}
+		if b.manualActivity && !b.activityOpen {
+			b.activityOpen = true
+			if err := b.sendTarget(&dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{ActivityStart: &struct{}{}}}); err != nil {
+				return err
+			}
+		}
+		return b.sendTarget(&dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{
+			Audio: &dto.GeminiInlineData{MimeType: geminiLiveInputAudioMimeType, Data: event.Audio},
+		}})
+	case dto.RealtimeEventInputAudioBufferCommit:
+		input := &dto.GeminiLiveRealtimeInput{AudioStreamEnd: true}
+		if b.manualActivity {
+			if !b.activityOpen {
+				return nil
+			}
+			b.activityOpen = false
+			input = &dto.GeminiLiveRealtimeInput{ActivityEnd: &struct{}{}}
+		}
+		if err := b.sendTarget(&dto.GeminiLiveClientMessage{RealtimeInput: input}); err != nil {
+			return err
+		}
+		return b.sendClient(map[string]any{
+			"type":    dto.RealtimeEventInputAudioBufferCommitted,
+			"item_id": "item_" + common.GetUUID(),
+		})
+	case dto.RealtimeEventTypeConversationCreate:
+		if event.Item == nil {
+			return nil
+		}
+		switch event.Item.Type {
+		case "message":
+			role := "user"
+			if event.Item.Role == "assistant" {
+				role = "model"
+			}
+			text := realtimeItemText(event.Item)
+			if text == "" {
+				return nil
+			}
+			b.pendingContent = true
+			if err := b.sendTarget(&dto.GeminiLiveClientMessage{ClientContent: &dto.GeminiLiveClientContent{
+				Turns: []dto.GeminiChatContent{{Role: role, Parts: []dto.GeminiPart{{Text: text}}}},
+			}}); err != nil {
+				return err
+			}
+		case "function_call_output":
+			var output any = event.Item.Output
+			if parsed := gjson.Parse(event.Item.Output); parsed.IsObject() {
+				output = parsed.Value()
+			}
+			if err := b.sendTarget(&dto.GeminiLiveClientMessage{ToolResponse: &dto.GeminiLiveToolResponse{
+				FunctionResponses: []dto.GeminiLiveFunctionResponse{{
+					Id:       event.Item.CallId,
+					Name:     b.callNames[event.Item.CallId],
+					Response: map[string]any{"output": output},
+				}},
+			}}); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		if event.Item.Id == "" {
+			event.Item.Id = "item_" + common.GetUUID()
+		}
+		return b.sendClient(map[string]any{
+			"type": dto.RealtimeEventConversationItemCreated,
+			"item": event.Item,
+		})
+	case dto.RealtimeEventTypeResponseCreate:
+		// 音频输入由上游语音活动检测或 commit 触发回复，只有文本输入需要显式结束本轮
+		if !b.pendingContent {
+			return nil
+		}
+		b.pendingContent = false
+		return b.sendTarget(&dto.GeminiLiveClientMessage{ClientContent: &dto.GeminiLiveClientContent{TurnComplete: true}})
+	}
+	return nil
+}
+
+func geminiLiveUsageToRealtime(metadata *dto.GeminiLiveUsageMetadata) *dto.RealtimeUsage {
+	usage := &dto.RealtimeUsage{
+		InputTokens:  metadata.PromptTokenCount,
+		OutputTokens: metadata.ResponseTokenCount + metadata.ThoughtsTokenCount,
+		TotalTokens:  metadata.TotalTokenCount,
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	}
+	usage.InputTokenDetails.CachedTokens = metadata.CachedContentTokenCount
+	for _, detail := range metadata.PromptTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.InputTokenDetails.AudioTokens += detail.TokenCount
+		}
+	}
+	usage.InputTokenDetails.TextTokens = usage.InputTokens - usage.InputTokenDetails.AudioTokens
+	for _, detail := range metadata.ResponseTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.OutputTokenDetails.AudioTokens += detail.TokenCount
+		}
+	}
+	usage.OutputTokenDetails.TextTokens = usage.OutputTokens - usage.OutputTokenDetails.AudioTokens
+	return usage
+}
+
+// startResponse 在模型开始输出时发送 response.created；上一轮仍在等待用量时先按本地估算结束
+func (b *geminiLiveBridge) startResponse() error {
+	if b.responseId != "" && !b.awaitingUsage {
+		return nil
+	}
+	if b.awaitingUsage {
+		if err := b.finishResponse(); err != nil {
+			return err
+		}
+	}
+	b.responseId = "resp_" + common.GetUUID()
+	b.itemId = "item_" + common.GetUUID()
+	if err := b.sendClient(map[string]any{
+		"type": dto.RealtimeEventTypeResponseCreated,
+		"response": map[string]any{
+			"id":     b.responseId,
+			"object": "realtime.response",
+			"status": "in_progress",
+			"output": []any{},
+		},
+	}); err != nil {
+		return err
+	}
+	return b.sendClient(map[string]any{
+		"type":         dto.RealtimeEventResponseOutputItemAdded,
+		"response_id":  b.responseId,
+		"output_index": 0,
+		"item": map[string]any{
+			"id":      b.itemId,
+			"object":  "realtime.item",
+			"type":    "message",
+			"role":    "assistant",
+			"status":  "in_progress",
+			"content": []any{},
+		},
+	})
+}
+
+func (b *geminiLiveBridge) sendDelta(eventType string, delta string) error {
+	return b.sendClient(map[string]any{
+		"type":          eventType,
+		"response_id":   b.responseId,
+		"item_id":       b.itemId,
+		"output_index":  0,
+		"content_index": 0,
+		"delta":         delta,
+	})
+}
+
+// completeTurn 标记本轮输出结束；上游用量可能晚于 turnComplete 到达，届时再发送 response.done
+func (b *geminiLiveBridge) completeTurn() error {
+	if b.inputTranscript.Len() > 0 {
+		transcript := b.inputTranscript.String()
+		b.inputTranscript.Reset()
+		if err := b.sendClient(map[string]any{
+			"type":          dto.RealtimeEventInputAudioTranscriptionCompleted,
+			"item_id":       "item_" + common.GetUUID(),
+			"content_index": 0,
+			"transcript":    transcript,
+		}); err != nil {
+			return err
+		}
+	}
+	if b.responseId == "" {
+		return nil
+	}
+	b.awaitingUsage = true
+	if b.turnUsage != nil {
+		return b.finishResponse()
+	}
+	return nil
+}
+
+// finishResponse 按上游用量（缺失时按本地估算）预扣费并发送 response.done
+func (b *geminiLiveBridge) finishResponse() error {
+	usage := b.turnUsage
+	if usage == nil {
+		usage = b.localUsage
+	}
+	b.turnUsage = nil
+	b.localUsage = &dto.RealtimeUsage{}
+	if err := b.consumeUsage(usage); err != nil {
+		return err
+	}
+	status := "completed"
+	if b.interrupted {
+		status = "cancelled"
+	}
+	responseId := b.responseId
+	b.responseId = ""
+	b.awaitingUsage = false
+	b.interrupted = false
+	return b.sendClient(map[string]any{
+		"type": dto.RealtimeEventTypeResponseDone,
+		"response": map[string]any{
+			"id":     responseId,
+			"object": "realtime.response",
+			"status": status,
+			"usage":  usage,
+		},
+	})
+}
+
+func (b *geminiLiveBridge) consumeUsage(usage *dto.RealtimeUsage) error {
+	if usage.TotalTokens == 0 {
+		return nil
+	}
+	b.sumUsage.TotalTokens += usage.TotalTokens
+	b.sumUsage.InputTokens += usage.InputTokens
+	b.sumUsage.OutputTokens += usage.OutputTokens
+	b.sumUsage.InputTokenDetails.CachedTokens += usage.InputTokenDetails.CachedTokens
+	b.sumUsage.InputTokenDetails.TextTokens += usage.InputTokenDetails.TextTokens
+	b.sumUsage.InputTokenDetails.AudioTokens += usage.InputTokenDetails.AudioTokens
+	b.sumUsage.OutputTokenDetails.TextTokens += usage.OutputTokenDetails.TextTokens
+	b.sumUsage.OutputTokenDetails.AudioTokens += usage.OutputTokenDetails.AudioTokens
+	return service.PreWssConsumeQuota(b.c, b.info, usage)
+}
+
+// handleTargetMessage 将一条 Gemini Live 服务端消息转换为 OpenAI realtime 事件
+func (b *geminiLiveBridge) handleTargetMessage(message []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	serverMessage := &dto.GeminiLiveServerMessage{}
+	if err := common.Unmarshal(message, serverMessage); err != nil {
+		return fmt.Errorf("error unmarshalling message: %v", err)
+	}
+	if serverMessage.SetupComplete != nil {
+		if err := b.sendClient(b.sessionEvent(dto.RealtimeEventTypeSessionUpdated)); err != nil {
+			return err
+		}
+	}
+	if serverMessage.UsageMetadata != nil {
+		b.turnUsage = geminiLiveUsageToRealtime(serverMessage.UsageMetadata)
+		if b.awaitingUsage {
+			if err := b.finishResponse(); err != nil {
+				return err
+			}
+		}
+	}
+	if content := serverMessage.ServerContent; content != nil {
+		if content.InputTranscription != nil {
+			b.inputTranscript.WriteString(content.InputTranscription.Text)
+		}
+		if content.ModelTurn != nil {
+			for _, part := range content.ModelTurn.Parts {
+				if part.Thought {
+					continue
+				}
+				if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+					if err := b.startResponse(); err != nil {
+						return err
+					}
+					audioToken, err := service.CountAudioTokenOutput(part.InlineData.Data, b.info.OutputAudioFormat)
+					if err != nil {
+						return fmt.Errorf("error counting audio token: %v", err)
+					}
+					b.addLocalUsage(false, 0, audioToken)
+					if err := b.sendDelta(dto.RealtimeEventResponseAudioDelta, part.InlineData.Data); err != nil {
+						return err
+					}
+				} else if part.Text != "" {
+					if err := b.startResponse(); err != nil {
+						return err
+					}
+					b.addLocalUsage(false, service.CountTextToken(part.Text, b.info.UpstreamModelName), 0)
+					if err := b.sendDelta(dto.RealtimeEventResponseTextDelta, part.Text); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if content.OutputTranscription != nil && content.OutputTranscription.Text != "" {
+			if err := b.startResponse(); err != nil {
+				return err
+			}
+			if err := b.sendDelta(dto.RealtimeEventResponseAudioTranscriptionDelta, content.OutputTranscription.Text); err != nil {
+				return err
+			}
+		}
+		if content.Interrupted {
+			b.interrupted = true
+		}
+		if content.TurnComplete {
+			if err := b.completeTurn(); err != nil {
+				return err
+			}
+		}
+	}
+	if toolCall := serverMessage.ToolCall; toolCall != nil && len(toolCall.FunctionCalls) > 0 {
+		if err := b.startResponse(); err != nil {
+			return err
+		}
+		for i, call := range toolCall.FunctionCalls {
+			arguments, err := common.Marshal(call.Args)
+			if err != nil {
+				return err
+			}
+			if call.Args == nil {
+				arguments = []byte("{}")
+			}
+			b.callNames[call.Id] = call.Name
+			b.addLocalUsage(false, service.CountTextToken(string(arguments), b.info.UpstreamModelName), 0)
+			if err := b.sendClient(map[string]any{
+				"type":         dto.RealtimeEventResponseFunctionCallArgumentsDone,
+				"response_id":  b.responseId,
+				"item_id":      "item_" + common.GetUUID(),
+				"output_index": i,
+				"call_id":      call.Id,
+				"name":         call.Name,
+				"arguments":    string(arguments),
+			}); err != nil {
+				return err
+			}
+		}
+		// 上游等待 toolResponse 期间不会发送 turnComplete，客户端需要 response.done 才会回传结果
+		if err := b.completeTurn(); err != nil {
+			return err
+		}
+	}
+	if serverMessage.GoAway != nil {
+		logger.LogWarn(b.c, "gemini live: upstream is going away, time left: "+serverMessage.GoAway.TimeLeft)
+	}
+	return nil
+}
+
+// finish 连接结束时结算未发送 response.done 的回合与剩余的本地估算用量
+func (b *geminiLiveBridge) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.responseId != "" {
+		_ = b.finishResponse()
+	}
+	if b.turnUsage != nil {
+		_ = b.consumeUsage(b.turnUsage)
+		b.turnUsage = nil
+		b.localUsage = &dto.RealtimeUsage{}
+	}
+	_ = b.consumeUsage(b.localUsage)
+	b.localUsage = &dto.RealtimeUsage{}
+}
+
+// GeminiLiveRealtimeHandler 将 /v1/realtime 客户端桥接到 Gemini Live，返回整个会话的累计用量
+func GeminiLiveRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*dto.RealtimeUsage, *types.NewAPIError) {
+	if info == nil || info.ClientWs == nil || info.TargetWs == nil {
+		return nil, types.NewError(fmt.Errorf("invalid websocket connection"), types.ErrorCodeBadResponse)
+	}
+	info.IsStream = true
+	info.InputAudioFormat = common.GetStringIfEmpty(info.InputAudioFormat, "pcm16")
+	info.OutputAudioFormat = common.GetStringIfEmpty(info.OutputAudioFormat, "pcm16")
+
+	bridge := newGeminiLiveBridge(c, info)
+	clientClosed := make(chan struct{})
+	targetClosed := make(chan struct{})
+	errChan := make(chan error, 2)
+
+	bridge.mu.Lock()
+	err := bridge.sendClient(bridge.sessionEvent(dto.RealtimeEventTypeSessionCreated))
+	bridge.mu.Unlock()
+	if err != nil {
+		return nil, types.NewError(fmt.Errorf("error writing to client: %v", err), types.ErrorCodeBadResponse)
+	}
+
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("panic in client reader: %v", r)
+			}
+		}()
+		for {
+			_, message, err := bridge.clientConn.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					errChan <- fmt.Errorf("error reading from client: %v", err)
+				}
+				close(clientClosed)
+				return
+			}
+			if err := bridge.handleClientMessage(message); err != nil {
+				errChan <- err
+				return
+			}
+		}
+	})
+
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("panic in target reader: %v", r)
+			}
+		}()
+		for {
+			_, message, err := bridge.targetConn.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					errChan <- fmt.Errorf("error reading from target: %v", err)
+				}
+				close(targetClosed)
+				return
+			}
+			info.SetFirstResponseTime()
+			if err := bridge.handleTargetMessage(message); err != nil {
+				errChan <- err
+				return
+			}
+		}
+	})
+
+	select {
+	case <-clientClosed:
+	case <-targetClosed:
+	case err := <-errChan:
+		logger.LogError(c, "gemini live realtime error: "+err.Error())
+	case <-c.Done():
+	}
+
+	bridge.finish()
+	return bridge.sumUsage, nil
+}
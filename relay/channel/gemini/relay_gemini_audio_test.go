@@ -0,0 +1,118 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newGeminiAudioResponse(t *testing.T, payload dto.GeminiChatResponse) *http.Response {
+	body, err := common.Marshal(payload)
+	require.NoError(t, err)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}
+}
+
+func TestConvertAudioRequestToGemini_Transcription(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("model", "gemini-2.5-flash"))
+	require.NoError(t, writer.WriteField("response_format", "srt"))
+	require.NoError(t, writer.WriteField("language", "de"))
+	part, err := writer.CreateFormFile("file", "meeting.flac")
+	require.NoError(t, err)
+	_, _ = part.Write([]byte("fLaC-audio"))
+	require.NoError(t, writer.Close())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	info := &relaycommon.RelayInfo{RelayMode: constant.RelayModeAudioTranscription, IsStream: true}
+
+	reader, err := ConvertAudioRequestToGemini(c, info, dto.AudioRequest{Model: "gemini-2.5-flash"})
+	require.NoError(t, err)
+	require.False(t, info.IsStream)
+	require.Equal(t, "srt", c.GetString(contextKeyAudioResponseFormat))
+
+	var geminiRequest dto.GeminiChatRequest
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, common.Unmarshal(data, &geminiRequest))
+	parts := geminiRequest.Contents[0].Parts
+	require.Len(t, parts, 2)
+	require.Contains(t, parts[0].Text, "The spoken language is de.")
+	require.Equal(t, "audio/flac", parts[1].InlineData.MimeType)
+	require.Equal(t, base64.StdEncoding.EncodeToString([]byte("fLaC-audio")), parts[1].InlineData.Data)
+	require.Equal(t, "application/json", geminiRequest.GenerationConfig.ResponseMimeType)
+}
+
+func TestGeminiTranscriptionHandler_SRT(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Set(contextKeyAudioResponseFormat, "srt")
+	info := &relaycommon.RelayInfo{RelayMode: constant.RelayModeAudioTranscription}
+
+	resp := newGeminiAudioResponse(t, dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{{Content: dto.GeminiChatContent{Parts: []dto.GeminiPart{
+			{Text: `{"language":"en","segments":[{"start":0,"end":1.5,"text":" Hello."},{"start":1.5,"end":62.25,"text":"World."}]}`},
+		}}}},
+		UsageMetadata: dto.GeminiUsageMetadata{
+			PromptTokenCount:     120,
+			CandidatesTokenCount: 30,
+			TotalTokenCount:      150,
+			PromptTokensDetails: []dto.GeminiPromptTokensDetails{
+				{Modality: "AUDIO", TokenCount: 100},
+				{Modality: "TEXT", TokenCount: 20},
+			},
+		},
+	})
+	usage, apiErr := GeminiTranscriptionHandler(c, info, resp)
+	require.Nil(t, apiErr)
+	require.Equal(t, "1\n00:00:00,000 --> 00:00:01,500\nHello.\n\n2\n00:00:01,500 --> 00:01:02,250\nWorld.\n\n", recorder.Body.String())
+	require.Equal(t, 100, usage.PromptTokensDetails.AudioTokens)
+	require.Equal(t, 20, usage.PromptTokensDetails.TextTokens)
+	require.Equal(t, 30, usage.CompletionTokenDetails.TextTokens)
+}
+
+func TestGeminiSpeechHandler_Wav(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Set(contextKeyAudioResponseFormat, "wav")
+	info := &relaycommon.RelayInfo{RelayMode: constant.RelayModeAudioSpeech}
+
+	pcm := []byte{1, 2, 3, 4}
+	resp := newGeminiAudioResponse(t, dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{{Content: dto.GeminiChatContent{Parts: []dto.GeminiPart{
+			{InlineData: &dto.GeminiInlineData{MimeType: "audio/L16;codec=pcm;rate=16000", Data: base64.StdEncoding.EncodeToString(pcm)}},
+		}}}},
+		UsageMetadata: dto.GeminiUsageMetadata{
+			PromptTokenCount:        8,
+			CandidatesTokenCount:    50,
+			TotalTokenCount:         58,
+			CandidatesTokensDetails: []dto.GeminiPromptTokensDetails{{Modality: "AUDIO", TokenCount: 50}},
+		},
+	})
+	usage, apiErr := GeminiSpeechHandler(c, info, resp)
+	require.Nil(t, apiErr)
+	require.Equal(t, "audio/wav", recorder.Header().Get("Content-Type"))
+	wav := recorder.Body.Bytes()
+	require.Len(t, wav, 44+len(pcm))
+	require.Equal(t, "RIFF", string(wav[0:4]))
+	require.Equal(t, []byte{0x80, 0x3e, 0, 0}, wav[24:28])
+	require.Equal(t, pcm, wav[44:])
+	require.Equal(t, 50, usage.CompletionTokenDetails.AudioTokens)
+	require.Equal(t, 8, usage.PromptTokensDetails.TextTokens)
+}
@@ -0,0 +1,170 @@
+package gemini
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// newFakeGeminiLiveServer 模拟 BidiGenerateContent：校验 setup，收到音频后回复一段音频、转写与用量
+func newFakeGeminiLiveServer(setupChan chan<- dto.GeminiLiveSetup) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var message dto.GeminiLiveClientMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			switch {
+			case message.Setup != nil:
+				setupChan <- *message.Setup
+				_ = conn.WriteJSON(map[string]any{"setupComplete": map[string]any{}})
+			case message.RealtimeInput != nil && message.RealtimeInput.Audio != nil:
+				_ = conn.WriteJSON(map[string]any{"serverContent": map[string]any{
+					"modelTurn": map[string]any{"parts": []any{
+						map[string]any{"inlineData": map[string]any{"mimeType": "audio/pcm;rate=24000", "data": "AAAAAA=="}},
+					}},
+					"outputTranscription": map[string]any{"text": "hello"},
+				}})
+				_ = conn.WriteJSON(map[string]any{
+					"serverContent": map[string]any{"turnComplete": true},
+					"usageMetadata": map[string]any{
+						"promptTokenCount":      30,
+						"responseTokenCount":    20,
+						"totalTokenCount":       50,
+						"promptTokensDetails":   []any{map[string]any{"modality": "AUDIO", "tokenCount": 25}, map[string]any{"modality": "TEXT", "tokenCount": 5}},
+						"responseTokensDetails": []any{map[string]any{"modality": "AUDIO", "tokenCount": 20}},
+					},
+				})
+			}
+		}
+	}))
+}
+
+func TestGeminiLiveRealtimeHandler_BridgesAudioTurn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupChan := make(chan dto.GeminiLiveSetup, 1)
+	upstream := newFakeGeminiLiveServer(setupChan)
+	defer upstream.Close()
+
+	usageChan := make(chan *dto.RealtimeUsage, 1)
+	upgrader := websocket.Upgrader{}
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientConn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer clientConn.Close()
+		targetConn, _, err := websocket.DefaultDialer.Dial(wsURL(upstream), nil)
+		if err != nil {
+			return
+		}
+		defer targetConn.Close()
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = r
+		info := &relaycommon.RelayInfo{
+			ClientWs: clientConn,
+			TargetWs: targetConn,
+			UsePrice: true,
+			ChannelMeta: &relaycommon.ChannelMeta{
+				UpstreamModelName: "gemini-2.0-flash-live-001",
+			},
+		}
+		usage, apiErr := GeminiLiveRealtimeHandler(c, info)
+		require.Nil(t, apiErr)
+		usageChan <- usage
+	}))
+	defer gateway.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial(wsURL(gateway), nil)
+	require.NoError(t, err)
+	readEvent := func() map[string]any {
+		require.NoError(t, client.SetReadDeadline(time.Now().Add(5*time.Second)))
+		var event map[string]any
+		require.NoError(t, client.ReadJSON(&event))
+		return event
+	}
+
+	require.Equal(t, dto.RealtimeEventTypeSessionCreated, readEvent()["type"])
+	require.NoError(t, client.WriteJSON(map[string]any{
+		"type": dto.RealtimeEventTypeSessionUpdate,
+		"session": map[string]any{
+			"modalities":   []string{"text", "audio"},
+			"instructions": "be brief",
+			"voice":        "Puck",
+		},
+	}))
+	setup := <-setupChan
+	require.Equal(t, "models/gemini-2.0-flash-live-001", setup.Model)
+	require.Equal(t, []string{"AUDIO"}, setup.GenerationConfig.ResponseModalities)
+	require.Equal(t, "Puck", setup.GenerationConfig.SpeechConfig.VoiceConfig.PrebuiltVoiceConfig.VoiceName)
+	require.Equal(t, "be brief", setup.SystemInstruction.Parts[0].Text)
+	require.NotNil(t, setup.OutputAudioTranscription)
+	require.Equal(t, dto.RealtimeEventTypeSessionUpdated, readEvent()["type"])
+
+	require.NoError(t, client.WriteJSON(map[string]any{
+		"type":  dto.RealtimeEventInputAudioBufferAppend,
+		"audio": "AAAAAAAAAAA=",
+	}))
+
+	var eventTypes []string
+	var done map[string]any
+	for done == nil {
+		event := readEvent()
+		eventType := event["type"].(string)
+		eventTypes = append(eventTypes, eventType)
+		switch eventType {
+		case dto.RealtimeEventResponseAudioDelta:
+			require.Equal(t, "AAAAAA==", event["delta"])
+		case dto.RealtimeEventResponseAudioTranscriptionDelta:
+			require.Equal(t, "hello", event["delta"])
+		case dto.RealtimeEventTypeResponseDone:
+			done = event
+		}
+	}
+	require.Equal(t, []string{
+		dto.RealtimeEventTypeResponseCreated,
+		dto.RealtimeEventResponseOutputItemAdded,
+		dto.RealtimeEventResponseAudioDelta,
+		dto.RealtimeEventResponseAudioTranscriptionDelta,
+		dto.RealtimeEventTypeResponseDone,
+	}, eventTypes)
+
+	var doneUsage dto.RealtimeUsage
+	raw, err := common.Marshal(done["response"].(map[string]any)["usage"])
+	require.NoError(t, err)
+	require.NoError(t, common.Unmarshal(raw, &doneUsage))
+	require.Equal(t, 50, doneUsage.TotalTokens)
+	require.Equal(t, 25, doneUsage.InputTokenDetails.AudioTokens)
+	require.Equal(t, 5, doneUsage.InputTokenDetails.TextTokens)
+	require.Equal(t, 20, doneUsage.OutputTokenDetails.AudioTokens)
+
+	require.NoError(t, client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+	select {
+	case usage := <-usageChan:
+		require.Equal(t, 50, usage.TotalTokens)
+		require.Equal(t, 30, usage.InputTokens)
+		require.Equal(t, 20, usage.OutputTokens)
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not return after client closed")
+	}
+}
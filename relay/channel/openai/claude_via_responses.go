@@ -0,0 +1,111 @@
+package openai
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OaiResponsesToClaudeHandler 将上游非流式 Responses 响应转换为 Claude Messages 响应，返回 Claude 语义的用量
+func OaiResponsesToClaudeHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	if resp == nil || resp.Body == nil {
+		return nil, types.NewOpenAIError(fmt.Errorf("invalid response"), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+
+	defer service.CloseResponseBodyGracefully(resp)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var responsesResp dto.OpenAIResponsesResponse
+	if err := common.Unmarshal(body, &responsesResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if oaiError := responsesResp.GetOpenAIError(); oaiError != nil && oaiError.Type != "" {
+		return nil, types.WithOpenAIError(*oaiError, resp.StatusCode, types.ErrOptionFromUpstream())
+	}
+
+	usage := service.ResponsesUsageToClaudeUsage(responsesResp.Usage)
+	if usage.TotalTokens == 0 {
+		text := service.ExtractOutputTextFromResponses(&responsesResp)
+		usage = service.ResponseText2Usage(c, text, info.UpstreamModelName, info.GetEstimatePromptTokens())
+	}
+
+	responseBody, err := common.Marshal(service.ResponseResponses2Claude(&responsesResp, usage))
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeJsonMarshalFailed, http.StatusInternalServerError)
+	}
+	service.IOCopyBytesGracefully(c, resp, responseBody)
+	return usage, nil
+}
+
+// OaiResponsesToClaudeStreamHandler 将上游 Responses SSE 转换为 Claude SSE，返回 Claude 语义的用量
+func OaiResponsesToClaudeStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	if resp == nil || resp.Body == nil {
+		return nil, types.NewOpenAIError(fmt.Errorf("invalid response"), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+
+	defer service.CloseResponseBodyGracefully(resp)
+
+	var (
+		converter = service.NewResponsesToClaudeStreamConverter(info)
+		usage     *dto.Usage
+		status    string
+		streamErr *types.NewAPIError
+	)
+	sendClaudeEvents := func(events []*dto.ClaudeResponse) {
+		for _, event := range events {
+			_ = helper.ClaudeData(c, *event)
+		}
+	}
+
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		var streamResp dto.ResponsesStreamResponse
+		if err := common.UnmarshalJsonStr(data, &streamResp); err != nil {
+			logger.LogError(c, "failed to unmarshal responses stream event: "+err.Error())
+			return true
+		}
+
+		switch streamResp.Type {
+		case "response.completed", "response.incomplete":
+			if streamResp.Response != nil {
+				status = streamResp.Response.GetStatus()
+				if streamResp.Response.Usage != nil {
+					usage = service.ResponsesUsageToClaudeUsage(streamResp.Response.Usage)
+				}
+			}
+			return false
+		case "response.error", "response.failed":
+			if streamResp.Response != nil {
+				if oaiErr := streamResp.Response.GetOpenAIError(); oaiErr != nil && oaiErr.Type != "" {
+					streamErr = types.WithOpenAIError(*oaiErr, http.StatusInternalServerError, types.ErrOptionFromUpstream())
+					return false
+				}
+			}
+			streamErr = types.NewOpenAIError(fmt.Errorf("responses stream error: %s", streamResp.Type), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+			return false
+		}
+		sendClaudeEvents(converter.Convert(&streamResp))
+		return true
+	})
+
+	if streamErr != nil {
+		return nil, streamErr
+	}
+	if usage == nil || usage.TotalTokens == 0 {
+		usage = service.ResponseText2Usage(c, converter.Text.String(), info.UpstreamModelName, info.GetEstimatePromptTokens())
+	}
+	sendClaudeEvents(converter.Finish(status, usage))
+	return usage, nil
+}
@@ -34,6 +34,11 @@ func OaiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 		return nil, types.WithOpenAIError(*oaiError, resp.StatusCode, types.ErrOptionFromUpstream())
 	}
 
+	if info != nil {
+		info.ResponsesID = responsesResponse.ID
+		info.ResponsesStatus = responsesResponse.GetStatus()
+	}
+
 	if responsesResponse.HasImageGenerationCall() {
 		c.Set("image_generation_call", true)
 		c.Set("image_generation_call_quality", responsesResponse.GetQuality())
@@ -98,6 +103,10 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 		// 检查当前数据是否包含 completed 状态和 usage 信息
 		var streamResponse dto.ResponsesStreamResponse
 		if err := common.UnmarshalJsonStr(data, &streamResponse); err == nil {
+			if streamResponse.Response != nil && streamResponse.Response.ID != "" {
+				info.ResponsesID = streamResponse.Response.ID
+				info.ResponsesStatus = streamResponse.Response.GetStatus()
+			}
 			switch streamResponse.Type {
 			case "response.completed":
 				if streamResponse.Response != nil {
@@ -0,0 +1,40 @@
+package vertex
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel/claude"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+type countTokensAdaptor struct {
+	*Adaptor
+}
+
+// GetRequestURL Vertex 上 Claude 的 count_tokens 为 publishers/anthropic/models/count-tokens:rawPredict
+func (a *countTokensAdaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return a.getRequestUrl(info, "count-tokens", "rawPredict")
+}
+
+func (a *Adaptor) CountClaudeTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (int, error) {
+	if a.RequestMode != RequestModeClaude {
+		return 0, errors.New("count tokens is only supported for claude models on vertex")
+	}
+	body, err := claude.BuildCountTokensBody(request)
+	if err != nil {
+		return 0, err
+	}
+	model := info.UpstreamModelName
+	if v, ok := claudeModelMap[info.UpstreamModelName]; ok {
+		model = v
+	}
+	body["model"], err = common.Marshal(model)
+	if err != nil {
+		return 0, err
+	}
+	return claude.DoCountTokensRequest(&countTokensAdaptor{a}, c, info, body)
+}
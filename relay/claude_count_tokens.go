@@ -0,0 +1,51 @@
+package relay
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaudeCountTokensHelper 计算 Claude Messages 请求的输入 token 数：
+// 渠道支持 count_tokens（Claude / AWS / Vertex）时转发到上游，否则或上游失败时本地估算。
+func ClaudeCountTokensHelper(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) int {
+	info.InitChannelMeta(c)
+
+	upstreamRequest, err := common.DeepCopy(request)
+	if err == nil {
+		err = helper.ModelMappedHelper(c, info, upstreamRequest)
+	}
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("count tokens fallback to local estimation: %s", err.Error()))
+		return service.EstimateClaudeInputTokens(request)
+	}
+
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return service.EstimateClaudeInputTokens(request)
+	}
+	counter, ok := adaptor.(channel.ClaudeTokenCounter)
+	if !ok {
+		return service.EstimateClaudeInputTokens(request)
+	}
+	adaptor.Init(info)
+	if upstreamRequest.MaxTokens == nil || *upstreamRequest.MaxTokens == 0 {
+		defaultMaxTokens := uint(model_setting.GetClaudeSettings().GetDefaultMaxTokens(upstreamRequest.Model))
+		upstreamRequest.MaxTokens = &defaultMaxTokens
+	}
+	tokens, err := counter.CountClaudeTokens(c, info, upstreamRequest)
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("upstream count tokens failed, fallback to local estimation: %s", err.Error()))
+		return service.EstimateClaudeInputTokens(request)
+	}
+	return tokens
+}
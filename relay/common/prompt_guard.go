@@ -0,0 +1,296 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const (
+	GuardStageInput  = "input"
+	GuardStageOutput = "output"
+)
+
+// GuardDetection 一条规则的命中统计，不包含命中的原文
+type GuardDetection struct {
+	Rule     string `json:"rule"`
+	Detector string `json:"detector"`
+	Action   string `json:"action"`
+	Stage    string `json:"stage"`
+	Count    int    `json:"count"`
+}
+
+// GuardBlockedError 命中 block 规则
+type GuardBlockedError struct {
+	Rule string
+}
+
+func (e *GuardBlockedError) Error() string {
+	return fmt.Sprintf("request blocked by prompt guard rule: %s", e.Rule)
+}
+
+var guardDetectorPatterns = map[string]*regexp.Regexp{
+	operation_setting.GuardDetectorEmail:      regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	operation_setting.GuardDetectorPhone:      regexp.MustCompile(`(?:\+\d{1,3}[\s\-]?)?(?:\b1[3-9]\d{9}\b|\(?\b\d{3}\)?[\s.\-]\d{3}[\s.\-]\d{4}\b)`),
+	operation_setting.GuardDetectorCreditCard: regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+	operation_setting.GuardDetectorAPIKey:     regexp.MustCompile(`\b(?:sk-(?:ant-|proj-)?[A-Za-z0-9_\-]{20,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_\-]{35}|gh[pousr]_[A-Za-z0-9]{36}|xox[abprs]-[A-Za-z0-9\-]{10,})`),
+	operation_setting.GuardDetectorNationalID: regexp.MustCompile(`\b\d{17}[\dXx]\b|\b\d{3}-\d{2}-\d{4}\b`),
+}
+
+var guardCustomPatterns sync.Map // map[string]*regexp.Regexp
+
+// 这些字段的值不是自然语言文本（标识符、枚举、二进制数据等），不参与检测
+var guardSkipKeys = map[string]struct{}{
+	"model":              {},
+	"role":               {},
+	"type":               {},
+	"id":                 {},
+	"object":             {},
+	"tool_call_id":       {},
+	"finish_reason":      {},
+	"stop_reason":        {},
+	"system_fingerprint": {},
+	"signature":          {},
+	"url":                {},
+	"image_url":          {},
+	"data":               {},
+	"file_data":          {},
+	"b64_json":           {},
+	"mime_type":          {},
+	"encoding_format":    {},
+}
+
+func guardRuleRegexp(rule operation_setting.GuardRule) (*regexp.Regexp, error) {
+	if rule.Detector != operation_setting.GuardDetectorRegex {
+		re, ok := guardDetectorPatterns[rule.Detector]
+		if !ok {
+			return nil, fmt.Errorf("unknown prompt guard detector: %s", rule.Detector)
+		}
+		return re, nil
+	}
+	if cached, ok := guardCustomPatterns.Load(rule.Pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt guard pattern for rule %s: %w", rule.Name, err)
+	}
+	guardCustomPatterns.Store(rule.Pattern, re)
+	return re, nil
+}
+
+// guardValidMatch 对卡号与身份证号做校验位检查，减少误报
+func guardValidMatch(detector string, match string) bool {
+	switch detector {
+	case operation_setting.GuardDetectorCreditCard:
+		return luhnValid(match)
+	case operation_setting.GuardDetectorNationalID:
+		if len(match) == 18 {
+			return chineseIDChecksumValid(match)
+		}
+	}
+	return true
+}
+
+func luhnValid(number string) bool {
+	sum, digits := 0, 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		ch := number[i]
+		if ch == ' ' || ch == '-' {
+			continue
+		}
+		d := int(ch - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+func chineseIDChecksumValid(id string) bool {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	checkCodes := "10X98765432"
+	sum := 0
+	for i := 0; i < 17; i++ {
+		sum += int(id[i]-'0') * weights[i]
+	}
+	return strings.ToUpper(id[17:]) == string(checkCodes[sum%11])
+}
+
+type guardScanner struct {
+	profile    *operation_setting.GuardProfile
+	stage      string
+	detections map[string]*GuardDetection
+	order      []string
+	blocked    *GuardBlockedError
+	err        error
+}
+
+func ruleName(rule operation_setting.GuardRule) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return rule.Detector
+}
+
+func (s *guardScanner) record(rule operation_setting.GuardRule, action string) {
+	name := ruleName(rule)
+	detection, ok := s.detections[name]
+	if !ok {
+		detection = &GuardDetection{Rule: name, Detector: rule.Detector, Action: action, Stage: s.stage}
+		s.detections[name] = detection
+		s.order = append(s.order, name)
+	}
+	detection.Count++
+}
+
+func (s *guardScanner) scanString(text string) string {
+	for _, rule := range s.profile.Rules {
+		re, err := guardRuleRegexp(rule)
+		if err != nil {
+			s.err = err
+			continue
+		}
+		action := rule.Action
+		// 输出已由上游生成，block 规则在输出阶段按 mask 处理
+		if s.stage == GuardStageOutput && action == operation_setting.GuardActionBlock {
+			action = operation_setting.GuardActionMask
+		}
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			if !guardValidMatch(rule.Detector, match) {
+				return match
+			}
+			s.record(rule, action)
+			switch action {
+			case operation_setting.GuardActionBlock:
+				if s.blocked == nil {
+					s.blocked = &GuardBlockedError{Rule: ruleName(rule)}
+				}
+			case operation_setting.GuardActionMask:
+				return "[REDACTED:" + ruleName(rule) + "]"
+			}
+			return match
+		})
+	}
+	return text
+}
+
+func (s *guardScanner) walk(node interface{}) (interface{}, bool) {
+	switch value := node.(type) {
+	case string:
+		if value == "" || strings.HasPrefix(value, "data:") {
+			return value, false
+		}
+		scanned := s.scanString(value)
+		return scanned, scanned != value
+	case map[string]interface{}:
+		changed := false
+		for key, item := range value {
+			if _, skip := guardSkipKeys[key]; skip {
+				continue
+			}
+			if updated, ok := s.walk(item); ok {
+				value[key] = updated
+				changed = true
+			}
+		}
+		return value, changed
+	case []interface{}:
+		changed := false
+		for i, item := range value {
+			if updated, ok := s.walk(item); ok {
+				value[i] = updated
+				changed = true
+			}
+		}
+		return value, changed
+	default:
+		return node, false
+	}
+}
+
+// ApplyPromptGuard 对 JSON 数据中的文本字段执行防护规则，返回处理后的数据与命中统计；
+// 输入阶段命中 block 规则时返回 *GuardBlockedError。非 JSON 数据原样返回。
+func ApplyPromptGuard(data []byte, profile *operation_setting.GuardProfile, stage string) ([]byte, []GuardDetection, error) {
+	if profile == nil || len(profile.Rules) == 0 || len(data) == 0 {
+		return data, nil, nil
+	}
+	// 使用 json.Number 避免大整数在重新序列化时丢失精度
+	var parsed interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&parsed); err != nil {
+		return data, nil, nil
+	}
+	scanner := &guardScanner{profile: profile, stage: stage, detections: map[string]*GuardDetection{}}
+	updated, changed := scanner.walk(parsed)
+
+	detections := make([]GuardDetection, 0, len(scanner.order))
+	for _, name := range scanner.order {
+		detections = append(detections, *scanner.detections[name])
+	}
+	if scanner.blocked != nil {
+		return data, detections, scanner.blocked
+	}
+	if scanner.err != nil {
+		return data, detections, scanner.err
+	}
+	if !changed {
+		return data, detections, nil
+	}
+	result, err := common.Marshal(updated)
+	if err != nil {
+		return data, detections, err
+	}
+	return result, detections, nil
+}
+
+// AddGuardDetections 合并同一阶段同一规则的命中次数
+func (info *RelayInfo) AddGuardDetections(detections []GuardDetection) {
+	for _, detection := range detections {
+		merged := false
+		for i := range info.GuardDetections {
+			existing := &info.GuardDetections[i]
+			if existing.Rule == detection.Rule && existing.Stage == detection.Stage {
+				existing.Count += detection.Count
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			info.GuardDetections = append(info.GuardDetections, detection)
+		}
+	}
+}
+
+// ShouldGuardOutput 是否需要对模型输出执行防护规则
+func (info *RelayInfo) ShouldGuardOutput() bool {
+	return info != nil && info.GuardProfile != nil && info.GuardProfile.CheckOutput
+}
+
+// ApplyOutputGuard 对非流式的上游 JSON 响应体执行防护规则，流式响应使用 OutputGuardStream
+func (info *RelayInfo) ApplyOutputGuard(data []byte) []byte {
+	if !info.ShouldGuardOutput() {
+		return data
+	}
+	guarded, detections, err := ApplyPromptGuard(data, info.GuardProfile, GuardStageOutput)
+	info.AddGuardDetections(detections)
+	if err != nil {
+		common.SysError("failed to apply output guard: " + err.Error())
+		return data
+	}
+	return guarded
+}
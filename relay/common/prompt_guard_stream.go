@@ -0,0 +1,229 @@
+package common
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// guardStreamWindow 每个文本字段末尾暂不下发的字节数。跨数据块的敏感信息只要不超过该长度，
+// 都能在拼接后的文本中被完整识别；更长的匹配可能已有前缀下发，无法保证完整脱敏。
+const guardStreamWindow = 64
+
+// guardStreamSegment 数据块中的一个文本字段，path 相同的字段在流中依次拼接成完整文本
+type guardStreamSegment struct {
+	path string
+	text string
+	set  func(string)
+}
+
+type guardStreamChunk struct {
+	data     string
+	parsed   interface{}
+	segments []*guardStreamSegment
+}
+
+// OutputGuardStream 流式输出防护：按字段路径拼接各数据块中的增量文本，暂存末尾窗口内的数据块，
+// 确认窗口之前的文本不会与后续增量组成新的匹配后再脱敏下发
+type OutputGuardStream struct {
+	info    *RelayInfo
+	pending []*guardStreamChunk
+}
+
+func (info *RelayInfo) NewOutputGuardStream() *OutputGuardStream {
+	return &OutputGuardStream{info: info}
+}
+
+// Push 加入一个上游 SSE 数据块，返回可以下发的数据块（可能为空，也可能包含之前暂存的数据块）
+func (s *OutputGuardStream) Push(data string) []string {
+	chunk := &guardStreamChunk{data: data}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&chunk.parsed); err == nil {
+		collectGuardSegments(chunk.parsed, "", func(v interface{}) { chunk.parsed = v }, &chunk.segments)
+	}
+	if len(chunk.segments) == 0 && len(s.pending) == 0 {
+		return []string{data}
+	}
+	s.pending = append(s.pending, chunk)
+	return s.release(false)
+}
+
+// Flush 上游流结束时下发全部暂存的数据块
+func (s *OutputGuardStream) Flush() []string {
+	return s.release(true)
+}
+
+func collectGuardSegments(node interface{}, path string, set func(interface{}), segments *[]*guardStreamSegment) {
+	switch value := node.(type) {
+	case string:
+		if value != "" && !strings.HasPrefix(value, "data:") {
+			*segments = append(*segments, &guardStreamSegment{path: path, text: value, set: func(text string) { set(text) }})
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			if _, skip := guardSkipKeys[key]; skip {
+				continue
+			}
+			collectGuardSegments(item, path+"."+key, func(v interface{}) { value[key] = v }, segments)
+		}
+	case []interface{}:
+		for i, item := range value {
+			collectGuardSegments(item, path+"["+strconv.Itoa(i)+"]", func(v interface{}) { value[i] = v }, segments)
+		}
+	}
+}
+
+type guardStreamMatch struct {
+	start, end int
+	rule       operation_setting.GuardRule
+	action     string
+}
+
+// release 计算可以下发的数据块前缀：数据块中每个文本字段之后还需有至少 guardStreamWindow 字节的后续文本，
+// 且字段末尾不能落在任何（可能尚未完整的）匹配内部。final 为 true 时全部下发。
+func (s *OutputGuardStream) release(final bool) []string {
+	profile := s.info.GuardProfile
+	type segmentRef struct {
+		chunk      int
+		segment    *guardStreamSegment
+		start, end int
+	}
+	texts := make(map[string]*strings.Builder)
+	refs := make(map[string][]segmentRef)
+	var paths []string
+	for i, chunk := range s.pending {
+		for _, segment := range chunk.segments {
+			builder, ok := texts[segment.path]
+			if !ok {
+				builder = &strings.Builder{}
+				texts[segment.path] = builder
+				paths = append(paths, segment.path)
+			}
+			start := builder.Len()
+			builder.WriteString(segment.text)
+			refs[segment.path] = append(refs[segment.path], segmentRef{chunk: i, segment: segment, start: start, end: builder.Len()})
+		}
+	}
+
+	releasable := len(s.pending)
+	matches := make(map[string][]guardStreamMatch, len(paths))
+	for _, path := range paths {
+		text := texts[path].String()
+		var spans [][]int
+		for _, rule := range profile.Rules {
+			re, err := guardRuleRegexp(rule)
+			if err != nil {
+				common.SysError("failed to apply output guard: " + err.Error())
+				continue
+			}
+			action := rule.Action
+			if action == operation_setting.GuardActionBlock {
+				action = operation_setting.GuardActionMask
+			}
+			for _, loc := range re.FindAllStringIndex(text, -1) {
+				spans = append(spans, loc)
+				if guardValidMatch(rule.Detector, text[loc[0]:loc[1]]) {
+					matches[path] = append(matches[path], guardStreamMatch{start: loc[0], end: loc[1], rule: rule, action: action})
+				}
+			}
+		}
+		if final {
+			continue
+		}
+		for _, ref := range refs[path] {
+			if ref.chunk >= releasable {
+				break
+			}
+			held := len(text)-ref.end < guardStreamWindow
+			for _, span := range spans {
+				if span[0] < ref.end && ref.end < span[1] {
+					held = true
+				}
+			}
+			if held {
+				releasable = ref.chunk
+				break
+			}
+		}
+	}
+	if releasable == 0 {
+		return nil
+	}
+
+	var detections []GuardDetection
+	for _, path := range paths {
+		var releasedEnd int
+		for _, ref := range refs[path] {
+			if ref.chunk < releasable {
+				releasedEnd = ref.end
+			}
+		}
+		if releasedEnd == 0 {
+			continue
+		}
+		// 不同规则的匹配可能重叠，按起点排序后跳过与前一个匹配重叠的部分
+		applied := make([]guardStreamMatch, 0, len(matches[path]))
+		sort.SliceStable(matches[path], func(i, j int) bool { return matches[path][i].start < matches[path][j].start })
+		lastEnd := 0
+		for _, match := range matches[path] {
+			if match.end > releasedEnd || match.start < lastEnd {
+				continue
+			}
+			applied = append(applied, match)
+			lastEnd = match.end
+			detections = append(detections, GuardDetection{Rule: ruleName(match.rule), Detector: match.rule.Detector, Action: match.action, Stage: GuardStageOutput, Count: 1})
+		}
+		text := texts[path].String()
+		for _, ref := range refs[path] {
+			if ref.chunk >= releasable {
+				break
+			}
+			rewritten := rewriteGuardSegment(text, ref.start, ref.end, applied)
+			if rewritten != ref.segment.text {
+				ref.segment.set(rewritten)
+				s.pending[ref.chunk].data = ""
+			}
+		}
+	}
+	s.info.AddGuardDetections(detections)
+
+	out := make([]string, 0, releasable)
+	for _, chunk := range s.pending[:releasable] {
+		if chunk.data == "" {
+			data, err := common.Marshal(chunk.parsed)
+			if err != nil {
+				common.SysError("failed to apply output guard: " + err.Error())
+			}
+			chunk.data = string(data)
+		}
+		out = append(out, chunk.data)
+	}
+	s.pending = s.pending[releasable:]
+	return out
+}
+
+// rewriteGuardSegment 生成 [start, end) 区间的文本：mask 匹配的替换文本放在匹配起点所在的字段，
+// 匹配在后续字段中的部分删除
+func rewriteGuardSegment(text string, start, end int, matches []guardStreamMatch) string {
+	var builder strings.Builder
+	pos := start
+	for _, match := range matches {
+		if match.action != operation_setting.GuardActionMask || match.end <= start || match.start >= end {
+			continue
+		}
+		if match.start >= pos {
+			builder.WriteString(text[pos:match.start])
+			builder.WriteString("[REDACTED:" + ruleName(match.rule) + "]")
+		}
+		pos = min(match.end, end)
+	}
+	if pos < end {
+		builder.WriteString(text[pos:end])
+	}
+	return builder.String()
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyPromptGuardMaskAndLog(t *testing.T) {
+	profile := &operation_setting.GuardProfile{Rules: []operation_setting.GuardRule{
+		{Name: "email", Detector: operation_setting.GuardDetectorEmail, Action: operation_setting.GuardActionMask},
+		{Name: "card", Detector: operation_setting.GuardDetectorCreditCard, Action: operation_setting.GuardActionMask},
+		{Name: "cn_id", Detector: operation_setting.GuardDetectorNationalID, Action: operation_setting.GuardActionLog},
+		{Name: "ticket", Detector: operation_setting.GuardDetectorRegex, Pattern: `TICKET-\d+`, Action: operation_setting.GuardActionMask},
+	}}
+	input := []byte(`{"model":"gpt-4o","seed":9007199254740993,"messages":[{"role":"user","content":[{"type":"text","text":"mail a@b.com or c@d.org, card 4111 1111 1111 1111, not 4111 1111 1111 1112, id 11010519491231002X, TICKET-42"}]}]}`)
+
+	out, detections, err := ApplyPromptGuard(input, profile, GuardStageInput)
+	require.NoError(t, err)
+	require.Equal(t, "mail [REDACTED:email] or [REDACTED:email], card [REDACTED:card], not 4111 1111 1111 1112, id 11010519491231002X, [REDACTED:ticket]",
+		gjson.GetBytes(out, "messages.0.content.0.text").String())
+	require.Equal(t, "9007199254740993", gjson.GetBytes(out, "seed").Raw)
+	require.Equal(t, []GuardDetection{
+		{Rule: "email", Detector: operation_setting.GuardDetectorEmail, Action: operation_setting.GuardActionMask, Stage: GuardStageInput, Count: 2},
+		{Rule: "card", Detector: operation_setting.GuardDetectorCreditCard, Action: operation_setting.GuardActionMask, Stage: GuardStageInput, Count: 1},
+		{Rule: "cn_id", Detector: operation_setting.GuardDetectorNationalID, Action: operation_setting.GuardActionLog, Stage: GuardStageInput, Count: 1},
+		{Rule: "ticket", Detector: operation_setting.GuardDetectorRegex, Action: operation_setting.GuardActionMask, Stage: GuardStageInput, Count: 1},
+	}, detections)
+}
+
+func TestApplyPromptGuardBlock(t *testing.T) {
+	profile := &operation_setting.GuardProfile{Rules: []operation_setting.GuardRule{
+		{Name: "api_key", Detector: operation_setting.GuardDetectorAPIKey, Action: operation_setting.GuardActionBlock},
+	}}
+	input := []byte(`{"input":"use sk-abcdefghijklmnopqrstuvwxyz123456 please"}`)
+
+	out, detections, err := ApplyPromptGuard(input, profile, GuardStageInput)
+	var blocked *GuardBlockedError
+	require.ErrorAs(t, err, &blocked)
+	require.Equal(t, "api_key", blocked.Rule)
+	require.Equal(t, input, out)
+	require.Len(t, detections, 1)
+
+	// 输出阶段 block 按 mask 处理
+	out, _, err = ApplyPromptGuard([]byte(`{"choices":[{"delta":{"content":"key sk-abcdefghijklmnopqrstuvwxyz123456"}}]}`), profile, GuardStageOutput)
+	require.NoError(t, err)
+	require.Equal(t, "key [REDACTED:api_key]", gjson.GetBytes(out, "choices.0.delta.content").String())
+}
+
+func TestRelayInfoApplyOutputGuard(t *testing.T) {
+	info := &RelayInfo{GuardProfile: &operation_setting.GuardProfile{
+		CheckOutput: true,
+		Rules:       []operation_setting.GuardRule{{Detector: operation_setting.GuardDetectorPhone, Action: operation_setting.GuardActionMask}},
+	}}
+	chunk := []byte(`{"id":"13800138000","choices":[{"delta":{"content":"call 13800138000"}}]}`)
+	out := info.ApplyOutputGuard(chunk)
+	require.Equal(t, "13800138000", gjson.GetBytes(out, "id").String())
+	require.Equal(t, "call [REDACTED:phone]", gjson.GetBytes(out, "choices.0.delta.content").String())
+	info.ApplyOutputGuard(chunk)
+	require.Equal(t, []GuardDetection{{Rule: "phone", Detector: operation_setting.GuardDetectorPhone, Action: operation_setting.GuardActionMask, Stage: GuardStageOutput, Count: 2}}, info.GuardDetections)
+
+	info.GuardProfile.CheckOutput = false
+	require.Equal(t, chunk, info.ApplyOutputGuard(chunk))
+}
+
+func TestOutputGuardStreamMasksAcrossChunks(t *testing.T) {
+	info := &RelayInfo{GuardProfile: &operation_setting.GuardProfile{CheckOutput: true, Rules: []operation_setting.GuardRule{
+		{Name: "email", Detector: operation_setting.GuardDetectorEmail, Action: operation_setting.GuardActionMask},
+		{Name: "card", Detector: operation_setting.GuardDetectorCreditCard, Action: operation_setting.GuardActionMask},
+	}}}
+	stream := info.NewOutputGuardStream()
+	deltas := []string{"Contact me at john.", "doe@exam", "ple.com, card 4111 1111 ", "1111 1111 and that is all for today, thanks a lot for asking!", " Bye."}
+
+	var out []string
+	out = append(out, stream.Push(`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant"}}]}`)...)
+	for _, delta := range deltas {
+		out = append(out, stream.Push(`{"id":"c1","choices":[{"index":0,"delta":{"content":"`+delta+`"}}]}`)...)
+	}
+	// 末尾窗口内的数据块在流结束前不会下发
+	require.Less(t, len(out), len(deltas)+1)
+	out = append(out, stream.Flush()...)
+	require.Len(t, out, len(deltas)+1)
+
+	var text strings.Builder
+	for _, data := range out {
+		text.WriteString(gjson.Get(data, "choices.0.delta.content").String())
+	}
+	require.Equal(t, "Contact me at [REDACTED:email], card [REDACTED:card] and that is all for today, thanks a lot for asking! Bye.", text.String())
+	require.Equal(t, []GuardDetection{
+		{Rule: "email", Detector: operation_setting.GuardDetectorEmail, Action: operation_setting.GuardActionMask, Stage: GuardStageOutput, Count: 1},
+		{Rule: "card", Detector: operation_setting.GuardDetectorCreditCard, Action: operation_setting.GuardActionMask, Stage: GuardStageOutput, Count: 1},
+	}, info.GuardDetections)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -90,6 +91,7 @@ type RelayInfo struct {
 	UsingGroup        string // 使用的分组，当auto跨分组重试时，会变动
 	UserGroup         string // 用户所在分组
 	TokenUnlimited    bool
+	ClientTokenId     string // 设置了额度上限的客户端令牌，扣减令牌额度时同步累计其用量
 	StartTime         time.Time
 	FirstResponseTime time.Time
 	isFirstResponse   bool
@@ -149,6 +151,15 @@ type RelayInfo struct {
 	LastError                             *types.NewAPIError
 	RuntimeHeadersOverride                map[string]interface{}
 	UseRuntimeHeadersOverride             bool
+	// ResponseCacheStatus 上游响应缓存状态：hit / miss，未使用缓存时为空
+	ResponseCacheStatus string
+	// GuardProfile 当前请求生效的输入/输出防护方案，未启用时为 nil
+	GuardProfile     *operation_setting.GuardProfile
+	GuardProfileName string
+	GuardDetections  []GuardDetection
+	// ResponsesID / ResponsesStatus 上游（或网关转换）返回的 Responses 响应 id 与状态，用于记录响应归属
+	ResponsesID     string
+	ResponsesStatus string
 
 	PriceData types.PriceData
 
@@ -462,6 +473,7 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
 		TokenGroup:     tokenGroup,
+		ClientTokenId:  clientTokenIdWithQuota(c),
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
@@ -865,3 +877,11 @@ func RemoveGeminiDisabledFields(jsonData []byte) ([]byte, error) {
 	}
 	return jsonDataAfter, nil
 }
+
+// clientTokenIdWithQuota 返回设置了额度上限的客户端令牌 id，未使用客户端令牌或未设置上限时返回空
+func clientTokenIdWithQuota(c *gin.Context) string {
+	if common.GetContextKeyInt(c, constant.ContextKeyClientTokenMaxQuota) <= 0 {
+		return ""
+	}
+	return common.GetContextKeyString(c, constant.ContextKeyClientTokenId)
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/tidwall/gjson"
+)
+
+// 响应覆盖（渠道设置 response_override）复用参数覆盖的 operations / conditions 规则：
+// 请求头类操作（set_header、delete_header、copy_header、move_header、pass_headers）作用于响应头，
+// 其余操作作用于上游的非流式 JSON 响应体以及流式响应的每个 SSE 数据块。
+
+var responseHeaderOperationModes = map[string]struct{}{
+	"set_header":    {},
+	"delete_header": {},
+	"copy_header":   {},
+	"move_header":   {},
+	"pass_headers":  {},
+}
+
+func isResponseHeaderOperation(mode string) bool {
+	_, ok := responseHeaderOperationModes[mode]
+	return ok
+}
+
+func getResponseOverrideMap(info *RelayInfo) map[string]interface{} {
+	if info == nil || info.ChannelMeta == nil {
+		return nil
+	}
+	return info.ChannelMeta.ChannelSetting.ResponseOverride
+}
+
+// HasResponseOverride 渠道是否配置了响应覆盖规则
+func HasResponseOverride(info *RelayInfo) bool {
+	return len(getResponseOverrideMap(info)) > 0
+}
+
+// ApplyResponseOverride 按渠道响应覆盖规则改写一个 JSON 响应体或 SSE 数据块，非 JSON 数据原样返回
+func ApplyResponseOverride(data []byte, info *RelayInfo) ([]byte, error) {
+	responseOverride := getResponseOverrideMap(info)
+	if len(responseOverride) == 0 || !gjson.ValidBytes(data) {
+		return data, nil
+	}
+
+	operations, ok := tryParseOperations(responseOverride)
+	if !ok {
+		return applyOperationsLegacy(data, responseOverride)
+	}
+
+	workingJSON := data
+	if legacyOverride := buildLegacyParamOverride(responseOverride); len(legacyOverride) > 0 {
+		var err error
+		workingJSON, err = applyOperationsLegacy(workingJSON, legacyOverride)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bodyOperations := lo.Filter(operations, func(op ParamOperation, _ int) bool {
+		return !isResponseHeaderOperation(op.Mode)
+	})
+	if len(bodyOperations) == 0 {
+		return workingJSON, nil
+	}
+	result, err := applyOperations(string(workingJSON), bodyOperations, BuildParamOverrideContext(info))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(result), nil
+}
+
+// ApplyResponseHeaderOverride 按渠道响应覆盖规则中的请求头类操作改写响应头。
+// body 为非流式 JSON 响应体，用于条件判断；流式响应传 nil。
+func ApplyResponseHeaderOverride(header http.Header, body []byte, info *RelayInfo) error {
+	responseOverride := getResponseOverrideMap(info)
+	if len(responseOverride) == 0 || header == nil {
+		return nil
+	}
+	operations, ok := tryParseOperations(responseOverride)
+	if !ok {
+		return nil
+	}
+	headerOperations := lo.Filter(operations, func(op ParamOperation, _ int) bool {
+		return isResponseHeaderOperation(op.Mode)
+	})
+	if len(headerOperations) == 0 {
+		return nil
+	}
+
+	original := make(map[string]interface{}, len(header))
+	for name, values := range header {
+		key := normalizeHeaderContextKey(name)
+		if key == "" || len(values) == 0 {
+			continue
+		}
+		original[key] = strings.Join(values, ",")
+	}
+
+	// 响应头同时作为读取来源（request_headers）与改写目标（header_override）
+	context := BuildParamOverrideContext(info)
+	context[paramOverrideContextRequestHeaders] = original
+	context[paramOverrideContextHeaderOverride] = lo.Assign(original)
+
+	jsonStr := "{}"
+	if gjson.ValidBytes(body) {
+		jsonStr = string(body)
+	}
+	if _, err := applyOperations(jsonStr, headerOperations, context); err != nil {
+		return err
+	}
+
+	updated := ensureMapKeyInContext(context, paramOverrideContextHeaderOverride)
+	for key := range original {
+		if _, exists := updated[key]; !exists {
+			header.Del(key)
+		}
+	}
+	for key, value := range updated {
+		headerValue := strings.TrimSpace(fmt.Sprintf("%v", value))
+		if headerValue == "" {
+			header.Del(key)
+			continue
+		}
+		if existing, exists := original[key]; exists && existing == headerValue {
+			continue
+		}
+		header.Set(key, headerValue)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func newResponseOverrideRelayInfo(responseOverride map[string]interface{}) *RelayInfo {
+	return &RelayInfo{
+		OriginModelName: "gpt-4o",
+		ChannelMeta: &ChannelMeta{
+			UpstreamModelName: "vendor/gpt-4o-2024-08-06",
+			ChannelSetting: dto.ChannelSettings{
+				ResponseOverride: responseOverride,
+			},
+		},
+	}
+}
+
+func TestApplyResponseOverrideBody(t *testing.T) {
+	info := newResponseOverrideRelayInfo(map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{
+				"path":  "model",
+				"mode":  "set",
+				"value": "gpt-4o",
+				"conditions": []interface{}{
+					map[string]interface{}{"path": "original_model", "mode": "full", "value": "gpt-4o"},
+				},
+			},
+			map[string]interface{}{"path": "system_fingerprint", "mode": "delete"},
+			map[string]interface{}{"from": "usage.input_tokens", "mode": "move", "to": "usage.prompt_tokens"},
+			map[string]interface{}{"path": "X-Vendor-Trace", "mode": "delete_header"},
+		},
+	})
+
+	out, err := ApplyResponseOverride([]byte(`{"model":"vendor/gpt-4o-2024-08-06","system_fingerprint":"fp_1","usage":{"input_tokens":3}}`), info)
+	if err != nil {
+		t.Fatalf("ApplyResponseOverride returned error: %v", err)
+	}
+	assertJSONEqual(t, `{"model":"gpt-4o","usage":{"prompt_tokens":3}}`, string(out))
+
+	// 非 JSON 数据（如 [DONE]）原样返回
+	out, err = ApplyResponseOverride([]byte(`[DONE]`), info)
+	if err != nil {
+		t.Fatalf("ApplyResponseOverride returned error: %v", err)
+	}
+	if string(out) != "[DONE]" {
+		t.Fatalf("expected non-JSON data to be unchanged, got: %s", out)
+	}
+}
+
+func TestApplyResponseHeaderOverride(t *testing.T) {
+	info := newResponseOverrideRelayInfo(map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"path": "model", "mode": "delete"},
+			map[string]interface{}{"path": "X-Vendor-Trace", "mode": "delete_header"},
+			map[string]interface{}{"path": "X-Served-By", "mode": "set_header", "value": "gateway"},
+			map[string]interface{}{"from": "X-Ratelimit-Remaining", "to": "X-Quota-Remaining", "mode": "move_header"},
+		},
+	})
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Vendor-Trace", "abc")
+	header.Set("X-Ratelimit-Remaining", "42")
+	if err := ApplyResponseHeaderOverride(header, []byte(`{"model":"x"}`), info); err != nil {
+		t.Fatalf("ApplyResponseHeaderOverride returned error: %v", err)
+	}
+
+	if header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected content-type to be preserved, got: %q", header.Get("Content-Type"))
+	}
+	if _, exists := header["X-Vendor-Trace"]; exists {
+		t.Fatalf("expected x-vendor-trace to be stripped")
+	}
+	if header.Get("X-Served-By") != "gateway" {
+		t.Fatalf("expected x-served-by to be set, got: %q", header.Get("X-Served-By"))
+	}
+	if header.Get("X-Quota-Remaining") != "42" || header.Get("X-Ratelimit-Remaining") != "" {
+		t.Fatalf("expected ratelimit header to be moved, got: %v", header)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
@@ -99,6 +100,11 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 	}
 
 	var requestBody io.Reader
+	var cacheRecorder *service.ResponseCacheRecorder
+	var cacheUsage *dto.Usage
+	defer func() {
+		cacheRecorder.Finish(cacheUsage, newAPIError == nil)
+	}()
 
 	if passThroughGlobal || info.ChannelSetting.PassThroughBodyEnabled {
 		storage, err := common.GetBodyStorage(c)
@@ -181,6 +187,10 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 
 		logger.LogDebug(c, fmt.Sprintf("text request body: %s", string(jsonData)))
 
+		var hit bool
+		if hit, cacheRecorder = tryResponseCache(c, info, jsonData); hit {
+			return nil
+		}
 		requestBody = bytes.NewBuffer(jsonData)
 	}
 
@@ -203,12 +213,15 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 	}
 
+	responseSpan := tracing.StartSpan(c, "relay.do_response")
 	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
+	responseSpan.EndAPIError(newApiErr)
 	if newApiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
 		return newApiErr
 	}
+	cacheUsage = usage.(*dto.Usage)
 
 	var containAudioTokens = usage.(*dto.Usage).CompletionTokenDetails.AudioTokens > 0 || usage.(*dto.Usage).PromptTokensDetails.AudioTokens > 0
 	var containsAudioRatios = ratio_setting.ContainsAudioRatio(info.OriginModelName) || ratio_setting.ContainsAudioCompletionRatio(info.OriginModelName)
@@ -249,6 +262,12 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	modelName := relayInfo.OriginModelName
 
 	tokenName := ctx.GetString("token_name")
+	// 阶梯价格按实际输入 token 数重新选择，Claude 语义的 input_tokens 不含缓存，需要加回
+	tierPromptTokens := promptTokens
+	if relayInfo.GetFinalRequestRelayFormat() == types.RelayFormatClaude {
+		tierPromptTokens += cacheTokens + cachedCreationTokens
+	}
+	relayInfo.PriceData.ApplyPriceTier(tierPromptTokens)
 	completionRatio := relayInfo.PriceData.CompletionRatio
 	cacheRatio := relayInfo.PriceData.CacheRatio
 	imageRatio := relayInfo.PriceData.ImageRatio
@@ -422,10 +441,18 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		if !ratio.IsZero() && quota == 0 {
 			quota = 1
 		}
-		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		if relayInfo.ResponseCacheStatus == service.ResponseCacheHit {
+			// 命中响应缓存时未请求上游，按命中计费比例结算且不计入渠道用量
+			quota = service.ApplyResponseCacheHitBilling(quota)
+			extraContent = append(extraContent, "命中响应缓存")
+			model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+		} else {
+			model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+			model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		}
 	}
 
+	service.SettleTokenRateLimit(ctx, totalTokens)
 	if err := service.SettleBilling(ctx, relayInfo, quota); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
@@ -439,6 +466,9 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		logModel = "gpt-4o-gizmo-*"
 		extraContent = append(extraContent, fmt.Sprintf("模型 %s", modelName))
 	}
+	if totalTokens != 0 && relayInfo.ResponseCacheStatus != service.ResponseCacheHit {
+		service.RecordConsumeUsage(ctx, relayInfo.ChannelId, logModel, relayInfo.UsingGroup, promptTokens, completionTokens, quota)
+	}
 	logContent := strings.Join(extraContent, ", ")
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, cacheTokens, cacheRatio, modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
 	if adminRejectReason != "" {
@@ -498,6 +528,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		ModelName:        logModel,
 		TokenName:        tokenName,
 		Quota:            quota,
+		UpstreamCost:     service.CalculateUpstreamCost(relayInfo, relayInfo.PriceData, quota),
 		Content:          logContent,
 		TokenId:          relayInfo.TokenId,
 		UseTimeSeconds:   int(useTimeSeconds),
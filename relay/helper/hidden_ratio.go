@@ -46,6 +46,7 @@ func ApplyHiddenRatio(info *relaycommon.RelayInfo, usage *dto.Usage) bool {
 		}
 	}
 
+	info.PriceData.AppliedHiddenRatio = hr
 	usage.PromptTokens = int(math.Round(float64(usage.PromptTokens) * hr))
 	usage.CompletionTokens = int(math.Round(float64(usage.CompletionTokens) * hr))
 	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
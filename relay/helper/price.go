@@ -74,6 +74,9 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	var audioRatio float64
 	var audioCompletionRatio float64
 	var freeModel bool
+	var priceTiers []types.ModelPriceTier
+	var basePrice types.ModelPriceTier
+	priceTier := -1
 	if !usePrice {
 		preConsumedTokens := common.Max(promptTokens, common.PreConsumedQuota)
 		if meta.MaxTokens != 0 {
@@ -94,6 +97,20 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		completionRatio = ratio_setting.GetCompletionRatio(info.OriginModelName)
 		cacheRatio, _ = ratio_setting.GetCacheRatio(info.OriginModelName)
 		cacheCreationRatio, _ = ratio_setting.GetCreateCacheRatio(info.OriginModelName)
+		// 阶梯价格：预扣时按估算的输入 token 数选择阶梯，结算时再按实际用量重新选择
+		priceTiers = ratio_setting.GetModelPriceTiers(info.OriginModelName)
+		basePrice = types.ModelPriceTier{
+			ModelRatio:         modelRatio,
+			CompletionRatio:    completionRatio,
+			CacheRatio:         cacheRatio,
+			CacheCreationRatio: cacheCreationRatio,
+		}
+		var tierPrice types.ModelPriceTier
+		priceTier, tierPrice = types.ResolvePriceTier(priceTiers, basePrice, promptTokens)
+		modelRatio = tierPrice.ModelRatio
+		completionRatio = tierPrice.CompletionRatio
+		cacheRatio = tierPrice.CacheRatio
+		cacheCreationRatio = tierPrice.CacheCreationRatio
 		cacheCreationRatio5m = cacheCreationRatio
 		// 固定1h和5min缓存写入价格的比例
 		cacheCreationRatio1h = cacheCreationRatio * claudeCacheCreation1hMultiplier
@@ -144,6 +161,10 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		CacheCreation1hRatio: cacheCreationRatio1h,
 		HiddenRatio:          hiddenRatio,
 		QuotaToPreConsume:    preConsumedQuota,
+		PriceTiers:           priceTiers,
+		BasePrice:            basePrice,
+		PriceTier:            priceTier,
+		PriceTierTokens:      promptTokens,
 	}
 
 	if common.DebugEnabled {
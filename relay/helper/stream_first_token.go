@@ -0,0 +1,247 @@
+package helper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+type firstTokenState int
+
+const (
+	firstTokenPending firstTokenState = iota
+	firstTokenReady
+	firstTokenFailed
+)
+
+// 只携带元信息、不含模型输出的事件，收到后继续等待
+var streamPreambleEventTypes = map[string]struct{}{
+	"ping":                 {},
+	"message_start":        {},
+	"response.created":     {},
+	"response.in_progress": {},
+	"response.queued":      {},
+}
+
+// classifyStreamFirstTokenData 判断 SSE data 是否为首个有效内容分片，兼容 OpenAI、Claude、Responses 与 Gemini 格式
+func classifyStreamFirstTokenData(data string) firstTokenState {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return firstTokenPending
+	}
+	if data == "[DONE]" {
+		return firstTokenReady
+	}
+	if !gjson.Valid(data) {
+		return firstTokenReady
+	}
+	result := gjson.Parse(data)
+	eventType := result.Get("type").String()
+	if errField := result.Get("error"); eventType == "error" || eventType == "response.failed" || (errField.Exists() && errField.Type != gjson.Null) {
+		return firstTokenFailed
+	}
+	if _, ok := streamPreambleEventTypes[eventType]; ok {
+		return firstTokenPending
+	}
+	choices := result.Get("choices")
+	if !choices.Exists() {
+		return firstTokenReady
+	}
+	if !choices.IsArray() || len(choices.Array()) == 0 {
+		// 仅携带 usage 的分片通常是流的末尾
+		if result.Get("usage").Exists() {
+			return firstTokenReady
+		}
+		return firstTokenPending
+	}
+	for _, choice := range choices.Array() {
+		if choice.Get("finish_reason").String() != "" {
+			return firstTokenReady
+		}
+		delta := choice.Get("delta")
+		for _, field := range []string{"content", "reasoning_content", "reasoning", "tool_calls", "function_call", "audio"} {
+			value := delta.Get(field)
+			if value.Exists() && value.Type != gjson.Null && value.String() != "" {
+				return firstTokenReady
+			}
+		}
+	}
+	return firstTokenPending
+}
+
+type firstTokenResult struct {
+	buffered []byte
+	err      error
+}
+
+// readUntilFirstToken 逐行读取并缓存 SSE，直到出现首个有效内容分片
+func readUntilFirstToken(reader *bufio.Reader) firstTokenResult {
+	var buffered bytes.Buffer
+	for {
+		line, err := reader.ReadBytes('\n')
+		buffered.Write(line)
+		if data, ok := strings.CutPrefix(strings.TrimSpace(string(line)), "data:"); ok {
+			switch classifyStreamFirstTokenData(data) {
+			case firstTokenReady:
+				return firstTokenResult{buffered: buffered.Bytes()}
+			case firstTokenFailed:
+				return firstTokenResult{err: fmt.Errorf("upstream returned an error before the first token: %s", common.MaskSensitiveInfo(strings.TrimSpace(data)))}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return firstTokenResult{err: errors.New("upstream closed the stream before the first token")}
+			}
+			return firstTokenResult{err: fmt.Errorf("read upstream stream failed before the first token: %w", err)}
+		}
+	}
+}
+
+type firstTokenBody struct {
+	io.Reader
+	io.Closer
+}
+
+var errStreamFirstTokenTimeout = errors.New("stream first token timeout")
+
+// StreamFirstTokenDeadline 流式故障转移的首字时限。在发出上游请求前开始计时，
+// 覆盖建立连接、等待响应头与等待首字的全部时间；到期时取消上游请求的 context
+type StreamFirstTokenDeadline struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+}
+
+// NewStreamFirstTokenDeadline 基于上游请求的 context 创建首字时限，未启用流式故障转移或不限时时返回 nil
+func NewStreamFirstTokenDeadline(parent context.Context, info *relaycommon.RelayInfo, startTime time.Time) *StreamFirstTokenDeadline {
+	setting := operation_setting.GetStreamFailoverSetting()
+	if !setting.Enabled || !info.IsStream {
+		return nil
+	}
+	d := setting.GetFirstTokenTimeout(info.UsingGroup, info.OriginModelName)
+	if d <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	return &StreamFirstTokenDeadline{
+		ctx:    ctx,
+		cancel: cancel,
+		timer:  time.AfterFunc(time.Until(startTime.Add(d)), func() { cancel(errStreamFirstTokenTimeout) }),
+	}
+}
+
+// Context 上游请求应使用的 context，首字到达前超时会被取消
+func (d *StreamFirstTokenDeadline) Context() context.Context {
+	return d.ctx
+}
+
+// stop 首字已到达或不再需要等待时停止计时，context 保持有效以继续读取响应体
+func (d *StreamFirstTokenDeadline) stop() {
+	if d != nil {
+		d.timer.Stop()
+	}
+}
+
+func (d *StreamFirstTokenDeadline) expired() bool {
+	return d != nil && errors.Is(context.Cause(d.ctx), errStreamFirstTokenTimeout)
+}
+
+func (d *StreamFirstTokenDeadline) done() <-chan struct{} {
+	if d == nil {
+		return nil
+	}
+	return d.ctx.Done()
+}
+
+// StreamFirstTokenTimeoutError 上游请求因首字时限到期而失败时，返回可重试的故障转移错误；否则返回 nil
+func StreamFirstTokenTimeoutError(c *gin.Context, info *relaycommon.RelayInfo, deadline *StreamFirstTokenDeadline, startTime time.Time) error {
+	if !deadline.expired() {
+		return nil
+	}
+	return streamFirstTokenFailed(c, info, fmt.Errorf("upstream did not return the first token within %s", time.Since(startTime).Round(time.Millisecond)), startTime)
+}
+
+// WaitStreamFirstToken 启用流式故障转移时，在向客户端输出任何内容前等待上游的首个有效内容分片。
+// 成功时将已缓存的数据放回响应体；超时、提前断开或收到错误事件时关闭响应体并返回可重试的错误，
+// 由调用方切换下一个渠道。deadline 为发出请求前创建的首字时限，nil 表示不限时
+func WaitStreamFirstToken(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, startTime time.Time, deadline *StreamFirstTokenDeadline) error {
+	defer deadline.stop()
+	setting := operation_setting.GetStreamFailoverSetting()
+	if !setting.Enabled || !info.IsStream || resp == nil || resp.Body == nil || resp.StatusCode != http.StatusOK ||
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return nil
+	}
+
+	reader := bufio.NewReaderSize(resp.Body, InitialScannerBufferSize)
+	resultChan := make(chan firstTokenResult, 1)
+	gopool.Go(func() {
+		resultChan <- readUntilFirstToken(reader)
+	})
+
+	var err error
+	select {
+	case result := <-resultChan:
+		if result.err == nil {
+			deadline.stop()
+			resp.Body = firstTokenBody{
+				Reader: io.MultiReader(bytes.NewReader(result.buffered), reader),
+				Closer: resp.Body,
+			}
+			return nil
+		}
+		err = result.err
+	case <-deadline.done():
+	case <-c.Request.Context().Done():
+		// 客户端已断开，无需再切换渠道
+		_ = resp.Body.Close()
+		return types.NewError(context.Cause(c.Request.Context()), types.ErrorCodeDoRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	if deadline.expired() {
+		// 时限到期会中断正在进行的读取，统一报告为超时
+		err = fmt.Errorf("upstream did not return the first token within %s", time.Since(startTime).Round(time.Millisecond))
+	} else if err == nil {
+		err = context.Cause(deadline.ctx)
+	}
+	// 关闭响应体以结束仍在阻塞读取的 goroutine
+	_ = resp.Body.Close()
+	return streamFirstTokenFailed(c, info, err, startTime)
+}
+
+func streamFirstTokenFailed(c *gin.Context, info *relaycommon.RelayInfo, err error, startTime time.Time) error {
+	logger.LogWarn(c, fmt.Sprintf("stream failover: channel #%d failed before the first token: %s", info.ChannelId, err.Error()))
+	recordStreamFailoverAttempt(c, info, err, startTime)
+	return types.NewOpenAIError(err, types.ErrorCodeStreamFirstTokenFailed, http.StatusBadGateway)
+}
+
+func recordStreamFailoverAttempt(c *gin.Context, info *relaycommon.RelayInfo, err error, startTime time.Time) {
+	var attempts []map[string]interface{}
+	if v, ok := common.GetContextKeyType[[]map[string]interface{}](c, constant.ContextKeyStreamFailoverAttempts); ok {
+		attempts = v
+	}
+	attempt := map[string]interface{}{
+		"channel_id": info.ChannelId,
+		"error":      err.Error(),
+		"elapsed_ms": time.Since(startTime).Milliseconds(),
+	}
+	if info.ChannelIsMultiKey {
+		attempt["multi_key_index"] = info.ChannelMultiKeyIndex
+	}
+	common.SetContextKey(c, constant.ContextKeyStreamFailoverAttempts, append(attempts, attempt))
+}
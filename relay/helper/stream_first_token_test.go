@@ -0,0 +1,134 @@
+package helper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func setupFirstTokenTest(t *testing.T, body io.ReadCloser, timeoutSeconds int) (*gin.Context, *http.Response, *relaycommon.RelayInfo) {
+	t.Helper()
+
+	setting := operation_setting.GetStreamFailoverSetting()
+	old := *setting
+	setting.Enabled = true
+	setting.FirstTokenTimeoutSeconds = timeoutSeconds
+	t.Cleanup(func() { *setting = old })
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       body,
+	}
+	info := &relaycommon.RelayInfo{IsStream: true}
+	info.ChannelMeta = &relaycommon.ChannelMeta{ChannelId: 7}
+	return c, resp, info
+}
+
+func TestClassifyStreamFirstTokenData(t *testing.T) {
+	cases := map[string]firstTokenState{
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`: firstTokenPending,
+		`{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`:                  firstTokenReady,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0}]}}]}`:      firstTokenReady,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`:         firstTokenReady,
+		`{"choices":[],"usage":{"prompt_tokens":1}}`:                          firstTokenReady,
+		`{"choices":[{"delta":{"content":null}}],"error":null}`:               firstTokenPending,
+		`{"type":"message_start","message":{}}`:                               firstTokenPending,
+		`{"type":"content_block_delta","delta":{"text":"Hi"}}`:                firstTokenReady,
+		`{"type":"response.created","response":{}}`:                           firstTokenPending,
+		`{"type":"response.output_text.delta","delta":"Hi"}`:                  firstTokenReady,
+		`{"type":"error","error":{"message":"overloaded"}}`:                   firstTokenFailed,
+		`{"error":{"message":"rate limited"}}`:                                firstTokenFailed,
+		`{"candidates":[{"content":{"parts":[{"text":"Hi"}]}}]}`:              firstTokenReady,
+		`[DONE]`: firstTokenReady,
+	}
+	for data, expected := range cases {
+		require.Equal(t, expected, classifyStreamFirstTokenData(data), data)
+	}
+}
+
+func TestWaitStreamFirstToken_ReplaysBufferedData(t *testing.T) {
+	stream := "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+		": keep-alive\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+	c, resp, info := setupFirstTokenTest(t, io.NopCloser(strings.NewReader(stream)), 5)
+
+	startTime := time.Now()
+	require.NoError(t, WaitStreamFirstToken(c, info, resp, startTime, NewStreamFirstTokenDeadline(context.Background(), info, startTime)))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, stream, string(body))
+	_, ok := common.GetContextKey(c, constant.ContextKeyStreamFailoverAttempts)
+	require.False(t, ok)
+}
+
+func TestWaitStreamFirstToken_FailsOnEarlyClose(t *testing.T) {
+	stream := "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n"
+	c, resp, info := setupFirstTokenTest(t, io.NopCloser(strings.NewReader(stream)), 5)
+
+	startTime := time.Now()
+	err := WaitStreamFirstToken(c, info, resp, startTime, NewStreamFirstTokenDeadline(context.Background(), info, startTime))
+	require.Error(t, err)
+	newAPIError := types.NewError(err, types.ErrorCodeDoRequestFailed)
+	require.Equal(t, types.ErrorCodeStreamFirstTokenFailed, newAPIError.GetErrorCode())
+	require.Equal(t, http.StatusBadGateway, newAPIError.StatusCode)
+}
+
+func TestWaitStreamFirstToken_TimesOutAndRecordsAttempt(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	c, resp, info := setupFirstTokenTest(t, pr, 1)
+
+	startTime := time.Now().Add(-900 * time.Millisecond)
+	err := WaitStreamFirstToken(c, info, resp, startTime, NewStreamFirstTokenDeadline(context.Background(), info, startTime))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "did not return the first token")
+
+	attempts, ok := common.GetContextKeyType[[]map[string]interface{}](c, constant.ContextKeyStreamFailoverAttempts)
+	require.True(t, ok)
+	require.Len(t, attempts, 1)
+	require.Equal(t, 7, attempts[0]["channel_id"])
+}
+
+func TestStreamFirstTokenDeadline_CoversResponseHeaders(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	c, _, info := setupFirstTokenTest(t, nil, 1)
+
+	startTime := time.Now().Add(-900 * time.Millisecond)
+	deadline := NewStreamFirstTokenDeadline(context.Background(), info, startTime)
+	require.NotNil(t, deadline)
+	req, err := http.NewRequestWithContext(deadline.Context(), http.MethodPost, server.URL, nil)
+	require.NoError(t, err)
+	_, err = http.DefaultClient.Do(req)
+	require.Error(t, err)
+
+	err = StreamFirstTokenTimeoutError(c, info, deadline, startTime)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "did not return the first token")
+	attempts, ok := common.GetContextKeyType[[]map[string]interface{}](c, constant.ContextKeyStreamFailoverAttempts)
+	require.True(t, ok)
+	require.Len(t, attempts, 1)
+}
@@ -58,6 +58,9 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		wg         sync.WaitGroup // 用于等待所有 goroutine 退出
 	)
 
+	responseOverrideEnabled := relaycommon.HasResponseOverride(info)
+	outputGuardEnabled := info.ShouldGuardOutput()
+
 	generalSettings := operation_setting.GetGeneralSetting()
 	pingEnabled := generalSettings.PingIntervalEnabled && !info.DisablePing
 	pingInterval := time.Duration(generalSettings.PingIntervalSeconds) * time.Second
@@ -212,6 +215,32 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			}
 		}()
 
+		var guardStream *relaycommon.OutputGuardStream
+		if outputGuardEnabled {
+			guardStream = info.NewOutputGuardStream()
+		}
+		send := func(data string) bool {
+			select {
+			case dataChan <- data:
+				return true
+			case <-ctx.Done():
+				return false
+			case <-stopChan:
+				return false
+			}
+		}
+		// 输出防护会暂存末尾的数据块，上游结束时需要全部下发
+		flushGuardStream := func() {
+			if guardStream == nil {
+				return
+			}
+			for _, data := range guardStream.Flush() {
+				if !send(data) {
+					return
+				}
+			}
+		}
+
 		for scanner.Scan() {
 			// 检查是否需要停止
 			select {
@@ -245,11 +274,23 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				info.SetFirstResponseTime()
 				info.ReceivedResponseCount++
 
-				select {
-				case dataChan <- data:
-				case <-ctx.Done():
-					return
-				case <-stopChan:
+				if responseOverrideEnabled {
+					if overridden, err := relaycommon.ApplyResponseOverride([]byte(data), info); err != nil {
+						logger.LogError(c, "apply response override failed: "+err.Error())
+					} else {
+						data = string(overridden)
+					}
+				}
+				if guardStream != nil {
+					for _, guarded := range guardStream.Push(data) {
+						if !send(guarded) {
+							return
+						}
+					}
+					continue
+				}
+
+				if !send(data) {
 					return
 				}
 			} else {
@@ -257,9 +298,11 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				if common.DebugEnabled {
 					println("received [DONE], stopping scanner")
 				}
+				flushGuardStream()
 				return
 			}
 		}
+		flushGuardStream()
 
 		if err := scanner.Err(); err != nil {
 			if err != io.EOF {
@@ -0,0 +1,24 @@
+package relay
+
+import (
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tryResponseCache 查询上游响应缓存：命中时直接回放并结算，释放渠道上游名额，返回 true；
+// 未命中时开始记录本次响应，调用方需在请求结束时调用 recorder.Finish
+func tryResponseCache(c *gin.Context, info *relaycommon.RelayInfo, jsonData []byte) (bool, *service.ResponseCacheRecorder) {
+	cacheKey := service.GetResponseCacheKey(c, info, jsonData)
+	if cacheKey == "" {
+		return false, nil
+	}
+	if usage, hit := service.ServeResponseCache(c, info, cacheKey); hit {
+		// 命中缓存没有请求上游，先归还本次尝试占用的渠道上游名额，结算时不再按缓存用量计入渠道 TPM
+		service.ReleaseUpstreamLimitForCacheHit(c)
+		postConsumeQuota(c, info, usage)
+		return true, nil
+	}
+	return false, service.StartResponseCacheRecorder(c, info, cacheKey)
+}
@@ -0,0 +1,72 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responsesResourceAdaptor 在渠道的 Responses 地址后追加 /{id}[/input_items|/cancel]，并透传客户端查询参数
+type responsesResourceAdaptor struct {
+	channel.Adaptor
+	subPath string
+	query   url.Values
+}
+
+func (a *responsesResourceAdaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	fullRequestURL, err := a.Adaptor.GetRequestURL(info)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(fullRequestURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + a.subPath
+	query := u.Query()
+	for key, values := range a.query {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// RelayResponsesResource 将响应的检索、删除、输入项查询与取消请求转发到创建该响应的渠道，
+// 调用前需已通过 SetupContextForSelectedChannel 设置渠道上下文；调用方负责关闭返回的响应体
+func RelayResponsesResource(c *gin.Context, subPath string) (*http.Response, *types.NewAPIError) {
+	info := relaycommon.GenRelayInfoResponses(c, &dto.OpenAIResponsesRequest{})
+	info.InitChannelMeta(c)
+	info.RequestURLPath = "/v1/responses"
+
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return nil, types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
+	}
+	adaptor.Init(info)
+
+	resp, err := channel.DoApiRequest(&responsesResourceAdaptor{
+		Adaptor: adaptor,
+		subPath: subPath,
+		query:   c.Request.URL.Query(),
+	}, c, info, nil)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		newAPIError := service.RelayErrorHandler(c.Request.Context(), resp, false)
+		service.ResetStatusCode(newAPIError, c.GetString("status_code_mapping"))
+		return nil, newAPIError
+	}
+	return resp, nil
+}
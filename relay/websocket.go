@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
@@ -31,11 +32,17 @@ func WssHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types.
 	}
 
 	if resp != nil {
-		info.TargetWs = resp.(*websocket.Conn)
+		targetWs, ok := resp.(*websocket.Conn)
+		if !ok {
+			return types.NewError(fmt.Errorf("channel type %d does not support realtime", info.ChannelType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
+		}
+		info.TargetWs = targetWs
 		defer info.TargetWs.Close()
 	}
 
+	responseSpan := tracing.StartSpan(c, "relay.do_response")
 	usage, newAPIError := adaptor.DoResponse(c, nil, info)
+	responseSpan.EndAPIError(newAPIError)
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
@@ -174,6 +174,13 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
 		}
 
+		configRoute := apiRouter.Group("/config")
+		configRoute.Use(middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired())
+		{
+			configRoute.POST("/export", controller.ExportConfig)
+			configRoute.POST("/import", controller.ImportConfig)
+		}
+
 		// Custom OAuth provider management (root only)
 		customOAuthRoute := apiRouter.Group("/custom-oauth-provider")
 		customOAuthRoute.Use(middleware.RootAuth())
@@ -240,6 +247,8 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
+			channelRoute.GET("/breakers", controller.GetChannelBreakers)
+			channelRoute.GET("/selection", controller.GetChannelSelection)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
@@ -282,6 +291,8 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.DELETE("/:id/session_bindings/:session_id", controller.ReleaseChannelSessionBinding)
 			channelRoute.DELETE("/:id/session_bindings", controller.ReleaseAllChannelSessionBindings)
 			channelRoute.GET("/:id/session_spoof", controller.GetChannelSpoofSessionId)
+			channelRoute.GET("/:id/breaker", controller.GetChannelBreaker)
+			channelRoute.DELETE("/:id/breaker", controller.ResetChannelBreaker)
 			channelRoute.POST("/analyze_users", controller.AnalyzeChannelUsers)
 			channelRoute.POST("/:id/user_bindings/batch", controller.BatchBindChannelUsers)
 		}
@@ -292,6 +303,7 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.GET("/search", middleware.SearchRateLimit(), controller.SearchTokens)
 			tokenRoute.GET("/:id", controller.GetToken)
 			tokenRoute.POST("/:id/key", middleware.CriticalRateLimit(), middleware.DisableCache(), controller.GetTokenKey)
+			tokenRoute.POST("/:id/rotate", middleware.CriticalRateLimit(), middleware.DisableCache(), controller.RotateTokenKey)
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
@@ -323,10 +335,13 @@ func SetApiRouter(router *gin.Engine) {
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
 		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/export", middleware.AdminAuth(), controller.ExportAllLogs)
+		logRoute.GET("/margin", middleware.AdminAuth(), controller.GetLogsMargin)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		logRoute.GET("/channel_affinity_usage_cache", middleware.AdminAuth(), controller.GetChannelAffinityUsageCacheStats)
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
+		logRoute.GET("/self/export", middleware.UserAuth(), middleware.SearchRateLimit(), controller.ExportUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), middleware.SearchRateLimit(), controller.SearchUserLogs)
 
 		dataRoute := apiRouter.Group("/data")
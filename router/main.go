@@ -10,6 +10,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,6 +18,7 @@ import (
 func SetRouter(router *gin.Engine, buildFS embed.FS, indexPage []byte) {
 	router.GET("/setup.sh", controller.GetSetupSh)
 	router.GET("/setup.ps1", controller.GetSetupPs1)
+	router.GET("/metrics", middleware.MetricsAuth(), gin.WrapH(metrics.Handler()))
 	SetApiRouter(router)
 	SetDashboardRouter(router)
 	SetRelayRouter(router)
@@ -71,6 +71,7 @@ func SetRelayRouter(router *gin.Engine) {
 	relayV1Router.Use(middleware.SystemPerformanceCheck())
 	relayV1Router.Use(middleware.TokenAuth())
 	relayV1Router.Use(middleware.ModelRequestRateLimit())
+	relayV1Router.Use(middleware.ConcurrencyLimit())
 	{
 		// WebSocket 路由（统一到 Relay）
 		wsRouter := relayV1Router.Group("")
@@ -88,6 +89,7 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/messages", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatClaude)
 		})
+		httpRouter.POST("/messages/count_tokens", controller.RelayClaudeCountTokens)
 
 		// chat related routes
 		httpRouter.POST("/completions", func(c *gin.Context) {
@@ -152,11 +154,6 @@ func SetRelayRouter(router *gin.Engine) {
 
 		// not implemented
 		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
 		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
@@ -165,6 +162,35 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.DELETE("/models/:model", controller.RelayNotImplemented)
 	}
 
+	{
+		// files & batches: stored by the gateway, batch lines are replayed through the relay routes above
+		fileRouter := relayV1Router.Group("")
+		fileRouter.GET("/files", controller.ListFiles)
+		fileRouter.POST("/files", controller.UploadFile)
+		fileRouter.DELETE("/files/:id", controller.DeleteFile)
+		fileRouter.GET("/files/:id", controller.RetrieveFile)
+		fileRouter.GET("/files/:id/content", controller.RetrieveFileContent)
+		fileRouter.POST("/batches", controller.CreateBatch)
+		fileRouter.GET("/batches", controller.ListBatches)
+		fileRouter.GET("/batches/:id", controller.RetrieveBatch)
+		fileRouter.POST("/batches/:id/cancel", controller.CancelBatch)
+		fileRouter.POST("/messages/batches", controller.CreateMessageBatch)
+		fileRouter.GET("/messages/batches", controller.ListMessageBatches)
+		fileRouter.GET("/messages/batches/:id", controller.RetrieveMessageBatch)
+		fileRouter.DELETE("/messages/batches/:id", controller.DeleteMessageBatch)
+		fileRouter.POST("/messages/batches/:id/cancel", controller.CancelMessageBatch)
+		fileRouter.GET("/messages/batches/:id/results", controller.RetrieveMessageBatchResults)
+	}
+
+	{
+		// stored responses: follow-up calls are routed to the channel and key that created the response
+		responsesRouter := relayV1Router.Group("")
+		responsesRouter.GET("/responses/:id", controller.RetrieveResponse)
+		responsesRouter.DELETE("/responses/:id", controller.DeleteResponse)
+		responsesRouter.GET("/responses/:id/input_items", controller.ListResponseInputItems)
+		responsesRouter.POST("/responses/:id/cancel", controller.CancelResponse)
+	}
+
 	relayMjRouter := router.Group("/mj")
 	relayMjRouter.Use(middleware.RouteTag("relay"))
 	relayMjRouter.Use(middleware.SystemPerformanceCheck())
@@ -191,6 +217,7 @@ func SetRelayRouter(router *gin.Engine) {
 	relayGeminiRouter.Use(middleware.SystemPerformanceCheck())
 	relayGeminiRouter.Use(middleware.TokenAuth())
 	relayGeminiRouter.Use(middleware.ModelRequestRateLimit())
+	relayGeminiRouter.Use(middleware.ConcurrencyLimit())
 	relayGeminiRouter.Use(middleware.Distribute())
 	{
 		// Gemini API 路径格式: /v1beta/models/{model_name}:{action}
@@ -0,0 +1,470 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	batchTickInterval  = 5 * time.Second
+	batchQueryLimit    = 20
+	batchMaxLineErrors = 100
+	// batchLinesPerRound 每轮为单个批处理执行的最大请求行数，之后轮到下一个批处理，避免大批处理阻塞其他批处理
+	batchLinesPerRound = 100
+	// 请求行被限流（429）时的最大重试次数与单次最长等待时间
+	batchRateLimitRetries = 3
+	batchMaxRetryWait     = 60 * time.Second
+)
+
+// BatchRelayHandler 用于执行批处理请求行的 HTTP 处理器（即 gin 引擎本身），
+// 由 main 注入以打破 service -> router 的循环依赖。每一行都会完整地经过
+// TokenAuth / Distribute / Relay，因此限流、渠道选择、重试与计费与普通请求完全一致。
+var BatchRelayHandler http.Handler
+
+// SupportedBatchEndpoints 允许批处理的接口
+var SupportedBatchEndpoints = []string{
+	"/v1/chat/completions",
+	"/v1/completions",
+	"/v1/embeddings",
+	"/v1/responses",
+	"/v1/moderations",
+}
+
+var (
+	batchTaskOnce    sync.Once
+	batchTaskRunning atomic.Bool
+)
+
+func IsSupportedBatchEndpoint(endpoint string) bool {
+	return common.StringsContains(SupportedBatchEndpoints, endpoint)
+}
+
+// ParseBatchInput 解析并校验批处理输入文件，返回请求行以及逐行的校验错误
+func ParseBatchInput(content []byte, endpoint string) ([]dto.OpenAIBatchRequestInput, []dto.OpenAIBatchError) {
+	var (
+		inputs    []dto.OpenAIBatchRequestInput
+		errs      []dto.OpenAIBatchError
+		customIds = make(map[string]struct{})
+	)
+	addError := func(line int, code, message string) {
+		if len(errs) < batchMaxLineErrors {
+			errs = append(errs, dto.OpenAIBatchError{Code: code, Message: message, Line: common.GetPointer(line)})
+		}
+	}
+	for i, raw := range bytes.Split(content, []byte("\n")) {
+		lineNo := i + 1
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+		var input dto.OpenAIBatchRequestInput
+		if err := common.Unmarshal(raw, &input); err != nil {
+			addError(lineNo, "invalid_json_line", fmt.Sprintf("This line is not parseable as valid JSON: %s", err.Error()))
+			continue
+		}
+		if input.CustomID == "" {
+			addError(lineNo, "missing_required_parameter", "custom_id is required")
+			continue
+		}
+		if _, ok := customIds[input.CustomID]; ok {
+			addError(lineNo, "duplicate_custom_id", fmt.Sprintf("The custom_id %s is duplicated", input.CustomID))
+			continue
+		}
+		customIds[input.CustomID] = struct{}{}
+		if !strings.EqualFold(input.Method, http.MethodPost) {
+			addError(lineNo, "invalid_method", "Only POST requests are supported")
+			continue
+		}
+		if input.URL != endpoint {
+			addError(lineNo, "mismatched_url", fmt.Sprintf("The url %s does not match the batch endpoint %s", input.URL, endpoint))
+			continue
+		}
+		var body struct {
+			Model string `json:"model"`
+		}
+		if len(input.Body) == 0 || common.Unmarshal(input.Body, &body) != nil {
+			addError(lineNo, "invalid_request", "body must be a JSON object")
+			continue
+		}
+		if body.Model == "" {
+			addError(lineNo, "missing_required_parameter", "body.model is required")
+			continue
+		}
+		inputs = append(inputs, input)
+	}
+	if len(inputs) == 0 && len(errs) == 0 {
+		errs = append(errs, dto.OpenAIBatchError{Code: "empty_file", Message: "The input file contains no requests"})
+	}
+	return inputs, errs
+}
+
+func StartBatchProcessingTask() {
+	batchTaskOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("batch processing task started: tick=%s", batchTickInterval))
+			ticker := time.NewTicker(batchTickInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				runBatchProcessingOnce()
+			}
+		})
+	})
+}
+
+func runBatchProcessingOnce() {
+	if !batchTaskRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer batchTaskRunning.Store(false)
+
+	// 按 id 轮转处理：每轮每个批处理最多执行 batchLinesPerRound 行，直到没有剩余工作；
+	// 每轮重新加载列表，新提交的批处理无需等待已有批处理全部完成
+	// 分页加载时 hasMore 在整轮内累计，只有完整一轮所有分页都没有剩余工作时才结束
+	afterId := 0
+	hasMore := false
+	for {
+		batches, err := model.GetPendingBatches(afterId, batchQueryLimit)
+		if err != nil {
+			logger.LogWarn(context.Background(), fmt.Sprintf("batch processing task failed to load batches: %v", err))
+			return
+		}
+		for _, batch := range batches {
+			more, err := processBatch(batch)
+			if err != nil {
+				logger.LogError(context.Background(), fmt.Sprintf("batch %s processing failed: %v", batch.BatchId, err))
+				continue
+			}
+			hasMore = hasMore || more
+		}
+		if len(batches) == batchQueryLimit {
+			afterId = batches[len(batches)-1].Id
+			continue
+		}
+		// 一轮结束
+		if !hasMore {
+			return
+		}
+		afterId = 0
+		hasMore = false
+	}
+}
+
+// processBatch 推进批处理一步，返回是否还有待执行的请求行
+func processBatch(batch *model.Batch) (bool, error) {
+	switch batch.Status {
+	case dto.BatchStatusValidating:
+		// 校验通过后在下一轮开始执行
+		return true, validateBatch(batch)
+	case dto.BatchStatusInProgress:
+		return executeBatch(batch)
+	case dto.BatchStatusFinalizing:
+		return false, finalizeBatch(batch, dto.BatchStatusCompleted, nil)
+	case dto.BatchStatusCancelling:
+		inputs, _, err := loadBatchInputs(batch)
+		if err != nil {
+			return false, finalizeBatch(batch, dto.BatchStatusCancelled, nil)
+		}
+		return false, finalizeBatch(batch, dto.BatchStatusCancelled, remainingBatchInputs(batch, inputs))
+	}
+	return false, nil
+}
+
+func loadBatchInputs(batch *model.Batch) ([]dto.OpenAIBatchRequestInput, []dto.OpenAIBatchError, error) {
+	file, err := model.GetUserFileById(batch.UserId, batch.InputFileId, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load input file %s: %w", batch.InputFileId, err)
+	}
+	inputs, errs := ParseBatchInput(file.Content, batch.Endpoint)
+	return inputs, errs, nil
+}
+
+func remainingBatchInputs(batch *model.Batch, inputs []dto.OpenAIBatchRequestInput) []dto.OpenAIBatchRequestInput {
+	if batch.NextLine >= len(inputs) {
+		return nil
+	}
+	return inputs[batch.NextLine:]
+}
+
+func validateBatch(batch *model.Batch) error {
+	inputs, errs, err := loadBatchInputs(batch)
+	if err != nil {
+		errs = []dto.OpenAIBatchError{{Code: "invalid_input_file", Message: err.Error()}}
+	}
+	now := common.GetTimestamp()
+	if len(errs) > 0 {
+		_, updateErr := batch.UpdateStatus(dto.BatchStatusValidating, map[string]interface{}{
+			"status":    dto.BatchStatusFailed,
+			"failed_at": now,
+			"errors":    common.GetJsonString(errs),
+		})
+		return updateErr
+	}
+	_, err = batch.UpdateStatus(dto.BatchStatusValidating, map[string]interface{}{
+		"status":         dto.BatchStatusInProgress,
+		"in_progress_at": now,
+		"total_count":    len(inputs),
+	})
+	return err
+}
+
+// executeBatch 执行至多 batchLinesPerRound 个请求行，全部执行完毕后进入收尾；返回是否还有剩余请求行
+func executeBatch(batch *model.Batch) (bool, error) {
+	inputs, _, err := loadBatchInputs(batch)
+	if err != nil {
+		return false, err
+	}
+	tokenKey := ""
+	if token, err := model.GetTokenById(batch.TokenId); err == nil {
+		// 数据库只保存密钥摘要，重放请求时由 TokenAuth 按摘要鉴权
+		tokenKey = token.Key
+	}
+	concurrency := constant.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	roundEnd := min(batch.NextLine+max(batchLinesPerRound, concurrency), len(inputs))
+	for batch.NextLine < roundEnd {
+		status, err := model.GetBatchStatus(batch.Id)
+		if err != nil {
+			return false, err
+		}
+		if status != dto.BatchStatusInProgress {
+			// 已被取消，剩余行由 cancelling 分支在下一轮收尾
+			return true, nil
+		}
+		if batch.ExpiresAt > 0 && common.GetTimestamp() > batch.ExpiresAt {
+			return false, finalizeBatch(batch, dto.BatchStatusExpired, remainingBatchInputs(batch, inputs))
+		}
+
+		end := min(batch.NextLine+concurrency, roundEnd)
+		chunk := inputs[batch.NextLine:end]
+		results := make([]*dto.OpenAIBatchRequestOutput, len(chunk))
+		var wg sync.WaitGroup
+		for i := range chunk {
+			wg.Add(1)
+			gopool.Go(func() {
+				defer wg.Done()
+				results[i] = executeBatchLine(batch, tokenKey, chunk[i])
+			})
+		}
+		wg.Wait()
+
+		lineResults := make([]*model.BatchResult, len(results))
+		for i, result := range results {
+			lineResults[i] = newBatchResult(batch, batch.NextLine+i, result)
+		}
+		batch.NextLine = end
+		if err := batch.SaveProgress(lineResults); err != nil {
+			return false, err
+		}
+	}
+	if batch.NextLine < len(inputs) {
+		return true, nil
+	}
+
+	ok, err := batch.UpdateStatus(dto.BatchStatusInProgress, map[string]interface{}{
+		"status":        dto.BatchStatusFinalizing,
+		"finalizing_at": common.GetTimestamp(),
+	})
+	if err != nil || !ok {
+		return false, err
+	}
+	batch.Status = dto.BatchStatusFinalizing
+	return false, finalizeBatch(batch, dto.BatchStatusCompleted, nil)
+}
+
+// newBatchResult 生成第 line 行的输出记录并更新批处理计数
+func newBatchResult(batch *model.Batch, line int, result *dto.OpenAIBatchRequestOutput) *model.BatchResult {
+	data, _ := common.Marshal(result)
+	record := &model.BatchResult{BatchId: batch.Id, Line: line, Data: append(data, '\n')}
+	if result.Error == nil && result.Response != nil && result.Response.StatusCode >= 200 && result.Response.StatusCode < 300 {
+		batch.CompletedCount++
+	} else {
+		record.Failed = true
+		batch.FailedCount++
+	}
+	return record
+}
+
+// executeBatchLine 将单行请求重放到中继管道，返回批处理输出行
+func executeBatchLine(batch *model.Batch, tokenKey string, input dto.OpenAIBatchRequestInput) *dto.OpenAIBatchRequestOutput {
+	output := &dto.OpenAIBatchRequestOutput{
+		ID:       "batch_req_" + common.GetRandomString(24),
+		CustomID: input.CustomID,
+	}
+	if BatchRelayHandler == nil {
+		output.Error = &dto.OpenAIBatchLineError{Code: "server_error", Message: "batch relay handler is not initialized"}
+		return output
+	}
+	body, err := stripBatchStreamOptions(input.Body)
+	if err != nil {
+		output.Error = &dto.OpenAIBatchLineError{Code: "invalid_request", Message: err.Error()}
+		return output
+	}
+
+	var recorder *httptest.ResponseRecorder
+	for attempt := 0; ; attempt++ {
+		recorder, err = serveBatchLine(batch, tokenKey, input.URL, body)
+		if err != nil {
+			output.Error = &dto.OpenAIBatchLineError{Code: "invalid_request", Message: err.Error()}
+			return output
+		}
+		// 触发 TPM/并发限流时按 Retry-After 等待后重试，而不是直接记为失败
+		if recorder.Code != http.StatusTooManyRequests || attempt >= batchRateLimitRetries {
+			break
+		}
+		time.Sleep(batchRetryAfter(recorder.Header().Get("Retry-After")))
+	}
+
+	respBody := recorder.Body.Bytes()
+	if !json.Valid(respBody) {
+		respBody, _ = common.Marshal(string(respBody))
+	}
+	output.Response = &dto.OpenAIBatchResponseBody{
+		StatusCode: recorder.Code,
+		RequestID:  recorder.Header().Get(common.RequestIdKey),
+		Body:       respBody,
+	}
+	return output
+}
+
+func serveBatchLine(batch *model.Batch, tokenKey string, url string, body []byte) (*httptest.ResponseRecorder, error) {
+	ctx := context.WithValue(context.Background(), constant.ContextKeyBatchId, batch.BatchId)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tokenKey)
+	if batch.ClientIp != "" {
+		req.RemoteAddr = net.JoinHostPort(batch.ClientIp, "0")
+	}
+	recorder := httptest.NewRecorder()
+	BatchRelayHandler.ServeHTTP(recorder, req)
+	return recorder, nil
+}
+
+func batchRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		seconds = 1
+	}
+	return min(time.Duration(seconds)*time.Second, batchMaxRetryWait)
+}
+
+// stripBatchStreamOptions 批处理结果以完整 JSON 返回，因此移除流式参数
+func stripBatchStreamOptions(body json.RawMessage) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := common.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	_, hasStream := fields["stream"]
+	_, hasStreamOptions := fields["stream_options"]
+	if !hasStream && !hasStreamOptions {
+		return body, nil
+	}
+	delete(fields, "stream")
+	delete(fields, "stream_options")
+	return common.Marshal(fields)
+}
+
+// finalizeBatch 写出输出/错误文件并将批处理置为终态，remaining 为未执行的请求行
+func finalizeBatch(batch *model.Batch, finalStatus string, remaining []dto.OpenAIBatchRequestInput) error {
+	results, err := model.GetBatchResults(batch.Id)
+	if err != nil {
+		return err
+	}
+	errorCode := "batch_" + finalStatus
+	for i, input := range remaining {
+		results = append(results, newBatchResult(batch, batch.NextLine+i, &dto.OpenAIBatchRequestOutput{
+			ID:       "batch_req_" + common.GetRandomString(24),
+			CustomID: input.CustomID,
+			Error: &dto.OpenAIBatchLineError{
+				Code:    errorCode,
+				Message: fmt.Sprintf("This request could not be executed before the batch was %s.", finalStatus),
+			},
+		}))
+	}
+	var outputData, errorData []byte
+	for _, result := range results {
+		if result.Failed {
+			errorData = append(errorData, result.Data...)
+		} else {
+			outputData = append(outputData, result.Data...)
+		}
+	}
+
+	fields := map[string]interface{}{
+		"status":          finalStatus,
+		"next_line":       batch.NextLine + len(remaining),
+		"completed_count": batch.CompletedCount,
+		"failed_count":    batch.FailedCount,
+		"skipped_count":   len(remaining),
+	}
+	now := common.GetTimestamp()
+	switch finalStatus {
+	case dto.BatchStatusCompleted:
+		fields["completed_at"] = now
+	case dto.BatchStatusCancelled:
+		fields["cancelled_at"] = now
+	case dto.BatchStatusExpired:
+		fields["expired_at"] = now
+	}
+
+	outputFileId, err := createBatchResultFile(batch, "output", outputData)
+	if err != nil {
+		return err
+	}
+	errorFileId, err := createBatchResultFile(batch, "error", errorData)
+	if err != nil {
+		return err
+	}
+	fields["output_file_id"] = outputFileId
+	fields["error_file_id"] = errorFileId
+
+	ok, err := batch.UpdateStatus(batch.Status, fields)
+	if err != nil || !ok {
+		return err
+	}
+	return model.DeleteBatchResults(batch.Id)
+}
+
+func createBatchResultFile(batch *model.Batch, kind string, content []byte) (string, error) {
+	if len(content) == 0 {
+		return "", nil
+	}
+	file := &model.File{
+		FileId:   "file-" + common.GetRandomString(24),
+		UserId:   batch.UserId,
+		TokenId:  batch.TokenId,
+		Purpose:  dto.FilePurposeBatchOutput,
+		Filename: fmt.Sprintf("%s_%s.jsonl", batch.BatchId, kind),
+		Content:  content,
+	}
+	if err := file.Insert(); err != nil {
+		return "", fmt.Errorf("create batch %s file: %w", kind, err)
+	}
+	return file.FileId, nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBatchInput(t *testing.T) {
+	content := strings.Join([]string{
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","messages":[]}}`,
+		`not json`,
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`,
+		`{"custom_id":"b","method":"GET","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}`,
+		`{"custom_id":"c","method":"POST","url":"/v1/embeddings","body":{"model":"gpt-4o"}}`,
+		`{"custom_id":"d","method":"POST","url":"/v1/chat/completions","body":{"messages":[]}}`,
+		``,
+	}, "\n")
+
+	inputs, errs := ParseBatchInput([]byte(content), "/v1/chat/completions")
+	require.Len(t, inputs, 1)
+	require.Equal(t, "a", inputs[0].CustomID)
+
+	codes := make([]string, 0, len(errs))
+	for _, e := range errs {
+		codes = append(codes, e.Code)
+	}
+	require.Equal(t, []string{"invalid_json_line", "duplicate_custom_id", "invalid_method", "mismatched_url", "missing_required_parameter"}, codes)
+	require.Equal(t, 2, *errs[0].Line)
+}
+
+func TestParseBatchInputEmpty(t *testing.T) {
+	inputs, errs := ParseBatchInput([]byte("\n\n"), "/v1/embeddings")
+	require.Empty(t, inputs)
+	require.Len(t, errs, 1)
+	require.Equal(t, "empty_file", errs[0].Code)
+}
+
+func TestExecuteBatchLineReplaysThroughHandler(t *testing.T) {
+	var gotBody, gotAuth, gotBatchId string
+	BatchRelayHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotAuth = r.Header.Get("Authorization")
+		gotBatchId, _ = r.Context().Value(constant.ContextKeyBatchId).(string)
+		w.Header().Set(common.RequestIdKey, "req-1")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1"}`))
+	})
+	t.Cleanup(func() { BatchRelayHandler = nil })
+
+	batch := &model.Batch{BatchId: "batch_test", ClientIp: "10.0.0.1"}
+	output := executeBatchLine(batch, "secret", dto.OpenAIBatchRequestInput{
+		CustomID: "req-a",
+		Method:   http.MethodPost,
+		URL:      "/v1/chat/completions",
+		Body:     []byte(`{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true}}`),
+	})
+
+	require.Nil(t, output.Error)
+	require.Equal(t, "req-a", output.CustomID)
+	require.Equal(t, http.StatusOK, output.Response.StatusCode)
+	require.Equal(t, "req-1", output.Response.RequestID)
+	require.JSONEq(t, `{"id":"chatcmpl-1"}`, string(output.Response.Body))
+	require.JSONEq(t, `{"model":"gpt-4o"}`, gotBody)
+	require.Equal(t, "Bearer secret", gotAuth)
+	require.Equal(t, "batch_test", gotBatchId)
+
+	result := newBatchResult(batch, 0, output)
+	require.Equal(t, 1, batch.CompletedCount)
+	require.False(t, result.Failed)
+	require.Contains(t, string(result.Data), `"custom_id":"req-a"`)
+}
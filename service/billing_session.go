@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
@@ -30,7 +31,10 @@ type BillingSession struct {
 	fundingSettled   bool // funding.Settle 已成功，资金来源已提交
 	settled          bool // Settle 全部完成（资金 + 令牌）
 	refunded         bool // Refund 已调用
-	mu               sync.Mutex
+	// 设置了额度上限的客户端令牌：预占的额度，结算时按实际消耗修正，退款时归还
+	clientTokenId       string
+	clientTokenReserved int
+	mu                  sync.Mutex
 }
 
 // Settle 根据实际消耗额度进行结算。
@@ -42,6 +46,12 @@ func (s *BillingSession) Settle(actualQuota int) error {
 	if s.settled {
 		return nil
 	}
+	if s.clientTokenId != "" {
+		if err := model.IncreaseClientTokenUsedQuota(s.clientTokenId, actualQuota-s.clientTokenReserved); err != nil {
+			common.SysLog(fmt.Sprintf("error settling client token quota (clientTokenId=%s): %s", s.clientTokenId, err.Error()))
+		}
+		s.clientTokenReserved = actualQuota
+	}
 	delta := actualQuota - s.preConsumedQuota
 	if delta == 0 {
 		s.settled = true
@@ -79,7 +89,21 @@ func (s *BillingSession) Settle(actualQuota int) error {
 // Refund 退还所有预扣费，幂等安全，异步执行。
 func (s *BillingSession) Refund(c *gin.Context) {
 	s.mu.Lock()
-	if s.settled || s.refunded || !s.needsRefundLocked() {
+	if s.settled || s.refunded {
+		s.mu.Unlock()
+		return
+	}
+	clientTokenId := s.clientTokenId
+	clientTokenReserved := s.clientTokenReserved
+	if clientTokenId != "" {
+		s.clientTokenReserved = 0
+		gopool.Go(func() {
+			if err := model.IncreaseClientTokenUsedQuota(clientTokenId, -clientTokenReserved); err != nil {
+				common.SysLog("error refunding client token quota: " + err.Error())
+			}
+		})
+	}
+	if !s.needsRefundLocked() {
 		s.mu.Unlock()
 		return
 	}
@@ -157,9 +181,15 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 		logger.LogInfo(c, fmt.Sprintf("用户 %d 需要预扣费 %s (funding=%s)", s.relayInfo.UserId, logger.FormatQuota(effectiveQuota), s.funding.Source()))
 	}
 
+	// ---- 0) 预占客户端令牌额度（不受信任额度旁路影响）----
+	if apiErr := s.reserveClientTokenQuota(c, quota); apiErr != nil {
+		return apiErr
+	}
+
 	// ---- 1) 预扣令牌额度 ----
 	if effectiveQuota > 0 {
 		if err := PreConsumeTokenQuota(s.relayInfo, effectiveQuota); err != nil {
+			s.releaseClientTokenQuota()
 			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
 		s.tokenConsumed = effectiveQuota
@@ -167,6 +197,7 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 
 	// ---- 2) 预扣资金来源 ----
 	if err := s.funding.PreConsume(effectiveQuota); err != nil {
+		s.releaseClientTokenQuota()
 		// 预扣费失败，回滚令牌额度
 		if s.tokenConsumed > 0 && !s.relayInfo.IsPlayground {
 			if rollbackErr := model.IncreaseTokenQuota(s.relayInfo.TokenId, s.relayInfo.TokenKey, s.tokenConsumed); rollbackErr != nil {
@@ -191,6 +222,62 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 	return nil
 }
 
+// reserveClientTokenQuota 设置了额度上限的客户端令牌按预估额度预占用量，剩余额度不足时拒绝请求
+func (s *BillingSession) reserveClientTokenQuota(c *gin.Context, quota int) *types.NewAPIError {
+	clientTokenId := common.GetContextKeyString(c, constant.ContextKeyClientTokenId)
+	maxQuota := common.GetContextKeyInt(c, constant.ContextKeyClientTokenMaxQuota)
+	if clientTokenId == "" || maxQuota <= 0 {
+		return nil
+	}
+	quota = max(quota, 0)
+	ok, err := model.ReserveClientTokenQuota(clientTokenId, quota)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+	}
+	if !ok {
+		return types.NewErrorWithStatusCode(fmt.Errorf("客户端令牌额度不足（上限 %d）", maxQuota),
+			types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+	s.clientTokenId = clientTokenId
+	s.clientTokenReserved = quota
+	return nil
+}
+
+// reservesClientTokenQuota 会话是否已预占客户端令牌额度（其用量由 Settle / Refund 修正）
+func (s *BillingSession) reservesClientTokenQuota() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clientTokenId != ""
+}
+
+// postConsumeClientTokenQuota 扣减令牌额度时同步累计客户端令牌的用量；经计费会话预占额度的请求由会话结算修正，不重复累计
+func postConsumeClientTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) {
+	clientTokenId := relayInfo.ClientTokenId
+	if clientTokenId == "" || quota == 0 {
+		return
+	}
+	if session, ok := relayInfo.Billing.(*BillingSession); ok && session.reservesClientTokenQuota() {
+		return
+	}
+	gopool.Go(func() {
+		if err := model.IncreaseClientTokenUsedQuota(clientTokenId, quota); err != nil {
+			common.SysLog("failed to record client token usage: " + err.Error())
+		}
+	})
+}
+
+// releaseClientTokenQuota 预扣失败时同步归还已预占的客户端令牌额度
+func (s *BillingSession) releaseClientTokenQuota() {
+	if s.clientTokenId == "" {
+		return
+	}
+	if err := model.IncreaseClientTokenUsedQuota(s.clientTokenId, -s.clientTokenReserved); err != nil {
+		common.SysLog("error rolling back client token quota: " + err.Error())
+	}
+	s.clientTokenId = ""
+	s.clientTokenReserved = 0
+}
+
 // shouldTrust 统一信任额度检查，适用于钱包和订阅。
 func (s *BillingSession) shouldTrust(c *gin.Context) bool {
 	// 异步任务（ForcePreConsume=true）必须预扣全额，不允许信任旁路
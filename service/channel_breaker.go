@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isBreakerFailure 只有可归因于渠道的错误才计入熔断与延迟统计，用户请求错误（如参数错误）不计入
+func isBreakerFailure(err *types.NewAPIError) bool {
+	if err == nil {
+		return false
+	}
+	if types.IsChannelError(err) {
+		return true
+	}
+	switch {
+	case err.StatusCode >= http.StatusInternalServerError,
+		err.StatusCode == http.StatusTooManyRequests,
+		err.StatusCode == http.StatusUnauthorized,
+		err.StatusCode == http.StatusForbidden,
+		err.StatusCode == http.StatusRequestTimeout:
+		return true
+	}
+	return false
+}
+
+// RecordChannelBreakerResult 将一次中继尝试的结果计入渠道（以及多密钥渠道当前密钥）的熔断器。
+// latency 优先使用首字时间，慢于 SlowRequestSeconds 的成功请求同样计为失败。
+func RecordChannelBreakerResult(c *gin.Context, channelId int, info *relaycommon.RelayInfo, attemptStart time.Time, err *types.NewAPIError) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	if !setting.Enabled {
+		return
+	}
+	latency := time.Since(attemptStart)
+	if info != nil && info.HasSendResponse() && info.FirstResponseTime.After(attemptStart) {
+		latency = info.FirstResponseTime.Sub(attemptStart)
+	}
+
+	failed := isBreakerFailure(err)
+	errMsg := ""
+	if failed {
+		errMsg = err.ErrorWithStatusCode()
+	} else if err == nil && setting.SlowRequestSeconds > 0 && latency.Seconds() > setting.SlowRequestSeconds &&
+		(info == nil || info.RelayFormat != types.RelayFormatOpenAIRealtime) {
+		failed = true
+		errMsg = fmt.Sprintf("slow request: %.2fs", latency.Seconds())
+	}
+	if err != nil && !failed {
+		// 非渠道原因的失败既不计为成功也不计为失败
+		return
+	}
+
+	probe := common.GetContextKeyBool(c, constant.ContextKeyChannelBreakerProbe)
+	model.RecordChannelBreakerResult(channelId, model.ChannelBreakerAllKeys, failed, probe, errMsg)
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		keyIndex := common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+		keyProbe := common.GetContextKeyBool(c, constant.ContextKeyChannelKeyBreakerProbe)
+		model.RecordChannelBreakerResult(channelId, keyIndex, failed, keyProbe, errMsg)
+	}
+}
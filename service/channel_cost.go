@@ -0,0 +1,30 @@
+package service
+
+import (
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/shopspring/decimal"
+)
+
+// CalculateUpstreamCost 估算本次请求的上游成本：实际扣费额度去除分组倍率与隐藏倍率后，
+// 乘以渠道价格倍率（渠道其他设置中的 price_ratio / model_price_ratio）。
+// 渠道未配置价格倍率时成本未知，与命中响应缓存（未请求上游）、分组倍率为 0（无法还原基础价格）一样记为 0。
+func CalculateUpstreamCost(relayInfo *relaycommon.RelayInfo, priceData types.PriceData, quota int) int {
+	if relayInfo == nil || relayInfo.ChannelMeta == nil || quota <= 0 || relayInfo.ResponseCacheStatus == ResponseCacheHit {
+		return 0
+	}
+	priceRatio, ok := relayInfo.ChannelOtherSettings.GetCostRatio(relayInfo.OriginModelName)
+	if !ok {
+		return 0
+	}
+	groupRatio := priceData.GroupRatioInfo.GroupRatio
+	if groupRatio <= 0 {
+		return 0
+	}
+	cost := decimal.NewFromInt(int64(quota)).Div(decimal.NewFromFloat(groupRatio))
+	if hiddenRatio := priceData.AppliedHiddenRatio; hiddenRatio > 1 {
+		cost = cost.Div(decimal.NewFromFloat(hiddenRatio))
+	}
+	return int(cost.Mul(decimal.NewFromFloat(priceRatio)).Round(0).IntPart())
+}
@@ -2,12 +2,14 @@ package service
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/gin-gonic/gin"
 )
 
@@ -80,13 +82,27 @@ func (p *RetryParam) ResetRetryNextTry() {
 //
 //	Retry=3: GroupB, priority1 (startRetryIndex=2, priorityRetry=1)
 //	         分组B, 优先级1
+//
+// When every candidate was skipped because of upstream limits, the request waits in the upstream limit queue if enabled.
+// 所有候选渠道都因上游限制被跳过时，若启用了排队则等待名额释放。
 func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string, error) {
+	channel, selectGroup, err := cacheGetRandomSatisfiedChannel(param)
+	if errors.Is(err, model.ErrChannelUpstreamSaturated) {
+		return waitUpstreamLimitQueue(param)
+	}
+	return channel, selectGroup, err
+}
+
+func cacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string, error) {
 	var channel *model.Channel
+	var probe bool
 	var err error
+	common.SetContextKey(param.Ctx, constant.ContextKeyChannelBreakerProbe, false)
 	selectGroup := param.TokenGroup
 	userGroup := common.GetContextKeyString(param.Ctx, constant.ContextKeyUserGroup)
 	userId := param.Ctx.GetInt(string(constant.ContextKeyUserId))
 	sessionId := common.GetContextKeyString(param.Ctx, constant.ContextKeySessionId)
+	hashKey := getChannelHashKey(param.Ctx, sessionId, userId)
 
 	if param.TokenGroup == "auto" {
 		if len(setting.GetAutoGroups()) == 0 {
@@ -105,6 +121,7 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			}
 		}
 
+		upstreamSaturated := false
 		for i := startGroupIndex; i < len(autoGroups); i++ {
 			autoGroup := autoGroups[i]
 			// Calculate priorityRetry for current group
@@ -117,7 +134,10 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			}
 			logger.LogDebug(param.Ctx, "Auto selecting group: %s, priorityRetry: %d", autoGroup, priorityRetry)
 
-			channel, _ = model.GetRandomSatisfiedChannel(autoGroup, param.ModelName, priorityRetry, userId, sessionId)
+			channel, probe, err = model.GetRandomSatisfiedChannel(autoGroup, param.ModelName, priorityRetry, userId, sessionId, hashKey)
+			if errors.Is(err, model.ErrChannelUpstreamSaturated) {
+				upstreamSaturated = true
+			}
 			if channel == nil {
 				// Current group has no available channel for this model, try next group
 				// 当前分组没有该模型的可用渠道，尝试下一个分组
@@ -154,11 +174,31 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			}
 			break
 		}
+		if channel == nil && upstreamSaturated {
+			return nil, selectGroup, model.ErrChannelUpstreamSaturated
+		}
 	} else {
-		channel, err = model.GetRandomSatisfiedChannel(param.TokenGroup, param.ModelName, param.GetRetry(), userId, sessionId)
+		channel, probe, err = model.GetRandomSatisfiedChannel(param.TokenGroup, param.ModelName, param.GetRetry(), userId, sessionId, hashKey)
 		if err != nil {
 			return nil, param.TokenGroup, err
 		}
 	}
+	common.SetContextKey(param.Ctx, constant.ContextKeyChannelBreakerProbe, probe)
 	return channel, selectGroup, nil
 }
+
+// getChannelHashKey 返回一致性哈希选路使用的请求键：优先使用配置的请求头，其次会话 ID，最后用户 ID
+func getChannelHashKey(c *gin.Context, sessionId string, userId int) string {
+	if header := operation_setting.GetChannelSelectionSetting().HashKeyHeader; header != "" {
+		if value := c.Request.Header.Get(header); value != "" {
+			return value
+		}
+	}
+	if sessionId != "" {
+		return sessionId
+	}
+	if userId > 0 {
+		return "user:" + strconv.Itoa(userId)
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordChannelLatency 将一次中继的首字延迟与总耗时计入渠道的延迟统计，供最低延迟策略使用。
+// 可归因于渠道的失败（与熔断统计的判定一致）按惩罚延迟计入，其他失败不计入；实时会话的耗时取决于会话长度，不计入。
+func RecordChannelLatency(channelId int, info *relaycommon.RelayInfo, attemptStart time.Time, err *types.NewAPIError) {
+	// 命中响应缓存的请求未访问上游，不计入渠道延迟
+	if info == nil || info.RelayFormat == types.RelayFormatOpenAIRealtime || info.ResponseCacheStatus == ResponseCacheHit {
+		return
+	}
+	if err != nil {
+		if isBreakerFailure(err) {
+			model.RecordChannelFailure(channelId, time.Since(attemptStart))
+		}
+		return
+	}
+	var firstToken time.Duration
+	if info.IsStream && info.HasSendResponse() && info.FirstResponseTime.After(attemptStart) {
+		firstToken = info.FirstResponseTime.Sub(attemptStart)
+	}
+	model.RecordChannelLatency(channelId, firstToken, time.Since(attemptStart))
+}
+
+// ObserveRelayAttempt 将一次中继尝试计入 Prometheus 指标（请求数、错误码、耗时、首字时间与重试次数）
+func ObserveRelayAttempt(c *gin.Context, channelId int, info *relaycommon.RelayInfo, attemptStart time.Time, err *types.NewAPIError) {
+	if info == nil {
+		return
+	}
+	if info.RetryIndex > 0 {
+		metrics.IncRelayRetry(info.OriginModelName, info.UsingGroup)
+	}
+	statusCode := c.Writer.Status()
+	errorCode := ""
+	if err != nil {
+		statusCode = err.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusInternalServerError
+		}
+		errorCode = string(err.GetErrorCode())
+	}
+	var ttft time.Duration
+	if err == nil && info.IsStream && info.HasSendResponse() && info.FirstResponseTime.After(attemptStart) {
+		ttft = info.FirstResponseTime.Sub(attemptStart)
+	}
+	metrics.ObserveRelayAttempt(info.OriginModelName, info.UsingGroup, channelId, statusCode, errorCode, time.Since(attemptStart), ttft)
+}
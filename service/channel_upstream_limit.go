@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	upstreamLimitReservationKey = "upstream_limit_reservation"
+	upstreamCooldownAppliedKey  = "upstream_cooldown_applied"
+	// upstreamQueuePollInterval 排队期间重新选择渠道的间隔
+	upstreamQueuePollInterval = 200 * time.Millisecond
+)
+
+// upstreamQueueWaiting 本节点正在排队等待上游名额的请求数
+var upstreamQueueWaiting atomic.Int64
+
+type upstreamLimitReservation struct {
+	concurrencyKey string
+	rpmKey         string
+	// rpmBucket / tpmBucket 预占计入的窗口桶，归还或结算时修正同一个桶
+	rpmBucket int64
+	tpmKey    string
+	tpmBucket int64
+	reserved  int64
+	settled   bool
+}
+
+// release 释放并发名额，失败的尝试归还预占的 TPM；重复调用只生效一次
+func (r *upstreamLimitReservation) release(attemptErr *types.NewAPIError) {
+	if r.concurrencyKey != "" {
+		limiter.ReleaseConcurrency(context.Background(), r.concurrencyKey)
+		r.concurrencyKey = ""
+	}
+	if attemptErr != nil {
+		r.refundTokens()
+	}
+}
+
+// refundRequest 归还预占的 RPM
+func (r *upstreamLimitReservation) refundRequest() {
+	if r.rpmKey != "" {
+		_ = limiter.AdjustWindow(context.Background(), r.rpmKey, r.rpmBucket, -1)
+		r.rpmKey = ""
+	}
+}
+
+// refundTokens 归还预占的 TPM，之后的结算不再生效
+func (r *upstreamLimitReservation) refundTokens() {
+	if r.settled {
+		return
+	}
+	r.settled = true
+	if r.tpmKey != "" {
+		_ = limiter.AdjustWindow(context.Background(), r.tpmKey, r.tpmBucket, -r.reserved)
+	}
+}
+
+// upstreamLimitTarget 返回当前请求所选渠道的上游限制以及计数使用的密钥下标
+func upstreamLimitTarget(c *gin.Context) (channelId int, keyIndex int, limit *dto.UpstreamLimitSettings) {
+	channelId = common.GetContextKeyInt(c, constant.ContextKeyChannelId)
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		keyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
+	if setting, ok := common.GetContextKeyType[dto.ChannelSettings](c, constant.ContextKeyChannelSetting); ok {
+		limit = setting.UpstreamLimit
+	}
+	return channelId, keyIndex, limit
+}
+
+// AcquireUpstreamLimit 在发往上游前占用所选渠道（密钥）的并发名额，并在 RPM / TPM 窗口内预占额度。
+// 成功时返回释放函数，调用方在本次尝试结束后以尝试结果调用；失败的尝试会归还预占的 TPM。
+func AcquireUpstreamLimit(c *gin.Context, info *relaycommon.RelayInfo) (func(*types.NewAPIError), *types.NewAPIError) {
+	c.Set(upstreamCooldownAppliedKey, false)
+	c.Set(upstreamLimitReservationKey, nil)
+	channelId, keyIndex, limit := upstreamLimitTarget(c)
+	if !limit.IsEnabled() {
+		return func(*types.NewAPIError) {}, nil
+	}
+
+	reservation := &upstreamLimitReservation{}
+	release := reservation.release
+	reject := func(reason string) (func(*types.NewAPIError), *types.NewAPIError) {
+		reservation.refundRequest()
+		release(types.NewError(errors.New(reason), types.ErrorCodeChannelUpstreamLimited))
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("渠道 #%d 已达到上游%s限制", channelId, reason),
+			types.ErrorCodeChannelUpstreamLimited, http.StatusTooManyRequests, types.ErrOptionWithNoRecordErrorLog())
+	}
+
+	if limit.Concurrency > 0 {
+		key := model.UpstreamLimitKey(model.UpstreamLimitConcurrency, channelId, keyIndex)
+		allowed, _, err := limiter.AcquireConcurrency(c, key, int64(limit.Concurrency))
+		if err != nil {
+			// 限流存储异常时放行，避免影响正常请求
+			logger.LogError(c, fmt.Sprintf("upstream concurrency limit check failed: %s", err.Error()))
+		} else if !allowed {
+			return reject("并发")
+		} else {
+			reservation.concurrencyKey = key
+		}
+	}
+	if limit.RPM > 0 {
+		key := model.UpstreamLimitKey(model.UpstreamLimitRPM, channelId, keyIndex)
+		result, err := limiter.ReserveWindow(c, key, int64(limit.RPM), 1)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("upstream rpm limit check failed: %s", err.Error()))
+		} else if !result.Allowed {
+			return reject("RPM")
+		} else {
+			reservation.rpmKey = key
+			reservation.rpmBucket = result.Bucket
+		}
+	}
+	if limit.TPM > 0 {
+		key := model.UpstreamLimitKey(model.UpstreamLimitTPM, channelId, keyIndex)
+		amount := int64(max(info.GetEstimatePromptTokens(), 1))
+		result, err := limiter.ReserveWindow(c, key, int64(limit.TPM), amount)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("upstream tpm limit check failed: %s", err.Error()))
+		} else if !result.Allowed {
+			return reject("TPM")
+		} else {
+			reservation.tpmKey = key
+			reservation.tpmBucket = result.Bucket
+			reservation.reserved = amount
+		}
+	}
+	c.Set(upstreamLimitReservationKey, reservation)
+	return release, nil
+}
+
+// ReleaseUpstreamLimitForCacheHit 命中响应缓存时本次尝试没有请求上游：立即释放并发名额，并归还预占的 RPM 与 TPM
+func ReleaseUpstreamLimitForCacheHit(c *gin.Context) {
+	value, ok := c.Get(upstreamLimitReservationKey)
+	if !ok {
+		return
+	}
+	reservation, ok := value.(*upstreamLimitReservation)
+	if !ok {
+		return
+	}
+	reservation.refundRequest()
+	reservation.refundTokens()
+	reservation.release(nil)
+}
+
+// settleUpstreamTokenLimit 使用实际 token 用量修正本次尝试在渠道 TPM 窗口内预占的额度
+func settleUpstreamTokenLimit(c *gin.Context, actualTokens int) {
+	value, ok := c.Get(upstreamLimitReservationKey)
+	if !ok {
+		return
+	}
+	reservation, ok := value.(*upstreamLimitReservation)
+	if !ok || reservation.settled {
+		return
+	}
+	reservation.settled = true
+	if reservation.tpmKey == "" {
+		return
+	}
+	if delta := int64(actualTokens) - reservation.reserved; delta != 0 {
+		if err := limiter.AdjustWindow(c, reservation.tpmKey, reservation.tpmBucket, delta); err != nil {
+			logger.LogError(c, fmt.Sprintf("upstream tpm limit settle failed: %s", err.Error()))
+		}
+	}
+}
+
+// parseRateLimitReset 解析 Retry-After / x-ratelimit-reset-* 的取值：秒数、Go 时长（如 6m0s、20ms）、HTTP 日期或 RFC3339 时间
+func parseRateLimitReset(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	for _, layout := range []string{http.TimeFormat, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Sub(now)
+		}
+	}
+	return 0
+}
+
+// upstreamCooldownDuration 从限流响应头中取冷却时长：优先 Retry-After，其次各 x-ratelimit-reset-* 中的最大值
+func upstreamCooldownDuration(header http.Header, now time.Time) time.Duration {
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v > 0 {
+			return time.Duration(v * float64(time.Millisecond))
+		}
+	}
+	if d := parseRateLimitReset(header.Get("Retry-After"), now); d > 0 {
+		return d
+	}
+	var cooldown time.Duration
+	for name, values := range header {
+		lower := strings.ToLower(name)
+		if !strings.HasPrefix(lower, "x-ratelimit-reset") || len(values) == 0 {
+			continue
+		}
+		cooldown = max(cooldown, parseRateLimitReset(values[0], now))
+	}
+	return cooldown
+}
+
+// ApplyUpstreamCooldown 上游返回 429 并携带 Retry-After / x-ratelimit-reset-* 时，暂停使用当前渠道密钥直至限流重置
+func ApplyUpstreamCooldown(c *gin.Context, resp *http.Response) {
+	setting := operation_setting.GetUpstreamLimitSetting()
+	if !setting.CooldownEnabled || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+	cooldown := upstreamCooldownDuration(resp.Header, time.Now())
+	if cooldown <= 0 {
+		return
+	}
+	if setting.MaxCooldownSeconds > 0 {
+		cooldown = min(cooldown, time.Duration(setting.MaxCooldownSeconds)*time.Second)
+	}
+	channelId, keyIndex, _ := upstreamLimitTarget(c)
+	model.SetChannelKeyCooldown(channelId, keyIndex, cooldown)
+	c.Set(upstreamCooldownAppliedKey, true)
+	logger.LogWarn(c, fmt.Sprintf("channel #%d (key %d) rate limited by upstream, cooling down for %s", channelId, keyIndex, cooldown))
+}
+
+// IsUpstreamCooldownApplied 本次尝试的上游 429 是否已转为密钥冷却，此时不再自动禁用渠道
+func IsUpstreamCooldownApplied(c *gin.Context) bool {
+	return c.GetBool(upstreamCooldownAppliedKey)
+}
+
+// waitUpstreamLimitQueue 所有候选渠道的上游限制都已饱和时，在有界队列中等待名额释放，超时或客户端断开后失败
+func waitUpstreamLimitQueue(param *RetryParam) (*model.Channel, string, error) {
+	setting := operation_setting.GetUpstreamLimitSetting()
+	if !setting.QueueEnabled || setting.QueueTimeoutSeconds <= 0 {
+		return nil, param.TokenGroup, model.ErrChannelUpstreamSaturated
+	}
+	if upstreamQueueWaiting.Add(1) > int64(setting.QueueMaxWaiting) {
+		upstreamQueueWaiting.Add(-1)
+		return nil, param.TokenGroup, fmt.Errorf("%w, and the wait queue is full", model.ErrChannelUpstreamSaturated)
+	}
+	defer upstreamQueueWaiting.Add(-1)
+
+	// 自动分组选路失败时会推进分组下标与重试次数，每次重新选路前恢复
+	retry := param.GetRetry()
+	autoGroupIndex, hasAutoGroupIndex := common.GetContextKey(param.Ctx, constant.ContextKeyAutoGroupIndex)
+	restore := func() {
+		param.SetRetry(retry)
+		if hasAutoGroupIndex {
+			common.SetContextKey(param.Ctx, constant.ContextKeyAutoGroupIndex, autoGroupIndex)
+		} else {
+			common.SetContextKey(param.Ctx, constant.ContextKeyAutoGroupIndex, 0)
+		}
+	}
+
+	timeout := time.NewTimer(time.Duration(setting.QueueTimeoutSeconds) * time.Second)
+	defer timeout.Stop()
+	ticker := time.NewTicker(upstreamQueuePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-param.Ctx.Request.Context().Done():
+			return nil, param.TokenGroup, param.Ctx.Request.Context().Err()
+		case <-timeout.C:
+			return nil, param.TokenGroup, fmt.Errorf("%w, waited %ds", model.ErrChannelUpstreamSaturated, setting.QueueTimeoutSeconds)
+		case <-ticker.C:
+			restore()
+			channel, selectGroup, err := cacheGetRandomSatisfiedChannel(param)
+			if !errors.Is(err, model.ErrChannelUpstreamSaturated) {
+				return channel, selectGroup, err
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamCooldownDuration(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	header := http.Header{}
+	header.Set("Retry-After", "7")
+	header.Set("X-Ratelimit-Reset-Requests", "1m0s")
+	require.Equal(t, 7*time.Second, upstreamCooldownDuration(header, now))
+
+	header = http.Header{}
+	header.Set("X-Ratelimit-Reset-Requests", "120ms")
+	header.Set("X-Ratelimit-Reset-Tokens", "6m0s")
+	require.Equal(t, 6*time.Minute, upstreamCooldownDuration(header, now))
+
+	header = http.Header{}
+	header.Set("Retry-After", now.Add(30*time.Second).Format(http.TimeFormat))
+	require.Equal(t, 30*time.Second, upstreamCooldownDuration(header, now))
+
+	header = http.Header{}
+	header.Set("retry-after-ms", "1500")
+	require.Equal(t, 1500*time.Millisecond, upstreamCooldownDuration(header, now))
+
+	require.Zero(t, upstreamCooldownDuration(http.Header{}, now))
+}
+
+func TestReleaseUpstreamLimitForCacheHit(t *testing.T) {
+	const channelId = 9301
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyChannelId, channelId)
+	common.SetContextKey(c, constant.ContextKeyChannelSetting, dto.ChannelSettings{
+		UpstreamLimit: &dto.UpstreamLimitSettings{Concurrency: 1, RPM: 10, TPM: 1000},
+	})
+	info := &relaycommon.RelayInfo{}
+	info.SetEstimatePromptTokens(100)
+
+	release, apiErr := AcquireUpstreamLimit(c, info)
+	require.Nil(t, apiErr)
+	concurrencyKey := model.UpstreamLimitKey(model.UpstreamLimitConcurrency, channelId, 0)
+	rpmKey := model.UpstreamLimitKey(model.UpstreamLimitRPM, channelId, 0)
+	tpmKey := model.UpstreamLimitKey(model.UpstreamLimitTPM, channelId, 0)
+	current, err := limiter.CurrentConcurrency(c, concurrencyKey)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), current)
+
+	// 命中缓存：名额立即归还，之后的结算与尝试结束时的释放都不再重复生效
+	ReleaseUpstreamLimitForCacheHit(c)
+	settleUpstreamTokenLimit(c, 500)
+	release(nil)
+
+	current, err = limiter.CurrentConcurrency(c, concurrencyKey)
+	require.NoError(t, err)
+	require.Zero(t, current)
+	rpm, err := limiter.PeekWindow(c, rpmKey, 10)
+	require.NoError(t, err)
+	require.Zero(t, rpm.Used)
+	tpm, err := limiter.PeekWindow(c, tpmKey, 1000)
+	require.NoError(t, err)
+	require.Zero(t, tpm.Used)
+}
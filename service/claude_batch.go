@@ -0,0 +1,161 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ClaudeMessageBatchEndpoint Message Batches 的每个请求都作为一次 /v1/messages 调用执行
+const ClaudeMessageBatchEndpoint = "/v1/messages"
+
+const claudeMessageBatchMaxRequests = 100000
+
+var claudeBatchCustomIdPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// BuildClaudeMessageBatchInput 校验 Message Batches 请求，并转换为批处理任务使用的 JSONL 输入文件
+func BuildClaudeMessageBatchInput(items []dto.ClaudeMessageBatchRequestItem) ([]byte, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("requests: at least one request is required")
+	}
+	if len(items) > claudeMessageBatchMaxRequests {
+		return nil, fmt.Errorf("requests: a batch can contain at most %d requests", claudeMessageBatchMaxRequests)
+	}
+	var buf bytes.Buffer
+	customIds := make(map[string]struct{}, len(items))
+	for i, item := range items {
+		if !claudeBatchCustomIdPattern.MatchString(item.CustomID) {
+			return nil, fmt.Errorf("requests.%d.custom_id: must be 1-64 characters of letters, digits, underscores or hyphens", i)
+		}
+		if _, ok := customIds[item.CustomID]; ok {
+			return nil, fmt.Errorf("requests.%d.custom_id: duplicate custom_id %s", i, item.CustomID)
+		}
+		customIds[item.CustomID] = struct{}{}
+
+		var params struct {
+			Model string `json:"model"`
+		}
+		if len(item.Params) == 0 || common.Unmarshal(item.Params, &params) != nil {
+			return nil, fmt.Errorf("requests.%d.params: must be a JSON object", i)
+		}
+		if params.Model == "" {
+			return nil, fmt.Errorf("requests.%d.params.model: field required", i)
+		}
+		line, err := common.Marshal(dto.OpenAIBatchRequestInput{
+			CustomID: item.CustomID,
+			Method:   http.MethodPost,
+			URL:      ClaudeMessageBatchEndpoint,
+			Body:     item.Params,
+		})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// ConvertBatchResultsToClaude 将批处理输出/错误文件（OpenAI 批处理输出格式）转换为 Message Batches 结果 JSONL
+func ConvertBatchResultsToClaude(contents ...[]byte) []byte {
+	var buf bytes.Buffer
+	for _, content := range contents {
+		for _, raw := range bytes.Split(content, []byte("\n")) {
+			raw = bytes.TrimSpace(raw)
+			if len(raw) == 0 {
+				continue
+			}
+			var output dto.OpenAIBatchRequestOutput
+			if err := common.Unmarshal(raw, &output); err != nil {
+				continue
+			}
+			line, err := common.Marshal(dto.ClaudeMessageBatchResult{
+				CustomID: output.CustomID,
+				Result:   claudeBatchResultBody(&output),
+			})
+			if err != nil {
+				continue
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func claudeBatchResultBody(output *dto.OpenAIBatchRequestOutput) dto.ClaudeMessageBatchResultBody {
+	if output.Error != nil {
+		switch output.Error.Code {
+		case "batch_" + dto.BatchStatusCancelled:
+			return dto.ClaudeMessageBatchResultBody{Type: dto.ClaudeBatchResultCanceled}
+		case "batch_" + dto.BatchStatusExpired:
+			return dto.ClaudeMessageBatchResultBody{Type: dto.ClaudeBatchResultExpired}
+		}
+		return claudeBatchErroredResult("api_error", output.Error.Message)
+	}
+	if output.Response == nil {
+		return claudeBatchErroredResult("api_error", "no response")
+	}
+	if output.Response.StatusCode >= 200 && output.Response.StatusCode < 300 {
+		return dto.ClaudeMessageBatchResultBody{Type: dto.ClaudeBatchResultSucceeded, Message: output.Response.Body}
+	}
+
+	// 中继返回的错误可能是 Claude 格式，也可能是鉴权等中间件返回的 OpenAI 格式
+	var errBody struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := common.Unmarshal(output.Response.Body, &errBody); err == nil {
+		if errBody.Type == "error" {
+			return dto.ClaudeMessageBatchResultBody{Type: dto.ClaudeBatchResultErrored, Error: output.Response.Body}
+		}
+		if errBody.Error.Message != "" {
+			return claudeBatchErroredResult(claudeErrorTypeForStatus(output.Response.StatusCode), errBody.Error.Message)
+		}
+	}
+	var message string
+	if err := common.Unmarshal(output.Response.Body, &message); err != nil {
+		message = string(output.Response.Body)
+	}
+	return claudeBatchErroredResult(claudeErrorTypeForStatus(output.Response.StatusCode), message)
+}
+
+func claudeBatchErroredResult(errType string, message string) dto.ClaudeMessageBatchResultBody {
+	body, _ := common.Marshal(map[string]any{
+		"type": "error",
+		"error": map[string]string{
+			"type":    errType,
+			"message": message,
+		},
+	})
+	return dto.ClaudeMessageBatchResultBody{Type: dto.ClaudeBatchResultErrored, Error: json.RawMessage(body)}
+}
+
+// claudeErrorTypeForStatus https://docs.anthropic.com/en/api/errors
+func claudeErrorTypeForStatus(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	}
+	return "api_error"
+}
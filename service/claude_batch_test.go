@@ -0,0 +1,49 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildClaudeMessageBatchInput(t *testing.T) {
+	content, err := BuildClaudeMessageBatchInput([]dto.ClaudeMessageBatchRequestItem{
+		{CustomID: "req-1", Params: []byte(`{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[]}`)},
+		{CustomID: "req-2", Params: []byte(`{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[]}`)},
+	})
+	require.NoError(t, err)
+
+	inputs, errs := ParseBatchInput(content, ClaudeMessageBatchEndpoint)
+	require.Empty(t, errs)
+	require.Len(t, inputs, 2)
+	require.Equal(t, "req-2", inputs[1].CustomID)
+
+	_, err = BuildClaudeMessageBatchInput([]dto.ClaudeMessageBatchRequestItem{
+		{CustomID: "dup", Params: []byte(`{"model":"m"}`)},
+		{CustomID: "dup", Params: []byte(`{"model":"m"}`)},
+	})
+	require.ErrorContains(t, err, "duplicate custom_id")
+
+	_, err = BuildClaudeMessageBatchInput([]dto.ClaudeMessageBatchRequestItem{
+		{CustomID: "no model", Params: []byte(`{}`)},
+	})
+	require.ErrorContains(t, err, "custom_id")
+}
+
+func TestConvertBatchResultsToClaude(t *testing.T) {
+	output := `{"id":"batch_req_1","custom_id":"ok","response":{"status_code":200,"request_id":"r1","body":{"id":"msg_1","type":"message"}},"error":null}`
+	errorData := strings.Join([]string{
+		`{"id":"batch_req_2","custom_id":"bad","response":{"status_code":400,"request_id":"r2","body":{"type":"error","error":{"type":"invalid_request_error","message":"oops"}}},"error":null}`,
+		`{"id":"batch_req_3","custom_id":"auth","response":{"status_code":401,"request_id":"r3","body":{"error":{"message":"invalid token","type":"new_api_error"}}},"error":null}`,
+		`{"id":"batch_req_4","custom_id":"late","response":null,"error":{"code":"batch_cancelled","message":"cancelled"}}`,
+	}, "\n")
+
+	lines := strings.Split(strings.TrimSpace(string(ConvertBatchResultsToClaude([]byte(output), []byte(errorData)))), "\n")
+	require.Len(t, lines, 4)
+	require.JSONEq(t, `{"custom_id":"ok","result":{"type":"succeeded","message":{"id":"msg_1","type":"message"}}}`, lines[0])
+	require.JSONEq(t, `{"custom_id":"bad","result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"oops"}}}}`, lines[1])
+	require.JSONEq(t, `{"custom_id":"auth","result":{"type":"errored","error":{"type":"error","error":{"type":"authentication_error","message":"invalid token"}}}}`, lines[2])
+	require.JSONEq(t, `{"custom_id":"late","result":{"type":"canceled"}}`, lines[3])
+}
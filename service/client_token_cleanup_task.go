@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const clientTokenCleanupInterval = 1 * time.Hour
+
+var clientTokenCleanupOnce sync.Once
+
+// StartClientTokenCleanupTask 定期删除已过期客户端令牌的用量记录
+func StartClientTokenCleanupTask() {
+	clientTokenCleanupOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("client token cleanup task started: tick=%s", clientTokenCleanupInterval))
+			ticker := time.NewTicker(clientTokenCleanupInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				deleted, err := model.DeleteExpiredClientTokens(common.GetTimestamp())
+				if err != nil {
+					logger.LogWarn(context.Background(), fmt.Sprintf("client token cleanup failed: %v", err))
+					continue
+				}
+				if deleted > 0 {
+					logger.LogInfo(context.Background(), fmt.Sprintf("client token cleanup: deleted %d expired records", deleted))
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+
+	"github.com/samber/lo"
+)
+
+// ResponsesUsageToClaudeUsage 将 Responses 用量转换为 Claude 语义：input_tokens 不含缓存命中的部分
+func ResponsesUsageToClaudeUsage(responsesUsage *dto.Usage) *dto.Usage {
+	usage := &dto.Usage{}
+	if responsesUsage == nil {
+		return usage
+	}
+	cachedTokens := 0
+	if responsesUsage.InputTokensDetails != nil {
+		cachedTokens = responsesUsage.InputTokensDetails.CachedTokens
+	}
+	usage.PromptTokens = responsesUsage.InputTokens - cachedTokens
+	usage.InputTokens = usage.PromptTokens
+	usage.CompletionTokens = responsesUsage.OutputTokens
+	usage.OutputTokens = responsesUsage.OutputTokens
+	usage.TotalTokens = responsesUsage.InputTokens + responsesUsage.OutputTokens
+	usage.PromptTokensDetails.CachedTokens = cachedTokens
+	usage.CompletionTokenDetails.ReasoningTokens = responsesUsage.CompletionTokenDetails.ReasoningTokens
+	return usage
+}
+
+func claudeUsageFromUsage(usage *dto.Usage) *dto.ClaudeUsage {
+	return &dto.ClaudeUsage{
+		InputTokens:          usage.PromptTokens,
+		OutputTokens:         usage.CompletionTokens,
+		CacheReadInputTokens: usage.PromptTokensDetails.CachedTokens,
+	}
+}
+
+func responsesStopReasonToClaude(status string, sawToolUse bool) string {
+	switch {
+	case status == "incomplete":
+		return "max_tokens"
+	case sawToolUse:
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
+
+func responsesReasoningSignature(encryptedContent string) string {
+	if encryptedContent == "" {
+		return ""
+	}
+	return openaicompat.ResponsesReasoningSignaturePrefix + encryptedContent
+}
+
+func responsesReasoningText(item *dto.ResponsesOutput) string {
+	parts := make([]string, 0, len(item.Summary))
+	for _, summary := range item.Summary {
+		if summary.Text != "" {
+			parts = append(parts, summary.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func responsesMessageText(item *dto.ResponsesOutput) string {
+	var sb strings.Builder
+	for _, content := range item.Content {
+		if content.Type == "output_text" {
+			sb.WriteString(content.Text)
+		}
+	}
+	return sb.String()
+}
+
+func responsesArgumentsToClaudeInput(arguments string) any {
+	if strings.TrimSpace(arguments) == "" {
+		return map[string]any{}
+	}
+	var input map[string]any
+	if err := common.UnmarshalJsonStr(arguments, &input); err == nil {
+		return input
+	}
+	return arguments
+}
+
+// ResponseResponses2Claude 将非流式 Responses 响应转换为 Claude Messages 响应，
+// reasoning 项转为带签名的 thinking 块，function_call 转为 tool_use 块
+func ResponseResponses2Claude(resp *dto.OpenAIResponsesResponse, usage *dto.Usage) *dto.ClaudeResponse {
+	contents := make([]dto.ClaudeMediaMessage, 0, len(resp.Output))
+	sawToolUse := false
+	for i := range resp.Output {
+		item := &resp.Output[i]
+		switch item.Type {
+		case "reasoning":
+			thinking := responsesReasoningText(item)
+			contents = append(contents, dto.ClaudeMediaMessage{
+				Type:      "thinking",
+				Thinking:  &thinking,
+				Signature: responsesReasoningSignature(item.EncryptedContent),
+			})
+		case "message":
+			block := dto.ClaudeMediaMessage{Type: "text"}
+			block.SetText(responsesMessageText(item))
+			contents = append(contents, block)
+		case "function_call":
+			sawToolUse = true
+			contents = append(contents, dto.ClaudeMediaMessage{
+				Type:  "tool_use",
+				Id:    item.CallId,
+				Name:  item.Name,
+				Input: responsesArgumentsToClaudeInput(item.Arguments),
+			})
+		}
+	}
+	return &dto.ClaudeResponse{
+		Id:         resp.ID,
+		Type:       "message",
+		Role:       "assistant",
+		Model:      resp.Model,
+		Content:    contents,
+		StopReason: responsesStopReasonToClaude(resp.GetStatus(), sawToolUse),
+		Usage:      claudeUsageFromUsage(usage),
+	}
+}
+
+type responsesClaudeBlock struct {
+	index        int
+	blockType    string
+	sentDelta    bool
+	summaryIndex int
+}
+
+// ResponsesToClaudeStreamConverter 将 Responses 流式事件转换为 Claude SSE 事件。
+// 每个输出项对应一个内容块：output_item.added 时 content_block_start，output_item.done 时 content_block_stop
+type ResponsesToClaudeStreamConverter struct {
+	info       *relaycommon.RelayInfo
+	started    bool
+	done       bool
+	nextIndex  int
+	blocks     map[int]*responsesClaudeBlock
+	sawToolUse bool
+	// Text 收集输出内容，上游未返回用量时用于估算
+	Text strings.Builder
+}
+
+func NewResponsesToClaudeStreamConverter(info *relaycommon.RelayInfo) *ResponsesToClaudeStreamConverter {
+	return &ResponsesToClaudeStreamConverter{
+		info:   info,
+		blocks: make(map[int]*responsesClaudeBlock),
+	}
+}
+
+func (s *ResponsesToClaudeStreamConverter) messageStart(id string, model string) *dto.ClaudeResponse {
+	s.started = true
+	if model == "" {
+		model = s.info.UpstreamModelName
+	}
+	msg := &dto.ClaudeMediaMessage{
+		Id:    id,
+		Model: model,
+		Type:  "message",
+		Role:  "assistant",
+		Usage: &dto.ClaudeUsage{
+			InputTokens: s.info.GetEstimatePromptTokens(),
+		},
+	}
+	msg.SetContent(make([]any, 0))
+	return &dto.ClaudeResponse{
+		Type:    "message_start",
+		Message: msg,
+	}
+}
+
+func (s *ResponsesToClaudeStreamConverter) blockDelta(block *responsesClaudeBlock, delta *dto.ClaudeMediaMessage) *dto.ClaudeResponse {
+	block.sentDelta = true
+	return &dto.ClaudeResponse{
+		Index: common.GetPointer(block.index),
+		Type:  "content_block_delta",
+		Delta: delta,
+	}
+}
+
+func (s *ResponsesToClaudeStreamConverter) startBlock(outputIndex int, item *dto.ResponsesOutput) *dto.ClaudeResponse {
+	var contentBlock *dto.ClaudeMediaMessage
+	switch item.Type {
+	case "reasoning":
+		contentBlock = &dto.ClaudeMediaMessage{Type: "thinking", Thinking: common.GetPointer("")}
+	case "message":
+		contentBlock = &dto.ClaudeMediaMessage{Type: "text", Text: common.GetPointer("")}
+	case "function_call":
+		s.sawToolUse = true
+		contentBlock = &dto.ClaudeMediaMessage{Type: "tool_use", Id: item.CallId, Name: item.Name, Input: map[string]any{}}
+		s.Text.WriteString(item.Name)
+	default:
+		return nil
+	}
+	block := &responsesClaudeBlock{index: s.nextIndex, blockType: item.Type}
+	s.blocks[outputIndex] = block
+	s.nextIndex++
+	return &dto.ClaudeResponse{
+		Index:        common.GetPointer(block.index),
+		Type:         "content_block_start",
+		ContentBlock: contentBlock,
+	}
+}
+
+// stopBlock 关闭内容块；上游未逐字推送时用 output_item.done 中的完整内容补发一次 delta
+func (s *ResponsesToClaudeStreamConverter) stopBlock(outputIndex int, item *dto.ResponsesOutput) []*dto.ClaudeResponse {
+	block, ok := s.blocks[outputIndex]
+	if !ok {
+		return nil
+	}
+	delete(s.blocks, outputIndex)
+	var events []*dto.ClaudeResponse
+	if item != nil {
+		switch block.blockType {
+		case "reasoning":
+			if text := responsesReasoningText(item); text != "" && !block.sentDelta {
+				s.Text.WriteString(text)
+				events = append(events, s.blockDelta(block, &dto.ClaudeMediaMessage{Type: "thinking_delta", Thinking: &text}))
+			}
+			if signature := responsesReasoningSignature(item.EncryptedContent); signature != "" {
+				events = append(events, s.blockDelta(block, &dto.ClaudeMediaMessage{Type: "signature_delta", Signature: signature}))
+			}
+		case "message":
+			if text := responsesMessageText(item); text != "" && !block.sentDelta {
+				s.Text.WriteString(text)
+				events = append(events, s.blockDelta(block, &dto.ClaudeMediaMessage{Type: "text_delta", Text: &text}))
+			}
+		case "function_call":
+			if arguments := item.Arguments; arguments != "" && !block.sentDelta {
+				s.Text.WriteString(arguments)
+				events = append(events, s.blockDelta(block, &dto.ClaudeMediaMessage{Type: "input_json_delta", PartialJson: &arguments}))
+			}
+		}
+	}
+	return append(events, &dto.ClaudeResponse{
+		Index: common.GetPointer(block.index),
+		Type:  "content_block_stop",
+	})
+}
+
+// Convert 处理一个 Responses 流式事件，返回需要发送给客户端的 Claude 事件
+func (s *ResponsesToClaudeStreamConverter) Convert(event *dto.ResponsesStreamResponse) []*dto.ClaudeResponse {
+	if s.done {
+		return nil
+	}
+	var events []*dto.ClaudeResponse
+	if !s.started {
+		var id, model string
+		if event.Response != nil {
+			id, model = event.Response.ID, event.Response.Model
+		}
+		events = append(events, s.messageStart(id, model))
+	}
+	outputIndex := lo.FromPtr(event.OutputIndex)
+
+	switch event.Type {
+	case dto.ResponsesOutputTypeItemAdded:
+		if event.Item != nil {
+			if start := s.startBlock(outputIndex, event.Item); start != nil {
+				events = append(events, start)
+			}
+		}
+	case "response.reasoning_summary_text.delta":
+		block, ok := s.blocks[outputIndex]
+		if !ok || event.Delta == "" {
+			break
+		}
+		delta := event.Delta
+		// 多段推理摘要之间以空行分隔
+		if summaryIndex := lo.FromPtr(event.SummaryIndex); summaryIndex != block.summaryIndex {
+			block.summaryIndex = summaryIndex
+			if block.sentDelta {
+				delta = "\n\n" + delta
+			}
+		}
+		s.Text.WriteString(delta)
+		events = append(events, s.blockDelta(block, &dto.ClaudeMediaMessage{Type: "thinking_delta", Thinking: &delta}))
+	case "response.output_text.delta":
+		block, ok := s.blocks[outputIndex]
+		if !ok || event.Delta == "" {
+			break
+		}
+		delta := event.Delta
+		s.Text.WriteString(delta)
+		events = append(events, s.blockDelta(block, &dto.ClaudeMediaMessage{Type: "text_delta", Text: &delta}))
+	case "response.function_call_arguments.delta":
+		block, ok := s.blocks[outputIndex]
+		if !ok || event.Delta == "" {
+			break
+		}
+		delta := event.Delta
+		s.Text.WriteString(delta)
+		events = append(events, s.blockDelta(block, &dto.ClaudeMediaMessage{Type: "input_json_delta", PartialJson: &delta}))
+	case dto.ResponsesOutputTypeItemDone:
+		events = append(events, s.stopBlock(outputIndex, event.Item)...)
+	}
+	return events
+}
+
+// Finish 关闭所有未结束的内容块并发送 message_delta 与 message_stop，status 为 Responses 响应的最终状态
+func (s *ResponsesToClaudeStreamConverter) Finish(status string, usage *dto.Usage) []*dto.ClaudeResponse {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	var events []*dto.ClaudeResponse
+	if !s.started {
+		events = append(events, s.messageStart("", ""))
+	}
+	for outputIndex := range s.blocks {
+		events = append(events, s.stopBlock(outputIndex, nil)...)
+	}
+	events = append(events, &dto.ClaudeResponse{
+		Type:  "message_delta",
+		Usage: claudeUsageFromUsage(usage),
+		Delta: &dto.ClaudeMediaMessage{
+			StopReason: common.GetPointer(responsesStopReasonToClaude(status, s.sawToolUse)),
+		},
+	}, &dto.ClaudeResponse{
+		Type: "message_stop",
+	})
+	return events
+}
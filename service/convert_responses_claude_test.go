@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesToClaudeStreamConverter(t *testing.T) {
+	events := []string{
+		`{"type":"response.created","response":{"id":"resp_1","model":"gpt-5-codex"}}`,
+		`{"type":"response.output_item.added","output_index":0,"item":{"type":"reasoning","id":"rs_1"}}`,
+		`{"type":"response.reasoning_summary_text.delta","output_index":0,"summary_index":0,"delta":"think"}`,
+		`{"type":"response.reasoning_summary_text.delta","output_index":0,"summary_index":1,"delta":"more"}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1","encrypted_content":"enc"}}`,
+		`{"type":"response.output_item.added","output_index":1,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather"}}`,
+		`{"type":"response.function_call_arguments.delta","output_index":1,"delta":"{\"city\":\"Paris\"}"}`,
+		`{"type":"response.output_item.done","output_index":1,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}`,
+	}
+	converter := NewResponsesToClaudeStreamConverter(&relaycommon.RelayInfo{})
+	var out []*dto.ClaudeResponse
+	for _, data := range events {
+		var event dto.ResponsesStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(data, &event))
+		out = append(out, converter.Convert(&event)...)
+	}
+	usage := ResponsesUsageToClaudeUsage(&dto.Usage{
+		InputTokens:        100,
+		OutputTokens:       20,
+		InputTokensDetails: &dto.InputTokenDetails{CachedTokens: 60},
+	})
+	out = append(out, converter.Finish("completed", usage)...)
+
+	types := make([]string, 0, len(out))
+	for _, event := range out {
+		types = append(types, event.Type)
+	}
+	require.Equal(t, []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}, types)
+	require.Equal(t, "resp_1", out[0].Message.Id)
+	require.Equal(t, "\n\nmore", *out[3].Delta.Thinking)
+	require.Equal(t, "signature_delta", out[4].Delta.Type)
+	require.Equal(t, "responses-reasoning:enc", out[4].Delta.Signature)
+	require.Equal(t, 1, out[6].GetIndex())
+	require.Equal(t, "tool_use", out[6].ContentBlock.Type)
+	require.Equal(t, "call_1", out[6].ContentBlock.Id)
+
+	messageDelta := out[9]
+	require.Equal(t, "tool_use", *messageDelta.Delta.StopReason)
+	require.Equal(t, 40, messageDelta.Usage.InputTokens)
+	require.Equal(t, 60, messageDelta.Usage.CacheReadInputTokens)
+	require.Equal(t, 20, messageDelta.Usage.OutputTokens)
+}
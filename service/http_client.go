@@ -2,14 +2,18 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/setting/system_setting"
 
 	"golang.org/x/net/proxy"
@@ -19,6 +23,8 @@ var (
 	httpClient      *http.Client
 	proxyClientLock sync.Mutex
 	proxyClients    = make(map[string]*http.Client)
+	// tlsClients 按代理与证书内容的摘要缓存 mTLS 客户端
+	tlsClients = make(map[string]*http.Client)
 )
 
 func checkRedirect(req *http.Request, via []*http.Request) error {
@@ -80,6 +86,12 @@ func ResetProxyClientCache() {
 		}
 	}
 	proxyClients = make(map[string]*http.Client)
+	for _, client := range tlsClients {
+		if transport, ok := client.Transport.(*http.Transport); ok && transport != nil {
+			transport.CloseIdleConnections()
+		}
+	}
+	tlsClients = make(map[string]*http.Client)
 }
 
 // NewProxyHttpClient 创建支持代理的 HTTP 客户端
@@ -98,6 +110,36 @@ func NewProxyHttpClient(proxyURL string) (*http.Client, error) {
 	}
 	proxyClientLock.Unlock()
 
+	transport, err := newProxyTransport(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	if common.TLSInsecureSkipVerify {
+		transport.TLSClientConfig = common.InsecureTLSConfig
+	}
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: checkRedirect,
+	}
+	client.Timeout = time.Duration(common.RelayTimeout) * time.Second
+	proxyClientLock.Lock()
+	proxyClients[proxyURL] = client
+	proxyClientLock.Unlock()
+	return client, nil
+}
+
+// newProxyTransport 创建连接池参数与全局客户端一致的 Transport，proxyURL 为空时使用环境变量代理
+func newProxyTransport(proxyURL string) (*http.Transport, error) {
+	transport := &http.Transport{
+		MaxIdleConns:        common.RelayMaxIdleConns,
+		MaxIdleConnsPerHost: common.RelayMaxIdleConnsPerHost,
+		ForceAttemptHTTP2:   true,
+	}
+	if proxyURL == "" {
+		transport.Proxy = http.ProxyFromEnvironment
+		return transport, nil
+	}
+
 	parsedURL, err := url.Parse(proxyURL)
 	if err != nil {
 		return nil, err
@@ -105,24 +147,8 @@ func NewProxyHttpClient(proxyURL string) (*http.Client, error) {
 
 	switch parsedURL.Scheme {
 	case "http", "https":
-		transport := &http.Transport{
-			MaxIdleConns:        common.RelayMaxIdleConns,
-			MaxIdleConnsPerHost: common.RelayMaxIdleConnsPerHost,
-			ForceAttemptHTTP2:   true,
-			Proxy:               http.ProxyURL(parsedURL),
-		}
-		if common.TLSInsecureSkipVerify {
-			transport.TLSClientConfig = common.InsecureTLSConfig
-		}
-		client := &http.Client{
-			Transport:     transport,
-			CheckRedirect: checkRedirect,
-		}
-		client.Timeout = time.Duration(common.RelayTimeout) * time.Second
-		proxyClientLock.Lock()
-		proxyClients[proxyURL] = client
-		proxyClientLock.Unlock()
-		return client, nil
+		transport.Proxy = http.ProxyURL(parsedURL)
+		return transport, nil
 
 	case "socks5", "socks5h":
 		// 获取认证信息
@@ -143,27 +169,63 @@ func NewProxyHttpClient(proxyURL string) (*http.Client, error) {
 		if err != nil {
 			return nil, err
 		}
-
-		transport := &http.Transport{
-			MaxIdleConns:        common.RelayMaxIdleConns,
-			MaxIdleConnsPerHost: common.RelayMaxIdleConnsPerHost,
-			ForceAttemptHTTP2:   true,
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return dialer.Dial(network, addr)
-			},
-		}
-		if common.TLSInsecureSkipVerify {
-			transport.TLSClientConfig = common.InsecureTLSConfig
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.Dial(network, addr)
 		}
+		return transport, nil
 
-		client := &http.Client{Transport: transport, CheckRedirect: checkRedirect}
-		client.Timeout = time.Duration(common.RelayTimeout) * time.Second
-		proxyClientLock.Lock()
-		proxyClients[proxyURL] = client
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s, must be http, https, socks5 or socks5h", parsedURL.Scheme)
+	}
+}
+
+// GetChannelTLSConfig 返回渠道的 mTLS / 自定义 CA 配置，未配置时返回 nil
+func GetChannelTLSConfig(setting dto.ChannelSettings) (*tls.Config, error) {
+	if !setting.HasCustomTLS() {
+		return nil, nil
+	}
+	tlsConfig, err := common.BuildClientTLSConfig(setting.TLSClientCert, setting.TLSClientKey, setting.TLSCACert)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel tls settings: %w", err)
+	}
+	return tlsConfig, nil
+}
+
+// GetChannelHttpClient 按渠道设置返回 HTTP 客户端：未配置 mTLS 与自定义 CA 时与 GetHttpClientWithProxy 相同，
+// 否则按代理与证书组合缓存独立的客户端
+func GetChannelHttpClient(setting dto.ChannelSettings) (*http.Client, error) {
+	if !setting.HasCustomTLS() {
+		return GetHttpClientWithProxy(setting.Proxy)
+	}
+	cacheKey := hex.EncodeToString(common.Sha256Raw([]byte(strings.Join([]string{
+		setting.Proxy, setting.TLSClientCert, setting.TLSClientKey, setting.TLSCACert,
+	}, "\x00"))))
+
+	proxyClientLock.Lock()
+	if client, ok := tlsClients[cacheKey]; ok {
 		proxyClientLock.Unlock()
 		return client, nil
+	}
+	proxyClientLock.Unlock()
 
-	default:
-		return nil, fmt.Errorf("unsupported proxy scheme: %s, must be http, https, socks5 or socks5h", parsedURL.Scheme)
+	tlsConfig, err := GetChannelTLSConfig(setting)
+	if err != nil {
+		return nil, err
 	}
+	transport, err := newProxyTransport(setting.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: checkRedirect,
+	}
+	if common.RelayTimeout != 0 {
+		client.Timeout = time.Duration(common.RelayTimeout) * time.Second
+	}
+	proxyClientLock.Lock()
+	tlsClients[cacheKey] = client
+	proxyClientLock.Unlock()
+	return client, nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -50,6 +51,18 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
 
+	if relayInfo.ResponseCacheStatus != "" {
+		other["response_cache"] = relayInfo.ResponseCacheStatus
+		if relayInfo.ResponseCacheStatus == ResponseCacheHit {
+			other["response_cache_billing_ratio"] = operation_setting.GetResponseCacheSetting().HitBillingRatio
+		}
+	}
+
+	if len(relayInfo.GuardDetections) > 0 {
+		other["guard_profile"] = relayInfo.GuardProfileName
+		other["guard_detections"] = relayInfo.GuardDetections
+	}
+
 	isSystemPromptOverwritten := common.GetContextKeyBool(ctx, constant.ContextKeySystemPromptOverride)
 	if isSystemPromptOverwritten {
 		other["is_system_prompt_overwritten"] = true
@@ -68,15 +81,33 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		adminInfo["local_count_tokens"] = isLocalCountTokens
 	}
 
+	if attempts, ok := common.GetContextKey(ctx, constant.ContextKeyStreamFailoverAttempts); ok {
+		adminInfo["stream_failover_attempts"] = attempts
+	}
+
 	AppendChannelAffinityAdminInfo(ctx, adminInfo)
 
 	other["admin_info"] = adminInfo
 	appendRequestPath(ctx, relayInfo, other)
 	appendRequestConversionChain(relayInfo, other)
 	appendBillingInfo(relayInfo, other)
+	appendPriceTierInfo(relayInfo, other)
 	return other
 }
 
+// appendPriceTierInfo 记录阶梯计费命中的档位，price_tier 为 0 表示按基础价格计费
+func appendPriceTierInfo(relayInfo *relaycommon.RelayInfo, other map[string]interface{}) {
+	if relayInfo == nil || other == nil || len(relayInfo.PriceData.PriceTiers) == 0 {
+		return
+	}
+	priceData := relayInfo.PriceData
+	other["price_tier"] = priceData.PriceTier + 1
+	other["price_tier_prompt_tokens"] = priceData.PriceTierTokens
+	if priceData.PriceTier >= 0 && priceData.PriceTier < len(priceData.PriceTiers) {
+		other["price_tier_threshold"] = priceData.PriceTiers[priceData.PriceTier].Threshold
+	}
+}
+
 func appendBillingInfo(relayInfo *relaycommon.RelayInfo, other map[string]interface{}) {
 	if relayInfo == nil || other == nil {
 		return
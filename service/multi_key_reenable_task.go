@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const multiKeyReenableTickInterval = 1 * time.Minute
+
+var (
+	multiKeyReenableOnce    sync.Once
+	multiKeyReenableRunning atomic.Bool
+)
+
+// StartMultiKeyReenableTask 定期恢复额度感知模式下因周期额度用尽而被临时禁用的密钥
+func StartMultiKeyReenableTask() {
+	multiKeyReenableOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("multi-key reenable task started: tick=%s", multiKeyReenableTickInterval))
+			ticker := time.NewTicker(multiKeyReenableTickInterval)
+			defer ticker.Stop()
+
+			runMultiKeyReenableOnce()
+			for range ticker.C {
+				runMultiKeyReenableOnce()
+			}
+		})
+	})
+}
+
+func runMultiKeyReenableOnce() {
+	if !multiKeyReenableRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer multiKeyReenableRunning.Store(false)
+
+	ctx := context.Background()
+	n, err := model.ReenableDueChannelKeys()
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("multi-key reenable task failed: %v", err))
+	}
+	if n > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("multi-key reenable task: %d keys re-enabled", n))
+		model.InitChannelCache()
+	}
+}
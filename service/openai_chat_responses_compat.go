@@ -16,3 +16,7 @@ func ResponsesResponseToChatCompletionsResponse(resp *dto.OpenAIResponsesRespons
 func ExtractOutputTextFromResponses(resp *dto.OpenAIResponsesResponse) string {
 	return openaicompat.ExtractOutputTextFromResponses(resp)
 }
+
+func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest, history []dto.Message) (*dto.GeneralOpenAIRequest, []dto.Message, error) {
+	return openaicompat.ResponsesRequestToChatCompletionsRequest(req, history)
+}
@@ -0,0 +1,452 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/samber/lo"
+)
+
+const (
+	responsesStatusInProgress = "in_progress"
+	responsesStatusCompleted  = "completed"
+	responsesStatusIncomplete = "incomplete"
+	responsesStatusFailed     = "failed"
+)
+
+func responsesStatusRaw(status string) json.RawMessage {
+	raw, _ := common.Marshal(status)
+	return raw
+}
+
+func responsesItemIDSuffix(responseID string) string {
+	return strings.TrimPrefix(responseID, "resp_")
+}
+
+// ChatUsageToResponsesUsage 将 Chat Completions 的 usage 补齐为 Responses 的 input/output 字段
+func ChatUsageToResponsesUsage(usage *dto.Usage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	out := *usage
+	if usage.PromptTokens != 0 {
+		out.InputTokens = usage.PromptTokens
+	}
+	if usage.CompletionTokens != 0 {
+		out.OutputTokens = usage.CompletionTokens
+	}
+	if out.TotalTokens == 0 {
+		out.TotalTokens = out.InputTokens + out.OutputTokens
+	}
+	out.InputTokensDetails = &dto.InputTokenDetails{
+		CachedTokens: usage.PromptTokensDetails.CachedTokens,
+		AudioTokens:  usage.PromptTokensDetails.AudioTokens,
+		ImageTokens:  usage.PromptTokensDetails.ImageTokens,
+	}
+	return &out
+}
+
+func newResponsesResponse(id string, model string, createdAt int, status string, output []dto.ResponsesOutput, usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	if output == nil {
+		output = []dto.ResponsesOutput{}
+	}
+	return &dto.OpenAIResponsesResponse{
+		ID:                 id,
+		Object:             "response",
+		CreatedAt:          createdAt,
+		Status:             responsesStatusRaw(status),
+		Model:              model,
+		Output:             output,
+		ParallelToolCalls:  true,
+		PreviousResponseID: json.RawMessage("null"),
+		Store:              true,
+		ToolChoice:         json.RawMessage(`"auto"`),
+		Tools:              []map[string]any{},
+		Truncation:         json.RawMessage(`"disabled"`),
+		Usage:              ChatUsageToResponsesUsage(usage),
+		User:               json.RawMessage("null"),
+		Metadata:           json.RawMessage("{}"),
+	}
+}
+
+func applyResponsesFinishReason(resp *dto.OpenAIResponsesResponse, finishReason string) {
+	if finishReason == "length" {
+		resp.Status = responsesStatusRaw(responsesStatusIncomplete)
+		resp.IncompleteDetails = &dto.IncompleteDetails{Reasoning: "max_output_tokens"}
+	}
+}
+
+func chatCreatedToInt(created any) int {
+	switch v := created.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	}
+	return int(time.Now().Unix())
+}
+
+// ChatCompletionsResponseToResponsesResponse 将非流式 Chat Completions 响应转换为 Responses 响应，
+// usage 为空时使用响应中的 usage
+func ChatCompletionsResponseToResponsesResponse(resp *dto.OpenAITextResponse, id string, usage *dto.Usage) (*dto.OpenAIResponsesResponse, error) {
+	if resp == nil {
+		return nil, errors.New("response is nil")
+	}
+	if usage == nil {
+		usage = &resp.Usage
+	}
+	suffix := responsesItemIDSuffix(id)
+	output := make([]dto.ResponsesOutput, 0, 2)
+	finishReason := ""
+	if len(resp.Choices) > 0 {
+		choice := resp.Choices[0]
+		finishReason = choice.FinishReason
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
+		}
+		if reasoning != "" {
+			output = append(output, dto.ResponsesOutput{
+				Type:    "reasoning",
+				ID:      "rs_" + suffix,
+				Summary: []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: reasoning}},
+			})
+		}
+		if text := choice.Message.StringContent(); text != "" {
+			output = append(output, dto.ResponsesOutput{
+				Type:    "message",
+				ID:      "msg_" + suffix,
+				Status:  responsesStatusCompleted,
+				Role:    "assistant",
+				Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: text, Annotations: []interface{}{}}},
+			})
+		}
+		for i, toolCall := range choice.Message.ParseToolCalls() {
+			output = append(output, dto.ResponsesOutput{
+				Type:      "function_call",
+				ID:        fmt.Sprintf("fc_%s_%d", suffix, i),
+				Status:    responsesStatusCompleted,
+				CallId:    toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			})
+		}
+	}
+	out := newResponsesResponse(id, resp.Model, chatCreatedToInt(resp.Created), responsesStatusCompleted, output, usage)
+	applyResponsesFinishReason(out, finishReason)
+	return out, nil
+}
+
+// ResponsesOutputToChatMessage 将 Responses 输出还原为一条 assistant 消息，用于 previous_response_id 续接
+func ResponsesOutputToChatMessage(resp *dto.OpenAIResponsesResponse) dto.Message {
+	msg := dto.Message{Role: "assistant", Content: ExtractOutputTextFromResponses(resp)}
+	if resp == nil {
+		return msg
+	}
+	var toolCalls []dto.ToolCallRequest
+	for _, out := range resp.Output {
+		switch out.Type {
+		case "reasoning":
+			for _, part := range out.Summary {
+				if msg.ReasoningContent != "" {
+					msg.ReasoningContent += "\n\n"
+				}
+				msg.ReasoningContent += part.Text
+			}
+		case "function_call":
+			toolCalls = append(toolCalls, dto.ToolCallRequest{
+				ID:       out.CallId,
+				Type:     "function",
+				Function: dto.FunctionRequest{Name: out.Name, Arguments: out.Arguments},
+			})
+		}
+	}
+	if len(toolCalls) > 0 {
+		msg.SetToolCalls(toolCalls)
+	}
+	return msg
+}
+
+type responsesStreamItem struct {
+	outputIndex int
+	item        dto.ResponsesOutput
+	text        strings.Builder
+	done        bool
+}
+
+// ChatToResponsesStreamConverter 将 Chat Completions 流式分片转换为 Responses 流式事件（response.*）
+type ChatToResponsesStreamConverter struct {
+	ID        string
+	Model     string
+	CreatedAt int
+
+	sequence     int
+	started      bool
+	finished     bool
+	items        []*responsesStreamItem
+	toolCalls    map[int]*responsesStreamItem
+	finishReason string
+	response     *dto.OpenAIResponsesResponse
+}
+
+func NewChatToResponsesStreamConverter(id string, model string) *ChatToResponsesStreamConverter {
+	return &ChatToResponsesStreamConverter{
+		ID:        id,
+		Model:     model,
+		CreatedAt: int(time.Now().Unix()),
+		toolCalls: make(map[int]*responsesStreamItem),
+	}
+}
+
+func (s *ChatToResponsesStreamConverter) event(event dto.ResponsesStreamResponse) dto.ResponsesStreamResponse {
+	event.SequenceNumber = s.sequence
+	s.sequence++
+	return event
+}
+
+func (s *ChatToResponsesStreamConverter) output() []dto.ResponsesOutput {
+	output := make([]dto.ResponsesOutput, 0, len(s.items))
+	for _, item := range s.items {
+		output = append(output, item.item)
+	}
+	return output
+}
+
+func (s *ChatToResponsesStreamConverter) start() []dto.ResponsesStreamResponse {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	resp := newResponsesResponse(s.ID, s.Model, s.CreatedAt, responsesStatusInProgress, nil, nil)
+	return []dto.ResponsesStreamResponse{
+		s.event(dto.ResponsesStreamResponse{Type: "response.created", Response: resp}),
+		s.event(dto.ResponsesStreamResponse{Type: "response.in_progress", Response: resp}),
+	}
+}
+
+func (s *ChatToResponsesStreamConverter) openItem(item dto.ResponsesOutput) (*responsesStreamItem, []dto.ResponsesStreamResponse) {
+	streamItem := &responsesStreamItem{outputIndex: len(s.items), item: item}
+	s.items = append(s.items, streamItem)
+	added := streamItem.item
+	events := []dto.ResponsesStreamResponse{
+		s.event(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: lo.ToPtr(streamItem.outputIndex), Item: &added}),
+	}
+	switch item.Type {
+	case "reasoning":
+		events = append(events, s.event(dto.ResponsesStreamResponse{
+			Type:         "response.reasoning_summary_part.added",
+			ItemID:       item.ID,
+			OutputIndex:  lo.ToPtr(streamItem.outputIndex),
+			SummaryIndex: lo.ToPtr(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "summary_text"},
+		}))
+	case "message":
+		events = append(events, s.event(dto.ResponsesStreamResponse{
+			Type:         "response.content_part.added",
+			ItemID:       item.ID,
+			OutputIndex:  lo.ToPtr(streamItem.outputIndex),
+			ContentIndex: lo.ToPtr(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "output_text"},
+		}))
+	}
+	return streamItem, events
+}
+
+func (s *ChatToResponsesStreamConverter) closeItem(streamItem *responsesStreamItem) []dto.ResponsesStreamResponse {
+	if streamItem.done {
+		return nil
+	}
+	streamItem.done = true
+	text := streamItem.text.String()
+	outputIndex := lo.ToPtr(streamItem.outputIndex)
+	var events []dto.ResponsesStreamResponse
+	switch streamItem.item.Type {
+	case "reasoning":
+		streamItem.item.Summary = []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: text}}
+		events = append(events,
+			s.event(dto.ResponsesStreamResponse{Type: "response.reasoning_summary_text.done", ItemID: streamItem.item.ID, OutputIndex: outputIndex, SummaryIndex: lo.ToPtr(0), Text: text}),
+			s.event(dto.ResponsesStreamResponse{Type: "response.reasoning_summary_part.done", ItemID: streamItem.item.ID, OutputIndex: outputIndex, SummaryIndex: lo.ToPtr(0), Part: &dto.ResponsesReasoningSummaryPart{Type: "summary_text", Text: text}}),
+		)
+	case "message":
+		streamItem.item.Status = responsesStatusCompleted
+		streamItem.item.Content = []dto.ResponsesOutputContent{{Type: "output_text", Text: text, Annotations: []interface{}{}}}
+		events = append(events,
+			s.event(dto.ResponsesStreamResponse{Type: "response.output_text.done", ItemID: streamItem.item.ID, OutputIndex: outputIndex, ContentIndex: lo.ToPtr(0), Text: text}),
+			s.event(dto.ResponsesStreamResponse{Type: "response.content_part.done", ItemID: streamItem.item.ID, OutputIndex: outputIndex, ContentIndex: lo.ToPtr(0), Part: &dto.ResponsesReasoningSummaryPart{Type: "output_text", Text: text}}),
+		)
+	case "function_call":
+		streamItem.item.Status = responsesStatusCompleted
+		streamItem.item.Arguments = text
+		events = append(events,
+			s.event(dto.ResponsesStreamResponse{Type: "response.function_call_arguments.done", ItemID: streamItem.item.ID, OutputIndex: outputIndex, Arguments: text}),
+		)
+	}
+	done := streamItem.item
+	events = append(events, s.event(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: outputIndex, Item: &done}))
+	return events
+}
+
+// closeOpenItems 关闭除 keepType 类型以外仍未结束的输出项
+func (s *ChatToResponsesStreamConverter) closeOpenItems(keepType string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	for _, item := range s.items {
+		if item.done || (keepType != "" && item.item.Type == keepType) {
+			continue
+		}
+		events = append(events, s.closeItem(item)...)
+	}
+	return events
+}
+
+// lastOpenItem 返回最后一个输出项，仅当其类型匹配且尚未结束
+func (s *ChatToResponsesStreamConverter) lastOpenItem(itemType string) *responsesStreamItem {
+	if len(s.items) == 0 {
+		return nil
+	}
+	last := s.items[len(s.items)-1]
+	if last.done || last.item.Type != itemType {
+		return nil
+	}
+	return last
+}
+
+func (s *ChatToResponsesStreamConverter) textDelta(itemType string, delta string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	streamItem := s.lastOpenItem(itemType)
+	if streamItem == nil {
+		events = append(events, s.closeOpenItems("")...)
+		suffix := responsesItemIDSuffix(s.ID)
+		var item dto.ResponsesOutput
+		if itemType == "reasoning" {
+			item = dto.ResponsesOutput{Type: "reasoning", ID: fmt.Sprintf("rs_%s_%d", suffix, len(s.items)), Summary: []dto.ResponsesReasoningSummaryPart{}}
+		} else {
+			item = dto.ResponsesOutput{Type: "message", ID: fmt.Sprintf("msg_%s_%d", suffix, len(s.items)), Status: responsesStatusInProgress, Role: "assistant", Content: []dto.ResponsesOutputContent{}}
+		}
+		var opened []dto.ResponsesStreamResponse
+		streamItem, opened = s.openItem(item)
+		events = append(events, opened...)
+	}
+	streamItem.text.WriteString(delta)
+	event := dto.ResponsesStreamResponse{ItemID: streamItem.item.ID, OutputIndex: lo.ToPtr(streamItem.outputIndex), Delta: delta}
+	if itemType == "reasoning" {
+		event.Type = "response.reasoning_summary_text.delta"
+		event.SummaryIndex = lo.ToPtr(0)
+	} else {
+		event.Type = "response.output_text.delta"
+		event.ContentIndex = lo.ToPtr(0)
+	}
+	return append(events, s.event(event))
+}
+
+func (s *ChatToResponsesStreamConverter) toolCallDelta(toolCall dto.ToolCallResponse, fallbackIndex int) []dto.ResponsesStreamResponse {
+	index := fallbackIndex
+	if toolCall.Index != nil {
+		index = *toolCall.Index
+	}
+	var events []dto.ResponsesStreamResponse
+	streamItem, ok := s.toolCalls[index]
+	if !ok || streamItem.done {
+		events = append(events, s.closeOpenItems("function_call")...)
+		callID := toolCall.ID
+		if callID == "" {
+			callID = fmt.Sprintf("call_%s_%d", responsesItemIDSuffix(s.ID), index)
+		}
+		var opened []dto.ResponsesStreamResponse
+		streamItem, opened = s.openItem(dto.ResponsesOutput{
+			Type:   "function_call",
+			ID:     fmt.Sprintf("fc_%s_%d", responsesItemIDSuffix(s.ID), len(s.items)),
+			Status: responsesStatusInProgress,
+			CallId: callID,
+			Name:   toolCall.Function.Name,
+		})
+		s.toolCalls[index] = streamItem
+		events = append(events, opened...)
+	} else if toolCall.Function.Name != "" && streamItem.item.Name == "" {
+		streamItem.item.Name = toolCall.Function.Name
+	}
+	if toolCall.Function.Arguments != "" {
+		streamItem.text.WriteString(toolCall.Function.Arguments)
+		events = append(events, s.event(dto.ResponsesStreamResponse{
+			Type:        "response.function_call_arguments.delta",
+			ItemID:      streamItem.item.ID,
+			OutputIndex: lo.ToPtr(streamItem.outputIndex),
+			Delta:       toolCall.Function.Arguments,
+		}))
+	}
+	return events
+}
+
+// Chunk 处理一个 Chat Completions 流式分片，返回需要发送的 Responses 事件
+func (s *ChatToResponsesStreamConverter) Chunk(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
+	if chunk == nil || s.finished {
+		return nil
+	}
+	if !s.started && chunk.Model != "" {
+		s.Model = chunk.Model
+	}
+	events := s.start()
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		reasoning := choice.Delta.GetReasoningContent()
+		if reasoning != "" {
+			events = append(events, s.textDelta("reasoning", reasoning)...)
+		}
+		if content := choice.Delta.GetContentString(); content != "" {
+			events = append(events, s.textDelta("message", content)...)
+		}
+		for i, toolCall := range choice.Delta.ToolCalls {
+			events = append(events, s.toolCallDelta(toolCall, i)...)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// Finish 关闭所有输出项并生成 response.completed（或 response.incomplete）事件
+func (s *ChatToResponsesStreamConverter) Finish(usage *dto.Usage) []dto.ResponsesStreamResponse {
+	if s.finished {
+		return nil
+	}
+	events := s.start()
+	events = append(events, s.closeOpenItems("")...)
+	s.finished = true
+	s.response = newResponsesResponse(s.ID, s.Model, s.CreatedAt, responsesStatusCompleted, s.output(), usage)
+	applyResponsesFinishReason(s.response, s.finishReason)
+	eventType := "response.completed"
+	if s.finishReason == "length" {
+		eventType = "response.incomplete"
+	}
+	return append(events, s.event(dto.ResponsesStreamResponse{Type: eventType, Response: s.response}))
+}
+
+// Fail 关闭所有输出项并生成 response.failed 事件
+func (s *ChatToResponsesStreamConverter) Fail(code string, message string) []dto.ResponsesStreamResponse {
+	if s.finished {
+		return nil
+	}
+	events := s.start()
+	events = append(events, s.closeOpenItems("")...)
+	s.finished = true
+	s.response = newResponsesResponse(s.ID, s.Model, s.CreatedAt, responsesStatusFailed, s.output(), nil)
+	s.response.Error = map[string]any{"code": code, "message": message}
+	return append(events, s.event(dto.ResponsesStreamResponse{Type: "response.failed", Response: s.response}))
+}
+
+// Response 返回 Finish 生成的完整响应
+func (s *ChatToResponsesStreamConverter) Response() *dto.OpenAIResponsesResponse {
+	return s.response
+}
@@ -0,0 +1,58 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaudeRequestToResponsesRequest(t *testing.T) {
+	var req dto.ClaudeRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "gpt-5-codex",
+		"max_tokens": 1024,
+		"stream": true,
+		"system": [{"type":"text","text":"you are helpful"}],
+		"thinking": {"type":"enabled","budget_tokens":2048},
+		"metadata": {"user_id":"session-1"},
+		"tools": [{"name":"get_weather","description":"weather","input_schema":{"type":"object"}},{"type":"bash_20250124","name":"bash"}],
+		"tool_choice": {"type":"any","disable_parallel_tool_use":true},
+		"messages": [
+			{"role":"user","content":[{"type":"text","text":"weather?"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}}]},
+			{"role":"assistant","content":[
+				{"type":"thinking","thinking":"need a tool","signature":"responses-reasoning:enc"},
+				{"type":"thinking","thinking":"foreign","signature":"sig-from-elsewhere"},
+				{"type":"text","text":"checking"},
+				{"type":"tool_use","id":"call_1","name":"get_weather","input":{"city":"Paris"}}
+			]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"call_1","content":[{"type":"text","text":"sunny"}]}]}
+		]
+	}`, &req))
+
+	out, err := ClaudeRequestToResponsesRequest(&req)
+	require.NoError(t, err)
+	require.JSONEq(t, `"you are helpful"`, string(out.Instructions))
+	require.Equal(t, uint(1024), lo.FromPtr(out.MaxOutputTokens))
+	require.Equal(t, "low", out.Reasoning.Effort)
+	require.JSONEq(t, `["reasoning.encrypted_content"]`, string(out.Include))
+	require.JSONEq(t, `"session-1"`, string(out.PromptCacheKey))
+	require.JSONEq(t, `"required"`, string(out.ToolChoice))
+	require.Equal(t, "false", string(out.ParallelToolCalls))
+	require.JSONEq(t, `[{"type":"function","name":"get_weather","description":"weather","parameters":{"type":"object"}}]`, string(out.Tools))
+
+	var input []map[string]any
+	require.NoError(t, json.Unmarshal(out.Input, &input))
+	require.Len(t, input, 5)
+	require.Equal(t, "user", input[0]["role"])
+	require.Equal(t, "data:image/png;base64,AAAA", input[0]["content"].([]any)[1].(map[string]any)["image_url"])
+	require.Equal(t, "reasoning", input[1]["type"])
+	require.Equal(t, "enc", input[1]["encrypted_content"])
+	require.Equal(t, "output_text", input[2]["content"].([]any)[0].(map[string]any)["type"])
+	require.Equal(t, "function_call", input[3]["type"])
+	require.JSONEq(t, `{"city":"Paris"}`, input[3]["arguments"].(string))
+	require.Equal(t, map[string]any{"type": "function_call_output", "call_id": "call_1", "output": "sunny"}, input[4])
+}
@@ -0,0 +1,310 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponsesReasoningSignaturePrefix 标记由 Responses reasoning 项转换而来的 thinking 签名，
+// 签名内容为上游的 encrypted_content；不带该前缀的签名来自其他上游，无法回传给 Responses 接口
+const ResponsesReasoningSignaturePrefix = "responses-reasoning:"
+
+// claudeThinkingBudgetToEffort 按 thinking 预算映射推理强度
+func claudeThinkingBudgetToEffort(budget int) string {
+	switch {
+	case budget <= 0:
+		return "medium"
+	case budget < 4096:
+		return "low"
+	case budget < 16384:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+func claudeReasoningEffort(req *dto.ClaudeRequest) string {
+	switch effort := req.GetEfforts(); effort {
+	case "low", "medium", "high":
+		return effort
+	case "max":
+		return "high"
+	}
+	if req.Thinking == nil {
+		return ""
+	}
+	switch req.Thinking.Type {
+	case "enabled":
+		return claudeThinkingBudgetToEffort(req.Thinking.GetBudgetTokens())
+	case "adaptive":
+		return "medium"
+	}
+	return ""
+}
+
+func claudeImageSourceToURL(source *dto.ClaudeMessageSource) string {
+	if source == nil {
+		return ""
+	}
+	if source.Type == "url" {
+		return source.Url
+	}
+	data := common.Interface2String(source.Data)
+	if data == "" {
+		return ""
+	}
+	return fmt.Sprintf("data:%s;base64,%s", source.MediaType, data)
+}
+
+func claudeToolResultOutput(block dto.ClaudeMediaMessage) string {
+	if block.Content == nil {
+		return ""
+	}
+	if block.IsStringContent() {
+		return block.GetStringContent()
+	}
+	var sb strings.Builder
+	for _, part := range block.ParseMediaContent() {
+		if part.Type != dto.ContentTypeText {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(part.GetText())
+	}
+	return sb.String()
+}
+
+func claudeSystemToInstructions(req *dto.ClaudeRequest) string {
+	if req.System == nil {
+		return ""
+	}
+	if req.IsStringSystem() {
+		return strings.TrimSpace(req.GetStringSystem())
+	}
+	parts := make([]string, 0)
+	for _, system := range req.ParseSystem() {
+		if text := strings.TrimSpace(system.GetText()); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// claudeMessagesToResponsesInput 将 Claude 消息转换为 Responses 输入项，
+// tool_use/tool_result/thinking 块会拆成独立的输入项，并保持与文本块的先后顺序
+func claudeMessagesToResponsesInput(messages []dto.ClaudeMessage) ([]map[string]any, error) {
+	inputItems := make([]map[string]any, 0, len(messages))
+	for _, msg := range messages {
+		role := msg.Role
+		if msg.IsStringContent() {
+			inputItems = append(inputItems, map[string]any{
+				"role":    role,
+				"content": msg.GetStringContent(),
+			})
+			continue
+		}
+		blocks, err := msg.ParseContent()
+		if err != nil {
+			return nil, err
+		}
+
+		contentParts := make([]map[string]any, 0, len(blocks))
+		flush := func() {
+			if len(contentParts) == 0 {
+				return
+			}
+			inputItems = append(inputItems, map[string]any{
+				"role":    role,
+				"content": contentParts,
+			})
+			contentParts = make([]map[string]any, 0)
+		}
+
+		for _, block := range blocks {
+			switch block.Type {
+			case dto.ContentTypeText:
+				textType := "input_text"
+				if role == "assistant" {
+					textType = "output_text"
+				}
+				contentParts = append(contentParts, map[string]any{
+					"type": textType,
+					"text": block.GetText(),
+				})
+			case "image":
+				if imageURL := claudeImageSourceToURL(block.Source); imageURL != "" {
+					contentParts = append(contentParts, map[string]any{
+						"type":      "input_image",
+						"image_url": imageURL,
+					})
+				}
+			case "document":
+				if block.Source != nil && block.Source.Type == "base64" {
+					contentParts = append(contentParts, map[string]any{
+						"type":      "input_file",
+						"filename":  "document.pdf",
+						"file_data": claudeImageSourceToURL(block.Source),
+					})
+				}
+			case "tool_use":
+				flush()
+				arguments, err := common.Marshal(block.Input)
+				if err != nil {
+					return nil, err
+				}
+				if block.Input == nil {
+					arguments = []byte("{}")
+				}
+				inputItems = append(inputItems, map[string]any{
+					"type":      "function_call",
+					"call_id":   block.Id,
+					"name":      block.Name,
+					"arguments": string(arguments),
+				})
+			case "tool_result":
+				flush()
+				inputItems = append(inputItems, map[string]any{
+					"type":    "function_call_output",
+					"call_id": block.ToolUseId,
+					"output":  claudeToolResultOutput(block),
+				})
+			case "thinking", "redacted_thinking":
+				// 只有本网关从 Responses 转换出的签名才能回传，其他上游的思考内容直接丢弃
+				encrypted, ok := strings.CutPrefix(block.Signature, ResponsesReasoningSignaturePrefix)
+				if !ok || encrypted == "" {
+					continue
+				}
+				flush()
+				summary := make([]map[string]any, 0, 1)
+				if block.Thinking != nil && *block.Thinking != "" {
+					summary = append(summary, map[string]any{
+						"type": "summary_text",
+						"text": *block.Thinking,
+					})
+				}
+				inputItems = append(inputItems, map[string]any{
+					"type":              "reasoning",
+					"summary":           summary,
+					"encrypted_content": encrypted,
+				})
+			}
+		}
+		flush()
+	}
+	return inputItems, nil
+}
+
+func claudeToolsToResponsesTools(tools any) []map[string]any {
+	claudeTools, _ := common.Any2Type[[]map[string]any](tools)
+	out := make([]map[string]any, 0, len(claudeTools))
+	for _, tool := range claudeTools {
+		toolType := common.Interface2String(tool["type"])
+		if strings.HasPrefix(toolType, "web_search") {
+			out = append(out, map[string]any{"type": "web_search"})
+			continue
+		}
+		// 其他 Claude 服务端工具在 Responses 接口中没有对应实现
+		if toolType != "" && toolType != "custom" {
+			continue
+		}
+		name := common.Interface2String(tool["name"])
+		if name == "" {
+			continue
+		}
+		out = append(out, map[string]any{
+			"type":        "function",
+			"name":        name,
+			"description": tool["description"],
+			"parameters":  tool["input_schema"],
+		})
+	}
+	return out
+}
+
+func claudeToolChoiceToResponses(toolChoice any) (json.RawMessage, json.RawMessage) {
+	if toolChoice == nil {
+		return nil, nil
+	}
+	choice, err := common.Any2Type[dto.ClaudeToolChoice](toolChoice)
+	if err != nil {
+		return nil, nil
+	}
+	var choiceRaw json.RawMessage
+	switch choice.Type {
+	case "auto", "none":
+		choiceRaw, _ = common.Marshal(choice.Type)
+	case "any":
+		choiceRaw, _ = common.Marshal("required")
+	case "tool":
+		choiceRaw, _ = common.Marshal(map[string]any{
+			"type": "function",
+			"name": choice.Name,
+		})
+	}
+	var parallelRaw json.RawMessage
+	if choice.DisableParallelToolUse {
+		parallelRaw = json.RawMessage("false")
+	}
+	return choiceRaw, parallelRaw
+}
+
+// ClaudeRequestToResponsesRequest 将 Claude Messages 请求转换为 Responses 请求：
+// system 转为 instructions，tool_use/tool_result 转为 function_call/function_call_output，
+// thinking 转为 reasoning 配置并要求上游返回 encrypted_content，以便后续轮次回传推理内容
+func ClaudeRequestToResponsesRequest(req *dto.ClaudeRequest) (*dto.OpenAIResponsesRequest, error) {
+	if req == nil {
+		return nil, errors.New("request is nil")
+	}
+	if req.Model == "" {
+		return nil, errors.New("model is required")
+	}
+
+	inputItems, err := claudeMessagesToResponsesInput(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	inputRaw, err := common.Marshal(inputItems)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dto.OpenAIResponsesRequest{
+		Model:       req.Model,
+		Input:       inputRaw,
+		Stream:      req.Stream,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+	}
+	if req.MaxTokens != nil {
+		out.MaxOutputTokens = common.GetPointer(*req.MaxTokens)
+	}
+	if instructions := claudeSystemToInstructions(req); instructions != "" {
+		out.Instructions, _ = common.Marshal(instructions)
+	}
+	if tools := claudeToolsToResponsesTools(req.Tools); len(tools) > 0 {
+		out.Tools, _ = common.Marshal(tools)
+		out.ToolChoice, out.ParallelToolCalls = claudeToolChoiceToResponses(req.ToolChoice)
+	}
+	if effort := claudeReasoningEffort(req); effort != "" {
+		out.Reasoning = &dto.Reasoning{
+			Effort:  effort,
+			Summary: "auto",
+		}
+		out.Include = json.RawMessage(`["reasoning.encrypted_content"]`)
+	}
+	// Claude Code 在 metadata.user_id 中携带会话标识，用作缓存键可提高上游提示缓存命中率
+	if len(req.Metadata) > 0 {
+		var metadata dto.ClaudeMetadata
+		if err := common.Unmarshal(req.Metadata, &metadata); err == nil && metadata.UserId != "" {
+			out.PromptCacheKey, _ = common.Marshal(metadata.UserId)
+		}
+	}
+	return out, nil
+}
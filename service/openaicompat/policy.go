@@ -1,6 +1,9 @@
 package openaicompat
 
-import "github.com/QuantumNous/new-api/setting/model_setting"
+import (
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+)
 
 func ShouldChatCompletionsUseResponsesPolicy(policy model_setting.ChatCompletionsToResponsesPolicy, channelID int, channelType int, model string) bool {
 	if !policy.IsChannelEnabled(channelID, channelType) {
@@ -17,3 +20,13 @@ func ShouldChatCompletionsUseResponsesGlobal(channelID int, channelType int, mod
 		model,
 	)
 }
+
+// ShouldResponsesUseChatCompletions 上游没有原生 Responses 接口的渠道，/v1/responses 请求经 Chat Completions 转换
+func ShouldResponsesUseChatCompletions(apiType int) bool {
+	switch apiType {
+	case constant.APITypeAnthropic, constant.APITypeGemini, constant.APITypeAws, constant.APITypeVertexAi,
+		constant.APITypeOllama, constant.APITypeDeepSeek:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesRequestToChatCompletionsRequest(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:        "claude-sonnet-4",
+		Instructions: json.RawMessage(`"be brief"`),
+		Input: json.RawMessage(`[
+			{"role":"user","content":[{"type":"input_text","text":"weather?"},{"type":"input_image","image_url":"https://example.com/a.png"}]},
+			{"type":"reasoning","summary":[{"type":"summary_text","text":"need a tool"}]},
+			{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"},
+			{"type":"function_call","call_id":"call_2","name":"get_time","arguments":"{}"},
+			{"type":"function_call_output","call_id":"call_1","output":"sunny"}
+		]`),
+		Tools:           json.RawMessage(`[{"type":"function","name":"get_weather","parameters":{"type":"object"}},{"type":"web_search_preview"}]`),
+		ToolChoice:      json.RawMessage(`{"type":"function","name":"get_weather"}`),
+		Text:            json.RawMessage(`{"format":{"type":"json_schema","name":"w","schema":{"type":"object"}}}`),
+		MaxOutputTokens: lo.ToPtr(uint(256)),
+		Stream:          lo.ToPtr(true),
+		Reasoning:       &dto.Reasoning{Effort: "high"},
+	}
+	history := []dto.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}
+
+	chatReq, conversation, err := ResponsesRequestToChatCompletionsRequest(req, history)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 6)
+	require.Equal(t, "system", chatReq.Messages[0].Role)
+	require.Equal(t, "be brief", chatReq.Messages[0].StringContent())
+	require.Equal(t, "hi", chatReq.Messages[1].StringContent())
+	require.Len(t, chatReq.Messages[3].ParseContent(), 2)
+
+	assistant := chatReq.Messages[4]
+	require.Equal(t, "assistant", assistant.Role)
+	require.Equal(t, "need a tool", assistant.ReasoningContent)
+	toolCalls := assistant.ParseToolCalls()
+	require.Len(t, toolCalls, 2)
+	require.Equal(t, "get_weather", toolCalls[0].Function.Name)
+	require.Equal(t, "call_2", toolCalls[1].ID)
+	require.Equal(t, "tool", chatReq.Messages[5].Role)
+	require.Equal(t, "call_1", chatReq.Messages[5].ToolCallId)
+
+	// conversation 不含 instructions
+	require.Len(t, conversation, 5)
+	require.Len(t, chatReq.Tools, 1)
+	require.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}}, chatReq.ToolChoice)
+	require.Equal(t, "json_schema", chatReq.ResponseFormat.Type)
+	require.JSONEq(t, `{"name":"w","schema":{"type":"object"}}`, string(chatReq.ResponseFormat.JsonSchema))
+	require.Equal(t, uint(256), *chatReq.MaxTokens)
+	require.True(t, chatReq.StreamOptions.IncludeUsage)
+	require.Equal(t, "high", chatReq.ReasoningEffort)
+}
+
+func TestChatToResponsesStreamConverter(t *testing.T) {
+	converter := NewChatToResponsesStreamConverter("resp_abc", "claude-sonnet-4")
+	chunk := func(delta dto.ChatCompletionsStreamResponseChoiceDelta, finish string) *dto.ChatCompletionsStreamResponse {
+		choice := dto.ChatCompletionsStreamResponseChoice{Delta: delta}
+		if finish != "" {
+			choice.FinishReason = lo.ToPtr(finish)
+		}
+		return &dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{choice}}
+	}
+
+	var events []dto.ResponsesStreamResponse
+	events = append(events, converter.Chunk(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{ReasoningContent: lo.ToPtr("think")}, ""))...)
+	events = append(events, converter.Chunk(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{Content: lo.ToPtr("Hel")}, ""))...)
+	events = append(events, converter.Chunk(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{Content: lo.ToPtr("lo")}, ""))...)
+	toolCall := dto.ToolCallResponse{ID: "call_1", Type: "function", Function: dto.FunctionResponse{Name: "get_weather", Arguments: `{"city"`}}
+	toolCall.SetIndex(0)
+	events = append(events, converter.Chunk(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{toolCall}}, ""))...)
+	toolCall = dto.ToolCallResponse{Function: dto.FunctionResponse{Arguments: `:"Paris"}`}}
+	toolCall.SetIndex(0)
+	events = append(events, converter.Chunk(chunk(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{toolCall}}, "tool_calls"))...)
+	events = append(events, converter.Finish(&dto.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15})...)
+
+	types := make([]string, 0, len(events))
+	for i, event := range events {
+		require.Equal(t, i, event.SequenceNumber)
+		types = append(types, event.Type)
+	}
+	require.Equal(t, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.reasoning_summary_part.added",
+		"response.reasoning_summary_text.delta",
+		"response.reasoning_summary_text.done",
+		"response.reasoning_summary_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.function_call_arguments.delta",
+		"response.function_call_arguments.delta",
+		"response.function_call_arguments.done",
+		"response.output_item.done",
+		"response.completed",
+	}, types)
+
+	resp := converter.Response()
+	require.Len(t, resp.Output, 3)
+	require.Equal(t, "Hello", resp.Output[1].Content[0].Text)
+	require.Equal(t, `{"city":"Paris"}`, resp.Output[2].Arguments)
+	require.Equal(t, 10, resp.Usage.InputTokens)
+	require.Equal(t, 5, resp.Usage.OutputTokens)
+
+	msg := ResponsesOutputToChatMessage(resp)
+	require.Equal(t, "Hello", msg.StringContent())
+	require.Equal(t, "think", msg.ReasoningContent)
+	require.Len(t, msg.ParseToolCalls(), 1)
+}
@@ -0,0 +1,447 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func responsesContentToChatContent(role string, content any) any {
+	switch v := content.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		parts := make([]dto.MediaContent, 0, len(v))
+		allText := true
+		for _, partAny := range v {
+			part, ok := partAny.(map[string]any)
+			if !ok {
+				continue
+			}
+			partType := common.Interface2String(part["type"])
+			switch partType {
+			case "input_text", "output_text", "text", "refusal":
+				text := common.Interface2String(part["text"])
+				if partType == "refusal" {
+					text = common.Interface2String(part["refusal"])
+				}
+				parts = append(parts, dto.MediaContent{Type: dto.ContentTypeText, Text: text})
+			case "input_image":
+				imageURL := common.Interface2String(part["image_url"])
+				if imageURL == "" {
+					if nested, ok := part["image_url"].(map[string]any); ok {
+						imageURL = common.Interface2String(nested["url"])
+					}
+				}
+				if imageURL == "" {
+					continue
+				}
+				allText = false
+				parts = append(parts, dto.MediaContent{
+					Type: dto.ContentTypeImageURL,
+					ImageUrl: &dto.MessageImageUrl{
+						Url:    imageURL,
+						Detail: common.Interface2String(part["detail"]),
+					},
+				})
+			case "input_file":
+				allText = false
+				file := &dto.MessageFile{
+					FileName: common.Interface2String(part["filename"]),
+					FileData: common.Interface2String(part["file_data"]),
+					FileId:   common.Interface2String(part["file_id"]),
+				}
+				if fileURL := common.Interface2String(part["file_url"]); fileURL != "" && file.FileData == "" {
+					file.FileData = fileURL
+				}
+				parts = append(parts, dto.MediaContent{Type: dto.ContentTypeFile, File: file})
+			case "input_audio":
+				allText = false
+				audio, _ := part["input_audio"].(map[string]any)
+				parts = append(parts, dto.MediaContent{
+					Type: dto.ContentTypeInputAudio,
+					InputAudio: &dto.MessageInputAudio{
+						Data:   common.Interface2String(audio["data"]),
+						Format: common.Interface2String(audio["format"]),
+					},
+				})
+			}
+		}
+		// assistant 历史消息多数上游只接受纯文本
+		if allText && (role == "assistant" || len(parts) == 1) {
+			var sb strings.Builder
+			for _, part := range parts {
+				sb.WriteString(part.Text)
+			}
+			return sb.String()
+		}
+		return parts
+	default:
+		b, _ := common.Marshal(v)
+		return string(b)
+	}
+}
+
+// setChatMessageContent 数组内容通过 SetMediaContent 设置，保证渠道转换时 ParseContent 可以直接读取
+func setChatMessageContent(msg *dto.Message, content any) {
+	if parts, ok := content.([]dto.MediaContent); ok {
+		msg.SetMediaContent(parts)
+		return
+	}
+	msg.Content = content
+}
+
+func responsesReasoningText(item map[string]any) string {
+	var sb strings.Builder
+	for _, key := range []string{"summary", "content"} {
+		parts, ok := item[key].([]any)
+		if !ok {
+			continue
+		}
+		for _, partAny := range parts {
+			part, ok := partAny.(map[string]any)
+			if !ok {
+				continue
+			}
+			if text := common.Interface2String(part["text"]); text != "" {
+				if sb.Len() > 0 {
+					sb.WriteString("\n\n")
+				}
+				sb.WriteString(text)
+			}
+		}
+		if sb.Len() > 0 {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// ResponsesInputToChatMessages 将 Responses API 的 input（字符串或输入项数组）转换为 Chat Completions 消息，
+// 连续的 function_call 合并到同一条 assistant 消息，reasoning 项作为下一条 assistant 消息的 reasoning_content
+func ResponsesInputToChatMessages(input json.RawMessage) ([]dto.Message, error) {
+	if len(input) == 0 {
+		return nil, nil
+	}
+	var parsed any
+	if err := common.Unmarshal(input, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	if text, ok := parsed.(string); ok {
+		return []dto.Message{{Role: "user", Content: text}}, nil
+	}
+	items, ok := parsed.([]any)
+	if !ok {
+		return nil, errors.New("input must be a string or an array of input items")
+	}
+
+	messages := make([]dto.Message, 0, len(items))
+	toolCallsByMessage := make(map[int][]dto.ToolCallRequest)
+	pendingReasoning := ""
+
+	lastAssistant := func() int {
+		if len(messages) == 0 {
+			return -1
+		}
+		last := len(messages) - 1
+		if messages[last].Role != "assistant" {
+			return -1
+		}
+		return last
+	}
+
+	for _, itemAny := range items {
+		item, ok := itemAny.(map[string]any)
+		if !ok {
+			continue
+		}
+		itemType := common.Interface2String(item["type"])
+		switch itemType {
+		case "", "message":
+			role := strings.TrimSpace(common.Interface2String(item["role"]))
+			if role == "" {
+				role = "user"
+			}
+			if role == "developer" {
+				role = "system"
+			}
+			msg := dto.Message{Role: role}
+			setChatMessageContent(&msg, responsesContentToChatContent(role, item["content"]))
+			if role == "assistant" && pendingReasoning != "" {
+				msg.ReasoningContent = pendingReasoning
+				pendingReasoning = ""
+			}
+			messages = append(messages, msg)
+		case "function_call":
+			callID := strings.TrimSpace(common.Interface2String(item["call_id"]))
+			if callID == "" {
+				callID = strings.TrimSpace(common.Interface2String(item["id"]))
+			}
+			name := strings.TrimSpace(common.Interface2String(item["name"]))
+			if callID == "" || name == "" {
+				continue
+			}
+			idx := lastAssistant()
+			if idx < 0 {
+				messages = append(messages, dto.Message{Role: "assistant", Content: ""})
+				idx = len(messages) - 1
+			}
+			if pendingReasoning != "" {
+				messages[idx].ReasoningContent = pendingReasoning
+				pendingReasoning = ""
+			}
+			toolCallsByMessage[idx] = append(toolCallsByMessage[idx], dto.ToolCallRequest{
+				ID:   callID,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      name,
+					Arguments: common.Interface2String(item["arguments"]),
+				},
+			})
+		case "function_call_output":
+			callID := strings.TrimSpace(common.Interface2String(item["call_id"]))
+			var output string
+			switch v := item["output"].(type) {
+			case string:
+				output = v
+			case nil:
+				output = ""
+			default:
+				if content, ok := responsesContentToChatContent("tool", v).(string); ok {
+					output = content
+				} else {
+					b, _ := common.Marshal(v)
+					output = string(b)
+				}
+			}
+			messages = append(messages, dto.Message{Role: "tool", ToolCallId: callID, Content: output})
+		case "reasoning":
+			if text := responsesReasoningText(item); text != "" {
+				pendingReasoning = text
+			}
+		default:
+			// item_reference、内置工具调用等无法在 Chat Completions 中表达的项直接忽略
+		}
+	}
+
+	for idx, toolCalls := range toolCallsByMessage {
+		messages[idx].SetToolCalls(toolCalls)
+	}
+	return messages, nil
+}
+
+func convertResponsesTextToChatResponseFormat(text json.RawMessage) *dto.ResponseFormat {
+	if len(text) == 0 {
+		return nil
+	}
+	var textConfig struct {
+		Format map[string]any `json:"format"`
+	}
+	if err := common.Unmarshal(text, &textConfig); err != nil || textConfig.Format == nil {
+		return nil
+	}
+	formatType := common.Interface2String(textConfig.Format["type"])
+	switch formatType {
+	case "", "text":
+		return nil
+	case "json_schema":
+		schema := make(map[string]any, len(textConfig.Format))
+		for key, value := range textConfig.Format {
+			if key == "type" {
+				continue
+			}
+			schema[key] = value
+		}
+		schemaRaw, _ := common.Marshal(schema)
+		return &dto.ResponseFormat{Type: formatType, JsonSchema: schemaRaw}
+	default:
+		return &dto.ResponseFormat{Type: formatType}
+	}
+}
+
+func convertResponsesToolsToChatTools(tools json.RawMessage) []dto.ToolCallRequest {
+	if len(tools) == 0 {
+		return nil
+	}
+	var toolsMap []map[string]any
+	if err := common.Unmarshal(tools, &toolsMap); err != nil {
+		return nil
+	}
+	chatTools := make([]dto.ToolCallRequest, 0, len(toolsMap))
+	for _, tool := range toolsMap {
+		// 仅 function 工具可以映射，web_search 等内置工具依赖 OpenAI 服务端执行
+		if common.Interface2String(tool["type"]) != "function" {
+			continue
+		}
+		name := common.Interface2String(tool["name"])
+		if name == "" {
+			continue
+		}
+		chatTools = append(chatTools, dto.ToolCallRequest{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        name,
+				Description: common.Interface2String(tool["description"]),
+				Parameters:  tool["parameters"],
+			},
+		})
+	}
+	return chatTools
+}
+
+func convertResponsesToolChoiceToChat(toolChoice json.RawMessage) any {
+	if len(toolChoice) == 0 {
+		return nil
+	}
+	var parsed any
+	if err := common.Unmarshal(toolChoice, &parsed); err != nil {
+		return nil
+	}
+	switch v := parsed.(type) {
+	case string:
+		return v
+	case map[string]any:
+		// Responses: {"type":"function","name":"..."}
+		// Chat: {"type":"function","function":{"name":"..."}}
+		if common.Interface2String(v["type"]) == "function" {
+			if name := common.Interface2String(v["name"]); name != "" {
+				return map[string]any{
+					"type":     "function",
+					"function": map[string]any{"name": name},
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ResponsesRequestToChatCompletionsRequest 将 Responses 请求转换为 Chat Completions 请求。
+// history 为 previous_response_id 对应的历史对话，会插在 instructions 之后、本次 input 之前；
+// 返回的 conversation 为 history 与本次 input 对应的消息（不含 instructions），用于保存对话。
+func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest, history []dto.Message) (*dto.GeneralOpenAIRequest, []dto.Message, error) {
+	if req == nil {
+		return nil, nil, errors.New("request is nil")
+	}
+	if req.Model == "" {
+		return nil, nil, errors.New("model is required")
+	}
+
+	inputMessages, err := ResponsesInputToChatMessages(req.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+	conversation := make([]dto.Message, 0, len(history)+len(inputMessages))
+	conversation = append(conversation, history...)
+	conversation = append(conversation, inputMessages...)
+	if len(conversation) == 0 {
+		return nil, nil, errors.New("input is required")
+	}
+
+	messages := make([]dto.Message, 0, len(conversation)+1)
+	if len(req.Instructions) > 0 && common.GetJsonType(req.Instructions) == "string" {
+		var instructions string
+		_ = common.Unmarshal(req.Instructions, &instructions)
+		if strings.TrimSpace(instructions) != "" {
+			messages = append(messages, dto.Message{Role: "system", Content: instructions})
+		}
+	}
+	messages = append(messages, conversation...)
+
+	out := &dto.GeneralOpenAIRequest{
+		Model:          req.Model,
+		Messages:       messages,
+		Stream:         req.Stream,
+		MaxTokens:      req.MaxOutputTokens,
+		Temperature:    req.Temperature,
+		TopP:           req.TopP,
+		Tools:          convertResponsesToolsToChatTools(req.Tools),
+		ToolChoice:     convertResponsesToolChoiceToChat(req.ToolChoice),
+		ResponseFormat: convertResponsesTextToChatResponseFormat(req.Text),
+		User:           req.User,
+	}
+	if req.Stream != nil && *req.Stream {
+		out.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	if len(req.ParallelToolCalls) > 0 {
+		var parallel bool
+		if err := common.Unmarshal(req.ParallelToolCalls, &parallel); err == nil {
+			out.ParallelTooCalls = &parallel
+		}
+	}
+	if req.Reasoning != nil && req.Reasoning.Effort != "" {
+		out.ReasoningEffort = req.Reasoning.Effort
+	}
+	return out, conversation, nil
+}
+
+func chatContentToResponsesContent(msg dto.Message) []map[string]any {
+	textType := "input_text"
+	if msg.Role == "assistant" {
+		textType = "output_text"
+	}
+	content := make([]map[string]any, 0, 1)
+	for _, part := range msg.ParseContent() {
+		switch part.Type {
+		case dto.ContentTypeText:
+			if part.Text != "" {
+				content = append(content, map[string]any{"type": textType, "text": part.Text})
+			}
+		case dto.ContentTypeImageURL:
+			if image := part.GetImageMedia(); image != nil {
+				content = append(content, map[string]any{"type": "input_image", "image_url": image.Url, "detail": image.Detail})
+			}
+		case dto.ContentTypeFile:
+			if file := part.GetFile(); file != nil {
+				content = append(content, map[string]any{"type": "input_file", "filename": file.FileName, "file_data": file.FileData, "file_id": file.FileId})
+			}
+		}
+	}
+	return content
+}
+
+// ChatMessagesToResponsesInputItems 将保存的对话还原为 Responses 输入项，用于 input_items 接口；
+// 项 id 由响应 id 与序号生成，保证多次查询结果一致
+func ChatMessagesToResponsesInputItems(messages []dto.Message, responseID string) []map[string]any {
+	suffix := responsesItemIDSuffix(responseID)
+	items := make([]map[string]any, 0, len(messages))
+	for i, msg := range messages {
+		switch msg.Role {
+		case "tool":
+			items = append(items, map[string]any{
+				"id":      fmt.Sprintf("fco_%s_%d", suffix, i),
+				"type":    "function_call_output",
+				"call_id": msg.ToolCallId,
+				"output":  msg.StringContent(),
+				"status":  responsesStatusCompleted,
+			})
+			continue
+		}
+		if content := chatContentToResponsesContent(msg); len(content) > 0 {
+			items = append(items, map[string]any{
+				"id":      fmt.Sprintf("msg_%s_%d", suffix, i),
+				"type":    "message",
+				"role":    msg.Role,
+				"content": content,
+				"status":  responsesStatusCompleted,
+			})
+		}
+		for j, toolCall := range msg.ParseToolCalls() {
+			items = append(items, map[string]any{
+				"id":        fmt.Sprintf("fc_%s_%d_%d", suffix, i, j),
+				"type":      "function_call",
+				"call_id":   toolCall.ID,
+				"name":      toolCall.Function.Name,
+				"arguments": toolCall.Function.Arguments,
+				"status":    responsesStatusCompleted,
+			})
+		}
+	}
+	return items
+}
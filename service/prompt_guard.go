@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// ApplyInputGuard 按令牌/分组的防护方案检查请求中的提示词：命中 block 规则时拒绝请求，
+// 命中 mask 规则时同时改写已解析的请求与缓存的请求体（透传模式下直接使用请求体）
+func ApplyInputGuard(c *gin.Context, info *relaycommon.RelayInfo, request dto.Request) *types.NewAPIError {
+	name, profile := operation_setting.GetPromptGuardSetting().ResolveProfile(info.UsingGroup, common.GetContextKeyString(c, constant.ContextKeyTokenGuardProfile))
+	if profile == nil {
+		return nil
+	}
+	info.GuardProfileName = name
+	info.GuardProfile = profile
+
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	if !gjson.ValidBytes(body) {
+		// multipart 等非 JSON 请求体不做检查
+		return nil
+	}
+
+	guarded, detections, err := relaycommon.ApplyPromptGuard(body, profile, relaycommon.GuardStageInput)
+	info.AddGuardDetections(detections)
+	if err != nil {
+		if blocked, ok := err.(*relaycommon.GuardBlockedError); ok {
+			logger.LogWarn(c, fmt.Sprintf("prompt guard blocked request, profile %s, rule %s", name, blocked.Rule))
+			return types.NewErrorWithStatusCode(blocked, types.ErrorCodePromptGuardBlocked, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+		return types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	if len(detections) > 0 {
+		logger.LogInfo(c, fmt.Sprintf("prompt guard detections: %s", formatGuardDetections(detections)))
+	}
+	if bytes.Equal(guarded, body) {
+		return nil
+	}
+
+	if err = common.Unmarshal(guarded, request); err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	newStorage, err := common.CreateBodyStorage(guarded)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
+	}
+	_ = storage.Close()
+	c.Set(common.KeyBodyStorage, newStorage)
+	return nil
+}
+
+func formatGuardDetections(detections []relaycommon.GuardDetection) string {
+	parts := make([]string, 0, len(detections))
+	for _, detection := range detections {
+		parts = append(parts, fmt.Sprintf("%s/%s:%s x%d", detection.Stage, detection.Rule, detection.Action, detection.Count))
+	}
+	return strings.Join(parts, ", ")
+}
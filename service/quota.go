@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"strings"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -13,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/setting/system_setting"
@@ -95,7 +95,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 		return err
 	}
 
-	token, err := model.GetTokenByKey(strings.TrimPrefix(relayInfo.TokenKey, "sk-"), false)
+	token, err := model.GetTokenByKeyHash(relayInfo.TokenKey, false)
 	if err != nil {
 		return err
 	}
@@ -211,8 +211,11 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	} else {
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		RecordConsumeUsage(ctx, relayInfo.ChannelId, modelName, relayInfo.UsingGroup, usage.InputTokens, usage.OutputTokens, quota)
 	}
 
+	SettleTokenRateLimit(ctx, totalTokens)
+
 	logModel := modelName
 	if extraContent != "" {
 		logContent += ", " + extraContent
@@ -226,6 +229,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		ModelName:        logModel,
 		TokenName:        tokenName,
 		Quota:            quota,
+		UpstreamCost:     CalculateUpstreamCost(relayInfo, relayInfo.PriceData, quota),
 		Content:          logContent,
 		TokenId:          relayInfo.TokenId,
 		UseTimeSeconds:   int(useTimeSeconds),
@@ -246,12 +250,18 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	modelName := relayInfo.OriginModelName
 
 	tokenName := ctx.GetString("token_name")
+	cacheTokens := usage.PromptTokensDetails.CachedTokens
+	// 阶梯价格按实际输入 token 数重新选择，Claude 的 input_tokens 不含缓存（OpenRouter 除外），需要加回
+	tierPromptTokens := promptTokens
+	if relayInfo.ChannelType != constant.ChannelTypeOpenRouter {
+		tierPromptTokens += cacheTokens + usage.PromptTokensDetails.CachedCreationTokens
+	}
+	relayInfo.PriceData.ApplyPriceTier(tierPromptTokens)
 	completionRatio := relayInfo.PriceData.CompletionRatio
 	modelRatio := relayInfo.PriceData.ModelRatio
 	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
 	modelPrice := relayInfo.PriceData.ModelPrice
 	cacheRatio := relayInfo.PriceData.CacheRatio
-	cacheTokens := usage.PromptTokensDetails.CachedTokens
 
 	cacheCreationRatio := relayInfo.PriceData.CacheCreationRatio
 	cacheCreationRatio5m := relayInfo.PriceData.CacheCreation5mRatio
@@ -308,8 +318,10 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	} else {
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		RecordConsumeUsage(ctx, relayInfo.ChannelId, modelName, relayInfo.UsingGroup, promptTokens, completionTokens, quota)
 	}
 
+	SettleTokenRateLimit(ctx, totalTokens)
 	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
@@ -327,6 +339,7 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		ModelName:        modelName,
 		TokenName:        tokenName,
 		Quota:            quota,
+		UpstreamCost:     CalculateUpstreamCost(relayInfo, relayInfo.PriceData, quota),
 		Content:          logContent,
 		TokenId:          relayInfo.TokenId,
 		UseTimeSeconds:   int(useTimeSeconds),
@@ -372,6 +385,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(relayInfo.OriginModelName))
 
+	relayInfo.PriceData.ApplyPriceTier(usage.PromptTokens)
 	modelRatio := relayInfo.PriceData.ModelRatio
 	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
 	modelPrice := relayInfo.PriceData.ModelPrice
@@ -414,8 +428,10 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	} else {
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		RecordConsumeUsage(ctx, relayInfo.ChannelId, relayInfo.OriginModelName, relayInfo.UsingGroup, usage.PromptTokens, usage.CompletionTokens, quota)
 	}
 
+	SettleTokenRateLimit(ctx, totalTokens)
 	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
@@ -433,6 +449,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 		ModelName:        logModel,
 		TokenName:        tokenName,
 		Quota:            quota,
+		UpstreamCost:     CalculateUpstreamCost(relayInfo, relayInfo.PriceData, quota),
 		Content:          logContent,
 		TokenId:          relayInfo.TokenId,
 		UseTimeSeconds:   int(useTimeSeconds),
@@ -452,7 +469,7 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	//if relayInfo.TokenUnlimited {
 	//	return nil
 	//}
-	token, err := model.GetTokenByKey(relayInfo.TokenKey, false)
+	token, err := model.GetTokenByKeyHash(relayInfo.TokenKey, false)
 	if err != nil {
 		return err
 	}
@@ -501,6 +518,7 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		if err != nil {
 			return err
 		}
+		postConsumeClientTokenQuota(relayInfo, quota)
 	}
 
 	if sendEmail {
@@ -512,6 +530,18 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 	return nil
 }
 
+// RecordConsumeUsage 记录一次消耗的用量统计：Prometheus 消耗指标以及多密钥渠道的密钥用量。
+// 与用户、渠道已用额度一同在结算时更新，不受消费日志开关影响。
+func RecordConsumeUsage(c *gin.Context, channelId int, modelName string, group string, promptTokens int, completionTokens int, quota int) {
+	metrics.AddConsumption(modelName, group, channelId, promptTokens, completionTokens, quota)
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		keyIndex := common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+		gopool.Go(func() {
+			model.RecordChannelKeyUsage(channelId, keyIndex, promptTokens+completionTokens, quota)
+		})
+	}
+}
+
 func checkAndSendQuotaNotify(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int) {
 	gopool.Go(func() {
 		userSetting := relayInfo.UserSetting
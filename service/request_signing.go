@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// RequestSignature 计算请求签名，规范字符串为 METHOD\nPATH?QUERY\nTIMESTAMP\nBODY
+func RequestSignature(signing *dto.RequestSigningSettings, method string, requestURI string, timestamp string, body []byte) string {
+	var newHash func() hash.Hash
+	switch signing.GetAlgorithm() {
+	case "sha512":
+		newHash = sha512.New
+	case "sha1":
+		newHash = sha1.New
+	default:
+		newHash = sha256.New
+	}
+	mac := hmac.New(newHash, []byte(signing.Secret))
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n"))
+	mac.Write(body)
+	sum := mac.Sum(nil)
+	if signing.GetEncoding() == "base64" {
+		return signing.SignaturePrefix + base64.StdEncoding.EncodeToString(sum)
+	}
+	return signing.SignaturePrefix + hex.EncodeToString(sum)
+}
+
+// SignRequest 为发往上游的请求添加 HMAC 签名头，读取后会恢复请求体以便继续发送
+func SignRequest(req *http.Request, signing *dto.RequestSigningSettings) error {
+	if !signing.IsEnabled() {
+		return nil
+	}
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(signing.GetTimestampHeader(), timestamp)
+	req.Header.Set(signing.GetSignatureHeader(), RequestSignature(signing, req.Method, req.URL.RequestURI(), timestamp, body))
+	if signing.KeyId != "" {
+		req.Header.Set(signing.GetKeyIdHeader(), signing.KeyId)
+	}
+	return nil
+}
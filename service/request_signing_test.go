@@ -0,0 +1,114 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignRequest_PreservesBody(t *testing.T) {
+	signing := &dto.RequestSigningSettings{
+		Enabled:         true,
+		Secret:          "s3cret",
+		Encoding:        "base64",
+		SignaturePrefix: "v1=",
+		KeyId:           "gateway-1",
+	}
+	req := httptest.NewRequest(http.MethodPost, "https://upstream.example/v1/chat/completions?trace=1", strings.NewReader(`{"model":"m"}`))
+	require.NoError(t, SignRequest(req, signing))
+
+	timestamp := req.Header.Get("X-Timestamp")
+	require.NotEmpty(t, timestamp)
+	require.Equal(t, "gateway-1", req.Header.Get("X-Key-Id"))
+	expected := RequestSignature(signing, http.MethodPost, "/v1/chat/completions?trace=1", timestamp, []byte(`{"model":"m"}`))
+	require.Equal(t, expected, req.Header.Get("X-Signature"))
+	require.True(t, strings.HasPrefix(expected, "v1="))
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"model":"m"}`, string(body))
+	require.EqualValues(t, len(body), req.ContentLength)
+}
+
+func generateTestCertificate(t *testing.T, isClient bool) (certPEM string, keyPEM string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "new-api-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if !isClient {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.DNSNames = []string{"localhost"}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM, cert
+}
+
+func TestGetChannelHttpClient_MutualTLS(t *testing.T) {
+	clientCertPEM, clientKeyPEM, clientCert := generateTestCertificate(t, true)
+	serverCertPEM, serverKeyPEM, _ := generateTestCertificate(t, false)
+	serverCert, err := tls.X509KeyPair([]byte(serverCertPEM), []byte(serverKeyPEM))
+	require.NoError(t, err)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	client, err := GetChannelHttpClient(dto.ChannelSettings{
+		TLSClientCert: clientCertPEM,
+		TLSClientKey:  clientKeyPEM,
+		TLSCACert:     serverCertPEM,
+	})
+	require.NoError(t, err)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "new-api-test", string(body))
+
+	// 未携带客户端证书时握手失败
+	client, err = GetChannelHttpClient(dto.ChannelSettings{TLSCACert: serverCertPEM})
+	require.NoError(t, err)
+	_, err = client.Get(server.URL)
+	require.Error(t, err)
+}
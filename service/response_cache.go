@@ -0,0 +1,312 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/hot"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	responseCacheNamespace = "new-api:response_cache:v1"
+
+	ResponseCacheHit  = "hit"
+	ResponseCacheMiss = "miss"
+)
+
+// ResponseCacheEntry 缓存的客户端响应，流式响应保存完整的 SSE 输出用于回放
+type ResponseCacheEntry struct {
+	StatusCode  int       `json:"status_code"`
+	ContentType string    `json:"content_type"`
+	Body        []byte    `json:"body"`
+	Usage       dto.Usage `json:"usage"`
+	CreatedAt   int64     `json:"created_at"`
+	ExpiresAt   int64     `json:"expires_at"`
+}
+
+type responseCacheStore interface {
+	Get(key string) (*ResponseCacheEntry, bool, error)
+	Set(key string, entry *ResponseCacheEntry, ttl time.Duration) error
+}
+
+type hybridResponseCacheStore struct {
+	cache    *cachex.HybridCache[ResponseCacheEntry]
+	capacity int
+	ttl      time.Duration
+}
+
+func (s *hybridResponseCacheStore) Get(key string) (*ResponseCacheEntry, bool, error) {
+	entry, found, err := s.cache.Get(key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return &entry, true, nil
+}
+
+func (s *hybridResponseCacheStore) Set(key string, entry *ResponseCacheEntry, ttl time.Duration) error {
+	return s.cache.SetWithTTL(key, *entry, ttl)
+}
+
+var (
+	hybridResponseCacheLock sync.Mutex
+	hybridResponseCache     *hybridResponseCacheStore
+
+	diskResponseCacheLock sync.Mutex
+	diskResponseCache     *diskResponseCacheStore
+)
+
+func getResponseCacheStore() responseCacheStore {
+	setting := operation_setting.GetResponseCacheSetting()
+	if setting.Backend == operation_setting.ResponseCacheBackendDisk {
+		diskResponseCacheLock.Lock()
+		defer diskResponseCacheLock.Unlock()
+		if diskResponseCache == nil || diskResponseCache.dir != setting.DiskPath {
+			diskResponseCache = newDiskResponseCacheStore(setting.DiskPath)
+		}
+		return diskResponseCache
+	}
+	capacity := setting.MaxEntries
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	ttl := responseCacheTTL(setting)
+	hybridResponseCacheLock.Lock()
+	defer hybridResponseCacheLock.Unlock()
+	// 内存缓存的容量与默认过期时间在创建时确定，配置变更后重建
+	if hybridResponseCache == nil || hybridResponseCache.capacity != capacity || hybridResponseCache.ttl != ttl {
+		if hybridResponseCache != nil {
+			hybridResponseCache.cache.StopJanitor()
+		}
+		hybridResponseCache = &hybridResponseCacheStore{
+			capacity: capacity,
+			ttl:      ttl,
+			cache: cachex.NewHybridCache[ResponseCacheEntry](cachex.HybridCacheConfig[ResponseCacheEntry]{
+				Namespace: cachex.Namespace(responseCacheNamespace),
+				Redis:     common.RDB,
+				RedisEnabled: func() bool {
+					return common.RedisEnabled && common.RDB != nil
+				},
+				RedisCodec: cachex.JSONCodec[ResponseCacheEntry]{},
+				Memory: func() *hot.HotCache[string, ResponseCacheEntry] {
+					return hot.NewHotCache[string, ResponseCacheEntry](hot.LRU, capacity).
+						WithTTL(ttl).
+						WithJanitor().
+						Build()
+				},
+			}),
+		}
+	}
+	return hybridResponseCache
+}
+
+func responseCacheTTL(setting *operation_setting.ResponseCacheSetting) time.Duration {
+	if setting.TTLSeconds <= 0 {
+		return time.Hour
+	}
+	return time.Duration(setting.TTLSeconds) * time.Second
+}
+
+// GetResponseCacheKey 返回请求的缓存键，requestBody 为发往上游的最终请求体（已完成格式转换与模型映射）；
+// 请求不可缓存时返回空字符串
+func GetResponseCacheKey(c *gin.Context, info *relaycommon.RelayInfo, requestBody []byte) string {
+	setting := operation_setting.GetResponseCacheSetting()
+	if info == nil || len(requestBody) == 0 || !setting.IsEnabledFor(info.UsingGroup, common.GetContextKeyBool(c, constant.ContextKeyTokenResponseCache)) {
+		return ""
+	}
+	switch info.RelayMode {
+	case relayconstant.RelayModeChatCompletions:
+		// 仅缓存确定性的对话请求
+		request, ok := info.Request.(*dto.GeneralOpenAIRequest)
+		if !ok {
+			return ""
+		}
+		if setting.ChatRequireZeroTemperature && (request.Temperature == nil || *request.Temperature != 0) {
+			return ""
+		}
+		if request.N != nil && *request.N > 1 {
+			return ""
+		}
+	case relayconstant.RelayModeEmbeddings, relayconstant.RelayModeRerank:
+	default:
+		return ""
+	}
+
+	// 重新序列化以忽略字段顺序与空白差异
+	normalized := requestBody
+	var parsed interface{}
+	if err := common.Unmarshal(requestBody, &parsed); err == nil {
+		if data, err := common.Marshal(parsed); err == nil {
+			normalized = data
+		}
+	}
+
+	var scope string
+	switch setting.Scope {
+	case operation_setting.ResponseCacheScopeGlobal:
+		scope = "global"
+	case operation_setting.ResponseCacheScopeToken:
+		scope = "token:" + strconv.Itoa(info.TokenId)
+	default:
+		scope = "user:" + strconv.Itoa(info.UserId)
+	}
+
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\x00%d\x00%d\x00%s\x00", scope, info.RelayMode, info.ApiType, info.UpstreamModelName)
+	_, _ = h.Write(normalized)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ServeResponseCache 命中缓存时直接向客户端回放响应，返回缓存时记录的用量
+func ServeResponseCache(c *gin.Context, info *relaycommon.RelayInfo, key string) (*dto.Usage, bool) {
+	entry, found, err := getResponseCacheStore().Get(key)
+	if err != nil {
+		logger.LogWarn(c, "failed to get response cache: "+err.Error())
+		return nil, false
+	}
+	if !found || entry == nil || (entry.ExpiresAt > 0 && entry.ExpiresAt < time.Now().Unix()) {
+		return nil, false
+	}
+
+	isStream := strings.HasPrefix(entry.ContentType, "text/event-stream")
+	header := c.Writer.Header()
+	header.Set("Content-Type", entry.ContentType)
+	header.Set("X-Cache", ResponseCacheHit)
+	if isStream {
+		header.Set("Cache-Control", "no-cache")
+	} else {
+		header.Set("Content-Length", strconv.Itoa(len(entry.Body)))
+	}
+	c.Writer.WriteHeader(entry.StatusCode)
+	if _, err := c.Writer.Write(entry.Body); err != nil {
+		logger.LogError(c, "failed to write cached response: "+err.Error())
+	}
+	c.Writer.Flush()
+
+	info.ResponseCacheStatus = ResponseCacheHit
+	info.IsStream = isStream
+	info.SetFirstResponseTime()
+	usage := entry.Usage
+	return &usage, true
+}
+
+// ResponseCacheRecorder 记录写往客户端的响应，请求成功后写入缓存
+type ResponseCacheRecorder struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	key      string
+	limit    int
+	body     bytes.Buffer
+	overflow bool
+}
+
+// StartResponseCacheRecorder 替换 c.Writer 以记录未命中缓存的响应，结束时需调用 Finish
+func StartResponseCacheRecorder(c *gin.Context, info *relaycommon.RelayInfo, key string) *ResponseCacheRecorder {
+	info.ResponseCacheStatus = ResponseCacheMiss
+	recorder := &ResponseCacheRecorder{
+		ResponseWriter: c.Writer,
+		c:              c,
+		key:            key,
+		limit:          operation_setting.GetResponseCacheSetting().MaxEntryBytes,
+	}
+	c.Writer = recorder
+	return recorder
+}
+
+func (w *ResponseCacheRecorder) markMiss() {
+	if !w.ResponseWriter.Written() {
+		w.ResponseWriter.Header().Set("X-Cache", ResponseCacheMiss)
+	}
+}
+
+func (w *ResponseCacheRecorder) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.limit > 0 && w.body.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+func (w *ResponseCacheRecorder) WriteHeader(code int) {
+	w.markMiss()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ResponseCacheRecorder) Write(data []byte) (int, error) {
+	w.markMiss()
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *ResponseCacheRecorder) WriteString(s string) (int, error) {
+	w.markMiss()
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *ResponseCacheRecorder) Flush() {
+	w.markMiss()
+	w.ResponseWriter.Flush()
+}
+
+// Finish 恢复原始 ResponseWriter；请求成功且响应完整时将其写入缓存
+func (w *ResponseCacheRecorder) Finish(usage *dto.Usage, success bool) {
+	if w == nil {
+		return
+	}
+	w.c.Writer = w.ResponseWriter
+	if !success || usage == nil || w.overflow || w.body.Len() == 0 || w.ResponseWriter.Status() != http.StatusOK {
+		return
+	}
+	setting := operation_setting.GetResponseCacheSetting()
+	ttl := responseCacheTTL(setting)
+	now := time.Now()
+	entry := &ResponseCacheEntry{
+		StatusCode:  http.StatusOK,
+		ContentType: w.ResponseWriter.Header().Get("Content-Type"),
+		Body:        bytes.Clone(w.body.Bytes()),
+		Usage:       *usage,
+		CreatedAt:   now.Unix(),
+		ExpiresAt:   now.Add(ttl).Unix(),
+	}
+	key := w.key
+	gopool.Go(func() {
+		if err := getResponseCacheStore().Set(key, entry, ttl); err != nil {
+			common.SysError("failed to set response cache: " + err.Error())
+		}
+	})
+}
+
+// ApplyResponseCacheHitBilling 按配置的命中计费比例调整命中缓存请求的额度
+func ApplyResponseCacheHitBilling(quota int) int {
+	ratio := operation_setting.GetResponseCacheSetting().HitBillingRatio
+	if ratio <= 0 {
+		return 0
+	}
+	if ratio >= 1 {
+		return quota
+	}
+	return int(decimal.NewFromInt(int64(quota)).Mul(decimal.NewFromFloat(ratio)).Round(0).IntPart())
+}
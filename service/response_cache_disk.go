@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const diskResponseCacheCleanupInterval = time.Minute
+
+// diskResponseCacheStore 以文件形式保存响应缓存，按 key 前两位分目录；
+// 过期条目在读取时删除，总大小超过上限时按写入时间淘汰
+type diskResponseCacheStore struct {
+	dir         string
+	lastCleanup atomic.Int64
+	cleaning    atomic.Bool
+}
+
+func newDiskResponseCacheStore(dir string) *diskResponseCacheStore {
+	return &diskResponseCacheStore{dir: dir}
+}
+
+func (s *diskResponseCacheStore) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(s.dir, key+".json")
+	}
+	return filepath.Join(s.dir, key[:2], key+".json")
+}
+
+func (s *diskResponseCacheStore) Get(key string) (entry *ResponseCacheEntry, found bool, err error) {
+	defer func() {
+		result := "miss"
+		switch {
+		case err != nil:
+			result = "error"
+		case found:
+			result = "hit"
+		}
+		metrics.IncCacheRequest("response_cache_disk", result)
+	}()
+
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	entry = &ResponseCacheEntry{}
+	if err = common.Unmarshal(data, entry); err != nil {
+		_ = os.Remove(s.path(key))
+		return nil, false, err
+	}
+	if entry.ExpiresAt > 0 && entry.ExpiresAt < time.Now().Unix() {
+		_ = os.Remove(s.path(key))
+		return nil, false, nil
+	}
+	return entry, true, nil
+}
+
+func (s *diskResponseCacheStore) Set(key string, entry *ResponseCacheEntry, ttl time.Duration) error {
+	data, err := common.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	target := s.path(key)
+	if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免并发读取到不完整的内容
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), target); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	s.maybeCleanup(ttl)
+	return nil
+}
+
+func (s *diskResponseCacheStore) maybeCleanup(ttl time.Duration) {
+	now := time.Now()
+	last := s.lastCleanup.Load()
+	if now.Sub(time.Unix(0, last)) < diskResponseCacheCleanupInterval || !s.lastCleanup.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	if !s.cleaning.CompareAndSwap(false, true) {
+		return
+	}
+	gopool.Go(func() {
+		defer s.cleaning.Store(false)
+		maxBytes := int64(operation_setting.GetResponseCacheSetting().MaxDiskMB) << 20
+		if err := s.cleanup(ttl, maxBytes, now); err != nil {
+			common.SysError("failed to clean up response cache: " + err.Error())
+		}
+	})
+}
+
+type diskResponseCacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// cleanup 删除超过 ttl 的文件，并在总大小超过 maxBytes（大于 0 时）时从最早写入的文件开始删除
+func (s *diskResponseCacheStore) cleanup(ttl time.Duration, maxBytes int64, now time.Time) error {
+	files := make([]diskResponseCacheFile, 0)
+	var total int64
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if ttl > 0 && now.Sub(info.ModTime()) > ttl {
+			_ = os.Remove(path)
+			return nil
+		}
+		files = append(files, diskResponseCacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil || maxBytes <= 0 || total <= maxBytes {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, file := range files {
+		if total <= maxBytes {
+			break
+		}
+		if os.Remove(file.path) == nil {
+			total -= file.size
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func withResponseCacheSetting(t *testing.T, modify func(setting *operation_setting.ResponseCacheSetting)) {
+	setting := operation_setting.GetResponseCacheSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+	setting.Enabled = true
+	setting.Groups = []string{"ci"}
+	setting.Backend = operation_setting.ResponseCacheBackendHybrid
+	setting.Scope = operation_setting.ResponseCacheScopeUser
+	setting.ChatRequireZeroTemperature = true
+	if modify != nil {
+		modify(setting)
+	}
+}
+
+func newResponseCacheTestInfo(temperature *float64) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		UserId:     7,
+		TokenId:    3,
+		UsingGroup: "ci",
+		RelayMode:  relayconstant.RelayModeChatCompletions,
+		Request:    &dto.GeneralOpenAIRequest{Model: "gpt-4o", Temperature: temperature},
+		ChannelMeta: &relaycommon.ChannelMeta{
+			UpstreamModelName: "gpt-4o",
+		},
+	}
+}
+
+func TestGetResponseCacheKey(t *testing.T) {
+	withResponseCacheSetting(t, nil)
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	zero := 0.0
+	warm := 0.7
+
+	info := newResponseCacheTestInfo(&zero)
+	key := GetResponseCacheKey(c, info, []byte(`{"model":"gpt-4o","temperature":0,"messages":[]}`))
+	require.NotEmpty(t, key)
+	// 字段顺序与空白不影响缓存键
+	require.Equal(t, key, GetResponseCacheKey(c, info, []byte(`{ "messages":[], "temperature":0, "model":"gpt-4o" }`)))
+
+	require.Empty(t, GetResponseCacheKey(c, newResponseCacheTestInfo(&warm), []byte(`{"temperature":0.7}`)))
+	require.Empty(t, GetResponseCacheKey(c, newResponseCacheTestInfo(nil), []byte(`{}`)))
+
+	// 不同用户不共享缓存
+	other := newResponseCacheTestInfo(&zero)
+	other.UserId = 8
+	require.NotEqual(t, key, GetResponseCacheKey(c, other, []byte(`{"model":"gpt-4o","temperature":0,"messages":[]}`)))
+
+	// 未开启缓存的分组需令牌级开关
+	other.UsingGroup = "default"
+	require.Empty(t, GetResponseCacheKey(c, other, []byte(`{}`)))
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, true)
+	require.NotEmpty(t, GetResponseCacheKey(c, other, []byte(`{}`)))
+}
+
+func TestResponseCacheRecordAndReplay(t *testing.T) {
+	withResponseCacheSetting(t, nil)
+	gin.SetMode(gin.TestMode)
+	zero := 0.0
+	body := []byte(`{"model":"gpt-4o","temperature":0,"stream":true,"messages":[{"role":"user","content":"replay"}]}`)
+	stream := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	info := newResponseCacheTestInfo(&zero)
+	key := GetResponseCacheKey(c, info, body)
+	_, hit := ServeResponseCache(c, info, key)
+	require.False(t, hit)
+
+	recorder := StartResponseCacheRecorder(c, info, key)
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("X-Cache", "HIT from upstream")
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.WriteString(stream)
+	recorder.Finish(&dto.Usage{PromptTokens: 5, CompletionTokens: 1, TotalTokens: 6}, true)
+	require.Equal(t, ResponseCacheMiss, w.Header().Get("X-Cache"))
+	require.Equal(t, ResponseCacheMiss, info.ResponseCacheStatus)
+
+	// 写入缓存是异步的
+	var usage *dto.Usage
+	replay := httptest.NewRecorder()
+	replayCtx, _ := gin.CreateTestContext(replay)
+	replayInfo := newResponseCacheTestInfo(&zero)
+	require.Eventually(t, func() bool {
+		usage, hit = ServeResponseCache(replayCtx, replayInfo, key)
+		return hit
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, 6, usage.TotalTokens)
+	require.Equal(t, ResponseCacheHit, replay.Header().Get("X-Cache"))
+	require.Equal(t, stream, replay.Body.String())
+	require.True(t, replayInfo.IsStream)
+	require.Equal(t, ResponseCacheHit, replayInfo.ResponseCacheStatus)
+}
+
+func TestApplyResponseCacheHitBilling(t *testing.T) {
+	withResponseCacheSetting(t, func(setting *operation_setting.ResponseCacheSetting) {
+		setting.HitBillingRatio = 0
+	})
+	require.Equal(t, 0, ApplyResponseCacheHitBilling(1000))
+	operation_setting.GetResponseCacheSetting().HitBillingRatio = 0.25
+	require.Equal(t, 250, ApplyResponseCacheHitBilling(1000))
+}
+
+func TestDiskResponseCacheStore(t *testing.T) {
+	store := newDiskResponseCacheStore(t.TempDir())
+	entry := &ResponseCacheEntry{StatusCode: http.StatusOK, ContentType: "application/json", Body: []byte(`{"ok":true}`), ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	require.NoError(t, store.Set("abcdef", entry, time.Hour))
+
+	got, found, err := store.Get("abcdef")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, entry.Body, got.Body)
+
+	expired := *entry
+	expired.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	require.NoError(t, store.Set("abcdeg", &expired, time.Hour))
+	_, found, err = store.Get("abcdeg")
+	require.NoError(t, err)
+	require.False(t, found)
+	_, err = os.Stat(filepath.Join(store.dir, "ab", "abcdeg.json"))
+	require.True(t, os.IsNotExist(err))
+
+	// 超过总大小上限时淘汰最早写入的条目
+	require.NoError(t, store.Set("ff0001", entry, time.Hour))
+	old := time.Now().Add(-time.Minute)
+	require.NoError(t, os.Chtimes(store.path("abcdef"), old, old))
+	info, err := os.Stat(store.path("ff0001"))
+	require.NoError(t, err)
+	require.NoError(t, store.cleanup(time.Hour, info.Size(), time.Now()))
+	_, found, _ = store.Get("abcdef")
+	require.False(t, found)
+	_, found, _ = store.Get("ff0001")
+	require.True(t, found)
+}
+
+func TestHybridResponseCacheRebuildsOnSettingChange(t *testing.T) {
+	withResponseCacheSetting(t, func(setting *operation_setting.ResponseCacheSetting) {
+		setting.MaxEntries = 100
+		setting.TTLSeconds = 60
+	})
+	store := getResponseCacheStore()
+	require.Same(t, store, getResponseCacheStore())
+
+	setting := operation_setting.GetResponseCacheSetting()
+	setting.MaxEntries = 200
+	rebuilt := getResponseCacheStore()
+	require.NotSame(t, store, rebuilt)
+	require.Equal(t, 200, rebuilt.(*hybridResponseCacheStore).capacity)
+
+	setting.TTLSeconds = 120
+	require.Equal(t, 120*time.Second, getResponseCacheStore().(*hybridResponseCacheStore).ttl)
+}
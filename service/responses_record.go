@@ -0,0 +1,185 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+var responsesEndedStatuses = []string{"completed", "failed", "incomplete", "cancelled"}
+
+func IsResponsesStatusEnded(status string) bool {
+	return common.StringsContains(responsesEndedStatuses, status)
+}
+
+// SaveResponsesRecord 记录响应由哪个渠道与 key 处理；conversation 与 resp 仅由网关转换生成的响应提供。
+// store=false 的响应上游不会保存，也就无法检索或续接，因此不记录
+func SaveResponsesRecord(c *gin.Context, info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest, conversation []dto.Message, resp *dto.OpenAIResponsesResponse) {
+	if info.ResponsesID == "" || request == nil || string(request.Store) == "false" {
+		return
+	}
+	background := string(request.Background) == "true"
+	record := &model.ResponsesRecord{
+		ResponseId: info.ResponsesID,
+		UserId:     info.UserId,
+		TokenId:    info.TokenId,
+		ChannelId:  info.ChannelId,
+		KeyIndex:   info.ChannelMultiKeyIndex,
+		Group:      info.UsingGroup,
+		ModelName:  info.OriginModelName,
+		Background: background,
+		Status:     info.ResponsesStatus,
+	}
+	// 后台响应创建时上游尚未返回用量，创建请求按零用量结算，完成后再按计费快照补扣
+	record.Settled = !background || IsResponsesStatusEnded(record.Status)
+	if !record.Settled {
+		record.Billing = model.ResponsesBillingContext{
+			BillingSource:   info.BillingSource,
+			SubscriptionId:  info.SubscriptionId,
+			ModelRatio:      info.PriceData.ModelRatio,
+			CompletionRatio: info.PriceData.CompletionRatio,
+			CacheRatio:      info.PriceData.CacheRatio,
+			GroupRatio:      info.PriceData.GroupRatioInfo.GroupRatio,
+			ModelPrice:      info.PriceData.ModelPrice,
+			UsePrice:        info.PriceData.UsePrice,
+		}
+	}
+	if resp != nil {
+		record.Local = true
+		record.Conversation = common.GetJsonString(conversation)
+		record.Response = common.GetJsonString(resp)
+	}
+	if err := record.Insert(); err != nil {
+		logger.LogError(c, "failed to save responses record: "+err.Error())
+	}
+}
+
+// LocalResponsesInputItems 返回网关转换生成的响应的输入项
+func LocalResponsesInputItems(record *model.ResponsesRecord) ([]map[string]any, error) {
+	var messages []dto.Message
+	if record.Conversation != "" {
+		if err := common.UnmarshalJsonStr(record.Conversation, &messages); err != nil {
+			return nil, err
+		}
+	}
+	return openaicompat.ChatMessagesToResponsesInputItems(messages, record.ResponseId), nil
+}
+
+// PinResponsesChannel 要求本次请求使用记录中的渠道与 key，SetupContextForSelectedChannel 会据此选择 key
+func PinResponsesChannel(c *gin.Context, record *model.ResponsesRecord) {
+	common.SetContextKey(c, constant.ContextKeyResponsesPinnedChannelId, record.ChannelId)
+	common.SetContextKey(c, constant.ContextKeyResponsesPinnedKeyIndex, record.KeyIndex)
+}
+
+// GetResponsesPinnedKeyIndex 返回该渠道被固定的 key 下标
+func GetResponsesPinnedKeyIndex(c *gin.Context, channelId int) (int, bool) {
+	pinnedChannelId, ok := common.GetContextKey(c, constant.ContextKeyResponsesPinnedChannelId)
+	if !ok || pinnedChannelId != channelId {
+		return 0, false
+	}
+	return common.GetContextKeyInt(c, constant.ContextKeyResponsesPinnedKeyIndex), true
+}
+
+// IsResponsesChannelPinned 续接的响应只存在于原上游，失败后重试其他渠道没有意义
+func IsResponsesChannelPinned(c *gin.Context) bool {
+	_, ok := common.GetContextKey(c, constant.ContextKeyResponsesPinnedChannelId)
+	return ok
+}
+
+func calculateResponsesQuota(billing model.ResponsesBillingContext, usage *dto.Usage) int {
+	dGroupRatio := decimal.NewFromFloat(billing.GroupRatio)
+	if billing.UsePrice {
+		return int(decimal.NewFromFloat(billing.ModelPrice).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).Mul(dGroupRatio).Round(0).IntPart())
+	}
+	if usage == nil || usage.InputTokens+usage.OutputTokens == 0 {
+		return 0
+	}
+	dCacheTokens := decimal.Zero
+	if usage.InputTokensDetails != nil {
+		dCacheTokens = decimal.NewFromInt(int64(usage.InputTokensDetails.CachedTokens))
+	}
+	promptQuota := decimal.NewFromInt(int64(usage.InputTokens)).Sub(dCacheTokens).
+		Add(dCacheTokens.Mul(decimal.NewFromFloat(billing.CacheRatio)))
+	completionQuota := decimal.NewFromInt(int64(usage.OutputTokens)).Mul(decimal.NewFromFloat(billing.CompletionRatio))
+	ratio := decimal.NewFromFloat(billing.ModelRatio).Mul(dGroupRatio)
+	quota := int(promptQuota.Add(completionQuota).Mul(ratio).Round(0).IntPart())
+	if !ratio.IsZero() && quota <= 0 {
+		quota = 1
+	}
+	return quota
+}
+
+// SyncResponsesRecordStatus 根据检索/取消返回的响应更新记录状态，
+// 后台响应首次进入终态时按创建时的计费快照扣费，每个响应只结算一次
+func SyncResponsesRecordStatus(c *gin.Context, record *model.ResponsesRecord, resp *dto.OpenAIResponsesResponse) {
+	status := resp.GetStatus()
+	if status == "" || (status == record.Status && record.Settled) {
+		return
+	}
+	settle := !record.Settled && IsResponsesStatusEnded(status)
+	settled, err := record.UpdateStatus(status, settle)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to update responses record %s: %s", record.ResponseId, err.Error()))
+		return
+	}
+	if !settled {
+		return
+	}
+	quota := calculateResponsesQuota(record.Billing, resp.Usage)
+	if quota <= 0 {
+		return
+	}
+
+	var fundingErr error
+	if record.Billing.BillingSource == BillingSourceSubscription && record.Billing.SubscriptionId > 0 {
+		fundingErr = model.PostConsumeUserSubscriptionDelta(record.Billing.SubscriptionId, int64(quota))
+	} else {
+		fundingErr = model.DecreaseUserQuota(record.UserId, quota)
+	}
+	if fundingErr != nil {
+		logger.LogError(c, fmt.Sprintf("后台响应 %s 结算扣费失败: %s", record.ResponseId, fundingErr.Error()))
+		return
+	}
+	if record.TokenId > 0 {
+		if tokenKey := resolveTokenKey(c, record.TokenId, record.ResponseId); tokenKey != "" {
+			if err := model.DecreaseTokenQuota(record.TokenId, tokenKey, quota); err != nil {
+				logger.LogWarn(c, fmt.Sprintf("后台响应 %s 扣减令牌额度失败: %s", record.ResponseId, err.Error()))
+			}
+		}
+	}
+	model.UpdateUserUsedQuotaAndRequestCount(record.UserId, quota)
+	model.UpdateChannelUsedQuota(record.ChannelId, quota)
+
+	other := map[string]interface{}{
+		"response_id":      record.ResponseId,
+		"model_ratio":      record.Billing.ModelRatio,
+		"completion_ratio": record.Billing.CompletionRatio,
+		"cache_ratio":      record.Billing.CacheRatio,
+		"group_ratio":      record.Billing.GroupRatio,
+		"model_price":      record.Billing.ModelPrice,
+	}
+	if resp.Usage != nil {
+		other["prompt_tokens"] = resp.Usage.InputTokens
+		other["completion_tokens"] = resp.Usage.OutputTokens
+	}
+	model.RecordTaskBillingLog(model.RecordTaskBillingLogParams{
+		UserId:    record.UserId,
+		LogType:   model.LogTypeConsume,
+		Content:   fmt.Sprintf("后台响应结算（%s）", status),
+		ChannelId: record.ChannelId,
+		ModelName: record.ModelName,
+		Quota:     quota,
+		TokenId:   record.TokenId,
+		Group:     record.Group,
+		Other:     other,
+	})
+}
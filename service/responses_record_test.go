@@ -0,0 +1,72 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncResponsesRecordStatus_SettlesBackgroundOnce(t *testing.T) {
+	truncate(t)
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM responses_records") })
+
+	const userID, tokenID, channelID = 1, 1, 1
+	seedUser(t, userID, 10000)
+	seedToken(t, tokenID, userID, "sk-responses", 10000)
+	seedChannel(t, channelID)
+
+	record := &model.ResponsesRecord{
+		ResponseId: "resp_bg",
+		UserId:     userID,
+		TokenId:    tokenID,
+		ChannelId:  channelID,
+		Group:      "default",
+		ModelName:  "gpt-5",
+		Background: true,
+		Status:     "queued",
+		Billing: model.ResponsesBillingContext{
+			BillingSource:   BillingSourceWallet,
+			ModelRatio:      2,
+			CompletionRatio: 4,
+			CacheRatio:      0.5,
+			GroupRatio:      1,
+		},
+	}
+	require.NoError(t, record.Insert())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/v1/responses/resp_bg", nil)
+
+	SyncResponsesRecordStatus(c, record, &dto.OpenAIResponsesResponse{Status: json.RawMessage(`"in_progress"`)})
+	require.Equal(t, 10000, getUserQuota(t, userID))
+	require.False(t, record.Settled)
+
+	completed := &dto.OpenAIResponsesResponse{
+		Status: json.RawMessage(`"completed"`),
+		Usage: &dto.Usage{
+			InputTokens:        100,
+			OutputTokens:       10,
+			InputTokensDetails: &dto.InputTokenDetails{CachedTokens: 40},
+		},
+	}
+	SyncResponsesRecordStatus(c, record, completed)
+	// (60 + 40*0.5 + 10*4) * 2 = 240
+	require.Equal(t, 10000-240, getUserQuota(t, userID))
+	require.Equal(t, 10000-240, getTokenRemainQuota(t, tokenID))
+
+	// 再次轮询不会重复扣费
+	stale, err := model.GetUserResponsesRecord(userID, "resp_bg")
+	require.NoError(t, err)
+	require.True(t, stale.Settled)
+	stale.Settled = false
+	SyncResponsesRecordStatus(c, stale, completed)
+	require.Equal(t, 10000-240, getUserQuota(t, userID))
+
+	_, err = model.GetUserResponsesRecord(userID+1, "resp_bg")
+	require.Error(t, err)
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/hot"
+	"gorm.io/gorm"
+)
+
+const responsesConversationNamespace = "new-api:responses_conversation:v1"
+
+var ErrResponsesConversationNotFound = errors.New("previous response not found")
+
+// ResponsesConversation 经 Chat Completions 转换的 Responses 请求保存的对话，用于 previous_response_id 续接
+type ResponsesConversation struct {
+	UserId   int           `json:"user_id"`
+	Messages []dto.Message `json:"messages"`
+}
+
+var (
+	responsesConversationOnce  sync.Once
+	responsesConversationCache *cachex.HybridCache[ResponsesConversation]
+	responsesConversationTTL   time.Duration
+)
+
+func getResponsesConversationCache() *cachex.HybridCache[ResponsesConversation] {
+	responsesConversationOnce.Do(func() {
+		responsesConversationTTL = time.Duration(common.GetEnvOrDefault("RESPONSES_CONVERSATION_TTL", 86400)) * time.Second
+		if responsesConversationTTL <= 0 {
+			responsesConversationTTL = 24 * time.Hour
+		}
+		capacity := common.GetEnvOrDefault("RESPONSES_CONVERSATION_CAPACITY", 10000)
+		responsesConversationCache = cachex.NewHybridCache[ResponsesConversation](cachex.HybridCacheConfig[ResponsesConversation]{
+			Namespace: cachex.Namespace(responsesConversationNamespace),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.JSONCodec[ResponsesConversation]{},
+			Memory: func() *hot.HotCache[string, ResponsesConversation] {
+				return hot.NewHotCache[string, ResponsesConversation](hot.LRU, capacity).
+					WithTTL(responsesConversationTTL).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return responsesConversationCache
+}
+
+// GetResponsesConversation 返回 previous_response_id 对应的历史对话，不属于该用户的对话视为不存在
+func GetResponsesConversation(userId int, responseID string) ([]dto.Message, error) {
+	conversation, found, err := getResponsesConversationCache().Get(responseID)
+	if err != nil {
+		return nil, err
+	}
+	if found && conversation.UserId == userId {
+		return conversation.Messages, nil
+	}
+	// 缓存过期后回退到持久化的响应记录
+	record, err := model.GetUserResponsesRecord(userId, responseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrResponsesConversationNotFound
+		}
+		return nil, err
+	}
+	if !record.Local {
+		return nil, ErrResponsesConversationNotFound
+	}
+	return responsesRecordConversation(record)
+}
+
+func responsesRecordConversation(record *model.ResponsesRecord) ([]dto.Message, error) {
+	var messages []dto.Message
+	if record.Conversation != "" {
+		if err := common.UnmarshalJsonStr(record.Conversation, &messages); err != nil {
+			return nil, err
+		}
+	}
+	if record.Response != "" {
+		var resp dto.OpenAIResponsesResponse
+		if err := common.UnmarshalJsonStr(record.Response, &resp); err != nil {
+			return nil, err
+		}
+		messages = append(messages, openaicompat.ResponsesOutputToChatMessage(&resp))
+	}
+	return messages, nil
+}
+
+func DeleteResponsesConversation(responseID string) error {
+	_, err := getResponsesConversationCache().DeleteMany([]string{responseID})
+	return err
+}
+
+// SaveResponsesConversation 保存本次请求的对话与模型输出
+func SaveResponsesConversation(userId int, responseID string, messages []dto.Message, resp *dto.OpenAIResponsesResponse) error {
+	if responseID == "" || resp == nil {
+		return nil
+	}
+	saved := make([]dto.Message, 0, len(messages)+1)
+	saved = append(saved, messages...)
+	saved = append(saved, openaicompat.ResponsesOutputToChatMessage(resp))
+	cache := getResponsesConversationCache()
+	return cache.SetWithTTL(responseID, ResponsesConversation{UserId: userId, Messages: saved}, responsesConversationTTL)
+}
+
+func NewResponsesID() string {
+	return "resp_" + strings.ReplaceAll(common.GetUUID(), "-", "")
+}
+
+// ResponsesChatWriter 将渠道按 Chat Completions 格式写出的响应转换为 Responses 格式：
+// 流式响应逐个转换 SSE 分片，非流式响应在 Finish 时整体转换
+type ResponsesChatWriter struct {
+	gin.ResponseWriter
+	c          *gin.Context
+	converter  *openaicompat.ChatToResponsesStreamConverter
+	statusCode int
+	stream     bool
+	decided    bool
+	buffer     bytes.Buffer
+}
+
+// StartResponsesChatWriter 替换 c.Writer，结束时需调用 Finish 或 Abort
+func StartResponsesChatWriter(c *gin.Context, info *relaycommon.RelayInfo, responseID string) *ResponsesChatWriter {
+	writer := &ResponsesChatWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		converter:      openaicompat.NewChatToResponsesStreamConverter(responseID, info.UpstreamModelName),
+		statusCode:     http.StatusOK,
+	}
+	c.Writer = writer
+	return writer
+}
+
+func (w *ResponsesChatWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	w.stream = strings.HasPrefix(w.ResponseWriter.Header().Get("Content-Type"), "text/event-stream")
+	if w.stream {
+		w.ResponseWriter.Header().Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.statusCode)
+	}
+}
+
+func (w *ResponsesChatWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.statusCode = code
+	}
+}
+
+func (w *ResponsesChatWriter) WriteHeaderNow() {}
+
+func (w *ResponsesChatWriter) Write(data []byte) (int, error) {
+	w.decide()
+	w.buffer.Write(data)
+	if w.stream {
+		w.drainEvents()
+	}
+	return len(data), nil
+}
+
+func (w *ResponsesChatWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ResponsesChatWriter) Flush() {
+	if w.stream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *ResponsesChatWriter) writeEvents(events []dto.ResponsesStreamResponse) {
+	for _, event := range events {
+		data, err := common.Marshal(event)
+		if err != nil {
+			logger.LogError(w.c, "failed to marshal responses stream event: "+err.Error())
+			continue
+		}
+		_, _ = w.ResponseWriter.WriteString(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data))
+	}
+}
+
+// drainEvents 处理缓冲区中完整的 SSE 事件，保留未结束的部分
+func (w *ResponsesChatWriter) drainEvents() {
+	for {
+		raw := w.buffer.Bytes()
+		end := bytes.Index(raw, []byte("\n\n"))
+		if end < 0 {
+			return
+		}
+		event := string(raw[:end])
+		w.buffer.Next(end + 2)
+		w.handleEvent(event)
+	}
+}
+
+func (w *ResponsesChatWriter) handleEvent(event string) {
+	for _, line := range strings.Split(event, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, ":") {
+			// 保活注释原样转发
+			_, _ = w.ResponseWriter.WriteString(line + "\n\n")
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+			logger.LogError(w.c, "failed to unmarshal chat stream chunk: "+err.Error())
+			continue
+		}
+		w.writeEvents(w.converter.Chunk(&chunk))
+	}
+}
+
+// Finish 恢复原始 ResponseWriter 并写出剩余的响应，返回转换后的完整 Responses 响应
+func (w *ResponsesChatWriter) Finish(usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	w.c.Writer = w.ResponseWriter
+	if w.stream {
+		w.drainEvents()
+		w.writeEvents(w.converter.Finish(usage))
+		w.ResponseWriter.Flush()
+		return w.converter.Response()
+	}
+
+	body := w.buffer.Bytes()
+	w.ResponseWriter.Header().Del("Content-Length")
+	if w.statusCode != http.StatusOK {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		_, _ = w.ResponseWriter.Write(body)
+		return nil
+	}
+	var chatResp dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &chatResp); err != nil {
+		logger.LogError(w.c, "failed to unmarshal chat response: "+err.Error())
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		_, _ = w.ResponseWriter.Write(body)
+		return nil
+	}
+	resp, err := openaicompat.ChatCompletionsResponseToResponsesResponse(&chatResp, w.converter.ID, usage)
+	if err != nil {
+		logger.LogError(w.c, "failed to convert chat response: "+err.Error())
+		return nil
+	}
+	w.c.JSON(http.StatusOK, resp)
+	return resp
+}
+
+// Abort 恢复原始 ResponseWriter 并丢弃尚未发送的内容，流式响应已开始时补发 response.failed 事件
+func (w *ResponsesChatWriter) Abort(err error) {
+	w.c.Writer = w.ResponseWriter
+	if !w.stream {
+		return
+	}
+	w.writeEvents(w.converter.Fail("server_error", err.Error()))
+	w.ResponseWriter.Flush()
+}
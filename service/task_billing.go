@@ -47,17 +47,19 @@ func LogTaskConsumption(c *gin.Context, info *relaycommon.RelayInfo) {
 		other["upstream_model_name"] = info.UpstreamModelName
 	}
 	model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
-		ChannelId: info.ChannelId,
-		ModelName: info.OriginModelName,
-		TokenName: tokenName,
-		Quota:     info.PriceData.Quota,
-		Content:   logContent,
-		TokenId:   info.TokenId,
-		Group:     info.UsingGroup,
-		Other:     other,
+		ChannelId:    info.ChannelId,
+		ModelName:    info.OriginModelName,
+		TokenName:    tokenName,
+		Quota:        info.PriceData.Quota,
+		Content:      logContent,
+		UpstreamCost: CalculateUpstreamCost(info, info.PriceData, info.PriceData.Quota),
+		TokenId:      info.TokenId,
+		Group:        info.UsingGroup,
+		Other:        other,
 	})
 	model.UpdateUserUsedQuotaAndRequestCount(info.UserId, info.PriceData.Quota)
 	model.UpdateChannelUsedQuota(info.ChannelId, info.PriceData.Quota)
+	RecordConsumeUsage(c, info.ChannelId, info.OriginModelName, info.UsingGroup, 0, 0, info.PriceData.Quota)
 }
 
 // ---------------------------------------------------------------------------
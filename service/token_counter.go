@@ -283,16 +283,10 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 				}
 				tkm += token
 			} else {
-				tkm += 520
+				tkm += estimateMediaToken(file.FileType)
 			}
-		case types.FileTypeAudio:
-			tkm += 256
-		case types.FileTypeVideo:
-			tkm += 4096 * 2
-		case types.FileTypeFile:
-			tkm += 4096
 		default:
-			tkm += 4096 // Default case for unknown file types
+			tkm += estimateMediaToken(file.FileType)
 		}
 	}
 
@@ -300,6 +294,34 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 	return tkm, nil
 }
 
+// estimateMediaToken 非 OpenAI 文本模型下媒体文件的固定估算值
+func estimateMediaToken(fileType types.FileType) int {
+	switch fileType {
+	case types.FileTypeImage:
+		return 520
+	case types.FileTypeAudio:
+		return 256
+	case types.FileTypeVideo:
+		return 4096 * 2
+	default:
+		return 4096 // 文件及未知类型
+	}
+}
+
+// EstimateClaudeInputTokens 本地估算 Claude Messages 请求的输入 token 数，
+// 用于上游不支持 count_tokens 时的 /v1/messages/count_tokens，不受 CountToken 开关影响
+func EstimateClaudeInputTokens(request *dto.ClaudeRequest) int {
+	meta := request.GetTokenCountMeta()
+	if meta == nil {
+		return 0
+	}
+	tkm := CountTextToken(meta.CombineText, request.Model)
+	for _, file := range meta.Files {
+		tkm += estimateMediaToken(file.FileType)
+	}
+	return tkm
+}
+
 func CountTokenRealtime(info *relaycommon.RelayInfo, request dto.RealtimeEvent, model string) (int, int, error) {
 	audioToken := 0
 	textToken := 0
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tokenRateLimitReservationKey = "token_rate_limit_reservation"
+
+// rateLimitScope 一个限流维度：令牌、用户或分组（分组限制与 ModelRequestRateLimitGroup 一致，按用户计数）
+type rateLimitScope struct {
+	label string
+	key   string
+	limit int
+	// bucket 预占计入的窗口桶，结算时修正同一个桶
+	bucket int64
+}
+
+type tokenRateLimitReservation struct {
+	scopes   []rateLimitScope
+	reserved int64
+	settled  bool
+}
+
+// RateLimitRejection 描述一次限流拒绝，用于生成 429 响应
+type RateLimitRejection struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func rateLimitGroup(c *gin.Context) string {
+	group := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
+	if group == "" {
+		group = common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	}
+	return group
+}
+
+func collectRateLimitScopes(c *gin.Context, kind string, tokenLimitKey, userLimitKey constant.ContextKey, groupLimit func(setting.TokenRateLimit) int) []rateLimitScope {
+	var scopes []rateLimitScope
+	userId := c.GetInt("id")
+	if limit := common.GetContextKeyInt(c, tokenLimitKey); limit > 0 {
+		scopes = append(scopes, rateLimitScope{
+			label: "令牌",
+			key:   fmt.Sprintf("%s:token:%d", kind, common.GetContextKeyInt(c, constant.ContextKeyTokenId)),
+			limit: limit,
+		})
+	}
+	if limit := common.GetContextKeyInt(c, userLimitKey); limit > 0 {
+		scopes = append(scopes, rateLimitScope{
+			label: "用户",
+			key:   fmt.Sprintf("%s:user:%d", kind, userId),
+			limit: limit,
+		})
+	}
+	group := rateLimitGroup(c)
+	if limits, ok := setting.GetGroupTokenRateLimit(group); ok {
+		if limit := groupLimit(limits); limit > 0 {
+			scopes = append(scopes, rateLimitScope{
+				label: "分组",
+				key:   fmt.Sprintf("%s:group:%s:%d", kind, group, userId),
+				limit: limit,
+			})
+		}
+	}
+	return scopes
+}
+
+func isClaudeRateLimitRequest(c *gin.Context) bool {
+	return c.Request != nil && c.Request.URL != nil && strings.HasPrefix(c.Request.URL.Path, "/v1/messages")
+}
+
+// setTokenRateLimitHeaders 写入 OpenAI（x-ratelimit-*）以及 Claude（anthropic-ratelimit-*）格式的限流响应头
+func setTokenRateLimitHeaders(c *gin.Context, result limiter.WindowResult) {
+	resetSeconds := int64(math.Ceil(result.ResetAfter.Seconds()))
+	c.Header("x-ratelimit-limit-tokens", strconv.FormatInt(result.Limit, 10))
+	c.Header("x-ratelimit-remaining-tokens", strconv.FormatInt(result.Remaining(), 10))
+	c.Header("x-ratelimit-reset-tokens", fmt.Sprintf("%ds", resetSeconds))
+	if isClaudeRateLimitRequest(c) {
+		c.Header("anthropic-ratelimit-tokens-limit", strconv.FormatInt(result.Limit, 10))
+		c.Header("anthropic-ratelimit-tokens-remaining", strconv.FormatInt(result.Remaining(), 10))
+		c.Header("anthropic-ratelimit-tokens-reset", time.Now().Add(result.ResetAfter).UTC().Format(time.RFC3339))
+	}
+}
+
+// SetRetryAfterHeader 写入 Retry-After（秒），至少为 1 秒
+func SetRetryAfterHeader(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.FormatInt(max(int64(math.Ceil(retryAfter.Seconds())), 1), 10))
+}
+
+// ReserveTokenRateLimit 使用预估的 token 数在令牌/用户/分组维度上预占 TPM 额度，
+// 实际用量在结算时通过 SettleTokenRateLimit 修正，请求失败时通过 ReleaseTokenRateLimit 归还。
+func ReserveTokenRateLimit(c *gin.Context, estimatedTokens int) *types.NewAPIError {
+	scopes := collectRateLimitScopes(c, "tpm", constant.ContextKeyTokenTpmLimit, constant.ContextKeyUserTpmLimit,
+		func(l setting.TokenRateLimit) int { return l.TPM })
+	if len(scopes) == 0 {
+		return nil
+	}
+	amount := int64(max(estimatedTokens, 1))
+
+	reserved := make([]rateLimitScope, 0, len(scopes))
+	var tightest *limiter.WindowResult
+	for _, scope := range scopes {
+		result, err := limiter.ReserveWindow(c, scope.key, int64(scope.limit), amount)
+		if err != nil {
+			// 限流存储异常时放行，避免影响正常请求
+			logger.LogError(c, fmt.Sprintf("tpm rate limit check failed: %s", err.Error()))
+			continue
+		}
+		if !result.Allowed {
+			for _, r := range reserved {
+				_ = limiter.AdjustWindow(c, r.key, r.bucket, -amount)
+			}
+			setTokenRateLimitHeaders(c, result)
+			SetRetryAfterHeader(c, result.ResetAfter)
+			message := fmt.Sprintf("您已达到%s每分钟令牌用量限制：%d tokens/min，已用 %d，本次预估 %d，请在 %d 秒后重试",
+				scope.label, scope.limit, result.Used, amount, int64(math.Ceil(result.ResetAfter.Seconds())))
+			if amount > int64(scope.limit) {
+				message = fmt.Sprintf("请求预估令牌数 %d 超过了%s每分钟令牌用量限制 %d tokens/min", amount, scope.label, scope.limit)
+			}
+			return types.NewErrorWithStatusCode(errors.New(message), types.ErrorCodeRateLimitExceeded, http.StatusTooManyRequests,
+				types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+		scope.bucket = result.Bucket
+		reserved = append(reserved, scope)
+		if tightest == nil || result.Remaining() < tightest.Remaining() {
+			tightest = &result
+		}
+	}
+	if tightest != nil {
+		setTokenRateLimitHeaders(c, *tightest)
+	}
+	c.Set(tokenRateLimitReservationKey, &tokenRateLimitReservation{scopes: reserved, reserved: amount})
+	return nil
+}
+
+// SettleTokenRateLimit 使用实际 token 用量（输入+输出）修正预占的 TPM 额度（包括渠道上游 TPM），重复调用只生效一次
+func SettleTokenRateLimit(c *gin.Context, actualTokens int) {
+	settleUpstreamTokenLimit(c, actualTokens)
+	value, ok := c.Get(tokenRateLimitReservationKey)
+	if !ok {
+		return
+	}
+	reservation, ok := value.(*tokenRateLimitReservation)
+	if !ok || reservation.settled {
+		return
+	}
+	reservation.settled = true
+	delta := int64(actualTokens) - reservation.reserved
+	if delta == 0 {
+		return
+	}
+	for _, scope := range reservation.scopes {
+		if err := limiter.AdjustWindow(c, scope.key, scope.bucket, delta); err != nil {
+			logger.LogError(c, fmt.Sprintf("tpm rate limit settle failed: %s", err.Error()))
+		}
+	}
+}
+
+// ReleaseTokenRateLimit 请求失败时归还预占的 TPM 额度
+func ReleaseTokenRateLimit(c *gin.Context) {
+	SettleTokenRateLimit(c, 0)
+}
+
+// AcquireConcurrencyLimit 在令牌/用户/分组维度上占用并发名额，成功时返回释放函数
+func AcquireConcurrencyLimit(c *gin.Context) (func(), *RateLimitRejection) {
+	scopes := collectRateLimitScopes(c, "concurrency", constant.ContextKeyTokenConcurrencyLimit, constant.ContextKeyUserConcurrencyLimit,
+		func(l setting.TokenRateLimit) int { return l.Concurrency })
+	acquired := make([]string, 0, len(scopes))
+	release := func() {
+		for _, key := range acquired {
+			limiter.ReleaseConcurrency(context.Background(), key)
+		}
+	}
+	for _, scope := range scopes {
+		allowed, current, err := limiter.AcquireConcurrency(c, scope.key, int64(scope.limit))
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("concurrency limit check failed: %s", err.Error()))
+			continue
+		}
+		if !allowed {
+			release()
+			return nil, &RateLimitRejection{
+				Message:    fmt.Sprintf("您已达到%s最大并发请求数限制：%d（当前 %d），请稍后重试", scope.label, scope.limit, current),
+				RetryAfter: time.Second,
+			}
+		}
+		acquired = append(acquired, scope.key)
+	}
+	return release, nil
+}
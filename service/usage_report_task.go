@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	usageReportTickInterval = 10 * time.Minute
+	// usageReportDelay 周期结束后延迟发送，等待数据看板缓存写入数据库
+	usageReportDelay = 1 * time.Hour
+)
+
+var (
+	usageReportOnce    sync.Once
+	usageReportRunning atomic.Bool
+	// 已处理的最近一个日 / 月周期的结束时间
+	usageReportDailyEnd   atomic.Int64
+	usageReportMonthlyEnd atomic.Int64
+)
+
+// lastCompletedUsageReportPeriod 返回扣除发送延迟后最近一个已结束的自然日或自然月 [start, end)
+func lastCompletedUsageReportPeriod(monthly bool, now time.Time) (start time.Time, end time.Time) {
+	ref := now.Add(-usageReportDelay)
+	if monthly {
+		end = time.Date(ref.Year(), ref.Month(), 1, 0, 0, 0, 0, ref.Location())
+		return end.AddDate(0, -1, 0), end
+	}
+	end = time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location())
+	return end.AddDate(0, 0, -1), end
+}
+
+// StartUsageReportTask 定期汇总数据看板数据并向超级管理员发送日报、月报。
+// 启动前已结束的周期不会补发。
+func StartUsageReportTask() {
+	usageReportOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		now := time.Now()
+		_, dailyEnd := lastCompletedUsageReportPeriod(false, now)
+		_, monthlyEnd := lastCompletedUsageReportPeriod(true, now)
+		usageReportDailyEnd.Store(dailyEnd.Unix())
+		usageReportMonthlyEnd.Store(monthlyEnd.Unix())
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("usage report task started: tick=%s", usageReportTickInterval))
+			ticker := time.NewTicker(usageReportTickInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				runUsageReportOnce()
+			}
+		})
+	})
+}
+
+func runUsageReportOnce() {
+	if !usageReportRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer usageReportRunning.Store(false)
+
+	setting := operation_setting.GetUsageReportSetting()
+	now := time.Now()
+	if start, end := lastCompletedUsageReportPeriod(false, now); end.Unix() > usageReportDailyEnd.Load() {
+		usageReportDailyEnd.Store(end.Unix())
+		if setting.DailyEnabled {
+			sendUsageReport("日报", start, end, setting.TopN)
+		}
+	}
+	if start, end := lastCompletedUsageReportPeriod(true, now); end.Unix() > usageReportMonthlyEnd.Load() {
+		usageReportMonthlyEnd.Store(end.Unix())
+		if setting.MonthlyEnabled {
+			sendUsageReport("月报", start, end, setting.TopN)
+		}
+	}
+}
+
+func sendUsageReport(kind string, start time.Time, end time.Time, topN int) {
+	ctx := context.Background()
+	if !common.DataExportEnabled {
+		logger.LogWarn(ctx, fmt.Sprintf("usage report (%s) skipped: data dashboard is disabled", kind))
+		return
+	}
+	content, err := buildUsageReportContent(start, end, topN)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("failed to build usage report (%s): %v", kind, err))
+		return
+	}
+	subject := fmt.Sprintf("用量%s：%s", kind, start.Format("2006-01-02"))
+	if kind == "月报" {
+		subject = fmt.Sprintf("用量%s：%s", kind, start.Format("2006-01"))
+	}
+	NotifyRootUser(dto.NotifyTypeUsageReport, subject, content)
+}
+
+func buildUsageReportContent(start time.Time, end time.Time, topN int) (string, error) {
+	if topN <= 0 {
+		topN = 10
+	}
+	total, err := model.SumQuotaData(start.Unix(), end.Unix())
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("统计周期：%s ~ %s\n", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04")))
+	sb.WriteString(fmt.Sprintf("请求次数：%d\nToken 用量：%d\n消耗额度：%s\n", total.Count, total.TokenUsed, logger.FormatQuota(int(total.Quota))))
+	sections := []struct {
+		title     string
+		dimension string
+	}{
+		{"用户", model.QuotaDataDimensionUser},
+		{"模型", model.QuotaDataDimensionModel},
+	}
+	for _, section := range sections {
+		summaries, err := model.GetQuotaDataSummary(start.Unix(), end.Unix(), section.dimension, topN)
+		if err != nil {
+			return "", err
+		}
+		if len(summaries) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%s用量 Top %d：\n", section.title, topN))
+		for i, summary := range summaries {
+			sb.WriteString(fmt.Sprintf("%d. %s：请求 %d 次，Token %d，额度 %s\n",
+				i+1, summary.Name, summary.Count, summary.TokenUsed, logger.FormatQuota(int(summary.Quota))))
+		}
+	}
+	return sb.String(), nil
+}
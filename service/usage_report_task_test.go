@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastCompletedUsageReportPeriod(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 30, 0, 0, time.UTC)
+	start, end := lastCompletedUsageReportPeriod(false, now)
+	require.Equal(t, time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC), start)
+	require.Equal(t, time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), end)
+	start, end = lastCompletedUsageReportPeriod(true, now)
+	require.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), start)
+	require.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), end)
+
+	// 超过发送延迟后，刚结束的周期才算完成
+	now = time.Date(2026, 3, 1, 1, 0, 0, 0, time.UTC)
+	start, end = lastCompletedUsageReportPeriod(false, now)
+	require.Equal(t, time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), start)
+	require.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), end)
+	start, end = lastCompletedUsageReportPeriod(true, now)
+	require.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), start)
+	require.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), end)
+}
@@ -0,0 +1,64 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	ChannelStrategyWeighted         = "weighted"
+	ChannelStrategyLeastLatency     = "least_latency"
+	ChannelStrategyLeastCost        = "least_cost"
+	ChannelStrategyLeastOutstanding = "least_outstanding"
+	ChannelStrategyConsistentHash   = "consistent_hash"
+)
+
+// ChannelSelectionSetting 同一优先级内的渠道选择策略，模型配置优先于分组配置，均未配置时使用默认策略
+type ChannelSelectionSetting struct {
+	DefaultStrategy string            `json:"default_strategy"`
+	GroupStrategies map[string]string `json:"group_strategies"`
+	ModelStrategies map[string]string `json:"model_strategies"`
+	// LatencyEWMAAlpha 延迟指数加权移动平均的平滑系数，越大越偏向最近的请求
+	LatencyEWMAAlpha float64 `json:"latency_ewma_alpha"`
+	// ScoreTolerancePercent 与最优得分相差不超过该比例的渠道视为同等，再按权重随机，避免流量全部集中到单个渠道
+	ScoreTolerancePercent float64 `json:"score_tolerance_percent"`
+	// StatsExpireSeconds 延迟统计超过该时间未更新则视为无数据，使长时间未被选中的渠道重新获得探测机会
+	StatsExpireSeconds int `json:"stats_expire_seconds"`
+	// FailurePenaltyMs 可归因于渠道的失败请求按该延迟（不低于实际耗时）计入延迟统计，使持续失败的渠道排到后面
+	FailurePenaltyMs int `json:"failure_penalty_ms"`
+	// ExplorationPercent 最低延迟策略下分配给无延迟数据渠道的请求比例，用于探测新渠道与统计已过期的渠道
+	ExplorationPercent float64 `json:"exploration_percent"`
+	// HashKeyHeader 一致性哈希使用的请求头，未配置或请求未携带时依次使用会话 ID、用户 ID
+	HashKeyHeader string `json:"hash_key_header"`
+}
+
+var channelSelectionSetting = ChannelSelectionSetting{
+	DefaultStrategy:       ChannelStrategyWeighted,
+	GroupStrategies:       map[string]string{},
+	ModelStrategies:       map[string]string{},
+	LatencyEWMAAlpha:      0.3,
+	ScoreTolerancePercent: 10,
+	StatsExpireSeconds:    300,
+	FailurePenaltyMs:      10000,
+	ExplorationPercent:    10,
+	HashKeyHeader:         "",
+}
+
+func init() {
+	config.GlobalConfig.Register("channel_selection_setting", &channelSelectionSetting)
+}
+
+func GetChannelSelectionSetting() *ChannelSelectionSetting {
+	return &channelSelectionSetting
+}
+
+// GetChannelStrategy 返回分组与模型对应的渠道选择策略
+func (s *ChannelSelectionSetting) GetChannelStrategy(group string, model string) string {
+	if strategy, ok := s.ModelStrategies[model]; ok && strategy != "" {
+		return strategy
+	}
+	if strategy, ok := s.GroupStrategies[group]; ok && strategy != "" {
+		return strategy
+	}
+	if s.DefaultStrategy != "" {
+		return s.DefaultStrategy
+	}
+	return ChannelStrategyWeighted
+}
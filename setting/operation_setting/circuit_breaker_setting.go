@@ -0,0 +1,38 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// CircuitBreakerSetting 渠道（及多密钥渠道的单个密钥）熔断配置，基于真实请求的滚动错误率与延迟
+type CircuitBreakerSetting struct {
+	Enabled bool `json:"enabled"`
+	// WindowSeconds 统计错误率的滚动窗口
+	WindowSeconds int `json:"window_seconds"`
+	// MinRequests 窗口内请求数达到该值后才会评估错误率
+	MinRequests int `json:"min_requests"`
+	// ErrorRatePercent 失败（含慢请求）占比达到该值时熔断
+	ErrorRatePercent float64 `json:"error_rate_percent"`
+	// SlowRequestSeconds 首字延迟超过该值的请求计为失败，0 表示不按延迟判定
+	SlowRequestSeconds float64 `json:"slow_request_seconds"`
+	// OpenSeconds 熔断后进入半开状态前的等待时间
+	OpenSeconds int `json:"open_seconds"`
+	// ProbeIntervalSeconds 半开状态下放行一个探测请求的间隔（多节点共享）
+	ProbeIntervalSeconds int `json:"probe_interval_seconds"`
+}
+
+var circuitBreakerSetting = CircuitBreakerSetting{
+	Enabled:              false,
+	WindowSeconds:        60,
+	MinRequests:          10,
+	ErrorRatePercent:     50,
+	SlowRequestSeconds:   0,
+	OpenSeconds:          30,
+	ProbeIntervalSeconds: 5,
+}
+
+func init() {
+	config.GlobalConfig.Register("circuit_breaker_setting", &circuitBreakerSetting)
+}
+
+func GetCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &circuitBreakerSetting
+}
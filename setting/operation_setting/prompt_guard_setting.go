@@ -0,0 +1,104 @@
+package operation_setting
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	GuardDetectorEmail      = "email"
+	GuardDetectorPhone      = "phone"
+	GuardDetectorCreditCard = "credit_card"
+	GuardDetectorAPIKey     = "api_key"
+	GuardDetectorNationalID = "national_id"
+	GuardDetectorRegex      = "regex"
+
+	GuardActionBlock = "block"
+	GuardActionMask  = "mask"
+	GuardActionLog   = "log"
+)
+
+// GuardRule 一条检测规则，Detector 为 regex 时使用 Pattern 作为自定义正则
+type GuardRule struct {
+	Name     string `json:"name"`
+	Detector string `json:"detector"`
+	Pattern  string `json:"pattern,omitempty"`
+	Action   string `json:"action"`
+}
+
+// GuardProfile 一组检测规则，CheckOutput 为 true 时同时检查模型输出（包括流式增量）
+type GuardProfile struct {
+	Rules       []GuardRule `json:"rules"`
+	CheckOutput bool        `json:"check_output"`
+}
+
+// PromptGuardSetting 输入/输出防护，分组未指定方案时使用默认方案，令牌指定的方案在此基础上追加规则
+type PromptGuardSetting struct {
+	Enabled        bool                    `json:"enabled"`
+	DefaultProfile string                  `json:"default_profile"`
+	GroupProfiles  map[string]string       `json:"group_profiles"`
+	Profiles       map[string]GuardProfile `json:"profiles"`
+}
+
+var promptGuardSetting = PromptGuardSetting{
+	Enabled:        false,
+	DefaultProfile: "",
+	GroupProfiles:  map[string]string{},
+	Profiles: map[string]GuardProfile{
+		"pii": {
+			Rules: []GuardRule{
+				{Name: "email", Detector: GuardDetectorEmail, Action: GuardActionMask},
+				{Name: "phone", Detector: GuardDetectorPhone, Action: GuardActionMask},
+				{Name: "credit_card", Detector: GuardDetectorCreditCard, Action: GuardActionMask},
+				{Name: "api_key", Detector: GuardDetectorAPIKey, Action: GuardActionBlock},
+				{Name: "national_id", Detector: GuardDetectorNationalID, Action: GuardActionMask},
+			},
+		},
+	},
+}
+
+func init() {
+	config.GlobalConfig.Register("prompt_guard_setting", &promptGuardSetting)
+}
+
+func GetPromptGuardSetting() *PromptGuardSetting {
+	return &promptGuardSetting
+}
+
+// HasProfile 方案是否存在，令牌只能引用管理员已配置的方案
+func (s *PromptGuardSetting) HasProfile(name string) bool {
+	_, ok := s.Profiles[name]
+	return ok
+}
+
+// ResolveProfile 返回请求生效的防护方案：以分组方案（未配置时为默认方案）为基础，
+// 令牌指定的方案只追加规则，不会替换或绕过分组与默认方案。未启用或没有任何规则时返回 nil
+func (s *PromptGuardSetting) ResolveProfile(group string, tokenProfile string) (string, *GuardProfile) {
+	if !s.Enabled {
+		return "", nil
+	}
+	baseName := s.GroupProfiles[group]
+	if baseName == "" {
+		baseName = s.DefaultProfile
+	}
+	var names []string
+	merged := GuardProfile{}
+	for _, name := range []string{baseName, tokenProfile} {
+		if name == "" || slices.Contains(names, name) {
+			continue
+		}
+		profile, ok := s.Profiles[name]
+		if !ok || len(profile.Rules) == 0 {
+			continue
+		}
+		names = append(names, name)
+		merged.Rules = append(merged.Rules, profile.Rules...)
+		merged.CheckOutput = merged.CheckOutput || profile.CheckOutput
+	}
+	if len(merged.Rules) == 0 {
+		return "", nil
+	}
+	return strings.Join(names, "+"), &merged
+}
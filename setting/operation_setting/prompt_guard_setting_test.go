@@ -0,0 +1,44 @@
+package operation_setting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveProfile_TokenProfileOnlyAddsRules(t *testing.T) {
+	s := &PromptGuardSetting{
+		Enabled:        true,
+		DefaultProfile: "pii",
+		GroupProfiles:  map[string]string{"vip": "strict"},
+		Profiles: map[string]GuardProfile{
+			"pii":    {Rules: []GuardRule{{Name: "email", Detector: GuardDetectorEmail, Action: GuardActionMask}}},
+			"strict": {Rules: []GuardRule{{Name: "api_key", Detector: GuardDetectorAPIKey, Action: GuardActionBlock}}, CheckOutput: true},
+			"empty":  {},
+		},
+	}
+
+	name, profile := s.ResolveProfile("default", "")
+	require.Equal(t, "pii", name)
+	require.Len(t, profile.Rules, 1)
+
+	// 未知或空方案不能关闭默认方案
+	for _, tokenProfile := range []string{"missing", "empty", "pii"} {
+		name, profile = s.ResolveProfile("default", tokenProfile)
+		require.Equal(t, "pii", name)
+		require.Len(t, profile.Rules, 1)
+	}
+
+	name, profile = s.ResolveProfile("vip", "pii")
+	require.Equal(t, "strict+pii", name)
+	require.Len(t, profile.Rules, 2)
+	require.True(t, profile.CheckOutput)
+
+	s.DefaultProfile = ""
+	name, profile = s.ResolveProfile("default", "")
+	require.Empty(t, name)
+	require.Nil(t, profile)
+
+	require.True(t, s.HasProfile("empty"))
+	require.False(t, s.HasProfile("missing"))
+}
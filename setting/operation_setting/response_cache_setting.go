@@ -0,0 +1,69 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	ResponseCacheBackendHybrid = "hybrid" // Redis 可用时使用 Redis，否则使用内存
+	ResponseCacheBackendDisk   = "disk"
+
+	ResponseCacheScopeToken  = "token"
+	ResponseCacheScopeUser   = "user"
+	ResponseCacheScopeGlobal = "global"
+)
+
+// ResponseCacheSetting 上游响应缓存（对话、Embeddings、Rerank），需按分组或令牌显式开启
+type ResponseCacheSetting struct {
+	Enabled bool `json:"enabled"`
+	// Groups 对其中的分组开启缓存；令牌开启了 response_cache_enabled 时不受此限制
+	Groups  []string `json:"groups"`
+	Backend string   `json:"backend"`
+	// DiskPath 磁盘缓存目录，仅 Backend 为 disk 时生效
+	DiskPath string `json:"disk_path"`
+	// Scope 缓存共享范围：token / user / global，避免不同用户间共享响应内容
+	Scope      string `json:"scope"`
+	TTLSeconds int    `json:"ttl_seconds"`
+	// MaxEntries 内存缓存最大条目数（修改后需重启生效）
+	MaxEntries int `json:"max_entries"`
+	// MaxEntryBytes 单条响应超过该大小时不缓存
+	MaxEntryBytes int `json:"max_entry_bytes"`
+	// MaxDiskMB 磁盘缓存总大小上限，超过后淘汰最早写入的条目
+	MaxDiskMB int `json:"max_disk_mb"`
+	// ChatRequireZeroTemperature 对话请求仅在 temperature 为 0 时缓存
+	ChatRequireZeroTemperature bool `json:"chat_require_zero_temperature"`
+	// HitBillingRatio 命中缓存时按原价的该比例计费，0 表示免费
+	HitBillingRatio float64 `json:"hit_billing_ratio"`
+}
+
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:                    false,
+	Groups:                     []string{},
+	Backend:                    ResponseCacheBackendHybrid,
+	DiskPath:                   "./data/response_cache",
+	Scope:                      ResponseCacheScopeUser,
+	TTLSeconds:                 3600,
+	MaxEntries:                 10000,
+	MaxEntryBytes:              1 << 20,
+	MaxDiskMB:                  1024,
+	ChatRequireZeroTemperature: true,
+	HitBillingRatio:            0,
+}
+
+func init() {
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// IsEnabledFor 分组是否开启了缓存，tokenEnabled 为令牌级开关
+func (s *ResponseCacheSetting) IsEnabledFor(group string, tokenEnabled bool) bool {
+	if !s.Enabled {
+		return false
+	}
+	return tokenEnabled || slices.Contains(s.Groups, group)
+}
@@ -0,0 +1,48 @@
+package operation_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// StreamFailoverSetting 流式请求首字前故障转移：在收到首个有效内容分片前缓冲上游响应，
+// 上游超时未出首字、提前断开或返回错误事件时换下一个渠道重试，客户端不会收到不完整的响应
+type StreamFailoverSetting struct {
+	Enabled bool `json:"enabled"`
+	// FirstTokenTimeoutSeconds 默认首字时限，0 表示不限时（仍会在首字前检测上游断开与错误事件）
+	FirstTokenTimeoutSeconds int `json:"first_token_timeout_seconds"`
+	// GroupTimeouts、ModelTimeouts 按分组、模型覆盖首字时限，模型配置优先于分组配置
+	GroupTimeouts map[string]int `json:"group_timeouts"`
+	ModelTimeouts map[string]int `json:"model_timeouts"`
+}
+
+var streamFailoverSetting = StreamFailoverSetting{
+	Enabled:                  false,
+	FirstTokenTimeoutSeconds: 30,
+	GroupTimeouts:            map[string]int{},
+	ModelTimeouts:            map[string]int{},
+}
+
+func init() {
+	config.GlobalConfig.Register("stream_failover_setting", &streamFailoverSetting)
+}
+
+func GetStreamFailoverSetting() *StreamFailoverSetting {
+	return &streamFailoverSetting
+}
+
+// GetFirstTokenTimeout 返回分组与模型对应的首字时限，0 表示不限时
+func (s *StreamFailoverSetting) GetFirstTokenTimeout(group string, model string) time.Duration {
+	seconds := s.FirstTokenTimeoutSeconds
+	if v, ok := s.GroupTimeouts[group]; ok {
+		seconds = v
+	}
+	if v, ok := s.ModelTimeouts[model]; ok {
+		seconds = v
+	}
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,33 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// UpstreamLimitSetting 渠道上游限制（并发 / RPM / TPM 在渠道设置中配置）的全局行为
+type UpstreamLimitSetting struct {
+	// QueueEnabled 所有候选渠道都已达到上游限制时，请求排队等待而不是直接失败
+	QueueEnabled bool `json:"queue_enabled"`
+	// QueueMaxWaiting 本节点同时排队的请求数上限，超过后直接失败
+	QueueMaxWaiting int `json:"queue_max_waiting"`
+	// QueueTimeoutSeconds 单个请求最长排队时间
+	QueueTimeoutSeconds int `json:"queue_timeout_seconds"`
+	// CooldownEnabled 根据上游返回的 Retry-After / x-ratelimit-reset-* 暂停使用对应密钥
+	CooldownEnabled bool `json:"cooldown_enabled"`
+	// MaxCooldownSeconds 单次冷却时间上限
+	MaxCooldownSeconds int `json:"max_cooldown_seconds"`
+}
+
+var upstreamLimitSetting = UpstreamLimitSetting{
+	QueueEnabled:        false,
+	QueueMaxWaiting:     100,
+	QueueTimeoutSeconds: 30,
+	CooldownEnabled:     true,
+	MaxCooldownSeconds:  300,
+}
+
+func init() {
+	config.GlobalConfig.Register("upstream_limit_setting", &upstreamLimitSetting)
+}
+
+func GetUpstreamLimitSetting() *UpstreamLimitSetting {
+	return &upstreamLimitSetting
+}
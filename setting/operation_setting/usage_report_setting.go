@@ -0,0 +1,27 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// UsageReportSetting 定期用量报告，汇总数据看板数据后通过超级管理员的通知方式（邮件、Webhook 等）发送
+type UsageReportSetting struct {
+	// DailyEnabled 每天发送前一天的用量报告
+	DailyEnabled bool `json:"daily_enabled"`
+	// MonthlyEnabled 每月 1 日发送上个月的用量报告
+	MonthlyEnabled bool `json:"monthly_enabled"`
+	// TopN 报告中按用户、模型分别列出的条目数
+	TopN int `json:"top_n"`
+}
+
+var usageReportSetting = UsageReportSetting{
+	DailyEnabled:   false,
+	MonthlyEnabled: false,
+	TopN:           10,
+}
+
+func init() {
+	config.GlobalConfig.Register("usage_report_setting", &usageReportSetting)
+}
+
+func GetUsageReportSetting() *UsageReportSetting {
+	return &usageReportSetting
+}
@@ -67,3 +67,57 @@ func CheckModelRequestRateLimitGroup(jsonStr string) error {
 
 	return nil
 }
+
+// TokenRateLimit 按令牌用量与并发数的限制，0 表示不限制
+type TokenRateLimit struct {
+	TPM         int `json:"tpm"`
+	Concurrency int `json:"concurrency"`
+}
+
+// ModelTokenRateLimitGroup 分组级别的 TPM / 并发限制
+var ModelTokenRateLimitGroup = map[string]TokenRateLimit{}
+var ModelTokenRateLimitMutex sync.RWMutex
+
+func ModelTokenRateLimitGroup2JSONString() string {
+	ModelTokenRateLimitMutex.RLock()
+	defer ModelTokenRateLimitMutex.RUnlock()
+
+	jsonBytes, err := json.Marshal(ModelTokenRateLimitGroup)
+	if err != nil {
+		common.SysLog("error marshalling token rate limit group: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelTokenRateLimitGroupByJSONString(jsonStr string) error {
+	limits := make(map[string]TokenRateLimit)
+	if err := json.Unmarshal([]byte(jsonStr), &limits); err != nil {
+		return err
+	}
+	ModelTokenRateLimitMutex.Lock()
+	defer ModelTokenRateLimitMutex.Unlock()
+	ModelTokenRateLimitGroup = limits
+	return nil
+}
+
+func GetGroupTokenRateLimit(group string) (TokenRateLimit, bool) {
+	ModelTokenRateLimitMutex.RLock()
+	defer ModelTokenRateLimitMutex.RUnlock()
+
+	limits, found := ModelTokenRateLimitGroup[group]
+	return limits, found
+}
+
+func CheckModelTokenRateLimitGroup(jsonStr string) error {
+	checkModelTokenRateLimitGroup := make(map[string]TokenRateLimit)
+	err := json.Unmarshal([]byte(jsonStr), &checkModelTokenRateLimitGroup)
+	if err != nil {
+		return err
+	}
+	for group, limits := range checkModelTokenRateLimitGroup {
+		if limits.TPM < 0 || limits.Concurrency < 0 {
+			return fmt.Errorf("group %s has negative token rate limit values: tpm=%d, concurrency=%d", group, limits.TPM, limits.Concurrency)
+		}
+	}
+	return nil
+}
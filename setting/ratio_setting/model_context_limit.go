@@ -60,8 +60,8 @@ func init() {
 }
 
 // lookupWithSuffixStrip tries exact match first, then progressively strips
-// trailing "-xxx" segments until a match is found. Returns 0 if no match.
-func lookupWithSuffixStrip(m *types.RWMap[string, int], name string) int {
+// trailing "-xxx" segments until a match is found. Returns the zero value if no match.
+func lookupWithSuffixStrip[V any](m *types.RWMap[string, V], name string) V {
 	if limit, ok := m.Get(name); ok {
 		return limit
 	}
@@ -76,7 +76,8 @@ func lookupWithSuffixStrip(m *types.RWMap[string, int], name string) int {
 			return limit
 		}
 	}
-	return 0
+	var zero V
+	return zero
 }
 
 // GetModelContextLimit returns the context window size for a model.
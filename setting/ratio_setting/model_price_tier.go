@@ -0,0 +1,85 @@
+package ratio_setting
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// 长上下文阶梯价格：输入超过阈值后整次请求按更高的倍率计费
+var defaultModelPriceTiers = map[string][]types.ModelPriceTier{
+	// $1.25 / $10 -> $2.50 / $15 per 1M tokens above 200k
+	"gemini-2.5-pro": {{Threshold: 200000, ModelRatio: 1.25, CompletionRatio: 6}},
+	// $3 / $15 -> $6 / $22.50 per 1M tokens above 200k
+	"claude-sonnet-4": {{Threshold: 200000, ModelRatio: 3, CompletionRatio: 3.75}},
+}
+
+var modelPriceTiersMap = types.NewRWMap[string, []types.ModelPriceTier]()
+
+func init() {
+	modelPriceTiersMap.AddAll(defaultModelPriceTiers)
+}
+
+// GetModelPriceTiers 返回模型的阶梯计费规则（按阈值升序），匹配方式与上下文长度限制一致：先精确匹配，再逐段去掉 "-xxx" 后缀
+func GetModelPriceTiers(name string) []types.ModelPriceTier {
+	return lookupWithSuffixStrip(modelPriceTiersMap, FormatMatchingModelName(name))
+}
+
+func ModelPriceTiers2JSONString() string {
+	return modelPriceTiersMap.MarshalJSONString()
+}
+
+func UpdateModelPriceTiersByJSONString(jsonStr string) error {
+	tiers, err := parseModelPriceTiers(jsonStr)
+	if err != nil {
+		return err
+	}
+	for _, modelTiers := range tiers {
+		sort.Slice(modelTiers, func(i, j int) bool {
+			return modelTiers[i].Threshold < modelTiers[j].Threshold
+		})
+	}
+	sorted, err := common.Marshal(tiers)
+	if err != nil {
+		return err
+	}
+	return types.LoadFromJsonStringWithCallback(modelPriceTiersMap, string(sorted), InvalidateExposedDataCache)
+}
+
+// CheckModelPriceTiers 校验阶梯价格配置，不修改当前配置
+func CheckModelPriceTiers(jsonStr string) error {
+	_, err := parseModelPriceTiers(jsonStr)
+	return err
+}
+
+func parseModelPriceTiers(jsonStr string) (map[string][]types.ModelPriceTier, error) {
+	tiers := make(map[string][]types.ModelPriceTier)
+	if err := common.Unmarshal([]byte(jsonStr), &tiers); err != nil {
+		return nil, err
+	}
+	for modelName, modelTiers := range tiers {
+		if err := validateModelPriceTiers(modelTiers); err != nil {
+			return nil, fmt.Errorf("模型 %s 的阶梯价格无效：%w", modelName, err)
+		}
+	}
+	return tiers, nil
+}
+
+func validateModelPriceTiers(tiers []types.ModelPriceTier) error {
+	thresholds := make(map[int]struct{}, len(tiers))
+	for _, tier := range tiers {
+		if tier.Threshold <= 0 {
+			return fmt.Errorf("threshold must be positive")
+		}
+		if _, ok := thresholds[tier.Threshold]; ok {
+			return fmt.Errorf("duplicate threshold %d", tier.Threshold)
+		}
+		thresholds[tier.Threshold] = struct{}{}
+		if tier.ModelRatio < 0 || tier.CompletionRatio < 0 || tier.CacheRatio < 0 || tier.CacheCreationRatio < 0 {
+			return fmt.Errorf("ratios must not be negative")
+		}
+	}
+	return nil
+}
@@ -657,6 +657,12 @@ func ImageRatio2JSONString() string {
 	return imageRatioMap.MarshalJSONString()
 }
 
+// CheckRatioJSONString 校验以模型名为键的倍率配置（图片、音频、缓存创建倍率等），不修改当前配置
+func CheckRatioJSONString(jsonStr string) error {
+	ratios := make(map[string]float64)
+	return common.Unmarshal([]byte(jsonStr), &ratios)
+}
+
 func UpdateImageRatioByJSONString(jsonStr string) error {
 	return types.LoadFromJsonString(imageRatioMap, jsonStr)
 }
@@ -26,6 +26,15 @@ type PriceData struct {
 	Quota                int // 按次计费的最终额度（MJ / Task）
 	QuotaToPreConsume    int // 按量计费的预消耗额度
 	GroupRatioInfo       GroupRatioInfo
+	// PriceTiers 模型的阶梯计费规则（按阈值升序），BasePrice 为未进入任何阶梯时的倍率，
+	// PriceTier 为当前适用的阶梯下标，-1 表示基础价格
+	PriceTiers []ModelPriceTier
+	BasePrice  ModelPriceTier
+	PriceTier  int
+	// PriceTierTokens 选择阶梯时使用的输入 token 数
+	PriceTierTokens int
+	// AppliedHiddenRatio 实际作用于用量的隐藏倍率（受上下文长度截断），未作用时为 0
+	AppliedHiddenRatio float64
 }
 
 func (p *PriceData) AddOtherRatio(key string, ratio float64) {
@@ -38,6 +47,30 @@ func (p *PriceData) AddOtherRatio(key string, ratio float64) {
 	p.OtherRatios[key] = ratio
 }
 
+// ApplyPriceTier 按实际输入 token 数重新选择阶梯并更新倍率，返回适用的阶梯是否发生变化
+func (p *PriceData) ApplyPriceTier(promptTokens int) bool {
+	if p.UsePrice || len(p.PriceTiers) == 0 {
+		return false
+	}
+	p.PriceTierTokens = promptTokens
+	idx, resolved := ResolvePriceTier(p.PriceTiers, p.BasePrice, promptTokens)
+	if idx == p.PriceTier {
+		return false
+	}
+	p.PriceTier = idx
+	p.ModelRatio = resolved.ModelRatio
+	p.CompletionRatio = resolved.CompletionRatio
+	p.CacheRatio = resolved.CacheRatio
+	// 5 分钟 / 1 小时缓存写入倍率按与基础缓存写入倍率的比例同步调整
+	if p.CacheCreationRatio != 0 {
+		scale := resolved.CacheCreationRatio / p.CacheCreationRatio
+		p.CacheCreation5mRatio *= scale
+		p.CacheCreation1hRatio *= scale
+	}
+	p.CacheCreationRatio = resolved.CacheCreationRatio
+	return true
+}
+
 func (p *PriceData) ToSetting() string {
 	return fmt.Sprintf("ModelPrice: %f, ModelRatio: %f, CompletionRatio: %f, CacheRatio: %f, GroupRatio: %f, UsePrice: %t, CacheCreationRatio: %f, CacheCreation5mRatio: %f, CacheCreation1hRatio: %f, QuotaToPreConsume: %d, ImageRatio: %f, AudioRatio: %f, AudioCompletionRatio: %f", p.ModelPrice, p.ModelRatio, p.CompletionRatio, p.CacheRatio, p.GroupRatioInfo.GroupRatio, p.UsePrice, p.CacheCreationRatio, p.CacheCreation5mRatio, p.CacheCreation1hRatio, p.QuotaToPreConsume, p.ImageRatio, p.AudioRatio, p.AudioCompletionRatio)
 }
@@ -0,0 +1,46 @@
+package types
+
+// ModelPriceTier 输入 token 数超过 Threshold 后适用的倍率，值为 0 的倍率沿用模型的基础倍率。
+// CacheRatio / CacheCreationRatio 与基础配置含义相同，为相对模型倍率的比例。
+type ModelPriceTier struct {
+	Threshold          int     `json:"threshold"`
+	ModelRatio         float64 `json:"model_ratio,omitempty"`
+	CompletionRatio    float64 `json:"completion_ratio,omitempty"`
+	CacheRatio         float64 `json:"cache_ratio,omitempty"`
+	CacheCreationRatio float64 `json:"cache_creation_ratio,omitempty"`
+}
+
+// MatchPriceTier 返回 promptTokens 适用的阶梯下标（超过阈值的最高一档），tiers 需按阈值升序；未超过任何阈值时返回 -1
+func MatchPriceTier(tiers []ModelPriceTier, promptTokens int) int {
+	matched := -1
+	for i, tier := range tiers {
+		if promptTokens > tier.Threshold {
+			matched = i
+		}
+	}
+	return matched
+}
+
+// ResolvePriceTier 返回 promptTokens 适用的阶梯下标与合并后的倍率，未命中阶梯时返回 -1 与 base
+func ResolvePriceTier(tiers []ModelPriceTier, base ModelPriceTier, promptTokens int) (int, ModelPriceTier) {
+	idx := MatchPriceTier(tiers, promptTokens)
+	if idx < 0 {
+		return idx, base
+	}
+	resolved := base
+	tier := tiers[idx]
+	resolved.Threshold = tier.Threshold
+	if tier.ModelRatio > 0 {
+		resolved.ModelRatio = tier.ModelRatio
+	}
+	if tier.CompletionRatio > 0 {
+		resolved.CompletionRatio = tier.CompletionRatio
+	}
+	if tier.CacheRatio > 0 {
+		resolved.CacheRatio = tier.CacheRatio
+	}
+	if tier.CacheCreationRatio > 0 {
+		resolved.CacheCreationRatio = tier.CacheCreationRatio
+	}
+	return idx, resolved
+}
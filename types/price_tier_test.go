@@ -0,0 +1,62 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePriceTier(t *testing.T) {
+	tiers := []ModelPriceTier{
+		{Threshold: 128000, ModelRatio: 2},
+		{Threshold: 200000, ModelRatio: 3, CompletionRatio: 4},
+	}
+	base := ModelPriceTier{ModelRatio: 1, CompletionRatio: 5, CacheRatio: 0.1}
+
+	idx, price := ResolvePriceTier(tiers, base, 128000)
+	require.Equal(t, -1, idx)
+	require.Equal(t, base, price)
+
+	idx, price = ResolvePriceTier(tiers, base, 128001)
+	require.Equal(t, 0, idx)
+	require.Equal(t, 2.0, price.ModelRatio)
+	require.Equal(t, 5.0, price.CompletionRatio)
+	require.Equal(t, 0.1, price.CacheRatio)
+
+	idx, price = ResolvePriceTier(tiers, base, 300000)
+	require.Equal(t, 1, idx)
+	require.Equal(t, 3.0, price.ModelRatio)
+	require.Equal(t, 4.0, price.CompletionRatio)
+}
+
+func TestPriceDataApplyPriceTier(t *testing.T) {
+	p := PriceData{
+		ModelRatio:           1.5,
+		CompletionRatio:      5,
+		CacheRatio:           0.1,
+		CacheCreationRatio:   1.25,
+		CacheCreation5mRatio: 1.25,
+		CacheCreation1hRatio: 2,
+		PriceTiers:           []ModelPriceTier{{Threshold: 200000, ModelRatio: 3, CompletionRatio: 3.75, CacheCreationRatio: 2.5}},
+		BasePrice:            ModelPriceTier{ModelRatio: 1.5, CompletionRatio: 5, CacheRatio: 0.1, CacheCreationRatio: 1.25},
+		PriceTier:            -1,
+	}
+
+	require.False(t, p.ApplyPriceTier(1000))
+	require.True(t, p.ApplyPriceTier(250000))
+	require.Equal(t, 0, p.PriceTier)
+	require.Equal(t, 3.0, p.ModelRatio)
+	require.Equal(t, 3.75, p.CompletionRatio)
+	require.Equal(t, 0.1, p.CacheRatio)
+	require.Equal(t, 2.5, p.CacheCreation5mRatio)
+	require.Equal(t, 4.0, p.CacheCreation1hRatio)
+
+	// 预扣按阶梯计费，实际用量回落到阈值以下时恢复基础价格
+	require.True(t, p.ApplyPriceTier(1000))
+	require.Equal(t, -1, p.PriceTier)
+	require.Equal(t, 1.5, p.ModelRatio)
+	require.Equal(t, 2.0, p.CacheCreation1hRatio)
+
+	fixed := PriceData{UsePrice: true, ModelPrice: 0.1, PriceTier: -1}
+	require.False(t, fixed.ApplyPriceTier(300000))
+}
@@ -327,6 +327,7 @@ const renderOperations = (
   setEditingToken,
   setShowEdit,
   manageToken,
+  openRotateToken,
   refresh,
   t,
 ) => {
@@ -415,6 +416,14 @@ const renderOperations = (
         {t('编辑')}
       </Button>
 
+      <Button
+        type='tertiary'
+        size='small'
+        onClick={() => openRotateToken(record)}
+      >
+        {t('轮换')}
+      </Button>
+
       <Button
         type='danger'
         size='small'
@@ -448,6 +457,7 @@ export const getTokensColumns = ({
   onOpenLink,
   setEditingToken,
   setShowEdit,
+  openRotateToken,
   refresh,
 }) => {
   return [
@@ -526,6 +536,7 @@ export const getTokensColumns = ({
           setEditingToken,
           setShowEdit,
           manageToken,
+          openRotateToken,
           refresh,
           t,
         ),
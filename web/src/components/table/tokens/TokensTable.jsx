@@ -47,6 +47,7 @@ const TokensTable = (tokensData) => {
     onOpenLink,
     setEditingToken,
     setShowEdit,
+    openRotateToken,
     refresh,
     t,
   } = tokensData;
@@ -64,6 +65,7 @@ const TokensTable = (tokensData) => {
       onOpenLink,
       setEditingToken,
       setShowEdit,
+      openRotateToken,
       refresh,
     });
   }, [
@@ -77,6 +79,7 @@ const TokensTable = (tokensData) => {
     onOpenLink,
     setEditingToken,
     setShowEdit,
+    openRotateToken,
     refresh,
   ]);
 
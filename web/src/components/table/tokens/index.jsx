@@ -39,6 +39,8 @@ import TokensFilters from './TokensFilters';
 import TokensDescription from './TokensDescription';
 import EditTokenModal from './modals/EditTokenModal';
 import CCSwitchModal from './modals/CCSwitchModal';
+import TokenKeyRevealModal from './modals/TokenKeyRevealModal';
+import RotateTokenKeyModal from './modals/RotateTokenKeyModal';
 import { useTokensData } from '../../../hooks/tokens/useTokensData';
 import { useIsMobile } from '../../../hooks/common/useIsMobile';
 import { createCardProPagination } from '../../../helpers/utils';
@@ -353,6 +355,15 @@ function TokensPage() {
     closeEdit,
     refresh,
 
+    // One-time key state
+    copyText,
+    revealedKeys,
+    showTokenKeys,
+    closeTokenKeys,
+    rotatingToken,
+    closeRotateToken,
+    handleTokenRotated,
+
     // Actions state
     selectedKeys,
     setEditingToken,
@@ -382,6 +393,24 @@ function TokensPage() {
         editingToken={editingToken}
         visiable={showEdit}
         handleClose={closeEdit}
+        onTokensCreated={(keys) => showTokenKeys(keys, t('令牌创建成功'))}
+      />
+
+      <TokenKeyRevealModal
+        visible={revealedKeys.visible}
+        title={revealedKeys.title}
+        keys={revealedKeys.keys}
+        onClose={closeTokenKeys}
+        copyText={copyText}
+        t={t}
+      />
+
+      <RotateTokenKeyModal
+        visible={!!rotatingToken}
+        token={rotatingToken}
+        onCancel={closeRotateToken}
+        onRotated={handleTokenRotated}
+        t={t}
       />
 
       <CCSwitchModal
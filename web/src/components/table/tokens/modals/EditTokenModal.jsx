@@ -235,7 +235,8 @@ const EditTokenModal = (props) => {
       }
     } else {
       const count = parseInt(values.tokenCount, 10) || 1;
-      let successCount = 0;
+      // 密钥明文只在创建响应中返回一次
+      const createdKeys = [];
       for (let i = 0; i < count; i++) {
         let { tokenCount: _tc, ...localInputs } = values;
         const baseName =
@@ -259,18 +260,22 @@ const EditTokenModal = (props) => {
         localInputs.model_limits = localInputs.model_limits.join(',');
         localInputs.model_limits_enabled = localInputs.model_limits.length > 0;
         let res = await API.post(`/api/token/`, localInputs);
-        const { success, message } = res.data;
+        const { success, message, data } = res.data;
         if (success) {
-          successCount++;
+          createdKeys.push({
+            id: data?.id,
+            name: localInputs.name,
+            key: data?.key,
+          });
         } else {
           showError(t(message));
           break;
         }
       }
-      if (successCount > 0) {
-        showSuccess(t('令牌创建成功，请在列表页面点击复制获取令牌！'));
+      if (createdKeys.length > 0) {
         props.refresh();
         props.handleClose();
+        props.onTokensCreated?.(createdKeys.filter((item) => item.key));
       }
     }
     setLoading(false);
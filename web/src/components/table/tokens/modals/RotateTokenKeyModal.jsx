@@ -0,0 +1,91 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+
+import React, { useEffect, useState } from 'react';
+import { Modal, Select, Typography } from '@douyinfe/semi-ui';
+import { showError } from '../../../../helpers';
+import { rotateTokenKey } from '../../../../helpers/token';
+
+const { Text } = Typography;
+
+const RotateTokenKeyModal = ({ visible, token, onCancel, onRotated, t }) => {
+  const [gracePeriod, setGracePeriod] = useState(0);
+  const [loading, setLoading] = useState(false);
+
+  useEffect(() => {
+    if (visible) {
+      setGracePeriod(0);
+    }
+  }, [visible]);
+
+  const gracePeriodOptions = [
+    { label: t('立即失效'), value: 0 },
+    { label: t('1 小时后失效'), value: 3600 },
+    { label: t('24 小时后失效'), value: 86400 },
+    { label: t('7 天后失效'), value: 604800 },
+  ];
+
+  const handleOk = async () => {
+    if (!token?.id) {
+      return;
+    }
+    setLoading(true);
+    try {
+      const result = await rotateTokenKey(token.id, gracePeriod);
+      onRotated(token, result);
+    } catch (error) {
+      showError(error.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <Modal
+      title={t('轮换密钥')}
+      visible={visible}
+      onCancel={onCancel}
+      onOk={handleOk}
+      okText={t('轮换')}
+      confirmLoading={loading}
+    >
+      <div className='flex flex-col gap-3'>
+        <Text>
+          {t('将为令牌「{{name}}」生成新密钥，新密钥只显示一次。', {
+            name: token?.name || '',
+          })}
+        </Text>
+        <div className='flex flex-col gap-1'>
+          <Text type='secondary' size='small'>
+            {t('旧密钥')}
+          </Text>
+          <Select
+            value={gracePeriod}
+            onChange={setGracePeriod}
+            optionList={gracePeriodOptions}
+            style={{ width: '100%' }}
+          />
+        </div>
+      </div>
+    </Modal>
+  );
+};
+
+export default RotateTokenKeyModal;
@@ -0,0 +1,93 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+
+import React from 'react';
+import { Modal, Button, Banner, Input, Typography } from '@douyinfe/semi-ui';
+import { IconCopy } from '@douyinfe/semi-icons';
+
+const { Text } = Typography;
+
+// 令牌密钥以摘要保存，创建与轮换后只展示这一次
+const TokenKeyRevealModal = ({ visible, onClose, title, keys, copyText, t }) => {
+  const items = keys || [];
+
+  const handleCopyAll = async () => {
+    const content = items
+      .map((item) =>
+        items.length > 1 ? `${item.name}    sk-${item.key}` : `sk-${item.key}`,
+      )
+      .join('\n');
+    await copyText(content);
+  };
+
+  return (
+    <Modal
+      title={title || t('令牌密钥')}
+      visible={visible}
+      onCancel={onClose}
+      maskClosable={false}
+      closeOnEsc={false}
+      footer={
+        <div className='flex justify-end gap-2'>
+          <Button type='tertiary' icon={<IconCopy />} onClick={handleCopyAll}>
+            {items.length > 1 ? t('复制全部') : t('复制')}
+          </Button>
+          <Button theme='solid' onClick={onClose}>
+            {t('我已保存')}
+          </Button>
+        </div>
+      }
+    >
+      <Banner
+        type='warning'
+        closeIcon={null}
+        className='mb-4'
+        description={t('密钥只显示这一次，关闭后将无法再次查看，请立即复制并妥善保存')}
+      />
+      <div className='flex flex-col gap-3'>
+        {items.map((item) => (
+          <div key={item.id || item.key}>
+            {items.length > 1 && (
+              <Text type='secondary' size='small'>
+                {item.name}
+              </Text>
+            )}
+            <Input
+              readOnly
+              value={`sk-${item.key}`}
+              suffix={
+                <Button
+                  theme='borderless'
+                  size='small'
+                  type='tertiary'
+                  icon={<IconCopy />}
+                  aria-label='copy token key'
+                  onClick={() => copyText(`sk-${item.key}`)}
+                />
+              }
+            />
+          </div>
+        ))}
+      </div>
+    </Modal>
+  );
+};
+
+export default TokenKeyRevealModal;
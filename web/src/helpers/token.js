@@ -21,6 +21,7 @@ import { API } from './api';
 
 /**
  * 按需获取单个令牌的真实 key
+ * 令牌密钥以摘要保存，仅创建与轮换时返回一次明文；无法查看时抛出的错误带有 notRevealable 标记
  * @param {number|string} tokenId
  * @returns {Promise<string>} 返回不带 sk- 前缀的真实 token key
  */
@@ -28,16 +29,37 @@ export async function fetchTokenKey(tokenId) {
   const response = await API.post(`/api/token/${tokenId}/key`);
   const { success, data, message } = response.data || {};
   if (!success || !data?.key) {
-    throw new Error(message || 'Failed to fetch token key');
+    const error = new Error(message || 'Failed to fetch token key');
+    error.notRevealable = data?.revealable === false;
+    throw error;
   }
   return data.key;
 }
 
+/**
+ * 轮换令牌密钥，新密钥明文只在本次响应中返回
+ * @param {number|string} tokenId
+ * @param {number} gracePeriod 旧密钥继续可用的秒数，0 表示立即失效
+ * @returns {Promise<{key: string, token: object, prev_key_expired_time: number}>}
+ */
+export async function rotateTokenKey(tokenId, gracePeriod = 0) {
+  const response = await API.post(`/api/token/${tokenId}/rotate`, {
+    grace_period: gracePeriod,
+  });
+  const { success, data, message } = response.data || {};
+  if (!success || !data?.key) {
+    throw new Error(message || 'Failed to rotate token key');
+  }
+  return data;
+}
+
 /**
  * 获取可用的 token keys
+ * 启用的令牌密钥均无法查看（仅创建时显示一次）时抛出带 notRevealable 标记的错误
  * @returns {Promise<string[]>} 返回 active 状态的不带 sk- 前缀的真实 token key 数组
  */
 export async function fetchTokenKeys() {
+  let keyResults;
   try {
     const response = await API.get('/api/token/?p=1&size=10');
     const { success, data } = response.data;
@@ -45,16 +67,25 @@ export async function fetchTokenKeys() {
 
     const tokenItems = Array.isArray(data) ? data : data.items || [];
     const activeTokens = tokenItems.filter((token) => token.status === 1);
-    const keyResults = await Promise.allSettled(
+    keyResults = await Promise.allSettled(
       activeTokens.map((token) => fetchTokenKey(token.id)),
     );
-    return keyResults
-      .filter((result) => result.status === 'fulfilled' && result.value)
-      .map((result) => result.value);
   } catch (error) {
     console.error('Error fetching token keys:', error);
     return [];
   }
+  const keys = keyResults
+    .filter((result) => result.status === 'fulfilled' && result.value)
+    .map((result) => result.value);
+  if (
+    keys.length === 0 &&
+    keyResults.some((result) => result.reason?.notRevealable)
+  ) {
+    const error = new Error('Token keys are only shown once at creation');
+    error.notRevealable = true;
+    throw error;
+  }
+  return keys;
 }
 
 /**
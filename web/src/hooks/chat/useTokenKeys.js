@@ -28,9 +28,17 @@ export function useTokenKeys(id) {
 
   useEffect(() => {
     const loadAllData = async () => {
-      const fetchedKeys = await fetchTokenKeys();
+      let fetchedKeys = [];
+      try {
+        fetchedKeys = await fetchTokenKeys();
+        if (fetchedKeys.length === 0) {
+          showError('当前没有可用的启用令牌，请确认是否有令牌处于启用状态！');
+        }
+      } catch (error) {
+        // 令牌密钥只在创建时显示一次，需轮换后才能在此使用
+        showError('令牌密钥仅在创建时显示一次，请在令牌页面轮换密钥后重试！');
+      }
       if (fetchedKeys.length === 0) {
-        showError('当前没有可用的启用令牌，请确认是否有令牌处于启用状态！');
         setTimeout(() => {
           window.location.href = '/console/token';
         }, 1500); // 延迟 1.5 秒后跳转
@@ -58,6 +58,13 @@ export const useTokensData = (openFluentNotification, openCCSwitchModal) => {
   const [resolvedTokenKeys, setResolvedTokenKeys] = useState({});
   const [loadingTokenKeys, setLoadingTokenKeys] = useState({});
   const keyRequestsRef = useRef({});
+  // 创建或轮换后一次性展示的明文密钥
+  const [revealedKeys, setRevealedKeys] = useState({
+    visible: false,
+    title: '',
+    keys: [],
+  });
+  const [rotatingToken, setRotatingToken] = useState(null);
 
   // Form state
   const [formApi, setFormApi] = useState(null);
@@ -127,6 +134,54 @@ export const useTokensData = (openFluentNotification, openCCSwitchModal) => {
     }
   };
 
+  const showTokenKeys = (keys, title) => {
+    setRevealedKeys({ visible: true, title, keys });
+  };
+
+  const closeTokenKeys = () => {
+    setRevealedKeys({ visible: false, title: '', keys: [] });
+  };
+
+  const openRotateToken = (record) => {
+    setRotatingToken(record);
+  };
+
+  const closeRotateToken = () => {
+    setRotatingToken(null);
+  };
+
+  const handleTokenRotated = async (token, result) => {
+    setRotatingToken(null);
+    // 旧密钥已失效，清除已获取的明文
+    setResolvedTokenKeys((prev) => {
+      const next = { ...prev };
+      delete next[token.id];
+      return next;
+    });
+    setShowKeys((prev) => ({ ...prev, [token.id]: false }));
+    showTokenKeys(
+      [{ id: token.id, name: token.name, key: result.key }],
+      t('密钥已轮换'),
+    );
+    await refresh();
+  };
+
+  // 密钥以摘要保存时无法再次查看，引导用户轮换
+  const promptRotateTokenKey = (tokenOrId, message) => {
+    const tokenId =
+      typeof tokenOrId === 'object' ? tokenOrId?.id : Number(tokenOrId);
+    const record =
+      typeof tokenOrId === 'object'
+        ? tokenOrId
+        : tokens.find((token) => token.id === tokenId) || { id: tokenId };
+    Modal.confirm({
+      title: t('无法查看令牌密钥'),
+      content: message,
+      okText: t('轮换密钥'),
+      onOk: () => openRotateToken(record),
+    });
+  };
+
   const fetchTokenKey = async (tokenOrId, options = {}) => {
     const { suppressError = false } = options;
     const tokenId =
@@ -158,8 +213,13 @@ export const useTokensData = (openFluentNotification, openCCSwitchModal) => {
         const normalizedError = new Error(
           error?.message || t('获取令牌密钥失败'),
         );
+        normalizedError.notRevealable = !!error?.notRevealable;
         if (!suppressError) {
-          showError(normalizedError.message);
+          if (normalizedError.notRevealable) {
+            promptRotateTokenKey(tokenOrId, normalizedError.message);
+          } else {
+            showError(normalizedError.message);
+          }
         }
         throw normalizedError;
       } finally {
@@ -451,6 +511,13 @@ export const useTokensData = (openFluentNotification, openCCSwitchModal) => {
     setShowKeys,
     resolvedTokenKeys,
     loadingTokenKeys,
+    revealedKeys,
+    showTokenKeys,
+    closeTokenKeys,
+    rotatingToken,
+    openRotateToken,
+    closeRotateToken,
+    handleTokenRotated,
 
     // Form state
     formApi,